@@ -7,8 +7,10 @@ import (
 	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/common"
 	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/file"
 	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
+	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/linkpreview"
 	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/message"
 	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/openapi"
+	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/organization"
 	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/qrcode"
 	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/report"
 	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/robot"
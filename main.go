@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/internal"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/elastic"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/module"
@@ -68,6 +69,8 @@ func main() {
 
 	if serverType == "api" || serverType == "" || serverType == "config" { // api服务启动
 		runAPI(ctx)
+	} else if serverType == "reindex" { // 重建消息搜索索引
+		runReindex(ctx)
 	}
 
 }
@@ -115,6 +118,15 @@ func runAPI(ctx *config.Context) {
 	}
 }
 
+// 根据消息表重建消息搜索索引（使用内置索引时需要先停止api服务，否则索引文件会被api服务覆盖）
+func runReindex(ctx *config.Context) {
+	total, err := elastic.NewService(ctx).Reindex()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("重建消息搜索索引完成，共处理%d条消息\n", total)
+}
+
 func printServerInfo(ctx *config.Context) {
 	infoStr := `
 [?25l[?7lLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLL
@@ -4,6 +4,7 @@ import (
 	"embed"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/app"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/elastic"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/register"
 )
@@ -22,4 +23,15 @@ func init() {
 			SQLDir: register.NewSQLFS(sqlFS),
 		}
 	})
+
+	// 注册消息搜索索引模块
+	register.AddModule(func(ctx interface{}) register.Module {
+
+		return register.Module{
+			Name: "elastic",
+			SetupAPI: func() register.APIRouter {
+				return elastic.NewService(ctx.(*config.Context))
+			},
+		}
+	})
 }
@@ -0,0 +1,213 @@
+package elastic
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+)
+
+// SearchBackend 消息搜索后端
+type SearchBackend interface {
+	// Index 新增或更新文档（部分文档失败时返回*BulkError）
+	Index(docs []*MessageDocument) error
+	// Delete 删除文档（文档不存在不算失败）
+	Delete(messageIDs []string) error
+	// Search 搜索
+	Search(req *SearchReq) (*SearchResult, error)
+	// Flush 持久化未保存的数据
+	Flush() error
+}
+
+// BulkError 批量操作中部分文档失败
+type BulkError struct {
+	Failed map[string]string // 失败的文档ID -> 失败原因
+}
+
+func (e *BulkError) Error() string {
+	return fmt.Sprintf("%d个文档索引失败", len(e.Failed))
+}
+
+// MessageDocument 消息文档
+type MessageDocument struct {
+	MessageID    string   `json:"message_id"`             // 消息唯一ID
+	MessageSeq   uint32   `json:"message_seq"`            // 消息序列号
+	ClientMsgNo  string   `json:"client_msg_no"`          // 客户端消息唯一编号
+	FromUID      string   `json:"from_uid"`               // 发送者uid
+	ChannelID    string   `json:"channel_id"`             // 频道ID（个人频道为fake频道ID）
+	ChannelType  uint8    `json:"channel_type"`           // 频道类型
+	Participants []string `json:"participants,omitempty"` // 个人频道的双方uid
+	ContentType  int      `json:"content_type"`           // 正文类型
+	Content      string   `json:"content"`                // 搜索正文（编辑过的消息为编辑后的正文）
+	Payload      string   `json:"payload"`                // 消息内容（json）
+	Timestamp    int64    `json:"timestamp"`              // 消息时间 时间戳（秒）
+}
+
+// SearchReq 搜索请求
+type SearchReq struct {
+	Keyword     string   // 关键字
+	UID         string   // 搜索者uid（未指定频道时搜索其参与的个人频道）
+	GroupNos    []string // 搜索者所在的群（未指定频道时搜索的群频道）
+	ChannelID   string   // 指定频道（个人频道为fake频道ID）
+	ChannelType uint8    // 指定频道类型
+	FromUID     string   // 指定发送者
+	ContentType int      // 指定正文类型
+	StartTime   int64    // 开始时间 时间戳（秒）
+	EndTime     int64    // 结束时间 时间戳（秒）
+	Page        int      // 页码（从1开始）
+	Limit       int      // 每页数量
+
+	ExcludeMessageIDs []string         // 搜索者删除的消息
+	ChannelOffsets    []*ChannelOffset // 搜索者的频道偏移（清空聊天记录之前的消息）
+}
+
+// ChannelOffset 频道偏移，消息序列号小于等于偏移的消息不可搜索
+type ChannelOffset struct {
+	ChannelID   string // 频道ID（个人频道为fake频道ID）
+	ChannelType uint8  // 频道类型
+	MessageSeq  uint32 // 偏移的消息序列号
+}
+
+func (r *SearchReq) offset() int {
+	return (r.Page - 1) * r.Limit
+}
+
+// 文档是否满足搜索条件（关键字除外）
+func (r *SearchReq) match(doc *MessageDocument) bool {
+	if r.ChannelID != "" {
+		if doc.ChannelID != r.ChannelID || doc.ChannelType != r.ChannelType {
+			return false
+		}
+	} else if !r.canAccess(doc) {
+		return false
+	}
+	if r.FromUID != "" && doc.FromUID != r.FromUID {
+		return false
+	}
+	if r.ContentType != 0 && doc.ContentType != r.ContentType {
+		return false
+	}
+	if r.StartTime > 0 && doc.Timestamp < r.StartTime {
+		return false
+	}
+	if r.EndTime > 0 && doc.Timestamp > r.EndTime {
+		return false
+	}
+	return true
+}
+
+// 搜索者不可见的消息（自己删除的和频道偏移之前的）
+type excludeFilter struct {
+	messageIDs map[string]struct{}
+	offsets    map[string]uint32 // 频道ID@频道类型 -> 偏移的消息序列号
+}
+
+func newExcludeFilter(req *SearchReq) *excludeFilter {
+	f := &excludeFilter{
+		messageIDs: make(map[string]struct{}, len(req.ExcludeMessageIDs)),
+		offsets:    make(map[string]uint32, len(req.ChannelOffsets)),
+	}
+	for _, messageID := range req.ExcludeMessageIDs {
+		f.messageIDs[messageID] = struct{}{}
+	}
+	for _, offset := range req.ChannelOffsets {
+		key := channelKey(offset.ChannelID, offset.ChannelType)
+		if offset.MessageSeq > f.offsets[key] {
+			f.offsets[key] = offset.MessageSeq
+		}
+	}
+	return f
+}
+
+func (f *excludeFilter) exclude(doc *MessageDocument) bool {
+	if _, ok := f.messageIDs[doc.MessageID]; ok {
+		return true
+	}
+	offset, ok := f.offsets[channelKey(doc.ChannelID, doc.ChannelType)]
+	return ok && doc.MessageSeq <= offset
+}
+
+func channelKey(channelID string, channelType uint8) string {
+	return fmt.Sprintf("%s@%d", channelID, channelType)
+}
+
+// 未指定频道时，只能搜索参与的个人频道和所在的群
+func (r *SearchReq) canAccess(doc *MessageDocument) bool {
+	if doc.ChannelType == common.ChannelTypePerson.Uint8() {
+		for _, participant := range doc.Participants {
+			if participant == r.UID {
+				return true
+			}
+		}
+		return false
+	}
+	if doc.ChannelType == common.ChannelTypeGroup.Uint8() {
+		for _, groupNo := range r.GroupNos {
+			if groupNo == doc.ChannelID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SearchResult 搜索结果
+type SearchResult struct {
+	Total    int64              // 总数量
+	Messages []*MessageDocument // 当前页的消息（按时间倒序）
+}
+
+// 根据配置创建搜索后端
+func newBackend(ctx *config.Context) (SearchBackend, error) {
+	backend := strings.TrimSpace(os.Getenv(BackendEnv))
+	if backend == "" {
+		backend = BackendEmbedded
+	}
+	switch backend {
+	case BackendElasticsearch:
+		return newElasticBackend(ctx.GetElasticsearch(), MessageIndex)
+	case BackendEmbedded:
+		return newEmbeddedBackend(filepath.Join(ctx.GetConfig().RootDir, EmbeddedIndexFile))
+	}
+	return nil, fmt.Errorf("不支持的搜索后端[%s]", backend)
+}
+
+// 消息是否可以被搜索，返回正文类型和搜索正文
+func getSearchContent(payload map[string]interface{}) (int, string, bool) {
+	if payload == nil {
+		return 0, "", false
+	}
+	contentTypeNumber, _ := payload["type"].(json.Number)
+	contentTypeInt64, _ := contentTypeNumber.Int64()
+	contentType := int(contentTypeInt64)
+	// 命令、系统消息等不可搜索
+	if contentType <= 0 || contentType == common.CMD.Int() || contentType == common.ContentError.Int() || contentType == common.SignalError.Int() || contentType >= common.FriendApply.Int() {
+		return contentType, "", false
+	}
+	// 仅部分成员可见的消息不可搜索
+	if visibles, ok := payload["visibles"].([]interface{}); ok && len(visibles) > 0 {
+		return contentType, "", false
+	}
+	texts := make([]string, 0)
+	for _, key := range []string{"content", "name", "title"} {
+		if text, ok := payload[key].(string); ok && strings.TrimSpace(text) != "" {
+			texts = append(texts, text)
+		}
+	}
+	if len(texts) == 0 {
+		return contentType, "", false
+	}
+	return contentType, strings.Join(texts, " "), true
+}
+
+// 个人频道的双方uid
+func getParticipants(channelID string, channelType uint8) []string {
+	if channelType != common.ChannelTypePerson.Uint8() || !common.IsFakeChannel(channelID) {
+		return nil
+	}
+	return strings.Split(channelID, "@")
+}
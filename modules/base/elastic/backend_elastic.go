@@ -0,0 +1,188 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/olivere/elastic"
+)
+
+const elasticDocType = "_doc"
+
+// 消息索引的mapping（正文使用内置的cjk分词器，无需安装中文分词插件）
+const elasticMessageMapping = `{
+	"mappings": {
+		"_doc": {
+			"properties": {
+				"message_id": {"type": "keyword"},
+				"message_seq": {"type": "long"},
+				"client_msg_no": {"type": "keyword"},
+				"from_uid": {"type": "keyword"},
+				"channel_id": {"type": "keyword"},
+				"channel_type": {"type": "integer"},
+				"participants": {"type": "keyword"},
+				"content_type": {"type": "integer"},
+				"content": {"type": "text", "analyzer": "cjk"},
+				"payload": {"type": "keyword", "index": false, "doc_values": false},
+				"timestamp": {"type": "long"}
+			}
+		}
+	}
+}`
+
+// elasticBackend Elasticsearch搜索后端
+type elasticBackend struct {
+	client *elastic.Client
+	index  string
+}
+
+func newElasticBackend(client *elastic.Client, index string) (*elasticBackend, error) {
+	b := &elasticBackend{
+		client: client,
+		index:  index,
+	}
+	if err := b.ensureIndex(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// 索引不存在时创建索引
+func (b *elasticBackend) ensureIndex() error {
+	ctx, cancel := context.WithTimeout(context.Background(), IndexTimeout)
+	defer cancel()
+	exists, err := b.client.IndexExists(b.index).Do(ctx)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	_, err = b.client.CreateIndex(b.index).BodyString(elasticMessageMapping).Do(ctx)
+	return err
+}
+
+func (b *elasticBackend) Index(docs []*MessageDocument) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	bulk := b.client.Bulk()
+	for _, doc := range docs {
+		bulk.Add(elastic.NewBulkIndexRequest().Index(b.index).Type(elasticDocType).Id(doc.MessageID).Doc(doc))
+	}
+	return b.doBulk(bulk)
+}
+
+func (b *elasticBackend) Delete(messageIDs []string) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+	bulk := b.client.Bulk()
+	for _, messageID := range messageIDs {
+		bulk.Add(elastic.NewBulkDeleteRequest().Index(b.index).Type(elasticDocType).Id(messageID))
+	}
+	return b.doBulk(bulk)
+}
+
+func (b *elasticBackend) doBulk(bulk *elastic.BulkService) error {
+	ctx, cancel := context.WithTimeout(context.Background(), IndexTimeout)
+	defer cancel()
+	resp, err := bulk.Do(ctx)
+	if err != nil {
+		return err
+	}
+	failed := map[string]string{}
+	for _, item := range resp.Failed() {
+		if item.Status == http.StatusNotFound { // 删除不存在的文档
+			continue
+		}
+		reason := "未知错误"
+		if item.Error != nil {
+			reason = item.Error.Reason
+		}
+		failed[item.Id] = reason
+	}
+	if len(failed) > 0 {
+		return &BulkError{Failed: failed}
+	}
+	return nil
+}
+
+func (b *elasticBackend) Search(req *SearchReq) (*SearchResult, error) {
+	query := elastic.NewBoolQuery().Must(elastic.NewMatchQuery("content", req.Keyword).Operator("and"))
+	if req.ChannelID != "" {
+		query.Filter(elastic.NewTermQuery("channel_id", req.ChannelID), elastic.NewTermQuery("channel_type", req.ChannelType))
+	} else {
+		access := elastic.NewBoolQuery().MinimumNumberShouldMatch(1)
+		access.Should(elastic.NewBoolQuery().Filter(elastic.NewTermQuery("channel_type", common.ChannelTypePerson.Uint8()), elastic.NewTermQuery("participants", req.UID)))
+		if len(req.GroupNos) > 0 {
+			groupNos := make([]interface{}, 0, len(req.GroupNos))
+			for _, groupNo := range req.GroupNos {
+				groupNos = append(groupNos, groupNo)
+			}
+			access.Should(elastic.NewBoolQuery().Filter(elastic.NewTermQuery("channel_type", common.ChannelTypeGroup.Uint8()), elastic.NewTermsQuery("channel_id", groupNos...)))
+		}
+		query.Filter(access)
+	}
+	if req.FromUID != "" {
+		query.Filter(elastic.NewTermQuery("from_uid", req.FromUID))
+	}
+	if req.ContentType != 0 {
+		query.Filter(elastic.NewTermQuery("content_type", req.ContentType))
+	}
+	if req.StartTime > 0 || req.EndTime > 0 {
+		timeRange := elastic.NewRangeQuery("timestamp")
+		if req.StartTime > 0 {
+			timeRange.Gte(req.StartTime)
+		}
+		if req.EndTime > 0 {
+			timeRange.Lte(req.EndTime)
+		}
+		query.Filter(timeRange)
+	}
+	if len(req.ExcludeMessageIDs) > 0 {
+		messageIDs := make([]interface{}, 0, len(req.ExcludeMessageIDs))
+		for _, messageID := range req.ExcludeMessageIDs {
+			messageIDs = append(messageIDs, messageID)
+		}
+		query.MustNot(elastic.NewTermsQuery("message_id", messageIDs...))
+	}
+	for _, offset := range req.ChannelOffsets {
+		if offset.MessageSeq == 0 {
+			continue
+		}
+		query.MustNot(elastic.NewBoolQuery().Filter(elastic.NewTermQuery("channel_id", offset.ChannelID), elastic.NewTermQuery("channel_type", offset.ChannelType), elastic.NewRangeQuery("message_seq").Lte(offset.MessageSeq)))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), IndexTimeout)
+	defer cancel()
+	resp, err := b.client.Search(b.index).Type(elasticDocType).Query(query).Sort("timestamp", false).Sort("message_seq", false).From(req.offset()).Size(req.Limit).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &SearchResult{
+		Messages: make([]*MessageDocument, 0),
+	}
+	if resp.Hits == nil {
+		return result, nil
+	}
+	result.Total = resp.Hits.TotalHits
+	for _, hit := range resp.Hits.Hits {
+		if hit.Source == nil {
+			continue
+		}
+		var doc *MessageDocument
+		if err := json.Unmarshal(*hit.Source, &doc); err != nil {
+			return nil, err
+		}
+		result.Messages = append(result.Messages, doc)
+	}
+	return result, nil
+}
+
+// Flush Elasticsearch自行持久化
+func (b *elasticBackend) Flush() error {
+	return nil
+}
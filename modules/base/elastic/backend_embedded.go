@@ -0,0 +1,207 @@
+package elastic
+
+import (
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// embeddedBackend 内置搜索后端（内存倒排索引，定时落盘到本地文件，适合小规模单实例部署）
+type embeddedBackend struct {
+	mu       sync.RWMutex
+	path     string
+	docs     map[string]*MessageDocument
+	postings map[string]map[string]struct{} // 词 -> 消息ID集合
+	dirty    bool                           // 是否有未落盘的修改
+}
+
+func newEmbeddedBackend(path string) (*embeddedBackend, error) {
+	b := &embeddedBackend{
+		path:     path,
+		docs:     map[string]*MessageDocument{},
+		postings: map[string]map[string]struct{}{},
+	}
+	if err := b.load(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// 从索引文件加载文档并重建倒排索引
+func (b *embeddedBackend) load() error {
+	f, err := os.Open(b.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	var docs []*MessageDocument
+	if err := gob.NewDecoder(f).Decode(&docs); err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		b.addDoc(doc)
+	}
+	return nil
+}
+
+func (b *embeddedBackend) addDoc(doc *MessageDocument) {
+	if old := b.docs[doc.MessageID]; old != nil {
+		b.removeDoc(old)
+	}
+	b.docs[doc.MessageID] = doc
+	for _, token := range tokenize(doc.Content) {
+		ids := b.postings[token]
+		if ids == nil {
+			ids = map[string]struct{}{}
+			b.postings[token] = ids
+		}
+		ids[doc.MessageID] = struct{}{}
+	}
+}
+
+func (b *embeddedBackend) removeDoc(doc *MessageDocument) {
+	for _, token := range tokenize(doc.Content) {
+		ids := b.postings[token]
+		delete(ids, doc.MessageID)
+		if len(ids) == 0 {
+			delete(b.postings, token)
+		}
+	}
+	delete(b.docs, doc.MessageID)
+}
+
+func (b *embeddedBackend) Index(docs []*MessageDocument) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, doc := range docs {
+		docCopy := *doc
+		b.addDoc(&docCopy)
+	}
+	if len(docs) > 0 {
+		b.dirty = true
+	}
+	return nil
+}
+
+func (b *embeddedBackend) Delete(messageIDs []string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, messageID := range messageIDs {
+		if doc := b.docs[messageID]; doc != nil {
+			b.removeDoc(doc)
+			b.dirty = true
+		}
+	}
+	return nil
+}
+
+func (b *embeddedBackend) Search(req *SearchReq) (*SearchResult, error) {
+	result := &SearchResult{
+		Messages: make([]*MessageDocument, 0),
+	}
+	tokens := tokenizeQuery(req.Keyword)
+	if len(tokens) == 0 {
+		return result, nil
+	}
+
+	b.mu.RLock()
+	// 从最短的倒排列表开始求交集
+	lists := make([]map[string]struct{}, 0, len(tokens))
+	for _, token := range tokens {
+		ids := b.postings[token]
+		if len(ids) == 0 {
+			b.mu.RUnlock()
+			return result, nil
+		}
+		lists = append(lists, ids)
+	}
+	sort.Slice(lists, func(i, j int) bool {
+		return len(lists[i]) < len(lists[j])
+	})
+	exclude := newExcludeFilter(req)
+	matches := make([]*MessageDocument, 0)
+	for messageID := range lists[0] {
+		hitAll := true
+		for _, ids := range lists[1:] {
+			if _, ok := ids[messageID]; !ok {
+				hitAll = false
+				break
+			}
+		}
+		if !hitAll {
+			continue
+		}
+		doc := b.docs[messageID]
+		if doc == nil || !req.match(doc) || exclude.exclude(doc) || !containsKeyword(doc.Content, req.Keyword) {
+			continue
+		}
+		docCopy := *doc
+		matches = append(matches, &docCopy)
+	}
+	b.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Timestamp != matches[j].Timestamp {
+			return matches[i].Timestamp > matches[j].Timestamp
+		}
+		return matches[i].MessageSeq > matches[j].MessageSeq
+	})
+	result.Total = int64(len(matches))
+	start := req.offset()
+	if start >= len(matches) {
+		return result, nil
+	}
+	end := start + req.Limit
+	if end > len(matches) {
+		end = len(matches)
+	}
+	result.Messages = matches[start:end]
+	return result, nil
+}
+
+// Flush 有修改时将所有文档写入索引文件（先写临时文件再替换，避免写入中断损坏索引）
+func (b *embeddedBackend) Flush() error {
+	b.mu.Lock()
+	if !b.dirty {
+		b.mu.Unlock()
+		return nil
+	}
+	docs := make([]*MessageDocument, 0, len(b.docs))
+	for _, doc := range b.docs {
+		docs = append(docs, doc)
+	}
+	b.dirty = false
+	b.mu.Unlock()
+
+	err := b.save(docs)
+	if err != nil {
+		b.mu.Lock()
+		b.dirty = true
+		b.mu.Unlock()
+	}
+	return err
+}
+
+func (b *embeddedBackend) save(docs []*MessageDocument) error {
+	if err := os.MkdirAll(filepath.Dir(b.path), 0755); err != nil {
+		return err
+	}
+	tmpPath := b.path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(f).Encode(docs); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, b.path)
+}
@@ -0,0 +1,155 @@
+package elastic
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDoc(messageID string, channelID string, channelType uint8, content string, timestamp int64) *MessageDocument {
+	return &MessageDocument{
+		MessageID:    messageID,
+		FromUID:      "u1",
+		ChannelID:    channelID,
+		ChannelType:  channelType,
+		Participants: getParticipants(channelID, channelType),
+		ContentType:  common.Text.Int(),
+		Content:      content,
+		Timestamp:    timestamp,
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tokens := tokenize("唐僧叨叨 Hello")
+	sort.Strings(tokens)
+	assert.Equal(t, []string{"hello", "僧", "僧叨", "叨", "叨叨", "唐", "唐僧"}, tokens)
+
+	tokens = tokenizeQuery("唐僧叨 WORLD")
+	sort.Strings(tokens)
+	assert.Equal(t, []string{"world", "僧叨", "唐僧"}, tokens)
+
+	assert.True(t, containsKeyword("欢迎使用唐僧叨叨", "唐僧"))
+	assert.False(t, containsKeyword("唐朝的僧人", "唐僧"))
+}
+
+func TestGetSearchContent(t *testing.T) {
+	_, content, ok := getSearchContent(map[string]interface{}{"type": json.Number("1"), "content": "你好"})
+	assert.True(t, ok)
+	assert.Equal(t, "你好", content)
+
+	_, _, ok = getSearchContent(map[string]interface{}{"type": json.Number("1001"), "content": "群创建"})
+	assert.False(t, ok)
+
+	_, _, ok = getSearchContent(map[string]interface{}{"type": json.Number("1"), "content": "仅部分可见", "visibles": []interface{}{"u1"}})
+	assert.False(t, ok)
+}
+
+func TestEmbeddedBackend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "message.idx")
+	b, err := newEmbeddedBackend(path)
+	assert.NoError(t, err)
+
+	personChannelID := common.GetFakeChannelIDWith("u1", "u2")
+	err = b.Index([]*MessageDocument{
+		newTestDoc("1", personChannelID, common.ChannelTypePerson.Uint8(), "明天一起去北京", 100),
+		newTestDoc("2", "g1", common.ChannelTypeGroup.Uint8(), "北京的天气怎么样", 200),
+		newTestDoc("3", "g2", common.ChannelTypeGroup.Uint8(), "北京欢迎你", 300),
+		newTestDoc("4", "g1", common.ChannelTypeGroup.Uint8(), "上海见 Hello World", 400),
+	})
+	assert.NoError(t, err)
+
+	// 只能搜索到参与的单聊和所在的群
+	result, err := b.Search(&SearchReq{Keyword: "北京", UID: "u1", GroupNos: []string{"g1"}, Page: 1, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), result.Total)
+	assert.Equal(t, "2", result.Messages[0].MessageID)
+	assert.Equal(t, "1", result.Messages[1].MessageID)
+
+	result, err = b.Search(&SearchReq{Keyword: "北京", UID: "u3", GroupNos: []string{"g2"}, Page: 1, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result.Total)
+	assert.Equal(t, "3", result.Messages[0].MessageID)
+
+	// 指定频道和分页
+	result, err = b.Search(&SearchReq{Keyword: "北京", ChannelID: "g1", ChannelType: common.ChannelTypeGroup.Uint8(), Page: 2, Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result.Total)
+	assert.Len(t, result.Messages, 0)
+
+	result, err = b.Search(&SearchReq{Keyword: "hello", UID: "u1", GroupNos: []string{"g1"}, Page: 1, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result.Total)
+
+	// 编辑后旧的正文搜索不到
+	err = b.Index([]*MessageDocument{newTestDoc("2", "g1", common.ChannelTypeGroup.Uint8(), "今天下雨了", 200)})
+	assert.NoError(t, err)
+	result, err = b.Search(&SearchReq{Keyword: "北京", UID: "u1", GroupNos: []string{"g1"}, Page: 1, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result.Total)
+	assert.Equal(t, "1", result.Messages[0].MessageID)
+
+	// 删除
+	err = b.Delete([]string{"1", "not_exist"})
+	assert.NoError(t, err)
+	result, err = b.Search(&SearchReq{Keyword: "北京", UID: "u1", GroupNos: []string{"g1"}, Page: 1, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), result.Total)
+
+	// 落盘后重新加载
+	err = b.Flush()
+	assert.NoError(t, err)
+	b2, err := newEmbeddedBackend(path)
+	assert.NoError(t, err)
+	result, err = b2.Search(&SearchReq{Keyword: "下雨", UID: "u1", GroupNos: []string{"g1"}, Page: 1, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result.Total)
+	assert.Equal(t, "2", result.Messages[0].MessageID)
+	assert.Len(t, b2.docs, 3)
+}
+
+func TestEmbeddedBackendExclude(t *testing.T) {
+	b, err := newEmbeddedBackend(filepath.Join(t.TempDir(), "message.idx"))
+	assert.NoError(t, err)
+
+	docs := []*MessageDocument{
+		newTestDoc("1", "g1", common.ChannelTypeGroup.Uint8(), "北京一", 100),
+		newTestDoc("2", "g1", common.ChannelTypeGroup.Uint8(), "北京二", 200),
+		newTestDoc("3", "g1", common.ChannelTypeGroup.Uint8(), "北京三", 300),
+		newTestDoc("4", "g1", common.ChannelTypeGroup.Uint8(), "北京四", 400),
+		newTestDoc("5", "t1", common.ChannelTypeCommunityTopic.Uint8(), "北京五", 500),
+	}
+	for i, doc := range docs {
+		doc.MessageSeq = uint32(i + 1)
+	}
+	err = b.Index(docs)
+	assert.NoError(t, err)
+
+	// 删除的消息和频道偏移之前的消息在分页前过滤，分页和总数都准确
+	req := &SearchReq{
+		Keyword:           "北京",
+		ChannelID:         "g1",
+		ChannelType:       common.ChannelTypeGroup.Uint8(),
+		Page:              1,
+		Limit:             2,
+		ExcludeMessageIDs: []string{"3"},
+		ChannelOffsets: []*ChannelOffset{
+			{ChannelID: "g1", ChannelType: common.ChannelTypeGroup.Uint8(), MessageSeq: 1},
+		},
+	}
+	result, err := b.Search(req)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), result.Total)
+	assert.Len(t, result.Messages, 2)
+	assert.Equal(t, "4", result.Messages[0].MessageID)
+	assert.Equal(t, "2", result.Messages[1].MessageID)
+
+	// 话题频道
+	result, err = b.Search(&SearchReq{Keyword: "北京", ChannelID: "t1", ChannelType: common.ChannelTypeCommunityTopic.Uint8(), Page: 1, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result.Total)
+	assert.Equal(t, "5", result.Messages[0].MessageID)
+}
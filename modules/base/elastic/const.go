@@ -0,0 +1,59 @@
+package elastic
+
+import "time"
+
+const (
+	// BackendEnv 搜索后端的环境变量 elasticsearch：Elasticsearch embedded：内置索引（适合小规模部署，只能单实例运行）
+	BackendEnv = "TS_SEARCH_BACKEND"
+	// BackendElasticsearch Elasticsearch
+	BackendElasticsearch = "elasticsearch"
+	// BackendEmbedded 内置索引
+	BackendEmbedded = "embedded"
+
+	// MessageIndex 消息索引名
+	MessageIndex = "message"
+	// EmbeddedIndexFile 内置索引文件（位于数据根目录下）
+	EmbeddedIndexFile = "search/message.idx"
+	// EmbeddedSaveInterval 内置索引落盘间隔
+	EmbeddedSaveInterval = time.Second * 5
+
+	// IndexQueueSize 待索引消息队列大小（队列满时记录为索引失败，由重试任务补偿）
+	IndexQueueSize = 4096
+	// IndexBatchSize 每批索引数量
+	IndexBatchSize = 200
+	// IndexBatchInterval 攒批最长等待时间
+	IndexBatchInterval = time.Second
+	// IndexTimeout 单次索引请求超时时间
+	IndexTimeout = time.Second * 10
+
+	// RetryInterval 索引失败重试间隔
+	RetryInterval = time.Second * 30
+	// RetryBatchSize 每次重试数量
+	RetryBatchSize = 100
+	// RetryMaxCount 最大重试次数（超过后不再重试，需通过reindex修复）
+	RetryMaxCount = 10
+
+	// ReindexBatchSize 重建索引时每批读取的消息数量
+	ReindexBatchSize = 500
+
+	// SearchMaxLimit 每页最大搜索数量
+	SearchMaxLimit = 100
+)
+
+// 索引操作
+const (
+	// ActionIndex 新增或更新文档
+	ActionIndex = "index"
+	// ActionDelete 删除文档
+	ActionDelete = "delete"
+)
+
+// 索引失败记录状态
+const (
+	// IndexerErrorStatusPending 待重试
+	IndexerErrorStatusPending = 0
+	// IndexerErrorStatusDone 重试成功
+	IndexerErrorStatusDone = 1
+	// IndexerErrorStatusGiveUp 超过最大重试次数
+	IndexerErrorStatusGiveUp = 2
+)
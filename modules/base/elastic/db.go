@@ -1,6 +1,10 @@
 package elastic
 
 import (
+	"fmt"
+	"hash/crc32"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
 	"github.com/gocraft/dbr/v2"
@@ -9,12 +13,14 @@ import (
 // DB DB
 type DB struct {
 	session *dbr.Session
+	ctx     *config.Context
 }
 
 // NewDB NewDB
-func NewDB(session *dbr.Session) *DB {
+func NewDB(ctx *config.Context) *DB {
 	return &DB{
-		session: session,
+		session: ctx.DB(),
+		ctx:     ctx,
 	}
 }
 
@@ -24,6 +30,67 @@ func (d *DB) Insert(model *IndexerErrorModel) error {
 	return err
 }
 
+// 查询待重试的索引失败记录
+func (d *DB) queryPendingErrors(limit uint64) ([]*IndexerErrorModel, error) {
+	var models []*IndexerErrorModel
+	_, err := d.session.Select("*").From("indexer_error").Where("status=?", IndexerErrorStatusPending).OrderAsc("id").Limit(limit).Load(&models)
+	return models, err
+}
+
+func (d *DB) updateErrorStatus(id int64, status int) error {
+	_, err := d.session.Update("indexer_error").Set("status", status).Where("id=?", id).Exec()
+	return err
+}
+
+// 重试失败，记录失败原因并增加重试次数（超过最大次数的放弃重试）
+func (d *DB) updateErrorRetry(id int64, errMsg string) error {
+	_, err := d.session.UpdateBySql("update indexer_error set error=?,retry_count=retry_count+1,status=IF(retry_count>=?,?,status) where id=?", errMsg, RetryMaxCount, IndexerErrorStatusGiveUp, id).Exec()
+	return err
+}
+
+// 查询消息扩展（撤回、删除、编辑状态）
+func (d *DB) queryMessageExtrasWithMessageIDs(messageIDs []string) ([]*messageExtraModel, error) {
+	if len(messageIDs) == 0 {
+		return nil, nil
+	}
+	var models []*messageExtraModel
+	_, err := d.session.Select("message_id,channel_id,channel_type,`revoke`,is_deleted,IFNULL(content_edit,'') content_edit").From("message_extra").Where("message_id in ?", messageIDs).Load(&models)
+	return models, err
+}
+
+func (d *DB) queryMessagesWithMessageIDs(channelID string, messageIDs []string) ([]*messageModel, error) {
+	var models []*messageModel
+	_, err := d.session.Select("*").From(d.getMessageTable(channelID)).Where("message_id in ?", messageIDs).Load(&models)
+	return models, err
+}
+
+// 按自增ID分页查询消息表（重建索引使用）
+func (d *DB) queryMessagesWithTable(table string, startID int64, limit uint64) ([]*messageModel, error) {
+	var models []*messageModel
+	_, err := d.session.Select("*").From(table).Where("id>?", startID).OrderAsc("id").Limit(limit).Load(&models)
+	return models, err
+}
+
+// 所有消息表
+func (d *DB) getMessageTables() []string {
+	count := d.ctx.GetConfig().TablePartitionConfig.MessageTableCount
+	tables := make([]string, 0, count)
+	tables = append(tables, "message")
+	for i := 1; i < count; i++ {
+		tables = append(tables, fmt.Sprintf("message%d", i))
+	}
+	return tables
+}
+
+// 通过频道ID获取表
+func (d *DB) getMessageTable(channelID string) string {
+	tableIndex := crc32.ChecksumIEEE([]byte(channelID)) % uint32(d.ctx.GetConfig().TablePartitionConfig.MessageTableCount)
+	if tableIndex == 0 {
+		return "message"
+	}
+	return fmt.Sprintf("message%d", tableIndex)
+}
+
 // IndexerErrorModel IndexerErrorModel
 type IndexerErrorModel struct {
 	Index      string
@@ -31,5 +98,29 @@ type IndexerErrorModel struct {
 	DocumentID string
 	Body       string
 	Error      string
+	RetryCount int
+	Status     int
+	db.BaseModel
+}
+
+type messageModel struct {
+	MessageID   string
+	MessageSeq  uint32
+	ClientMsgNo string
+	FromUID     string
+	ChannelID   string
+	ChannelType uint8
+	Timestamp   int64
+	Payload     string
+	IsDeleted   int
 	db.BaseModel
 }
+
+type messageExtraModel struct {
+	MessageID   string
+	ChannelID   string
+	ChannelType uint8
+	Revoke      int
+	IsDeleted   int
+	ContentEdit string
+}
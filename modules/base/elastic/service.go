@@ -1,61 +1,484 @@
 package elastic
 
 import (
-	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
-	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/message"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
-	"github.com/olivere/elastic"
+	"go.uber.org/zap"
+)
+
+var (
+	serviceOnce sync.Once
+	service     *Service
 )
 
-// Service Service
+// Service 消息搜索服务（消息通知、编辑、撤回、删除时同步更新索引）
 type Service struct {
 	ctx *config.Context
 	log.Log
-	messageDB *message.DB
-	db        *DB
-	groupDB   *group.DB
+	db *DB
+
+	backendLock sync.Mutex
+	backend     SearchBackend
+
+	indexC chan *MessageDocument // 待索引的消息
 }
 
-// NewService NewService
+// NewService 获取消息搜索服务（进程内唯一，内置索引只能加载一份）
 func NewService(ctx *config.Context) *Service {
-
-	return &Service{
-		ctx:       ctx,
-		Log:       log.NewTLog("Eastic"),
-		messageDB: message.NewDB(ctx),
-		db:        NewDB(ctx.DB()),
-		groupDB:   group.NewDB(ctx),
-	}
+	serviceOnce.Do(func() {
+		service = &Service{
+			ctx:    ctx,
+			Log:    log.NewTLog("Elastic"),
+			db:     NewDB(ctx),
+			indexC: make(chan *MessageDocument, IndexQueueSize),
+		}
+	})
+	return service
 }
 
 // Route Route
 func (s *Service) Route(r *wkhttp.WKHttp) {
+	if !s.On() {
+		return
+	}
+	s.ctx.AddMessagesListener(s.IndexMessages)                                          // 新消息
+	s.ctx.AddEventListener(event.EventUpdateSearchMessage, s.handleUpdateSearchMessage) // 编辑、撤回、后台删除
+
+	go s.indexLoop()
+	go s.retryLoop()
+	go s.flushLoop()
+}
+
+// On 是否开启消息搜索
+func (s *Service) On() bool {
+	return s.ctx.GetConfig().ZincSearch.SearchOn
+}
+
+// 获取搜索后端（创建失败时下次使用再重新创建）
+func (s *Service) getBackend() (SearchBackend, error) {
+	s.backendLock.Lock()
+	defer s.backendLock.Unlock()
+	if s.backend == nil {
+		backend, err := newBackend(s.ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.backend = backend
+	}
+	return s.backend, nil
+}
+
+// IndexMessages 新消息加入索引队列（队列已满时记录为索引失败，由重试任务补偿）
+func (s *Service) IndexMessages(messages []*config.MessageResp) {
+	if !s.On() {
+		return
+	}
+	for _, message := range messages {
+		doc := newDocumentWithMessage(message)
+		if doc == nil {
+			continue
+		}
+		select {
+		case s.indexC <- doc:
+		default:
+			s.Warn("索引队列已满！", zap.String("messageID", doc.MessageID))
+			s.recordErrors(ActionIndex, []*MessageDocument{doc}, map[string]string{doc.MessageID: "索引队列已满"})
+		}
+	}
+}
+
+// SyncMessages 按消息当前状态更新索引（编辑后更新正文，撤回或删除后移除）
+func (s *Service) SyncMessages(messageIDs []string) error {
+	if !s.On() || len(messageIDs) == 0 {
+		return nil
+	}
+	extras, err := s.db.queryMessageExtrasWithMessageIDs(messageIDs)
+	if err != nil {
+		return err
+	}
+	channelMessageIDs := map[string][]string{}
+	for _, extra := range extras {
+		channelMessageIDs[extra.ChannelID] = append(channelMessageIDs[extra.ChannelID], extra.MessageID)
+	}
+	docs := make([]*MessageDocument, 0, len(extras))
+	for channelID, ids := range channelMessageIDs {
+		models, err := s.db.queryMessagesWithMessageIDs(channelID, ids)
+		if err != nil {
+			return err
+		}
+		for _, model := range models {
+			if doc := newDocumentWithModel(model); doc != nil {
+				docs = append(docs, doc)
+			}
+		}
+	}
+	s.indexDocs(docs)
+	return nil
+}
+
+// DeleteMessages 从索引中移除消息
+func (s *Service) DeleteMessages(messageIDs []string) {
+	if !s.On() || len(messageIDs) == 0 {
+		return
+	}
+	s.recordErrors(ActionDelete, nil, s.deleteFromBackend(messageIDs))
+}
+
+// Search 搜索消息
+func (s *Service) Search(req *SearchReq) (*SearchResult, error) {
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.Limit <= 0 || req.Limit > SearchMaxLimit {
+		req.Limit = SearchMaxLimit
+	}
+	backend, err := s.getBackend()
+	if err != nil {
+		return nil, err
+	}
+	return backend.Search(req)
+}
+
+// Reindex 根据消息表重建索引（已存在的文档会被覆盖，撤回或删除的消息会被移除），返回处理的消息数量
+func (s *Service) Reindex() (int, error) {
+	backend, err := s.getBackend()
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, table := range s.db.getMessageTables() {
+		var startID int64
+		for {
+			models, err := s.db.queryMessagesWithTable(table, startID, ReindexBatchSize)
+			if err != nil {
+				return total, err
+			}
+			if len(models) == 0 {
+				break
+			}
+			docs := make([]*MessageDocument, 0, len(models))
+			deleteIDs := make([]string, 0)
+			for _, model := range models {
+				doc := newDocumentWithModel(model)
+				if doc != nil {
+					docs = append(docs, doc)
+				} else if model.IsDeleted == 1 {
+					deleteIDs = append(deleteIDs, model.MessageID)
+				}
+			}
+			s.indexDocs(docs)
+			s.recordErrors(ActionDelete, nil, s.deleteFromBackend(deleteIDs))
+			total += len(models)
+			startID = models[len(models)-1].Id
+			s.Info("重建索引中...", zap.String("table", table), zap.Int64("id", startID), zap.Int("total", total))
+		}
+	}
+	return total, backend.Flush()
+}
+
+// 处理修改搜索消息事件
+func (s *Service) handleUpdateSearchMessage(data []byte, commit config.EventCommit) {
+	var req *config.UpdateSearchMessageReq
+	err := util.ReadJsonByByte(data, &req)
+	if err != nil {
+		s.Error("解析修改搜索消息事件失败！", zap.Error(err), zap.String("data", string(data)))
+		commit(err)
+		return
+	}
+	err = s.SyncMessages(req.MessageIDs)
+	if err != nil {
+		s.Error("更新消息索引失败！", zap.Error(err), zap.Strings("messageIDs", req.MessageIDs))
+	}
+	commit(err)
+}
+
+// 攒批索引新消息
+func (s *Service) indexLoop() {
+	ticker := time.NewTicker(IndexBatchInterval)
+	defer ticker.Stop()
+	docs := make([]*MessageDocument, 0, IndexBatchSize)
+	for {
+		select {
+		case doc := <-s.indexC:
+			docs = append(docs, doc)
+			if len(docs) < IndexBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(docs) == 0 {
+				continue
+			}
+		}
+		s.indexDocs(docs)
+		docs = make([]*MessageDocument, 0, IndexBatchSize)
+	}
+}
 
+// 定时重试索引失败的文档
+func (s *Service) retryLoop() {
+	ticker := time.NewTicker(RetryInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		models, err := s.db.queryPendingErrors(RetryBatchSize)
+		if err != nil {
+			s.Error("查询索引失败记录失败！", zap.Error(err))
+			continue
+		}
+		for _, model := range models {
+			s.retry(model)
+		}
+	}
+}
+
+// 定时持久化索引
+func (s *Service) flushLoop() {
+	ticker := time.NewTicker(EmbeddedSaveInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.backendLock.Lock()
+		backend := s.backend
+		s.backendLock.Unlock()
+		if backend == nil {
+			continue
+		}
+		if err := backend.Flush(); err != nil {
+			s.Error("持久化索引失败！", zap.Error(err))
+		}
+	}
+}
+
+func (s *Service) retry(model *IndexerErrorModel) {
+	var failed map[string]string
+	switch model.Action {
+	case ActionIndex:
+		var doc *MessageDocument
+		if err := util.ReadJsonByByte([]byte(model.Body), &doc); err != nil || doc == nil {
+			s.Warn("索引失败记录的文档格式有误，放弃重试！", zap.Error(err), zap.Int64("id", model.Id))
+			if err = s.db.updateErrorStatus(model.Id, IndexerErrorStatusGiveUp); err != nil {
+				s.Error("更新索引失败记录失败！", zap.Error(err), zap.Int64("id", model.Id))
+			}
+			return
+		}
+		docs, deleteIDs, err := s.applyMessageExtra([]*MessageDocument{doc})
+		if err != nil {
+			failed = map[string]string{model.DocumentID: err.Error()}
+			break
+		}
+		failed = s.indexToBackend(docs)
+		for messageID, reason := range s.deleteFromBackend(deleteIDs) {
+			failed[messageID] = reason
+		}
+	case ActionDelete:
+		failed = s.deleteFromBackend([]string{model.DocumentID})
+	default:
+		failed = map[string]string{model.DocumentID: fmt.Sprintf("不支持的索引操作[%s]", model.Action)}
+	}
+	var err error
+	if reason, ok := failed[model.DocumentID]; ok {
+		err = s.db.updateErrorRetry(model.Id, reason)
+	} else {
+		err = s.db.updateErrorStatus(model.Id, IndexerErrorStatusDone)
+	}
+	if err != nil {
+		s.Error("更新索引失败记录失败！", zap.Error(err), zap.Int64("id", model.Id))
+	}
+}
+
+// 根据消息扩展更新文档后写入索引，失败的记录下来等待重试
+func (s *Service) indexDocs(docs []*MessageDocument) {
+	if len(docs) == 0 {
+		return
+	}
+	indexDocs, deleteIDs, err := s.applyMessageExtra(docs)
+	if err != nil {
+		s.Error("查询消息扩展失败！", zap.Error(err))
+		failed := map[string]string{}
+		for _, doc := range docs {
+			failed[doc.MessageID] = err.Error()
+		}
+		s.recordErrors(ActionIndex, docs, failed)
+		return
+	}
+	s.recordErrors(ActionIndex, indexDocs, s.indexToBackend(indexDocs))
+	s.recordErrors(ActionDelete, nil, s.deleteFromBackend(deleteIDs))
+}
+
+// 根据消息扩展处理文档：撤回或删除的消息需要从索引移除，编辑过的消息使用编辑后的正文
+func (s *Service) applyMessageExtra(docs []*MessageDocument) ([]*MessageDocument, []string, error) {
+	messageIDs := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		messageIDs = append(messageIDs, doc.MessageID)
+	}
+	extras, err := s.db.queryMessageExtrasWithMessageIDs(messageIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	extraMap := map[string]*messageExtraModel{}
+	for _, extra := range extras {
+		extraMap[extra.MessageID] = extra
+	}
+	indexDocs := make([]*MessageDocument, 0, len(docs))
+	deleteIDs := make([]string, 0)
+	for _, doc := range docs {
+		extra := extraMap[doc.MessageID]
+		if extra == nil {
+			indexDocs = append(indexDocs, doc)
+			continue
+		}
+		if extra.Revoke == 1 || extra.IsDeleted == 1 {
+			deleteIDs = append(deleteIDs, doc.MessageID)
+			continue
+		}
+		if extra.ContentEdit != "" {
+			var payload map[string]interface{}
+			_ = util.ReadJsonByByte([]byte(extra.ContentEdit), &payload)
+			contentType, content, ok := getSearchContent(payload)
+			if !ok {
+				deleteIDs = append(deleteIDs, doc.MessageID)
+				continue
+			}
+			doc.ContentType = contentType
+			doc.Content = content
+			doc.Payload = extra.ContentEdit
+		}
+		indexDocs = append(indexDocs, doc)
+	}
+	return indexDocs, deleteIDs, nil
+}
+
+// 写入索引，返回失败的文档（消息ID -> 失败原因）
+func (s *Service) indexToBackend(docs []*MessageDocument) map[string]string {
+	if len(docs) == 0 {
+		return map[string]string{}
+	}
+	messageIDs := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		messageIDs = append(messageIDs, doc.MessageID)
+	}
+	return s.doBackend(messageIDs, func(backend SearchBackend) error {
+		return backend.Index(docs)
+	})
+}
+
+// 从索引删除，返回失败的文档（消息ID -> 失败原因）
+func (s *Service) deleteFromBackend(messageIDs []string) map[string]string {
+	if len(messageIDs) == 0 {
+		return map[string]string{}
+	}
+	return s.doBackend(messageIDs, func(backend SearchBackend) error {
+		return backend.Delete(messageIDs)
+	})
+}
+
+func (s *Service) doBackend(messageIDs []string, fn func(backend SearchBackend) error) map[string]string {
+	backend, err := s.getBackend()
+	if err == nil {
+		err = fn(backend)
+	}
+	if err == nil {
+		return map[string]string{}
+	}
+	var bulkErr *BulkError
+	if errors.As(err, &bulkErr) {
+		return bulkErr.Failed
+	}
+	s.Warn("操作索引失败！", zap.Error(err), zap.Int("count", len(messageIDs)))
+	failed := make(map[string]string, len(messageIDs))
+	for _, messageID := range messageIDs {
+		failed[messageID] = err.Error()
+	}
+	return failed
 }
 
-// PushMessageElasticIndexTask 创建消息索引的任务
-func (s *Service) PushMessageElasticIndexTask(resps []msgResp) {
-	if len(resps) <= 0 {
+// 记录索引失败的文档
+func (s *Service) recordErrors(action string, docs []*MessageDocument, failed map[string]string) {
+	if len(failed) == 0 {
 		return
 	}
-	for _, resp := range resps {
-		if resp.ChannelType == common.ChannelTypePerson.Uint8() {
-			elastic.NewBulkIndexRequest().Index(resp.FromUID).Type("message")
+	docMap := map[string]*MessageDocument{}
+	for _, doc := range docs {
+		docMap[doc.MessageID] = doc
+	}
+	for messageID, reason := range failed {
+		body := ""
+		if doc := docMap[messageID]; doc != nil {
+			body = util.ToJson(doc)
+		}
+		err := s.db.Insert(&IndexerErrorModel{
+			Index:      MessageIndex,
+			Action:     action,
+			DocumentID: messageID,
+			Body:       body,
+			Error:      reason,
+		})
+		if err != nil {
+			s.Error("记录索引失败失败！", zap.Error(err), zap.String("messageID", messageID), zap.String("action", action))
 		}
 	}
-	elastic.NewBulkIndexRequest().Index("message")
+}
 
+// 通过消息通知创建文档（不可搜索的消息返回nil）
+func newDocumentWithMessage(message *config.MessageResp) *MessageDocument {
+	payload, err := message.GetPayloadMap()
+	if err != nil {
+		return nil
+	}
+	contentType, content, ok := getSearchContent(payload)
+	if !ok {
+		return nil
+	}
+	channelID := message.ChannelID
+	if message.ChannelType == common.ChannelTypePerson.Uint8() {
+		channelID = common.GetFakeChannelIDWith(message.FromUID, message.ChannelID)
+	}
+	return &MessageDocument{
+		MessageID:    fmt.Sprintf("%d", message.MessageID),
+		MessageSeq:   message.MessageSeq,
+		ClientMsgNo:  message.ClientMsgNo,
+		FromUID:      message.FromUID,
+		ChannelID:    channelID,
+		ChannelType:  message.ChannelType,
+		Participants: getParticipants(channelID, message.ChannelType),
+		ContentType:  contentType,
+		Content:      content,
+		Payload:      string(message.Payload),
+		Timestamp:    int64(message.Timestamp),
+	}
 }
 
-// BulkIndexerItem BulkIndexerItem
-type msgResp struct {
-	MessageID   uint64 `json:"message_id"`   // 服务端的消息ID(全局唯一)
-	FromUID     string `json:"from_uid"`     // 发送者UID
-	ChannelID   string `json:"channel_id"`   // 频道ID
-	ChannelType uint8  `json:"channel_type"` // 频道类型
-	Timestamp   int64  `json:"timestamp"`    // 服务器消息时间戳(10位，到秒)
-	Payload     []byte `json:"payload"`      // 消息内容
+// 通过消息表数据创建文档（已删除或不可搜索的消息返回nil）
+func newDocumentWithModel(model *messageModel) *MessageDocument {
+	if model.IsDeleted == 1 {
+		return nil
+	}
+	var payload map[string]interface{}
+	if err := util.ReadJsonByByte([]byte(model.Payload), &payload); err != nil {
+		return nil
+	}
+	contentType, content, ok := getSearchContent(payload)
+	if !ok {
+		return nil
+	}
+	return &MessageDocument{
+		MessageID:    model.MessageID,
+		MessageSeq:   model.MessageSeq,
+		ClientMsgNo:  model.ClientMsgNo,
+		FromUID:      model.FromUID,
+		ChannelID:    model.ChannelID,
+		ChannelType:  model.ChannelType,
+		Participants: getParticipants(model.ChannelID, model.ChannelType),
+		ContentType:  contentType,
+		Content:      content,
+		Payload:      model.Payload,
+		Timestamp:    model.Timestamp,
+	}
 }
@@ -0,0 +1,95 @@
+package elastic
+
+import (
+	"strings"
+	"unicode"
+)
+
+// 是否是中日韩文字（中日韩文字没有空格分隔，按单字和双字切分）
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// 切分文本，中日韩文字连续的片段和其他字母数字连续的片段分别返回（其他字母转小写）
+func splitSegments(text string, fn func(segment []rune, cjk bool)) {
+	var segment []rune
+	var segmentCJK bool
+	flush := func() {
+		if len(segment) > 0 {
+			fn(segment, segmentCJK)
+			segment = nil
+		}
+	}
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			if !segmentCJK {
+				flush()
+			}
+			segmentCJK = true
+			segment = append(segment, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if segmentCJK {
+				flush()
+			}
+			segmentCJK = false
+			segment = append(segment, unicode.ToLower(r))
+		default:
+			flush()
+		}
+	}
+	flush()
+}
+
+// tokenize 文档分词：中日韩文字切分为单字和相邻双字，其他文字按单词切分
+func tokenize(text string) []string {
+	tokenMap := map[string]struct{}{}
+	splitSegments(text, func(segment []rune, cjk bool) {
+		if !cjk {
+			tokenMap[string(segment)] = struct{}{}
+			return
+		}
+		for i := range segment {
+			tokenMap[string(segment[i])] = struct{}{}
+			if i+1 < len(segment) {
+				tokenMap[string(segment[i:i+2])] = struct{}{}
+			}
+		}
+	})
+	tokens := make([]string, 0, len(tokenMap))
+	for token := range tokenMap {
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// tokenizeQuery 关键字分词：中日韩文字切分为相邻双字（只有一个字时为单字），其他文字按单词切分
+func tokenizeQuery(keyword string) []string {
+	tokenMap := map[string]struct{}{}
+	splitSegments(keyword, func(segment []rune, cjk bool) {
+		if !cjk || len(segment) == 1 {
+			tokenMap[string(segment)] = struct{}{}
+			return
+		}
+		for i := 0; i+1 < len(segment); i++ {
+			tokenMap[string(segment[i:i+2])] = struct{}{}
+		}
+	})
+	tokens := make([]string, 0, len(tokenMap))
+	for token := range tokenMap {
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// 正文是否包含关键字内的所有片段（双字倒排只能保证包含每个双字，不能保证双字相连，需要再次确认）
+func containsKeyword(content string, keyword string) bool {
+	content = strings.ToLower(content)
+	contains := true
+	splitSegments(keyword, func(segment []rune, cjk bool) {
+		if contains && !strings.Contains(content, string(segment)) {
+			contains = false
+		}
+	})
+	return contains
+}
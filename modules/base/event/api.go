@@ -24,6 +24,8 @@ const (
 	GroupMemberAdd string = "group.memberadd"
 	// GroupMemberScanJoin 扫码加入群
 	GroupMemberScanJoin string = "group.member.scan.join"
+	// GroupMemberDirectoryJoin 通过群目录加入群
+	GroupMemberDirectoryJoin string = "group.member.directory.join"
 	// GroupMemberTransferGrouper 转让群主
 	GroupMemberTransferGrouper string = "group.member.transfer.grouper"
 	// GroupAvatarUpdate 群头像更新
@@ -32,6 +34,10 @@ const (
 	GroupMemberRemove string = "group.memberremove"
 	// GroupDisband 群解散
 	GroupDisband string = "group.disband"
+	// GroupTopicCreate 群话题创建
+	GroupTopicCreate string = "group.topic.create"
+	// GroupTopicDelete 群话题删除
+	GroupTopicDelete string = "group.topic.delete"
 	// FriendApply 好友申请
 	FriendApply string = "friend.apply"
 	// GroupMemberInviteRequest 群邀请请求
@@ -40,6 +46,10 @@ const (
 	ConversationDelete string = "conversation.delete"
 	// EventUserRegister 用户注册
 	EventUserRegister string = "user.register"
+	// EventUserDestroy 用户注销
+	EventUserDestroy string = "user.destroy"
+	// EventUserDisable 用户被后台封禁
+	EventUserDisable string = "user.disable"
 	// EventUserPublishMoment 用户发布动态
 	EventUserPublishMoment string = "moment.publish"
 	// EventUserDeleteMoment 用户删除动态
@@ -54,6 +64,8 @@ const (
 	OrgOrDeptEmployeeUpdate string = "organization_department.employee.update"
 	// OrgEmployeeExit 组织成员退出
 	OrgEmployeeExit string = "organization.employee.exit"
+	// OrgDeptDelete 部门删除
+	OrgDeptDelete string = "organization_department.delete"
 	// EventUpdateSearchMessage 修改搜索消息内容
 	EventUpdateSearchMessage string = "message.update.search.data"
 )
@@ -0,0 +1,18 @@
+-- +migrate Up
+
+-- 搜索索引失败记录（由重试任务补偿）
+create table `indexer_error`
+(
+  id           bigint        not null primary key AUTO_INCREMENT,
+  `index`      VARCHAR(40)   not null default '',                  -- 索引名
+  action       VARCHAR(20)   not null default '',                  -- 操作 index.新增或更新 delete.删除
+  document_id  VARCHAR(40)   not null default '',                  -- 文档ID（消息ID）
+  body         TEXT,                                               -- 文档内容（json）
+  error        VARCHAR(1000) not null default '',                  -- 失败原因
+  retry_count  integer       not null default 0,                   -- 重试次数
+  status       smallint      not null default 0,                   -- 状态 0.待重试 1.重试成功 2.放弃重试
+  created_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP,   -- 创建时间
+  updated_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP    -- 更新时间
+);
+CREATE INDEX indexer_error_status_idx on `indexer_error` (status, id);
+CREATE INDEX indexer_error_document_idx on `indexer_error` (document_id);
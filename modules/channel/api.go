@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
@@ -27,13 +28,16 @@ type Channel struct {
 }
 
 func New(ctx *config.Context) *Channel {
-	return &Channel{
+	ch := &Channel{
 		ctx:              ctx,
 		Log:              log.NewTLog("Channel"),
 		userService:      user.NewService(ctx),
 		groupService:     group.NewService(ctx),
 		channelSettingDB: newChannelSettingDB(ctx),
 	}
+	ch.ctx.AddEventListener(event.GroupTopicCreate, ch.handleGroupTopicCreateEvent)
+	ch.ctx.AddEventListener(event.GroupTopicDelete, ch.handleGroupTopicDeleteEvent)
+	return ch
 }
 
 // Route 路由配置
@@ -371,3 +375,52 @@ type CallingParticipantResp struct {
 	UID  string `json:"uid"`
 	Name string `json:"name"`
 }
+
+// 群话题创建后记录话题频道的父频道（所属群）
+func (ch *Channel) handleGroupTopicCreateEvent(data []byte, commit config.EventCommit) {
+	var req map[string]interface{}
+	err := util.ReadJsonByByte(data, &req)
+	if err != nil {
+		ch.Error("群话题创建事件数据有误", zap.Error(err))
+		commit(err)
+		return
+	}
+	topicNo, _ := req["topic_no"].(string)
+	groupNo, _ := req["group_no"].(string)
+	if topicNo == "" || groupNo == "" {
+		ch.Error("话题编号或群编号不能为空")
+		commit(errors.New("话题编号或群编号不能为空"))
+		return
+	}
+	err = ch.channelSettingDB.insertOrUpdateParentChannel(topicNo, common.ChannelTypeCommunityTopic.Uint8(), groupNo, common.ChannelTypeGroup.Uint8())
+	if err != nil {
+		ch.Error("设置话题父频道失败", zap.Error(err))
+		commit(err)
+		return
+	}
+	commit(nil)
+}
+
+// 群话题删除后删除话题频道的设置
+func (ch *Channel) handleGroupTopicDeleteEvent(data []byte, commit config.EventCommit) {
+	var req map[string]interface{}
+	err := util.ReadJsonByByte(data, &req)
+	if err != nil {
+		ch.Error("群话题删除事件数据有误", zap.Error(err))
+		commit(err)
+		return
+	}
+	topicNo, _ := req["topic_no"].(string)
+	if topicNo == "" {
+		ch.Error("话题编号不能为空")
+		commit(errors.New("话题编号不能为空"))
+		return
+	}
+	err = ch.channelSettingDB.deleteWithChannel(topicNo, common.ChannelTypeCommunityTopic.Uint8())
+	if err != nil {
+		ch.Error("删除话题频道设置失败", zap.Error(err))
+		commit(err)
+		return
+	}
+	commit(nil)
+}
@@ -40,6 +40,16 @@ func (c *channelSettingDB) insertOrAddOffsetMessageSeq(channelID string, channel
 	return err
 }
 
+func (c *channelSettingDB) insertOrUpdateParentChannel(channelID string, channelType uint8, parentChannelID string, parentChannelType uint8) error {
+	_, err := c.session.InsertBySql("insert into channel_setting (channel_id, channel_type, parent_channel_id, parent_channel_type) values (?, ?, ?, ?) ON DUPLICATE KEY UPDATE parent_channel_id=VALUES(parent_channel_id),parent_channel_type=VALUES(parent_channel_type)", channelID, channelType, parentChannelID, parentChannelType).Exec()
+	return err
+}
+
+func (c *channelSettingDB) deleteWithChannel(channelID string, channelType uint8) error {
+	_, err := c.session.DeleteFrom("channel_setting").Where("channel_id=? and channel_type=?", channelID, channelType).Exec()
+	return err
+}
+
 type channelSettingModel struct {
 	ChannelID         string
 	ChannelType       uint8
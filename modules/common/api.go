@@ -322,6 +322,14 @@ func (cn *Common) appConfig(c *wkhttp.Context) {
 	} else {
 		revokeSecond = appConfigM.RevokeSecond
 	}
+	managerRevokeSecond := appConfigM.ManagerRevokeSecond
+	if managerRevokeSecond == 0 {
+		managerRevokeSecond = -1
+	}
+	creatorRevokeSecond := appConfigM.CreatorRevokeSecond
+	if creatorRevokeSecond == 0 {
+		creatorRevokeSecond = -1
+	}
 
 	c.JSON(http.StatusOK, &appConfigResp{
 		Version:                        appConfigM.Version,
@@ -329,6 +337,8 @@ func (cn *Common) appConfig(c *wkhttp.Context) {
 		ShortnoEditOff:                 shortnoEditOff,
 		WebURL:                         cn.ctx.GetConfig().External.WebLoginURL,
 		RevokeSecond:                   revokeSecond,
+		ManagerRevokeSecond:            managerRevokeSecond,
+		CreatorRevokeSecond:            creatorRevokeSecond,
 		RegisterInviteOn:               appConfigM.RegisterInviteOn,
 		SendWelcomeMessageOn:           appConfigM.SendWelcomeMessageOn,
 		InviteSystemAccountJoinGroupOn: appConfigM.InviteSystemAccountJoinGroupOn,
@@ -488,6 +498,8 @@ type appConfigResp struct {
 	PhoneSearchOff                 int    `json:"phone_search_off"`
 	ShortnoEditOff                 int    `json:"shortno_edit_off"`
 	RevokeSecond                   int    `json:"revoke_second"`
+	ManagerRevokeSecond            int    `json:"manager_revoke_second"` // 群管理员消息可撤回时长 -1.不限制
+	CreatorRevokeSecond            int    `json:"creator_revoke_second"` // 群主消息可撤回时长 -1.不限制
 	AppleSignIn                    int    `json:"apple_sign_in"`
 	RegisterInviteOn               int    `json:"register_invite_on"`                  // 开启注册邀请机制
 	SendWelcomeMessageOn           int    `json:"send_welcome_message_on"`             // 开启注册登录发送欢迎语
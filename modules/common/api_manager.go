@@ -204,6 +204,9 @@ func (m *Manager) updateConfig(c *wkhttp.Context) {
 		RegisterUserMustCompleteInfoOn int    `json:"register_user_must_complete_info_on"` // 注册用户必须填写完整信息
 		ChannelPinnedMessageMaxCount   int    `json:"channel_pinned_message_max_count"`    // 频道置顶消息最大数量
 		CanModifyApiUrl                int    `json:"can_modify_api_url"`                  // 是否可以修改api地址
+		GroupMemberMaxCount            int    `json:"group_member_max_count"`              // 群成员数量上限 0.不限制
+		ManagerRevokeSecond            int    `json:"manager_revoke_second"`               // 群管理员消息可撤回时长 0.不限制
+		CreatorRevokeSecond            int    `json:"creator_revoke_second"`               // 群主消息可撤回时长 0.不限制
 	}
 	var req reqVO
 	if err := c.BindJSON(&req); err != nil {
@@ -227,6 +230,9 @@ func (m *Manager) updateConfig(c *wkhttp.Context) {
 	configMap["register_user_must_complete_info_on"] = req.RegisterUserMustCompleteInfoOn
 	configMap["channel_pinned_message_max_count"] = req.ChannelPinnedMessageMaxCount
 	configMap["can_modify_api_url"] = req.CanModifyApiUrl
+	configMap["group_member_max_count"] = req.GroupMemberMaxCount
+	configMap["manager_revoke_second"] = req.ManagerRevokeSecond
+	configMap["creator_revoke_second"] = req.CreatorRevokeSecond
 	err = m.appconfigDB.updateWithMap(configMap, appConfigM.Id)
 	if err != nil {
 		m.Error("修改app配置信息错误", zap.Error(err))
@@ -257,6 +263,9 @@ func (m *Manager) appconfig(c *wkhttp.Context) {
 	var registerUserMustCompleteInfoOn = 0
 	var channelPinnedMessageMaxCount = 10
 	var canModifyApiUrl = 0
+	var groupMemberMaxCount = 0
+	var managerRevokeSecond = 0
+	var creatorRevokeSecond = 0
 	if appconfig != nil {
 		revokeSecond = appconfig.RevokeSecond
 		welcomeMessage = appconfig.WelcomeMessage
@@ -268,6 +277,9 @@ func (m *Manager) appconfig(c *wkhttp.Context) {
 		registerUserMustCompleteInfoOn = appconfig.RegisterUserMustCompleteInfoOn
 		channelPinnedMessageMaxCount = appconfig.ChannelPinnedMessageMaxCount
 		canModifyApiUrl = appconfig.CanModifyApiUrl
+		groupMemberMaxCount = appconfig.GroupMemberMaxCount
+		managerRevokeSecond = appconfig.ManagerRevokeSecond
+		creatorRevokeSecond = appconfig.CreatorRevokeSecond
 	}
 	if revokeSecond == 0 {
 		revokeSecond = 120
@@ -286,6 +298,9 @@ func (m *Manager) appconfig(c *wkhttp.Context) {
 		RegisterUserMustCompleteInfoOn: registerUserMustCompleteInfoOn,
 		ChannelPinnedMessageMaxCount:   channelPinnedMessageMaxCount,
 		CanModifyApiUrl:                canModifyApiUrl,
+		GroupMemberMaxCount:            groupMemberMaxCount,
+		ManagerRevokeSecond:            managerRevokeSecond,
+		CreatorRevokeSecond:            creatorRevokeSecond,
 	})
 }
 
@@ -300,6 +315,9 @@ type managerAppConfigResp struct {
 	RegisterUserMustCompleteInfoOn int    `json:"register_user_must_complete_info_on"` // 注册用户必须填写完整信息
 	ChannelPinnedMessageMaxCount   int    `json:"channel_pinned_message_max_count"`    // 频道置顶消息最大数量
 	CanModifyApiUrl                int    `json:"can_modify_api_url"`                  // 是否可以修改api地址
+	GroupMemberMaxCount            int    `json:"group_member_max_count"`              // 群成员数量上限 0.不限制
+	ManagerRevokeSecond            int    `json:"manager_revoke_second"`               // 群管理员消息可撤回时长 0.不限制
+	CreatorRevokeSecond            int    `json:"creator_revoke_second"`               // 群主消息可撤回时长 0.不限制
 }
 
 type managerAppModule struct {
@@ -51,5 +51,8 @@ type appConfigModel struct {
 	RegisterUserMustCompleteInfoOn int    // 注册用户是否必须完善个人信息
 	ChannelPinnedMessageMaxCount   int    // 频道置顶消息最大数量
 	CanModifyApiUrl                int    // 是否可以修改API地址
+	GroupMemberMaxCount            int    // 群成员数量上限 0.不限制
+	ManagerRevokeSecond            int    // 群管理员消息可撤回时长 0.不限制
+	CreatorRevokeSecond            int    // 群主消息可撤回时长 0.不限制
 	ldb.BaseModel
 }
@@ -67,6 +67,10 @@ func (s *service) GetAppConfig() (*AppConfigResp, error) {
 		InviteSystemAccountJoinGroupOn: appConfigM.InviteSystemAccountJoinGroupOn,
 		RegisterUserMustCompleteInfoOn: appConfigM.RegisterUserMustCompleteInfoOn,
 		ChannelPinnedMessageMaxCount:   appConfigM.ChannelPinnedMessageMaxCount,
+		GroupMemberMaxCount:            appConfigM.GroupMemberMaxCount,
+		RevokeSecond:                   appConfigM.RevokeSecond,
+		ManagerRevokeSecond:            appConfigM.ManagerRevokeSecond,
+		CreatorRevokeSecond:            appConfigM.CreatorRevokeSecond,
 	}, nil
 }
 
@@ -140,4 +144,8 @@ type AppConfigResp struct {
 	InviteSystemAccountJoinGroupOn int    // 是否允许邀请系统账号进入群聊
 	RegisterUserMustCompleteInfoOn int    // 是否要求注册用户必须填写完整信息
 	ChannelPinnedMessageMaxCount   int    // 频道置顶消息最大数量
+	GroupMemberMaxCount            int    // 群成员数量上限 0.不限制
+	RevokeSecond                   int    // 消息可撤回时长 0.不限制
+	ManagerRevokeSecond            int    // 群管理员消息可撤回时长 0.不限制
+	CreatorRevokeSecond            int    // 群主消息可撤回时长 0.不限制
 }
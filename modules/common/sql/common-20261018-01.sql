@@ -0,0 +1,3 @@
+-- +migrate Up
+
+ALTER TABLE `app_config` ADD COLUMN group_member_max_count integer not null DEFAULT 0 COMMENT '群成员数量上限（单个群可单独设置） 0.不限制';
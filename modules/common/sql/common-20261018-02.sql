@@ -0,0 +1,4 @@
+-- +migrate Up
+
+ALTER TABLE `app_config` ADD COLUMN manager_revoke_second integer not null DEFAULT 0 COMMENT '群管理员消息可撤回时长 0.不限制';
+ALTER TABLE `app_config` ADD COLUMN creator_revoke_second integer not null DEFAULT 0 COMMENT '群主消息可撤回时长 0.不限制';
@@ -24,4 +24,6 @@ const (
 	TypeWorkplaceBanner Type = "workplacebanner"
 	// TypeWorkplaceAppIcon
 	TypeWorkplaceAppIcon Type = "workplaceappicon"
+	// TypeLinkPreview 链接预览图片
+	TypeLinkPreview Type = "linkpreview"
 )
@@ -32,13 +32,17 @@ func init() {
 			Swagger: swaggerContent,
 			IMDatasource: register.IMDatasource{
 				HasData: func(channelID string, channelType uint8) register.IMDatasourceType {
-					if channelType == common.ChannelTypeGroup.Uint8() {
+					if channelType == common.ChannelTypeGroup.Uint8() || channelType == common.ChannelTypeCommunityTopic.Uint8() {
 						return register.IMDatasourceTypeChannelInfo | register.IMDatasourceTypeSubscribers | register.IMDatasourceTypeBlacklist | register.IMDatasourceTypeWhitelist
 					}
 					return register.IMDatasourceTypeNone
 				},
 				ChannelInfo: func(channelID string, channelType uint8) (map[string]interface{}, error) {
-					groupInfo, err := api.groupService.GetGroupWithGroupNo(channelID)
+					groupNo, err := api.getChannelGroupNo(channelID, channelType)
+					if err != nil {
+						return nil, err
+					}
+					groupInfo, err := api.groupService.GetGroupWithGroupNo(groupNo)
 					if err != nil {
 						return nil, err
 					}
@@ -57,8 +61,11 @@ func init() {
 					return channelInfoMap, nil
 				},
 				Subscribers: func(channelID string, channelType uint8) ([]string, error) {
-
-					mebmers, err := api.groupService.GetMembers(channelID)
+					groupNo, err := api.getChannelGroupNo(channelID, channelType)
+					if err != nil {
+						return nil, err
+					}
+					mebmers, err := api.groupService.GetMembers(groupNo)
 					if err != nil {
 						return nil, err
 					}
@@ -71,24 +78,63 @@ func init() {
 					return subscribers, nil
 				},
 				Blacklist: func(channelID string, channelType uint8) ([]string, error) {
-					return api.groupService.GetBlacklistMemberUIDs(channelID)
+					groupNo, err := api.getChannelGroupNo(channelID, channelType)
+					if err != nil {
+						return nil, err
+					}
+					uids, err := api.groupService.GetBlacklistMemberUIDs(groupNo)
+					if err != nil {
+						return nil, err
+					}
+					slowModeUIDs, err := api.getSlowModeLimitedUIDs(groupNo)
+					if err != nil {
+						return nil, err
+					}
+					rulesPendingUIDs, err := api.db.queryRulesPendingUIDs(groupNo)
+					if err != nil {
+						return nil, err
+					}
+					uids = append(uids, slowModeUIDs...)
+					return append(uids, rulesPendingUIDs...), nil
 				},
 				Whitelist: func(channelID string, channelType uint8) ([]string, error) {
-					groupInfo, err := api.groupService.GetGroupWithGroupNo(channelID)
+					groupNo := channelID
+					var topic *TopicResp
+					if channelType == common.ChannelTypeCommunityTopic.Uint8() {
+						var err error
+						topic, err = api.groupService.GetTopic(channelID)
+						if err != nil {
+							return nil, err
+						}
+						if topic == nil {
+							return nil, nil
+						}
+						groupNo = topic.GroupNo
+					}
+					groupInfo, err := api.groupService.GetGroupWithGroupNo(groupNo)
 					if err != nil {
 						return nil, err
 					}
 					if groupInfo == nil {
 						return nil, nil
 					}
+					if groupInfo.Status == GroupStatusArchived { // 已归档的群只有系统账号可发言
+						return api.archivedWhitelist(), nil
+					}
+					if topic != nil && topic.Status == TopicStatusClosed { // 已关闭的话题只有群主和管理员可发言
+						return api.groupService.GetMemberUIDsOfManager(groupNo)
+					}
 					if groupInfo.Forbidden == 1 {
-						return api.groupService.GetMemberUIDsOfManager(channelID)
+						return api.groupService.GetMemberUIDsOfManager(groupNo)
 					}
 					return make([]string, 0), nil
 				},
 			},
 			BussDataSource: register.BussDataSource{
 				ChannelGet: func(channelID string, channelType uint8, loginUID string) (*model.ChannelResp, error) {
+					if channelType == common.ChannelTypeCommunityTopic.Uint8() {
+						return api.topicChannelGet(channelID, loginUID)
+					}
 					if channelType != common.ChannelTypeGroup.Uint8() {
 						return nil, register.ErrDatasourceNotProcess
 					}
@@ -189,6 +235,19 @@ func newChannelRespWithGroupResp(groupResp *GroupResp) *model.ChannelResp {
 	extraMap["allow_view_history_msg"] = groupResp.AllowViewHistoryMsg
 	extraMap["group_type"] = groupResp.GroupType
 	extraMap["allow_member_pinned_message"] = groupResp.AllowMemberPinnedMessage
+	extraMap["join_apply"] = groupResp.JoinApply
+	extraMap["is_public"] = groupResp.IsPublic
+	extraMap["slow_mode"] = groupResp.SlowMode
+	extraMap["succession_policy"] = groupResp.SuccessionPolicy
+	extraMap["topic_mode"] = groupResp.TopicMode
+	extraMap["member_max_count"] = groupResp.MemberMaxCount
+	extraMap["rules_required"] = groupResp.RulesRequired
+	extraMap["revoke_second"] = groupResp.RevokeSecond
+	extraMap["manager_revoke_second"] = groupResp.ManagerRevokeSecond
+	extraMap["creator_revoke_second"] = groupResp.CreatorRevokeSecond
+	if len(groupResp.JoinQuestions) > 0 {
+		extraMap["join_questions"] = groupResp.JoinQuestions
+	}
 	if groupResp.MemberCount != 0 {
 		extraMap["member_count"] = groupResp.MemberCount
 	}
@@ -0,0 +1,644 @@
+package group
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkevent"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/gocraft/dbr/v2"
+	"go.uber.org/zap"
+)
+
+// 发布群公告（publish_at大于当前时间时定时发布）
+func (g *Group) announcementAdd(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	loginName := c.GetLoginName()
+	groupNo := c.Param("group_no")
+	var req announcementReq
+	if err := c.BindJSON(&req); err != nil {
+		g.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if err := g.checkAnnouncementPermission(groupNo, loginUID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	now := time.Now().Unix()
+	model := &AnnouncementModel{
+		AnnouncementNo: util.GenerUUID(),
+		GroupNo:        groupNo,
+		Creator:        loginUID,
+		Content:        req.Content,
+		RequireAck:     req.RequireAck,
+		Pinned:         req.Pinned,
+		PublishAt:      req.PublishAt,
+		Status:         AnnouncementStatusScheduled,
+	}
+	if model.PublishAt <= now {
+		model.PublishAt = now
+	}
+	err := g.db.insertAnnouncement(model)
+	if err != nil {
+		g.Error("添加群公告失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加群公告失败！"))
+		return
+	}
+	if model.PublishAt <= now {
+		err = g.publishAnnouncement(model, loginName)
+		if err != nil {
+			g.Error("发布群公告失败！", zap.Error(err))
+			// 已返回失败，不再由定时任务发布
+			if err := g.db.failAnnouncement(model.Id); err != nil {
+				g.Error("更新群公告状态失败！", zap.Error(err))
+			}
+			c.ResponseError(errors.New("发布群公告失败！"))
+			return
+		}
+	}
+	c.Response(newAnnouncementResp(&AnnouncementDetailModel{AnnouncementModel: *model, CreatorName: loginName}, false))
+}
+
+// 群公告列表（有公告权限的成员可以看到待发布的公告）
+func (g *Group) announcementList(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	groupNo := c.Param("group_no")
+	if err := g.checkGroupMember(groupNo, loginUID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	hasPermission, err := g.groupService.HasPermission(groupNo, loginUID, PermissionEditNotice)
+	if err != nil {
+		g.Error("查询群权限失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询群权限失败！"))
+		return
+	}
+	pageIndex, pageSize := c.GetPage()
+	models, err := g.db.queryAnnouncementsWithPage(groupNo, hasPermission, uint64(pageSize), uint64(pageIndex))
+	if err != nil {
+		g.Error("查询群公告失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询群公告失败！"))
+		return
+	}
+	count, err := g.db.queryAnnouncementCount(groupNo, hasPermission)
+	if err != nil {
+		g.Error("查询群公告数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询群公告数量失败！"))
+		return
+	}
+	announcementNos := make([]string, 0, len(models))
+	for _, model := range models {
+		announcementNos = append(announcementNos, model.AnnouncementNo)
+	}
+	ackedNos, err := g.db.queryAckedAnnouncementNos(announcementNos, loginUID)
+	if err != nil {
+		g.Error("查询公告确认状态失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询公告确认状态失败！"))
+		return
+	}
+	list := make([]*announcementResp, 0, len(models))
+	for _, model := range models {
+		acked := false
+		for _, ackedNo := range ackedNos {
+			if ackedNo == model.AnnouncementNo {
+				acked = true
+				break
+			}
+		}
+		list = append(list, newAnnouncementResp(model, acked))
+	}
+	c.Response(map[string]interface{}{
+		"count": count,
+		"list":  list,
+	})
+}
+
+// 删除群公告（删除置顶公告时清空群公告）
+func (g *Group) announcementDelete(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	loginName := c.GetLoginName()
+	groupNo := c.Param("group_no")
+	announcementNo := c.Param("announcement_no")
+	if err := g.checkAnnouncementPermission(groupNo, loginUID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	model, err := g.getAnnouncement(groupNo, announcementNo)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	tx, err := g.ctx.DB().Begin()
+	if err != nil {
+		g.Error("开启事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("开启事务失败！"))
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.Rollback()
+			panic(err)
+		}
+	}()
+	err = g.db.deleteAnnouncementTx(announcementNo, tx)
+	if err != nil {
+		tx.Rollback()
+		g.Error("删除群公告失败！", zap.Error(err))
+		c.ResponseError(errors.New("删除群公告失败！"))
+		return
+	}
+	var eventID int64
+	if model.Pinned == 1 && model.Status == AnnouncementStatusPublished {
+		eventID, err = g.updateGroupNoticeTx(groupNo, "", loginUID, loginName, tx)
+		if err != nil {
+			tx.Rollback()
+			g.Error("清空群公告失败！", zap.Error(err))
+			c.ResponseError(errors.New("清空群公告失败！"))
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		tx.RollbackUnlessCommitted()
+		g.Error("提交事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("提交事务失败！"))
+		return
+	}
+	if eventID > 0 {
+		g.ctx.EventCommit(eventID)
+	}
+	c.ResponseOK()
+}
+
+// 置顶或取消置顶群公告（置顶公告同步为群公告）
+func (g *Group) announcementPin(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	loginName := c.GetLoginName()
+	groupNo := c.Param("group_no")
+	announcementNo := c.Param("announcement_no")
+	var req struct {
+		Pinned int `json:"pinned"` // 是否置顶 0.否 1.是
+	}
+	if err := c.BindJSON(&req); err != nil {
+		g.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if req.Pinned != 0 && req.Pinned != 1 {
+		c.ResponseError(errors.New("是否置顶参数有误！"))
+		return
+	}
+	if err := g.checkAnnouncementPermission(groupNo, loginUID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	model, err := g.getAnnouncement(groupNo, announcementNo)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if model.Pinned == req.Pinned {
+		c.ResponseOK()
+		return
+	}
+	model.Pinned = req.Pinned
+	tx, err := g.ctx.DB().Begin()
+	if err != nil {
+		g.Error("开启事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("开启事务失败！"))
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.Rollback()
+			panic(err)
+		}
+	}()
+	err = g.db.updateAnnouncementPinnedTx(model, tx)
+	if err != nil {
+		tx.Rollback()
+		g.Error("修改公告置顶状态失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改公告置顶状态失败！"))
+		return
+	}
+	var eventID int64
+	if model.Status == AnnouncementStatusPublished { // 待发布的公告在发布时才同步为群公告
+		notice := ""
+		if model.Pinned == 1 {
+			notice = model.Content
+		}
+		eventID, err = g.updateGroupNoticeTx(groupNo, notice, loginUID, loginName, tx)
+		if err != nil {
+			tx.Rollback()
+			g.Error("更新群公告失败！", zap.Error(err))
+			c.ResponseError(errors.New("更新群公告失败！"))
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		tx.RollbackUnlessCommitted()
+		g.Error("提交事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("提交事务失败！"))
+		return
+	}
+	if eventID > 0 {
+		g.ctx.EventCommit(eventID)
+	}
+	c.ResponseOK()
+}
+
+// 确认已读群公告
+func (g *Group) announcementAck(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	groupNo := c.Param("group_no")
+	announcementNo := c.Param("announcement_no")
+	if err := g.checkGroupMember(groupNo, loginUID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	model, err := g.getAnnouncement(groupNo, announcementNo)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if model.Status != AnnouncementStatusPublished {
+		c.ResponseError(errors.New("群公告还未发布！"))
+		return
+	}
+	if model.RequireAck != 1 {
+		c.ResponseError(errors.New("此公告无需确认！"))
+		return
+	}
+	err = g.db.insertAnnouncementAck(announcementNo, groupNo, loginUID)
+	if err != nil {
+		g.Error("确认群公告失败！", zap.Error(err))
+		c.ResponseError(errors.New("确认群公告失败！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 群公告确认情况（已确认和未确认的成员）
+func (g *Group) announcementAcks(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	groupNo := c.Param("group_no")
+	announcementNo := c.Param("announcement_no")
+	if err := g.checkAnnouncementPermission(groupNo, loginUID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	_, err := g.getAnnouncement(groupNo, announcementNo)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	ackedModels, err := g.db.queryAnnouncementAckedMembers(announcementNo, groupNo)
+	if err != nil {
+		g.Error("查询已确认成员失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询已确认成员失败！"))
+		return
+	}
+	unackedModels, err := g.db.queryAnnouncementUnackedMembers(announcementNo, groupNo)
+	if err != nil {
+		g.Error("查询未确认成员失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询未确认成员失败！"))
+		return
+	}
+	acked := make([]*announcementAckMemberResp, 0, len(ackedModels))
+	for _, model := range ackedModels {
+		acked = append(acked, &announcementAckMemberResp{
+			UID:     model.UID,
+			Name:    model.Name,
+			AckedAt: model.AckedAt,
+		})
+	}
+	unacked := make([]*announcementAckMemberResp, 0, len(unackedModels))
+	for _, model := range unackedModels {
+		unacked = append(unacked, &announcementAckMemberResp{
+			UID:  model.UID,
+			Name: model.Name,
+		})
+	}
+	c.Response(map[string]interface{}{
+		"acked":   acked,
+		"unacked": unacked,
+	})
+}
+
+// CheckAnnouncementPublishLoop 发布到达时间的定时公告
+// 多实例部署时只有持有调度租约的实例执行，避免重复发布
+func (g *Group) CheckAnnouncementPublishLoop() {
+	var limit uint64 = 100
+	var errSleep = time.Second * 5
+	var checkSleep = time.Second * 10
+	for {
+		ok, err := g.leaseRedis.HoldLease(AnnouncementPublishLeaseCacheKey, g.leaseID, AnnouncementPublishLeaseTTL)
+		if err != nil {
+			g.Warn("获取定时公告调度租约失败", zap.Error(err))
+		}
+		if !ok {
+			time.Sleep(checkSleep)
+			continue
+		}
+		models, err := g.db.queryAnnouncementsToPublish(time.Now().Unix(), limit)
+		if err != nil {
+			g.Warn("查询待发布的群公告失败", zap.Error(err))
+			time.Sleep(errSleep)
+			continue
+		}
+		for _, model := range models {
+			g.publishScheduledAnnouncement(model)
+		}
+		time.Sleep(checkSleep)
+	}
+}
+
+// 发布定时公告（群已解散的标记为发布失败，发布出错的重试，超过最大重试次数后标记为发布失败，避免一直阻塞后续公告的发布）
+func (g *Group) publishScheduledAnnouncement(model *AnnouncementModel) {
+	groupModel, err := g.db.QueryWithGroupNo(model.GroupNo)
+	if err != nil {
+		g.Warn("查询群信息失败", zap.Error(err))
+		return
+	}
+	if groupModel == nil || groupModel.Status == GroupStatusDisband {
+		if err = g.db.failAnnouncement(model.Id); err != nil {
+			g.Warn("更新群公告状态失败", zap.Error(err), zap.String("announcement_no", model.AnnouncementNo))
+		}
+		return
+	}
+	creatorName := ""
+	creator, err := g.userDB.QueryByUID(model.Creator)
+	if err != nil {
+		g.Warn("查询公告发布者失败", zap.Error(err))
+	} else if creator != nil {
+		creatorName = creator.Name
+	}
+	err = g.publishAnnouncement(model, creatorName)
+	if err != nil {
+		g.Warn("发布定时群公告失败", zap.Error(err), zap.String("announcement_no", model.AnnouncementNo), zap.Int("retry_count", model.RetryCount))
+		if err = g.db.incrAnnouncementRetry(model.Id); err != nil {
+			g.Warn("更新群公告重试次数失败", zap.Error(err), zap.String("announcement_no", model.AnnouncementNo))
+		}
+	}
+}
+
+// 发布群公告 置顶公告同步为群公告（由群更新事件通知成员），未置顶的发送提示消息
+func (g *Group) publishAnnouncement(model *AnnouncementModel, operatorName string) error {
+	tx, err := g.ctx.DB().Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.Rollback()
+			panic(err)
+		}
+	}()
+	ok, err := g.db.publishAnnouncementTx(model, tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if !ok { // 已被其他实例发布或已取消
+		tx.Rollback()
+		return nil
+	}
+	model.Status = AnnouncementStatusPublished
+	var eventID int64
+	if model.Pinned == 1 {
+		eventID, err = g.updateGroupNoticeTx(model.GroupNo, model.Content, model.Creator, operatorName, tx)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		tx.RollbackUnlessCommitted()
+		return err
+	}
+	if eventID > 0 {
+		g.ctx.EventCommit(eventID)
+		return nil
+	}
+	return g.ctx.SendMessage(&config.MsgSendReq{
+		Header: config.MsgHeader{
+			RedDot: 1,
+		},
+		ChannelID:   model.GroupNo,
+		ChannelType: common.ChannelTypeGroup.Uint8(),
+		Payload: []byte(util.ToJson(map[string]interface{}{
+			"content": "{0}发布了新的群公告",
+			"extra": []config.UserBaseVo{
+				{
+					UID:  model.Creator,
+					Name: operatorName,
+				},
+			},
+			"data": map[string]interface{}{
+				"announcement_no": model.AnnouncementNo,
+				"require_ack":     model.RequireAck,
+			},
+			"type": common.Tip,
+		})),
+	})
+}
+
+// 更新群公告并开启群更新事件（需在事务提交后提交事件）
+func (g *Group) updateGroupNoticeTx(groupNo string, notice string, operator string, operatorName string, tx *dbr.Tx) (int64, error) {
+	groupModel, err := g.db.QueryWithGroupNo(groupNo)
+	if err != nil {
+		return 0, err
+	}
+	if groupModel == nil {
+		return 0, errors.New("群不存在")
+	}
+	groupModel.Notice = notice
+	groupModel.Version = g.ctx.GenSeq(common.GroupSeqKey)
+	err = g.db.UpdateTx(groupModel, tx)
+	if err != nil {
+		return 0, err
+	}
+	return g.ctx.EventBegin(&wkevent.Data{
+		Event: event.GroupUpdate,
+		Type:  wkevent.Message,
+		Data: &config.MsgGroupUpdateReq{
+			GroupNo:      groupNo,
+			Operator:     operator,
+			OperatorName: operatorName,
+			Attr:         common.GroupAttrKeyNotice,
+			Data: map[string]string{
+				common.GroupAttrKeyNotice: notice,
+			},
+		},
+	}, tx)
+}
+
+// 通过修改群属性更新群公告（兼容旧接口）：发布一条置顶公告，内容为空时取消所有置顶并清空群公告
+func (g *Group) updateNoticeWithAnnouncement(groupNo string, notice string, operator string, operatorName string) error {
+	if strings.TrimSpace(notice) != "" {
+		if len([]rune(notice)) > AnnouncementContentMaxLength {
+			return fmt.Errorf("公告内容不能超过%d个字！", AnnouncementContentMaxLength)
+		}
+		model := &AnnouncementModel{
+			AnnouncementNo: util.GenerUUID(),
+			GroupNo:        groupNo,
+			Creator:        operator,
+			Content:        notice,
+			Pinned:         1,
+			PublishAt:      time.Now().Unix(),
+			Status:         AnnouncementStatusScheduled,
+		}
+		err := g.db.insertAnnouncement(model)
+		if err != nil {
+			g.Error("添加群公告失败！", zap.Error(err))
+			return errors.New("添加群公告失败！")
+		}
+		err = g.publishAnnouncement(model, operatorName)
+		if err != nil {
+			g.Error("发布群公告失败！", zap.Error(err))
+			if err := g.db.failAnnouncement(model.Id); err != nil {
+				g.Error("更新群公告状态失败！", zap.Error(err))
+			}
+			return errors.New("发布群公告失败！")
+		}
+		return nil
+	}
+	tx, err := g.ctx.DB().Begin()
+	if err != nil {
+		g.Error("开启事务失败！", zap.Error(err))
+		return errors.New("开启事务失败！")
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.Rollback()
+			panic(err)
+		}
+	}()
+	err = g.db.unpinAnnouncementsTx(groupNo, tx)
+	if err != nil {
+		tx.Rollback()
+		g.Error("取消公告置顶失败！", zap.Error(err))
+		return errors.New("取消公告置顶失败！")
+	}
+	eventID, err := g.updateGroupNoticeTx(groupNo, "", operator, operatorName, tx)
+	if err != nil {
+		tx.Rollback()
+		g.Error("清空群公告失败！", zap.Error(err))
+		return errors.New("清空群公告失败！")
+	}
+	if err := tx.Commit(); err != nil {
+		tx.RollbackUnlessCommitted()
+		g.Error("提交事务失败！", zap.Error(err))
+		return errors.New("提交事务失败！")
+	}
+	g.ctx.EventCommit(eventID)
+	return nil
+}
+
+// 查询群内的公告
+func (g *Group) getAnnouncement(groupNo string, announcementNo string) (*AnnouncementModel, error) {
+	model, err := g.db.queryAnnouncementWithAnnouncementNo(announcementNo)
+	if err != nil {
+		g.Error("查询群公告失败！", zap.Error(err))
+		return nil, errors.New("查询群公告失败！")
+	}
+	if model == nil || model.GroupNo != groupNo {
+		return nil, errors.New("群公告不存在！")
+	}
+	return model, nil
+}
+
+// 校验群是否存在以及操作者是否有群公告权限
+func (g *Group) checkAnnouncementPermission(groupNo string, uid string) error {
+	_, err := g.getGroupInfo(groupNo)
+	if err != nil {
+		return err
+	}
+	hasPermission, err := g.groupService.HasPermission(groupNo, uid, PermissionEditNotice)
+	if err != nil {
+		g.Error("查询群权限失败！", zap.Error(err))
+		return errors.New("查询群权限失败！")
+	}
+	if !hasPermission {
+		return errors.New("没有管理群公告的权限！")
+	}
+	return nil
+}
+
+type announcementReq struct {
+	Content    string `json:"content"`     // 公告内容
+	RequireAck int    `json:"require_ack"` // 是否需要成员确认已读 0.否 1.是
+	Pinned     int    `json:"pinned"`      // 是否置顶（置顶公告同步为群公告） 0.否 1.是
+	PublishAt  int64  `json:"publish_at"`  // 定时发布时间（10位时间戳） 0.立即发布
+}
+
+func (r announcementReq) check() error {
+	if strings.TrimSpace(r.Content) == "" {
+		return errors.New("公告内容不能为空！")
+	}
+	if len([]rune(r.Content)) > AnnouncementContentMaxLength {
+		return fmt.Errorf("公告内容不能超过%d个字！", AnnouncementContentMaxLength)
+	}
+	if r.RequireAck != 0 && r.RequireAck != 1 {
+		return errors.New("是否需要确认参数有误！")
+	}
+	if r.Pinned != 0 && r.Pinned != 1 {
+		return errors.New("是否置顶参数有误！")
+	}
+	if r.PublishAt > time.Now().Add(time.Hour*24*AnnouncementScheduleMaxDays).Unix() {
+		return fmt.Errorf("定时发布时间不能超过%d天！", AnnouncementScheduleMaxDays)
+	}
+	return nil
+}
+
+type announcementResp struct {
+	AnnouncementNo string `json:"announcement_no"` // 公告编号
+	GroupNo        string `json:"group_no"`        // 群编号
+	Creator        string `json:"creator"`         // 发布者uid
+	CreatorName    string `json:"creator_name"`    // 发布者名字
+	Content        string `json:"content"`         // 公告内容
+	RequireAck     int    `json:"require_ack"`     // 是否需要成员确认已读
+	Pinned         int    `json:"pinned"`          // 是否置顶
+	PublishAt      int64  `json:"publish_at"`      // 发布时间
+	Status         int    `json:"status"`          // 状态 0.待发布 1.已发布 2.发布失败
+	AckCount       int64  `json:"ack_count"`       // 已确认人数
+	Acked          int    `json:"acked"`           // 我是否已确认
+	CreatedAt      string `json:"created_at"`
+}
+
+func newAnnouncementResp(m *AnnouncementDetailModel, acked bool) *announcementResp {
+	ackedI := 0
+	if acked {
+		ackedI = 1
+	}
+	return &announcementResp{
+		AnnouncementNo: m.AnnouncementNo,
+		GroupNo:        m.GroupNo,
+		Creator:        m.Creator,
+		CreatorName:    m.CreatorName,
+		Content:        m.Content,
+		RequireAck:     m.RequireAck,
+		Pinned:         m.Pinned,
+		PublishAt:      m.PublishAt,
+		Status:         m.Status,
+		AckCount:       m.AckCount,
+		Acked:          ackedI,
+		CreatedAt:      m.CreatedAt.String(),
+	}
+}
+
+type announcementAckMemberResp struct {
+	UID     string `json:"uid"`
+	Name    string `json:"name"`
+	AckedAt int64  `json:"acked_at,omitempty"` // 确认时间
+}
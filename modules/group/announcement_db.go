@@ -0,0 +1,171 @@
+package group
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+// insertAnnouncement 添加群公告
+func (d *DB) insertAnnouncement(model *AnnouncementModel) error {
+	_, err := d.session.InsertInto("group_announcement").Columns(util.AttrToUnderscore(model)...).Record(model).Exec()
+	return err
+}
+
+// queryAnnouncementWithAnnouncementNo 通过公告编号查询群公告
+func (d *DB) queryAnnouncementWithAnnouncementNo(announcementNo string) (*AnnouncementModel, error) {
+	var model *AnnouncementModel
+	_, err := d.session.Select("*").From("group_announcement").Where("announcement_no=? and is_deleted=0", announcementNo).Load(&model)
+	return model, err
+}
+
+// queryAnnouncementsWithPage 分页查询群公告（置顶的在前）
+func (d *DB) queryAnnouncementsWithPage(groupNo string, includeScheduled bool, pageSize, page uint64) ([]*AnnouncementDetailModel, error) {
+	var models []*AnnouncementDetailModel
+	builder := d.session.Select("group_announcement.*,IFNULL(user.name,'') creator_name,(select count(*) from group_announcement_ack where group_announcement_ack.announcement_no=group_announcement.announcement_no) ack_count").From("group_announcement").LeftJoin("user", "group_announcement.creator=user.uid").Where("group_announcement.group_no=? and group_announcement.is_deleted=0", groupNo)
+	if !includeScheduled {
+		builder = builder.Where("group_announcement.status=?", AnnouncementStatusPublished)
+	}
+	_, err := builder.OrderDir("group_announcement.pinned", false).OrderDir("group_announcement.publish_at", false).Offset((page - 1) * pageSize).Limit(pageSize).Load(&models)
+	return models, err
+}
+
+// queryAnnouncementCount 查询群公告数量
+func (d *DB) queryAnnouncementCount(groupNo string, includeScheduled bool) (int64, error) {
+	var count int64
+	builder := d.session.Select("count(*)").From("group_announcement").Where("group_no=? and is_deleted=0", groupNo)
+	if !includeScheduled {
+		builder = builder.Where("status=?", AnnouncementStatusPublished)
+	}
+	_, err := builder.Load(&count)
+	return count, err
+}
+
+// queryAnnouncementsToPublish 查询到达发布时间的定时公告
+func (d *DB) queryAnnouncementsToPublish(now int64, limit uint64) ([]*AnnouncementModel, error) {
+	var models []*AnnouncementModel
+	_, err := d.session.Select("*").From("group_announcement").Where("status=? and is_deleted=0 and publish_at<=?", AnnouncementStatusScheduled, now).OrderAsc("publish_at").Limit(limit).Load(&models)
+	return models, err
+}
+
+// failAnnouncement 将待发布的公告标记为发布失败
+func (d *DB) failAnnouncement(id int64) error {
+	_, err := d.session.Update("group_announcement").Set("status", AnnouncementStatusFailed).Where("id=? and status=?", id, AnnouncementStatusScheduled).Exec()
+	return err
+}
+
+// incrAnnouncementRetry 待发布的公告发布失败次数+1，达到最大重试次数时标记为发布失败
+// （mysql按顺序执行赋值，判断status时retry_count已是+1后的值）
+func (d *DB) incrAnnouncementRetry(id int64) error {
+	_, err := d.session.UpdateBySql("update group_announcement set retry_count=retry_count+1,status=(case when retry_count>=? then ? else status end) where id=? and status=?", AnnouncementPublishMaxRetry, AnnouncementStatusFailed, id, AnnouncementStatusScheduled).Exec()
+	return err
+}
+
+// unpinAnnouncementsTx 取消群内所有公告的置顶
+func (d *DB) unpinAnnouncementsTx(groupNo string, tx *dbr.Tx) error {
+	_, err := tx.Update("group_announcement").Set("pinned", 0).Where("group_no=? and pinned=1", groupNo).Exec()
+	return err
+}
+
+// publishAnnouncementTx 发布待发布的群公告（置顶时取消群内其他公告的置顶），公告已被发布或已取消时返回false
+func (d *DB) publishAnnouncementTx(model *AnnouncementModel, tx *dbr.Tx) (bool, error) {
+	result, err := tx.Update("group_announcement").SetMap(map[string]interface{}{
+		"status":     AnnouncementStatusPublished,
+		"publish_at": model.PublishAt,
+		"pinned":     model.Pinned,
+	}).Where("id=? and status=? and is_deleted=0", model.Id, AnnouncementStatusScheduled).Exec()
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected == 0 {
+		return false, err
+	}
+	if model.Pinned == 1 {
+		_, err = tx.Update("group_announcement").Set("pinned", 0).Where("group_no=? and pinned=1 and id<>?", model.GroupNo, model.Id).Exec()
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// updateAnnouncementPinnedTx 修改群公告的置顶状态
+func (d *DB) updateAnnouncementPinnedTx(model *AnnouncementModel, tx *dbr.Tx) error {
+	if model.Pinned == 1 {
+		_, err := tx.Update("group_announcement").Set("pinned", 0).Where("group_no=? and pinned=1", model.GroupNo).Exec()
+		if err != nil {
+			return err
+		}
+	}
+	_, err := tx.Update("group_announcement").Set("pinned", model.Pinned).Where("id=?", model.Id).Exec()
+	return err
+}
+
+// deleteAnnouncementTx 删除群公告
+func (d *DB) deleteAnnouncementTx(announcementNo string, tx *dbr.Tx) error {
+	_, err := tx.Update("group_announcement").SetMap(map[string]interface{}{
+		"is_deleted": 1,
+		"pinned":     0,
+	}).Where("announcement_no=?", announcementNo).Exec()
+	return err
+}
+
+// insertAnnouncementAck 添加公告已读确认（重复确认忽略）
+func (d *DB) insertAnnouncementAck(announcementNo string, groupNo string, uid string) error {
+	_, err := d.session.InsertBySql("insert ignore into group_announcement_ack(announcement_no,group_no,uid) values(?,?,?)", announcementNo, groupNo, uid).Exec()
+	return err
+}
+
+// queryAckedAnnouncementNos 查询用户已确认的公告编号
+func (d *DB) queryAckedAnnouncementNos(announcementNos []string, uid string) ([]string, error) {
+	if len(announcementNos) == 0 {
+		return nil, nil
+	}
+	var ackedNos []string
+	_, err := d.session.Select("announcement_no").From("group_announcement_ack").Where("announcement_no in ? and uid=?", announcementNos, uid).Load(&ackedNos)
+	return ackedNos, err
+}
+
+// queryAnnouncementAckedMembers 查询已确认公告的群成员
+func (d *DB) queryAnnouncementAckedMembers(announcementNo string, groupNo string) ([]*AnnouncementAckMemberModel, error) {
+	var models []*AnnouncementAckMemberModel
+	_, err := d.session.Select("group_announcement_ack.uid,IFNULL(user.name,'') name,UNIX_TIMESTAMP(group_announcement_ack.created_at) acked_at").From("group_announcement_ack").Join("group_member", "group_member.uid=group_announcement_ack.uid and group_member.group_no=group_announcement_ack.group_no and group_member.is_deleted=0").LeftJoin("user", "group_announcement_ack.uid=user.uid").Where("group_announcement_ack.announcement_no=? and group_announcement_ack.group_no=?", announcementNo, groupNo).OrderAsc("group_announcement_ack.id").Load(&models)
+	return models, err
+}
+
+// queryAnnouncementUnackedMembers 查询未确认公告的群成员
+func (d *DB) queryAnnouncementUnackedMembers(announcementNo string, groupNo string) ([]*AnnouncementAckMemberModel, error) {
+	var models []*AnnouncementAckMemberModel
+	_, err := d.session.Select("group_member.uid,IFNULL(user.name,'') name").From("group_member").LeftJoin("user", "group_member.uid=user.uid").Where("group_member.group_no=? and group_member.is_deleted=0 and group_member.robot=0 and group_member.uid not in (select uid from group_announcement_ack where announcement_no=?)", groupNo, announcementNo).OrderAsc("group_member.id").Load(&models)
+	return models, err
+}
+
+// AnnouncementModel 群公告
+type AnnouncementModel struct {
+	AnnouncementNo string // 公告唯一编号
+	GroupNo        string // 群编号
+	Creator        string // 发布者uid
+	Content        string // 公告内容
+	RequireAck     int    // 是否需要成员确认已读
+	Pinned         int    // 是否置顶
+	PublishAt      int64  // 发布时间
+	Status         int    // 状态 0.待发布 1.已发布 2.发布失败
+	RetryCount     int    // 定时发布失败重试次数
+	IsDeleted      int    // 是否已删除
+	db.BaseModel
+}
+
+// AnnouncementDetailModel 群公告详情
+type AnnouncementDetailModel struct {
+	AnnouncementModel
+	CreatorName string // 发布者名字
+	AckCount    int64  // 已确认人数
+}
+
+// AnnouncementAckMemberModel 公告确认成员
+type AnnouncementAckMemberModel struct {
+	UID     string
+	Name    string
+	AckedAt int64
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/file"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/source"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/redis"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/model"
@@ -39,6 +40,8 @@ type Group struct {
 	groupService  IService
 	fileService   file.IService
 	commonService common2.IService
+	leaseID       string      // 调度租约（定时禁言）的持有者标识（每个实例唯一）
+	leaseRedis    *redis.Conn // 调度租约使用的redis连接（需要SET NX和lua脚本保证原子性）
 }
 
 // New New
@@ -53,6 +56,8 @@ func New(ctx *config.Context) *Group {
 		groupService:  NewService(ctx),
 		fileService:   file.NewService(ctx),
 		commonService: common2.NewService(ctx),
+		leaseID:       util.GenerUUID(),
+		leaseRedis:    redis.New(ctx.GetConfig().DB.RedisAddr, ctx.GetConfig().DB.RedisPass),
 	}
 	g.ctx.AddEventListener(event.GroupDisband, g.handleGroupDisbandEvent)
 	g.ctx.AddEventListener(event.EventUserRegister, g.handleRegisterUserEvent)
@@ -60,6 +65,10 @@ func New(ctx *config.Context) *Group {
 	g.ctx.AddEventListener(event.OrgOrDeptCreate, g.handleOrgOrDeptCreateEvent)
 	g.ctx.AddEventListener(event.OrgOrDeptEmployeeUpdate, g.handleOrgOrDeptEmployeeUpdate)
 	g.ctx.AddEventListener(event.OrgEmployeeExit, g.handleOrgEmployeeExit)
+	g.ctx.AddEventListener(event.OrgDeptDelete, g.handleOrgDeptDeleteEvent)
+	g.ctx.AddEventListener(event.EventUserDestroy, g.handleUserDestroyEvent)
+	g.ctx.AddEventListener(event.EventUserDisable, g.handleUserDisableEvent)
+	g.ctx.AddMessagesListener(g.slowModeMessagesListen) // 慢速模式
 	source.SetGroupMemberProvider(g)
 	return g
 }
@@ -70,7 +79,9 @@ func (g *Group) Route(r *wkhttp.WKHttp) {
 	{
 		group.POST("/create", g.groupCreate)
 		group.GET("/my", g.list)                            //我保存的群
+		group.GET("/archived", g.archivedList)              // 我加入的已归档群
 		group.GET("/forbidden_times", g.forbiddenTimesList) // 获取禁言时常列表
+		group.GET("/directory", g.directory)                // 群目录（搜索公开群）
 	}
 	groups := r.Group("/v1/groups", g.ctx.AuthMiddleware(r))
 	{
@@ -95,12 +106,45 @@ func (g *Group) Route(r *wkhttp.WKHttp) {
 		groups.POST("/:group_no/forbidden_with_member", g.forbiddenWithGroupMember)        // 禁言或解禁某个群成员
 		groups.POST("/:group_no/avatar", g.avatarUpload)                                   // 上传群头像
 		groups.DELETE("/:group_no/disband", g.disband)                                     // 解散群
+		groups.POST("/:group_no/join", g.directoryJoin)                                    // 通过群目录直接加入公开群
+		groups.POST("/:group_no/join_apply", g.joinApply)                                  // 申请入群
+		groups.GET("/:group_no/join_applys", g.joinApplyList)                              // 入群申请列表
+		groups.POST("/:group_no/join_applys/:apply_no/approve", g.joinApplyApprove)        // 通过入群申请
+		groups.POST("/:group_no/join_applys/:apply_no/refuse", g.joinApplyRefuse)          // 拒绝入群申请
+		groups.POST("/:group_no/invite_links", g.inviteLinkCreate)                         // 创建邀请链接
+		groups.GET("/:group_no/invite_links", g.inviteLinkList)                            // 邀请链接列表
+		groups.DELETE("/:group_no/invite_links/:link_no", g.inviteLinkRevoke)              // 撤销邀请链接
+		groups.GET("/:group_no/permissions", g.permissionList)                             // 群权限矩阵
+		groups.PUT("/:group_no/permissions", g.permissionUpdate)                           // 修改角色权限
+		groups.POST("/:group_no/roles", g.roleAdd)                                         // 添加管理员子角色
+		groups.DELETE("/:group_no/roles/:role_no", g.roleDelete)                           // 删除管理员子角色
+		groups.PUT("/:group_no/managers/:uid/role", g.managerRoleSet)                      // 设置管理员的子角色
+		groups.POST("/:group_no/mute_schedules", g.muteScheduleAdd)                        // 添加定时禁言计划
+		groups.GET("/:group_no/mute_schedules", g.muteScheduleList)                        // 定时禁言计划列表
+		groups.DELETE("/:group_no/mute_schedules/:schedule_no", g.muteScheduleDelete)      // 删除定时禁言计划
+		groups.POST("/:group_no/announcements", g.announcementAdd)                         // 发布群公告
+		groups.GET("/:group_no/announcements", g.announcementList)                         // 群公告历史
+		groups.DELETE("/:group_no/announcements/:announcement_no", g.announcementDelete)   // 删除群公告
+		groups.PUT("/:group_no/announcements/:announcement_no/pin", g.announcementPin)     // 置顶或取消置顶群公告
+		groups.POST("/:group_no/announcements/:announcement_no/ack", g.announcementAck)    // 确认已读群公告
+		groups.GET("/:group_no/announcements/:announcement_no/acks", g.announcementAcks)   // 群公告确认情况
+		groups.GET("/:group_no/audit_logs", g.auditLogList)                                // 群管理操作日志
+		groups.POST("/:group_no/topics", g.topicAdd)                                       // 创建话题
+		groups.GET("/:group_no/topics", g.topicList)                                       // 话题列表
+		groups.PUT("/:group_no/topics/:topic_no", g.topicUpdate)                           // 修改话题
+		groups.PUT("/:group_no/topics/:topic_no/status", g.topicStatusUpdate)              // 开启或关闭话题
+		groups.DELETE("/:group_no/topics/:topic_no", g.topicDelete)                        // 删除话题
+		groups.GET("/:group_no/welcome", g.welcomeGet)                                     // 获取欢迎语和群规
+		groups.PUT("/:group_no/welcome", g.welcomeUpdate)                                  // 设置欢迎语和群规
+		groups.POST("/:group_no/rules/accept", g.rulesAccept)                              // 同意群规
+		groups.POST("/:group_no/archive/:on", g.groupArchive)                              // 归档或取消归档群
 	}
 	openGroups := r.Group("/v1/groups")
 	{ // 获取群头像
-		openGroups.GET("/:group_no/avatar", g.avatarGet)       // 获取群头像
-		openGroups.GET("/:group_no/detail", g.groupDetailGet)  // 群详情
-		openGroups.GET("/:group_no/scanjoin", g.groupScanJoin) // 扫码加入群
+		openGroups.GET("/:group_no/avatar", g.avatarGet)        // 获取群头像
+		openGroups.GET("/:group_no/detail", g.groupDetailGet)   // 群详情
+		openGroups.GET("/:group_no/scanjoin", g.groupScanJoin)  // 扫码加入群
+		openGroups.POST("/:group_no/scanjoin", g.groupScanJoin) // 扫码加入群（需要审核的邀请链接通过body提交入群问题的回答）
 	}
 	openGroup := r.Group("/v1/group")
 	{
@@ -109,6 +153,10 @@ func (g *Group) Route(r *wkhttp.WKHttp) {
 		openGroup.POST("invite/sure", g.groupMemberInviteSure)         // 确认邀请
 	}
 	go g.CheckForbiddenLoop()
+	go g.CheckJoinApplyExpireLoop()
+	go g.CheckSlowModeReleaseLoop()
+	go g.CheckMuteScheduleLoop()
+	go g.CheckAnnouncementPublishLoop()
 }
 
 // 解散群
@@ -193,6 +241,7 @@ func (g *Group) disband(c *wkhttp.Context) {
 		return
 	}
 	g.ctx.EventCommit(eventID)
+	g.addAuditLog(groupNo, loginUID, AuditActionDisband, nil, nil)
 	c.ResponseOK()
 }
 
@@ -779,15 +828,25 @@ func (g *Group) groupUpdate(c *wkhttp.Context) {
 		c.ResponseError(err)
 		return
 	}
-	// 查询是否是管理者
-	isManager, err := g.db.QueryIsGroupManagerOrCreator(groupNo, loginUID)
-	if err != nil {
-		g.Error("查询是否是群管理者失败！", zap.Error(err))
-		c.ResponseError(errors.New("查询是否是群管理者失败！"))
-		return
+	// 校验修改权限
+	for key := range groupMap {
+		if err := g.checkGroupUpdatePermission(groupNo, loginUID, key); err != nil {
+			c.ResponseError(err)
+			return
+		}
 	}
-	if !isManager {
-		c.ResponseError(errors.New("只有群管理者才能修改！"))
+
+	// 群公告走群公告历史（置顶发布），清空时取消置顶
+	if notice, ok := groupMap[common.GroupAttrKeyNotice]; ok && len(groupMap) == 1 {
+		err = g.updateNoticeWithAnnouncement(groupNo, notice, loginUID, loginName)
+		if err != nil {
+			c.ResponseError(err)
+			return
+		}
+		g.addAuditLog(groupNo, loginUID, AuditActionGroupUpdate, nil, map[string]interface{}{
+			common.GroupAttrKeyNotice: notice,
+		})
+		c.ResponseOK()
 		return
 	}
 
@@ -853,9 +912,44 @@ func (g *Group) groupUpdate(c *wkhttp.Context) {
 	}
 	g.ctx.EventCommit(eventID)
 
+	g.addAuditLog(groupNo, loginUID, AuditActionGroupUpdate, nil, map[string]interface{}{
+		attrKey: groupMap[attrKey],
+	})
+
 	c.ResponseOK()
 }
 
+// 校验修改群属性的权限 群名称和群公告由群权限控制，其他属性需群主或管理员
+func (g *Group) checkGroupUpdatePermission(groupNo string, uid string, key string) error {
+	var permission Permission
+	switch key {
+	case common.GroupAttrKeyName:
+		permission = PermissionEditName
+	case common.GroupAttrKeyNotice:
+		permission = PermissionEditNotice
+	}
+	if permission != "" {
+		hasPermission, err := g.groupService.HasPermission(groupNo, uid, permission)
+		if err != nil {
+			g.Error("查询群权限失败！", zap.Error(err))
+			return errors.New("查询群权限失败！")
+		}
+		if !hasPermission {
+			return errors.New("没有修改权限！")
+		}
+		return nil
+	}
+	isManager, err := g.db.QueryIsGroupManagerOrCreator(groupNo, uid)
+	if err != nil {
+		g.Error("查询是否是群管理者失败！", zap.Error(err))
+		return errors.New("查询是否是群管理者失败！")
+	}
+	if !isManager {
+		return errors.New("只有群管理者才能修改！")
+	}
+	return nil
+}
+
 // 添加成员
 func (g *Group) memberAdd(c *wkhttp.Context) {
 	operator := c.MustGet("uid").(string)
@@ -899,6 +993,16 @@ func (g *Group) memberAdd(c *wkhttp.Context) {
 			return
 		}
 	}
+	canInvite, err := g.groupService.HasPermission(groupNo, operator, PermissionInvite)
+	if err != nil {
+		g.Error("查询群权限失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询群权限失败！"))
+		return
+	}
+	if !canInvite {
+		c.ResponseError(errors.New("没有邀请成员的权限！"))
+		return
+	}
 	/**
 	判断群是否开启了邀请模式 如果开启了 再判断邀请的人是否是群主或管理员 如果不是则不允许直接添加群成员
 	**/
@@ -921,44 +1025,6 @@ func (g *Group) memberAdd(c *wkhttp.Context) {
 		return
 	}
 
-	// memberCount, err := g.db.QueryMemberCount(groupNo)
-	// if err != nil {
-	// 	g.Error("查询群成员数量失败！", zap.Error(err))
-	// 	c.ResponseError(errors.New("查询群成员数量失败！"))
-	// 	return
-	// }
-	// 普通群自动升级
-	// if memberCount >= int64(g.ctx.GetConfig().GroupUpgradeWhenMemberCount) && group.GroupType == int(GroupTypeCommon) {
-
-	// 	var ban = 0
-	// 	if group.Status == GroupStatusDisabled {
-	// 		ban = 1
-	// 	}
-	// 	err = g.ctx.IMCreateOrUpdateChannel(&config.ChannelCreateReq{
-	// 		ChannelID:   groupNo,
-	// 		ChannelType: common.ChannelTypeGroup.Uint8(),
-	// 		Ban:         ban,
-	// 		Large:       1,
-	// 	})
-	// 	if err != nil {
-	// 		g.Error("更新频道信息失败！", zap.Error(err))
-	// 		c.ResponseError(errors.New("更新频道信息失败！"))
-	// 		return
-	// 	}
-
-	// 	err = g.db.UpdateGroupType(groupNo, GroupTypeSuper)
-	// 	if err != nil {
-	// 		g.Error("修改群为超级群失败！", zap.Error(err), zap.String("groupNo", groupNo))
-	// 		c.ResponseError(errors.New("修改群为超级群失败！"))
-	// 		return
-	// 	}
-	// 	// 发送群升级通知
-	// 	err = g.ctx.SendGroupUpgrade(groupNo)
-	// 	if err != nil {
-	// 		g.Warn("发送群升级通知失败！", zap.Error(err))
-	// 	}
-	// }
-
 	c.ResponseOK()
 
 }
@@ -1048,15 +1114,26 @@ func (g *Group) addMembersTx(members []string, groupNo string, operator, operato
 		g.Error("查询成员用户信息失败！", zap.Error(err))
 		return nil, errors.New("查询成员用户信息失败！")
 	}
-	memberCount, err := g.db.QueryMemberCount(groupNo)
+	groupModel, err := g.db.QueryWithGroupNo(groupNo)
 	if err != nil {
-		g.Error("查询群成员数量失败！", zap.Error(err))
-		return nil, errors.New("查询群成员数量失败！")
+		g.Error("查询群信息失败！", zap.Error(err))
+		return nil, errors.New("查询群信息失败！")
+	}
+	if groupModel == nil {
+		return nil, errors.New("群不存在！")
+	}
+	if groupModel.isArchived() {
+		return nil, errors.New("群已归档，不能变更群成员！")
+	}
+	memberCount, err := g.checkMemberMaxCountTx(groupModel, len(realMembers), tx)
+	if err != nil {
+		return nil, err
 	}
 	/**
 	 将成员信息存到数据库
 	**/
 	userBaseVos := make([]*config.UserBaseVo, 0, len(realMembers))
+	rulesPendingUIDs := make([]string, 0) // 需要同意群规后才能发言的成员
 	for _, realMember := range realMemberModels {
 		version := g.ctx.GenSeq(common.GroupMemberSeqKey)
 
@@ -1078,6 +1155,10 @@ func (g *Group) addMembersTx(members []string, groupNo string, operator, operato
 			Status:    int(common.GroupMemberStatusNormal),
 			Robot:     realMember.Robot,
 		}
+		if groupModel.RulesRequired == 1 && realMember.Robot == 0 {
+			newMember.RulesPending = 1
+			rulesPendingUIDs = append(rulesPendingUIDs, realMember.UID)
+		}
 		if existDelete {
 			err = g.db.recoverMemberTx(newMember, tx)
 		} else {
@@ -1178,6 +1259,7 @@ func (g *Group) addMembersTx(members []string, groupNo string, operator, operato
 	}
 
 	return func() {
+		g.syncTopicSubscribers(groupNo, realMembers, true)
 		// 提交事件
 		g.ctx.EventCommit(eventID)
 		if groupAvatarEventID != 0 {
@@ -1186,6 +1268,10 @@ func (g *Group) addMembersTx(members []string, groupNo string, operator, operato
 		if unableAddDestroyAccount != 0 {
 			g.ctx.EventCommit(unableAddDestroyAccount)
 		}
+		if len(rulesPendingUIDs) > 0 {
+			g.limitRulesPendingMembers(groupNo, rulesPendingUIDs)
+		}
+		g.upgradeToSuperGroupIfNeed(groupNo)
 	}, nil
 }
 
@@ -1304,6 +1390,7 @@ func (g *Group) managerAdd(c *wkhttp.Context) {
 			}
 		}
 	}
+	g.addAuditLog(groupNo, loginUID, AuditActionManagerAdd, memberUIDs, nil)
 	c.ResponseOK()
 }
 
@@ -1395,6 +1482,7 @@ func (g *Group) managerRemove(c *wkhttp.Context) {
 			}
 		}
 	}
+	g.addAuditLog(groupNo, loginUID, AuditActionManagerRemove, memberUIDs, nil)
 	c.ResponseOK()
 }
 
@@ -1404,14 +1492,14 @@ func (g *Group) groupForbidden(c *wkhttp.Context) {
 	loginName := c.MustGet("name").(string)
 	groupNo := c.Param("group_no")
 	on := c.Param("on")
-	isCreatorOrManager, err := g.db.QueryIsGroupManagerOrCreator(groupNo, loginUID)
+	canMute, err := g.groupService.HasPermission(groupNo, loginUID, PermissionMuteMember)
 	if err != nil {
-		g.Error("查询是否是创建者失败！", zap.Error(err))
-		c.ResponseError(errors.New("查询是否是创建者失败！"))
+		g.Error("查询群权限失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询群权限失败！"))
 		return
 	}
-	if !isCreatorOrManager {
-		c.ResponseError(errors.New("只有创建者或管理员才能禁言！"))
+	if !canMute {
+		c.ResponseError(errors.New("没有禁言权限！"))
 		return
 	}
 	groupModel, err := g.getGroupInfo(groupNo)
@@ -1488,6 +1576,10 @@ func (g *Group) groupForbidden(c *wkhttp.Context) {
 	}
 	g.ctx.EventCommit(eventID)
 
+	g.addAuditLog(groupNo, loginUID, AuditActionGroupForbidden, nil, map[string]interface{}{
+		common.GroupAttrKeyForbidden: forbidden,
+	})
+
 	c.ResponseOK()
 }
 
@@ -1502,8 +1594,16 @@ func (g *Group) setIMWhitelistForGroupManager(groupNo string) error {
 
 // 重新设置群管理的白名单
 func (g *Group) resetIMWhitelist(whitelist []string, groupNo string) error {
+	groupModel, err := g.db.QueryWithGroupNo(groupNo)
+	if err != nil {
+		g.Error("查询群信息失败！", zap.Error(err))
+		return err
+	}
+	if groupModel != nil && groupModel.isArchived() { // 已归档的群只有系统账号可发言
+		whitelist = g.archivedWhitelist()
+	}
 	// 群全员禁言
-	err := g.ctx.IMWhitelistSet(config.ChannelWhitelistReq{
+	err = g.ctx.IMWhitelistSet(config.ChannelWhitelistReq{
 		ChannelReq: config.ChannelReq{
 			ChannelID:   groupNo,
 			ChannelType: common.ChannelTypeGroup.Uint8(),
@@ -1514,6 +1614,7 @@ func (g *Group) resetIMWhitelist(whitelist []string, groupNo string) error {
 		g.Error("设置白名单失败！", zap.Error(err))
 		return err
 	}
+	g.syncTopicWhitelist(groupNo, whitelist)
 	return nil
 
 }
@@ -1564,13 +1665,24 @@ func (g *Group) groupScanJoin(c *wkhttp.Context) {
 		c.ResponseError(errors.New("群编号不能为空"))
 		return
 	}
+	var applyReq struct {
+		Answers []string `json:"answers"` // 入群问题的回答
+		Remark  string   `json:"remark"`  // 申请备注
+	}
+	if c.Request.Method == http.MethodPost {
+		if err := c.BindJSON(&applyReq); err != nil {
+			g.Error("数据格式有误！", zap.Error(err))
+			c.ResponseError(errors.New("数据格式有误！"))
+			return
+		}
+	}
 	group, err := g.getGroupInfo(groupNo)
 	if err != nil {
 		c.ResponseError(err)
 		return
 	}
-	if group.Invite == 1 {
-		c.ResponseError(errors.New("群开启了邀请模式，不能直接加入群聊"))
+	if group.isArchived() {
+		c.ResponseError(errors.New("群已归档，不能变更群成员！"))
 		return
 	}
 	authInfo, err := g.ctx.GetRedisConn().GetString(fmt.Sprintf("%s%s", common.AuthCodeCachePrefix, authCode))
@@ -1620,6 +1732,40 @@ func (g *Group) groupScanJoin(c *wkhttp.Context) {
 		c.ResponseError(errors.New("已经在群内，不能再加入！"))
 		return
 	}
+	// 通过邀请链接入群
+	var inviteLink *InviteLinkModel
+	linkNo, _ := authMap["link_no"].(string)
+	if strings.TrimSpace(linkNo) != "" {
+		inviteLink, err = g.db.QueryInviteLinkWithLinkNo(linkNo)
+		if err != nil {
+			g.Error("查询邀请链接失败！", zap.Error(err))
+			c.ResponseError(errors.New("查询邀请链接失败！"))
+			return
+		}
+		if inviteLink == nil || inviteLink.GroupNo != groupNo {
+			c.ResponseError(errors.New("邀请链接不存在！"))
+			return
+		}
+		if err = inviteLink.CheckAvailable(); err != nil {
+			c.ResponseError(err)
+			return
+		}
+		// 创建者退群或不再有邀请权限后，其创建的邀请链接失效
+		canInvite, err := g.groupService.HasPermission(groupNo, inviteLink.Creator, PermissionInvite)
+		if err != nil {
+			g.Error("查询邀请链接创建者权限失败！", zap.Error(err))
+			c.ResponseError(errors.New("查询邀请链接创建者权限失败！"))
+			return
+		}
+		if !canInvite {
+			c.ResponseError(errors.New("邀请链接已失效！"))
+			return
+		}
+	}
+	if group.Invite == 1 && (inviteLink == nil || inviteLink.NeedApprove != 1) {
+		c.ResponseError(errors.New("群开启了邀请模式，不能直接加入群聊"))
+		return
+	}
 	// 查询生成二维码信息
 	generatorInfo, err := g.userDB.QueryByUID(generator)
 	if err != nil {
@@ -1642,31 +1788,58 @@ func (g *Group) groupScanJoin(c *wkhttp.Context) {
 		c.ResponseError(errors.New("扫码者信息不存在！"))
 		return
 	}
+	if inviteLink != nil && inviteLink.NeedApprove == 1 { // 需要审核的邀请链接，转为入群申请
+		applyModel, err := g.addJoinApply(group, scaner, scanerInfo.Name, applyReq.Answers, applyReq.Remark, inviteLink.LinkNo)
+		if err != nil {
+			c.ResponseError(err)
+			return
+		}
+		err = g.db.incrInviteLinkApplyCount(inviteLink.LinkNo)
+		if err != nil {
+			g.Warn("更新邀请链接申请次数失败！", zap.Error(err))
+		}
+		c.Response(newJoinApplyResp(&JoinApplyDetailModel{JoinApplyModel: *applyModel, Name: scanerInfo.Name}))
+		return
+	}
 
-	memberCount, err := g.db.QueryMemberCount(groupNo)
+	err = g.joinGroup(group, scaner, generator, inviteLink, &wkevent.Data{
+		Event: event.GroupMemberScanJoin,
+		Type:  wkevent.Message,
+		Data: config.MsgGroupMemberScanJoin{
+			GroupNo:       groupNo,
+			Generator:     generatorInfo.UID,
+			GeneratorName: generatorInfo.Name,
+			Scaner:        scanerInfo.UID,
+			ScanerName:    scanerInfo.Name,
+		},
+	})
 	if err != nil {
-		g.Error("查询成员数量！", zap.Error(err))
-		c.ResponseError(errors.New("查询成员数量！"))
+		c.ResponseError(err)
 		return
 	}
+	c.ResponseOK()
+}
 
+// joinGroup 将用户加入群（扫码入群和群目录入群共用），joinEvent为加入成功后提交的事件
+func (g *Group) joinGroup(group *Model, uid string, inviteUID string, inviteLink *InviteLinkModel, joinEvent *wkevent.Data) error {
+	groupNo := group.GroupNo
 	version := g.ctx.GenSeq(common.GroupMemberSeqKey)
 
 	memberModel := &MemberModel{
-		GroupNo:   groupNo,
-		UID:       scaner,
-		Role:      MemberRoleCommon,
-		Version:   version,
-		Status:    int(common.GroupMemberStatusNormal),
-		InviteUID: generator,
-		Vercode:   fmt.Sprintf("%s@%d", util.GenerUUID(), common.GroupMember),
+		GroupNo:      groupNo,
+		UID:          uid,
+		Role:         MemberRoleCommon,
+		Version:      version,
+		Status:       int(common.GroupMemberStatusNormal),
+		InviteUID:    inviteUID,
+		Vercode:      fmt.Sprintf("%s@%d", util.GenerUUID(), common.GroupMember),
+		RulesPending: group.RulesRequired,
 	}
 
 	tx, err := g.db.session.Begin()
 	if err != nil {
 		g.Error("开启事务失败！", zap.Error(err))
-		c.ResponseError(errors.New("开启事务失败！"))
-		return
+		return errors.New("开启事务失败！")
 	}
 	defer func() {
 		if err := recover(); err != nil {
@@ -1674,22 +1847,16 @@ func (g *Group) groupScanJoin(c *wkhttp.Context) {
 			panic(err)
 		}
 	}()
-	eventID, err := g.ctx.EventBegin(&wkevent.Data{
-		Event: event.GroupMemberScanJoin,
-		Type:  wkevent.Message,
-		Data: config.MsgGroupMemberScanJoin{
-			GroupNo:       groupNo,
-			Generator:     generatorInfo.UID,
-			GeneratorName: generatorInfo.Name,
-			Scaner:        scanerInfo.UID,
-			ScanerName:    scanerInfo.Name,
-		},
-	}, tx)
+	memberCount, err := g.checkMemberMaxCountTx(group, 1, tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	eventID, err := g.ctx.EventBegin(joinEvent, tx)
 	if err != nil {
 		tx.Rollback()
 		g.Error("开启事件事务失败！", zap.Error(err))
-		c.ResponseError(errors.New("开启事件事务失败！"))
-		return
+		return errors.New("开启事件事务失败！")
 	}
 	var groupAvatarEventID int64
 
@@ -1703,14 +1870,13 @@ func (g *Group) groupScanJoin(c *wkhttp.Context) {
 		if err != nil {
 			tx.Rollback()
 			g.Error("查询先存成员信息失败！", zap.String("group_no", groupNo), zap.Error(err))
-			c.ResponseError(errors.New("查询先存成员信息失败！"))
-			return
+			return errors.New("查询先存成员信息失败！")
 		}
 		members := make([]string, 0, len(oldMembers)+1)
 		for _, oldMember := range oldMembers {
 			members = append(members, oldMember.UID)
 		}
-		members = append(members, scanerInfo.UID)
+		members = append(members, uid)
 
 		groupAvatarEventID, err = g.ctx.EventBegin(&wkevent.Data{
 			Event: event.GroupAvatarUpdate,
@@ -1723,17 +1889,15 @@ func (g *Group) groupScanJoin(c *wkhttp.Context) {
 		if err != nil {
 			tx.Rollback()
 			g.Error("开启群成员头像更新事件失败！", zap.Error(err))
-			c.ResponseError(errors.New("开启群成员头像更新事件失败！"))
-			return
+			return errors.New("开启群成员头像更新事件失败！")
 		}
 	}
 
-	existDelete, err := g.db.ExistMemberDelete(scaner, groupNo)
+	existDelete, err := g.db.ExistMemberDelete(uid, groupNo)
 	if err != nil {
 		tx.Rollback()
 		g.Error("查询是否存在删除成员失败！", zap.Error(err))
-		c.ResponseError(errors.New("查询是否存在删除成员失败！"))
-		return
+		return errors.New("查询是否存在删除成员失败！")
 	}
 	if existDelete {
 		err = g.db.recoverMemberTx(memberModel, tx)
@@ -1743,34 +1907,47 @@ func (g *Group) groupScanJoin(c *wkhttp.Context) {
 	if err != nil {
 		tx.Rollback()
 		g.Error("添加群成员失败！", zap.Error(err))
-		c.ResponseError(errors.New("添加群成员失败！"))
-		return
+		return errors.New("添加群成员失败！")
+	}
+	if inviteLink != nil {
+		ok, err := g.db.incrInviteLinkJoinCountTx(inviteLink.LinkNo, tx)
+		if err != nil {
+			tx.Rollback()
+			g.Error("更新邀请链接入群人数失败！", zap.Error(err))
+			return errors.New("更新邀请链接入群人数失败！")
+		}
+		if !ok {
+			tx.Rollback()
+			return errors.New("邀请链接已失效或已达到使用次数上限！")
+		}
 	}
 	// 调用IM的添加订阅者
 	err = g.ctx.IMAddSubscriber(&config.SubscriberAddReq{
 		ChannelID:   groupNo,
 		ChannelType: common.ChannelTypeGroup.Uint8(),
-		Subscribers: []string{scaner},
+		Subscribers: []string{uid},
 	})
 	if err != nil {
 		tx.RollbackUnlessCommitted()
 		g.Error("调用IM的订阅接口失败！", zap.Error(err))
-		c.ResponseError(errors.New("调用IM的订阅接口失败！"))
-		return
+		return errors.New("调用IM的订阅接口失败！")
 	}
 
 	if err := tx.Commit(); err != nil {
 		tx.Rollback()
 		g.Error("提交事务失败！", zap.Error(err))
-		c.ResponseError(errors.New("提交事务失败！"))
-		return
+		return errors.New("提交事务失败！")
 	}
+	g.syncTopicSubscribers(groupNo, []string{uid}, true)
 	g.ctx.EventCommit(eventID)
 	if groupAvatarEventID != 0 {
 		g.ctx.EventCommit(groupAvatarEventID)
 	}
-
-	c.ResponseOK()
+	if memberModel.RulesPending == 1 {
+		g.limitRulesPendingMembers(groupNo, []string{uid})
+	}
+	g.upgradeToSuperGroupIfNeed(groupNo)
+	return nil
 }
 
 // 群主转让
@@ -1926,6 +2103,8 @@ func (g *Group) transferGrouper(c *wkhttp.Context) {
 		}
 	}
 
+	g.addAuditLog(groupNo, loginUID, AuditActionTransferGrouper, []string{toUID}, nil)
+
 	c.ResponseOK()
 
 }
@@ -2016,11 +2195,15 @@ func (g *Group) memberRemove(c *wkhttp.Context) {
 	req.Members = util.RemoveRepeatedElement(req.Members)
 
 	// 判断群是否存在
-	_, err := g.getGroupInfo(groupNo)
+	group, err := g.getGroupInfo(groupNo)
 	if err != nil {
 		c.ResponseError(err)
 		return
 	}
+	if group.isArchived() {
+		c.ResponseError(errors.New("群已归档，不能变更群成员！"))
+		return
+	}
 	var loginMember *MemberModel
 	// 查询操作者身份
 	// 这里要兼容后台管理系统的删除操作
@@ -2035,8 +2218,14 @@ func (g *Group) memberRemove(c *wkhttp.Context) {
 			c.ResponseError(errors.New("操作者不再此群"))
 			return
 		}
-		if loginMember.Role != int(common.GroupMemberRoleCreater) && loginMember.Role != int(common.GroupMemberRoleManager) {
-			c.ResponseError(errors.New("普通成员无法删除群成员"))
+		canRemove, err := g.groupService.HasPermission(groupNo, operator, PermissionRemoveMember)
+		if err != nil {
+			g.Error("查询群权限失败！", zap.Error(err))
+			c.ResponseError(errors.New("查询群权限失败！"))
+			return
+		}
+		if !canRemove {
+			c.ResponseError(errors.New("没有删除群成员的权限"))
 			return
 		}
 	}
@@ -2060,15 +2249,13 @@ func (g *Group) memberRemove(c *wkhttp.Context) {
 	if loginMember != nil {
 		// 验证权限
 		for _, member := range deleteMembers {
-			if loginMember.Role == int(common.GroupMemberRoleManager) {
-				if member.Role == int(common.GroupMemberRoleManager) {
-					c.ResponseError(errors.New("管理员不能删除管理员"))
-					return
-				}
-				if member.Role == int(common.GroupMemberRoleCreater) {
-					c.ResponseError(errors.New("管理员不能删除群主"))
-					return
-				}
+			if member.Role == int(common.GroupMemberRoleCreater) {
+				c.ResponseError(errors.New("不能删除群主"))
+				return
+			}
+			if member.Role == int(common.GroupMemberRoleManager) && loginMember.Role != int(common.GroupMemberRoleCreater) {
+				c.ResponseError(errors.New("只有群主才能删除管理员"))
+				return
 			}
 		}
 	}
@@ -2214,6 +2401,7 @@ func (g *Group) memberRemove(c *wkhttp.Context) {
 		c.ResponseError(errors.New("调用IM的移除订阅者接口失败！"))
 		return
 	}
+	g.syncTopicSubscribers(groupNo, req.Members, false)
 
 	//给被踢的成员发送被踢消息
 	err = g.ctx.SendGroupMemberBeRemove(groupMemberRemoveReq)
@@ -2221,6 +2409,8 @@ func (g *Group) memberRemove(c *wkhttp.Context) {
 		g.Warn("发送群成员被踢消息失败！", zap.Error(err))
 	}
 
+	g.addAuditLog(groupNo, operator, AuditActionMemberRemove, req.Members, nil)
+
 	c.ResponseOK()
 }
 
@@ -2322,6 +2512,9 @@ func (g *Group) groupSettingUpdate(c *wkhttp.Context) {
 				c.ResponseError(err)
 				return
 			}
+			g.addAuditLog(groupNo, loginUID, AuditActionSettingUpdate, nil, map[string]interface{}{
+				key: value,
+			})
 			continue
 		}
 	}
@@ -2354,6 +2547,7 @@ func (g *Group) groupExit(c *wkhttp.Context) {
 		c.ResponseError(errors.New("移除订阅者失败！"))
 		return
 	}
+	g.syncTopicSubscribers(groupNo, []string{loginUID}, false)
 	loginMember, err := g.db.QueryMemberWithUID(loginUID, groupNo)
 	if err != nil {
 		g.Error("查询是否存在群成员失败！", zap.Error(err))
@@ -2382,15 +2576,14 @@ func (g *Group) groupExit(c *wkhttp.Context) {
 	}
 
 	/**
-	如果退出的人是群主，则选择第二个入群的人作为群主。
+	如果退出的人是群主，则按群主继承策略选择新群主或解散群。
 	**/
-	var newGrouper *MemberModel // 新群主
+	var successor *SuccessorModel // 新群主
 	if loginMember.Role == MemberRoleCreator {
-		// 查询第二老成员
-		newGrouper, err = g.db.QuerySecondOldestMember(groupNo)
+		successor, err = g.querySuccessor(groupInfo, loginUID)
 		if err != nil {
-			g.Error("查询第二元老成员失败！", zap.Error(err))
-			c.ResponseError(errors.New("查询第二元老成员失败！"))
+			g.Error("查询群主继承人失败！", zap.Error(err))
+			c.ResponseError(errors.New("查询群主继承人失败！"))
 			return
 		}
 	}
@@ -2426,8 +2619,9 @@ func (g *Group) groupExit(c *wkhttp.Context) {
 		c.ResponseError(errors.New("开启事件事务失败！"))
 		return
 	}
-	if newGrouper != nil {
-		err = g.db.UpdateMemberRoleTx(groupNo, newGrouper.UID, MemberRoleCreator, version, tx)
+	var successionEventID int64
+	if loginMember.Role == MemberRoleCreator {
+		successionEventID, err = g.succeedGrouperTx(groupInfo, loginUID, c.GetLoginName(), successor, false, tx)
 		if err != nil {
 			tx.Rollback()
 			g.Error("更换新的群主失败！", zap.Error(err))
@@ -2467,6 +2661,12 @@ func (g *Group) groupExit(c *wkhttp.Context) {
 		return
 	}
 	g.ctx.EventCommit(eventID)
+	if successionEventID > 0 {
+		g.ctx.EventCommit(successionEventID)
+	}
+	if loginMember.Role == MemberRoleCreator {
+		g.afterGrouperSucceeded(groupInfo, loginUID, c.GetLoginName(), successor, GrouperLeaveReasonExit)
+	}
 	// 发送群成员更新命令
 	err = g.ctx.SendCMD(config.MsgCMDReq{
 		ChannelID:   groupNo,
@@ -2576,12 +2776,12 @@ func (g *Group) blacklist(c *wkhttp.Context) {
 		}
 		removeUIDs := make([]string, 0)
 		for _, member := range members {
-			if member.ForbiddenExpirTime == 0 {
+			if member.ForbiddenExpirTime == 0 && member.RulesPending == 0 {
 				removeUIDs = append(removeUIDs, member.UID)
 			}
 		}
 		if len(removeUIDs) > 0 {
-			err = g.setGroupBlacklist(groupNo, req.Uids, false)
+			err = g.setGroupBlacklist(groupNo, removeUIDs, false)
 			if err != nil {
 				g.Error("移除IM黑名单错误", zap.Error(err))
 				c.ResponseError(errors.New("移除IM黑名单错误"))
@@ -2623,6 +2823,11 @@ func (g *Group) blacklist(c *wkhttp.Context) {
 			}
 		}
 	}
+	auditAction := AuditActionBlacklistRemove
+	if action == "add" {
+		auditAction = AuditActionBlacklistAdd
+	}
+	g.addAuditLog(groupNo, loginUID, auditAction, req.Uids, nil)
 	c.ResponseOK()
 }
 
@@ -2714,7 +2919,13 @@ func (g *Group) forbiddenWithGroupMember(c *wkhttp.Context) {
 		c.ResponseError(errors.New("该成员不在群内"))
 		return
 	}
-	if loginGroupMember.Role == MemberRoleCommon || member.Role == MemberRoleCreator || loginGroupMember.Role == member.Role {
+	canMute, err := g.groupService.CanOperateMember(group.GroupNo, loginUID, member.UID, PermissionMuteMember)
+	if err != nil {
+		g.Error("查询群权限失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询群权限失败！"))
+		return
+	}
+	if !canMute || loginUID == member.UID {
 		c.ResponseError(errors.New("操作用户权限不够"))
 		return
 	}
@@ -2759,13 +2970,15 @@ func (g *Group) forbiddenWithGroupMember(c *wkhttp.Context) {
 		}
 	}
 
-	// 加入talk黑名单
-	uids := make([]string, 0)
-	uids = append(uids, req.MemberUID)
-	err = g.setGroupBlacklist(groupNo, uids, req.Action == 1)
-	if err != nil {
-		c.ResponseError(errors.New("设置IM黑名单错误"))
-		return
+	// 加入talk黑名单（待同意群规的成员解禁后仍不能发言）
+	if req.Action == 1 || member.RulesPending == 0 {
+		uids := make([]string, 0)
+		uids = append(uids, req.MemberUID)
+		err = g.setGroupBlacklist(groupNo, uids, req.Action == 1)
+		if err != nil {
+			c.ResponseError(errors.New("设置IM黑名单错误"))
+			return
+		}
 	}
 	err = g.ctx.SendCMD(config.MsgCMDReq{
 		ChannelID:   groupNo,
@@ -2781,6 +2994,13 @@ func (g *Group) forbiddenWithGroupMember(c *wkhttp.Context) {
 		c.ResponseError(errors.New("发送命令消息失败！"))
 		return
 	}
+	if req.Action == 1 {
+		g.addAuditLog(groupNo, loginUID, AuditActionMemberMute, []string{req.MemberUID}, map[string]interface{}{
+			"forbidden_expir_time": member.ForbiddenExpirTime,
+		})
+	} else {
+		g.addAuditLog(groupNo, loginUID, AuditActionMemberUnmute, []string{req.MemberUID}, nil)
+	}
 	c.ResponseOK()
 }
 
@@ -2809,7 +3029,7 @@ func (g *Group) CheckForbiddenLoop() {
 			}
 			uids := make([]string, 0)
 			uids = append(uids, model.UID)
-			if model.Status != int(common.GroupMemberStatusBlacklist) {
+			if model.Status != int(common.GroupMemberStatusBlacklist) && model.RulesPending == 0 {
 				err = g.setGroupBlacklist(model.GroupNo, uids, false)
 				if err != nil {
 					g.Warn("更新禁言成员新消息错误", zap.Error(err))
@@ -2854,6 +3074,7 @@ func (g *Group) setGroupBlacklist(groupNo string, uids []string, isAdd bool) err
 		g.Error("设置群黑名单错误", zap.Error(err))
 		return err
 	}
+	g.syncTopicBlacklist(groupNo, uids, isAdd)
 	return nil
 }
 
@@ -2870,6 +3091,23 @@ func (g *Group) getGroupInfo(groupNo string) (*Model, error) {
 	return group, nil
 }
 
+// 校验群是否存在以及操作者是否是群成员
+func (g *Group) checkGroupMember(groupNo string, uid string) error {
+	_, err := g.getGroupInfo(groupNo)
+	if err != nil {
+		return err
+	}
+	isMember, err := g.db.ExistMember(uid, groupNo)
+	if err != nil {
+		g.Error("查询是否是群成员失败！", zap.Error(err))
+		return errors.New("查询是否是群成员失败！")
+	}
+	if !isMember {
+		return errors.New("不是群成员！")
+	}
+	return nil
+}
+
 // ---------- vo ----------
 
 type groupDetailResp struct {
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"strconv"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
@@ -42,13 +43,19 @@ func NewManager(ctx *config.Context) *Manager {
 func (m *Manager) Route(r *wkhttp.WKHttp) {
 	auth := r.Group("/v1/manager", m.ctx.AuthMiddleware(r))
 	{
-		auth.GET("/group/list", m.list)                              // 群列表
-		auth.GET("/group/disablelist", m.disablelist)                // 封禁群列表
-		auth.PUT("/group/liftban/:groupNo/:status", m.leftbangroup)  // 封禁或解禁某个群
-		auth.PUT("/groups/:group_no/forbidden/:on", m.forbidden)     // 群全员禁言
-		auth.GET("/groups/:group_no/members", m.members)             // 群成员
-		auth.GET("/groups/:group_no/members/blacklist", m.blacklist) // 群黑名单成员
-		auth.DELETE("/groups/:group_no/members", m.removeMember)     // 移除群成员
+		auth.GET("/group/list", m.list)                                  // 群列表
+		auth.GET("/group/disablelist", m.disablelist)                    // 封禁群列表
+		auth.PUT("/group/liftban/:groupNo/:status", m.leftbangroup)      // 封禁或解禁某个群
+		auth.PUT("/groups/:group_no/forbidden/:on", m.forbidden)         // 群全员禁言
+		auth.GET("/groups/:group_no/members", m.members)                 // 群成员
+		auth.GET("/groups/:group_no/members/blacklist", m.blacklist)     // 群黑名单成员
+		auth.DELETE("/groups/:group_no/members", m.removeMember)         // 移除群成员
+		auth.GET("/group/publiclist", m.publicList)                      // 公开群列表（群目录）
+		auth.PUT("/groups/:group_no/public_ban/:on", m.publicBan)        // 禁止或允许群在群目录展示
+		auth.GET("/groups/:group_no/audit_logs", m.auditLogs)            // 群管理操作日志
+		auth.PUT("/groups/:group_no/member_max_count", m.memberMaxCount) // 设置群成员数量上限
+		auth.PUT("/groups/:group_no/upgrade", m.upgrade)                 // 升级为超级群
+		auth.PUT("/groups/:group_no/archive/:on", m.archive)             // 归档或取消归档群
 	}
 }
 
@@ -153,6 +160,8 @@ func (m *Manager) getRespList(list []*managerGroupModel) ([]*managerGroupResp, e
 				Status:      group.Status,
 				Forbidden:   group.Forbidden,
 				MemberCount: count,
+				IsPublic:    group.IsPublic,
+				PublicBan:   group.PublicBan,
 			})
 		}
 	}
@@ -190,6 +199,202 @@ func (m *Manager) disablelist(c *wkhttp.Context) {
 	})
 }
 
+// 公开群列表
+func (m *Manager) publicList(c *wkhttp.Context) {
+	err := c.CheckLoginRole()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	keyword := c.Query("keyword")
+	pageIndex, pageSize := c.GetPage()
+	list, err := m.managerDB.queryPublicGroupsWithPage(keyword, uint64(pageSize), uint64(pageIndex))
+	if err != nil {
+		m.Error("查询公开群列表错误", zap.Error(err))
+		c.ResponseError(errors.New("查询公开群列表错误"))
+		return
+	}
+	count, err := m.managerDB.queryPublicGroupCount(keyword)
+	if err != nil {
+		m.Error("查询公开群数量错误", zap.Error(err))
+		c.ResponseError(errors.New("查询公开群数量错误"))
+		return
+	}
+	result, err := m.getRespList(list)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.Response(map[string]interface{}{
+		"count": count,
+		"list":  result,
+	})
+}
+
+// 禁止或允许群在群目录展示
+func (m *Manager) publicBan(c *wkhttp.Context) {
+	err := c.CheckLoginRoleIsSuperAdmin()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	groupNo := c.Param("group_no")
+	on, _ := strconv.Atoi(c.Param("on"))
+	if on != 0 && on != 1 {
+		c.ResponseError(errors.New("未知操作类型"))
+		return
+	}
+	group, err := m.db.QueryWithGroupNo(groupNo)
+	if err != nil {
+		m.Error("查询群信息错误", zap.Error(err))
+		c.ResponseError(errors.New("查询群信息错误"))
+		return
+	}
+	if group == nil {
+		c.ResponseError(errors.New("操作的群不存在"))
+		return
+	}
+	err = m.managerDB.updatePublicBan(groupNo, on)
+	if err != nil {
+		m.Error("修改群目录展示状态错误", zap.Error(err))
+		c.ResponseError(errors.New("修改群目录展示状态错误"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 设置群成员数量上限
+func (m *Manager) memberMaxCount(c *wkhttp.Context) {
+	err := c.CheckLoginRoleIsSuperAdmin()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req struct {
+		MemberMaxCount int `json:"member_max_count"` // 群成员数量上限 0.使用全局配置
+	}
+	if err := c.BindJSON(&req); err != nil {
+		m.Error(common.ErrData.Error(), zap.Error(err))
+		c.ResponseError(common.ErrData)
+		return
+	}
+	if req.MemberMaxCount < 0 {
+		c.ResponseError(errors.New("群成员数量上限不能小于0"))
+		return
+	}
+	groupNo := c.Param("group_no")
+	group, err := m.db.QueryWithGroupNo(groupNo)
+	if err != nil {
+		m.Error("查询群信息错误", zap.Error(err))
+		c.ResponseError(errors.New("查询群信息错误"))
+		return
+	}
+	if group == nil {
+		c.ResponseError(errors.New("操作的群不存在"))
+		return
+	}
+	err = m.db.updateMemberMaxCount(groupNo, req.MemberMaxCount, m.ctx.GenSeq(common.GroupSeqKey))
+	if err != nil {
+		m.Error("修改群成员数量上限错误", zap.Error(err))
+		c.ResponseError(errors.New("修改群成员数量上限错误"))
+		return
+	}
+	err = m.ctx.SendChannelUpdateToGroup(groupNo)
+	if err != nil {
+		m.Warn("发送频道更新命令失败！", zap.Error(err))
+	}
+	c.ResponseOK()
+}
+
+// 普通群升级为超级群
+func (m *Manager) upgrade(c *wkhttp.Context) {
+	err := c.CheckLoginRoleIsSuperAdmin()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	groupNo := c.Param("group_no")
+	group, err := m.db.QueryWithGroupNo(groupNo)
+	if err != nil {
+		m.Error("查询群信息错误", zap.Error(err))
+		c.ResponseError(errors.New("查询群信息错误"))
+		return
+	}
+	if group == nil {
+		c.ResponseError(errors.New("操作的群不存在"))
+		return
+	}
+	if group.GroupType == int(GroupTypeSuper) {
+		c.ResponseError(errors.New("该群已经是超级群"))
+		return
+	}
+	err = upgradeToSuperGroup(m.ctx, m.db, group, "本群已升级为超级群")
+	if err != nil {
+		m.Error("升级超级群错误", zap.Error(err), zap.String("group_no", groupNo))
+		c.ResponseError(errors.New("升级超级群错误"))
+		return
+	}
+	err = m.db.insertAuditLog(&AuditLogModel{
+		GroupNo:  groupNo,
+		Operator: c.GetLoginUID(),
+		Action:   string(AuditActionGroupUpgrade),
+	})
+	if err != nil {
+		m.Warn("记录群管理操作日志失败", zap.Error(err))
+	}
+	c.ResponseOK()
+}
+
+// 归档或取消归档群（转给群的归档接口处理）
+func (m *Manager) archive(c *wkhttp.Context) {
+	err := c.CheckLoginRoleIsSuperAdmin()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	on := c.Param("on")
+	if on != "0" && on != "1" {
+		c.ResponseError(errors.New("未知操作类型"))
+		return
+	}
+	c.Request.Method = http.MethodPost
+	c.Request.URL.Path = fmt.Sprintf("/v1/groups/%s/archive/%s", c.Param("group_no"), on)
+	m.ctx.GetHttpRoute().HandleContext(c)
+}
+
+// 群管理操作日志
+func (m *Manager) auditLogs(c *wkhttp.Context) {
+	err := c.CheckLoginRole()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	query := newAuditLogQuery(c, c.Param("group_no"))
+	pageIndex, pageSize := c.GetPage()
+	models, err := m.db.queryAuditLogsWithPage(query, uint64(pageSize), uint64(pageIndex))
+	if err != nil {
+		m.Error("查询群操作日志错误", zap.Error(err))
+		c.ResponseError(errors.New("查询群操作日志错误"))
+		return
+	}
+	count, err := m.db.queryAuditLogCount(query)
+	if err != nil {
+		m.Error("查询群操作日志数量错误", zap.Error(err))
+		c.ResponseError(errors.New("查询群操作日志数量错误"))
+		return
+	}
+	list, err := newAuditLogResps(models, m.userDB)
+	if err != nil {
+		m.Error("查询操作对象错误", zap.Error(err))
+		c.ResponseError(errors.New("查询操作对象错误"))
+		return
+	}
+	c.Response(map[string]interface{}{
+		"count": count,
+		"list":  list,
+	})
+}
+
 // 封禁或解禁某个群
 func (m *Manager) leftbangroup(c *wkhttp.Context) {
 	err := c.CheckLoginRoleIsSuperAdmin()
@@ -222,6 +427,10 @@ func (m *Manager) leftbangroup(c *wkhttp.Context) {
 		c.ResponseError(errors.New("未知操作类型"))
 		return
 	}
+	if group.Status == GroupStatusArchived { // 封禁和解禁会覆盖群状态，已归档的群需先取消归档
+		c.ResponseError(errors.New("群已归档，请先取消归档"))
+		return
+	}
 
 	if groupStatus == group.Status {
 		c.ResponseOK()
@@ -540,6 +749,8 @@ type managerGroupResp struct {
 	Status      int    `json:"status"`
 	MemberCount int    `json:"member_count"`
 	Forbidden   int    `json:"forbidden"`
+	IsPublic    int    `json:"is_public"`  // 是否公开到群目录
+	PublicBan   int    `json:"public_ban"` // 是否被禁止在群目录展示
 }
 
 type managerMemberResp struct {
@@ -3,10 +3,12 @@ package group
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkevent"
 	"go.uber.org/zap"
 )
@@ -68,6 +70,7 @@ func (g *groupUpdateContext) isManager() (bool, error) {
 	return isManager, nil
 }
 
+// 校验操作者是否是群主或管理员（群设置类操作不在权限矩阵中，只允许群主和管理员修改）
 func (g *groupUpdateContext) checkPermissions() error {
 	isManager, err := g.isManager()
 	if err != nil {
@@ -79,6 +82,32 @@ func (g *groupUpdateContext) checkPermissions() error {
 	return nil
 }
 
+// 校验操作者是否拥有指定的群权限
+func (g *groupUpdateContext) checkPermission(permission Permission) error {
+	has, err := g.g.groupService.HasPermission(g.groupModel.GroupNo, g.loginUID, permission)
+	if err != nil {
+		g.g.Error("查询群权限失败！", zap.Error(err))
+		return err
+	}
+	if !has {
+		return errors.New("没有权限！")
+	}
+	return nil
+}
+
+// 校验操作者是否是群主
+func (g *groupUpdateContext) checkCreator(msg string) error {
+	isCreator, err := g.g.db.QueryIsGroupCreator(g.groupModel.GroupNo, g.loginUID)
+	if err != nil {
+		g.g.Error("查询是否是群主失败！", zap.Error(err))
+		return err
+	}
+	if !isCreator {
+		return errors.New(msg)
+	}
+	return nil
+}
+
 func (g *groupUpdateContext) updateGroup() error {
 	return g.g.db.Update(g.groupModel)
 }
@@ -185,7 +214,7 @@ var settingActionMap = map[string]groupSettingActionFnc{
 
 var groupUpdateActionMap = map[string]groupUpdateActionFnc{
 	common.GroupAttrKeyForbidden: func(ctx *groupUpdateContext, value interface{}) error { // 群内禁言
-		if err := ctx.checkPermissions(); err != nil {
+		if err := ctx.checkPermission(PermissionMuteMember); err != nil {
 			return err
 		}
 		ctx.groupModel.Forbidden = int(value.(float64))
@@ -276,4 +305,167 @@ var groupUpdateActionMap = map[string]groupUpdateActionFnc{
 		// 通知群内成员更新频道
 		return ctx.g.ctx.SendChannelUpdateToGroup(groupNo)
 	},
+	GroupAttrKeyJoinApply: func(ctx *groupUpdateContext, value interface{}) error { // 允许申请入群
+		if err := ctx.checkPermissions(); err != nil {
+			return err
+		}
+		ctx.groupModel.JoinApply = int(value.(float64))
+		err := ctx.updateGroup()
+		if err != nil {
+			return err
+		}
+		return ctx.g.ctx.SendChannelUpdateToGroup(ctx.groupModel.GroupNo)
+	},
+	GroupAttrKeyIsPublic: func(ctx *groupUpdateContext, value interface{}) error { // 公开到群目录
+		if err := ctx.checkPermissions(); err != nil {
+			return err
+		}
+		ctx.groupModel.IsPublic = int(value.(float64))
+		err := ctx.updateGroup()
+		if err != nil {
+			return err
+		}
+		return ctx.g.ctx.SendChannelUpdateToGroup(ctx.groupModel.GroupNo)
+	},
+	GroupAttrKeySlowMode: func(ctx *groupUpdateContext, value interface{}) error { // 慢速模式
+		if err := ctx.checkPermissions(); err != nil {
+			return err
+		}
+		slowMode := int(value.(float64))
+		if slowMode < 0 || slowMode > SlowModeMaxSecond {
+			return fmt.Errorf("慢速模式间隔需在0到%d秒之间！", SlowModeMaxSecond)
+		}
+		if ctx.groupModel.SlowMode == slowMode {
+			return nil
+		}
+		ctx.groupModel.SlowMode = slowMode
+		err := ctx.updateGroup()
+		if err != nil {
+			return err
+		}
+		groupNo := ctx.groupModel.GroupNo
+		if slowMode == 0 {
+			ctx.g.releaseSlowModeGroup(groupNo)
+		}
+		err = ctx.g.sendSlowModeTip(groupNo, ctx.loginUID, ctx.loginName, slowMode)
+		if err != nil {
+			ctx.g.Warn("发送慢速模式提示失败！", zap.Error(err))
+		}
+		// 通知群内成员更新频道
+		return ctx.g.ctx.SendChannelUpdateToGroup(groupNo)
+	},
+	GroupAttrKeySuccessionPolicy: func(ctx *groupUpdateContext, value interface{}) error { // 群主继承策略（仅群主可设置）
+		isCreator, err := ctx.g.db.QueryIsGroupCreator(ctx.groupModel.GroupNo, ctx.loginUID)
+		if err != nil {
+			return err
+		}
+		if !isCreator {
+			return errors.New("只有群主才能设置群主继承策略！")
+		}
+		policy := int(value.(float64))
+		if policy != SuccessionPolicyManagerFirst && policy != SuccessionPolicyMember && policy != SuccessionPolicyDisband {
+			return errors.New("群主继承策略有误！")
+		}
+		ctx.groupModel.SuccessionPolicy = policy
+		err = ctx.updateGroup()
+		if err != nil {
+			return err
+		}
+		return ctx.g.ctx.SendChannelUpdateToGroup(ctx.groupModel.GroupNo)
+	},
+	GroupAttrKeyTopicMode: func(ctx *groupUpdateContext, value interface{}) error { // 话题模式（关闭后已有话题保留，但不能再创建新话题）
+		if err := ctx.checkPermissions(); err != nil {
+			return err
+		}
+		topicMode := int(value.(float64))
+		if topicMode != 0 && topicMode != 1 {
+			return errors.New("话题模式参数有误！")
+		}
+		ctx.groupModel.TopicMode = topicMode
+		err := ctx.updateGroup()
+		if err != nil {
+			return err
+		}
+		return ctx.g.ctx.SendChannelUpdateToGroup(ctx.groupModel.GroupNo)
+	},
+	GroupAttrKeyRevokeSecond: func(ctx *groupUpdateContext, value interface{}) error { // 普通成员消息可撤回时长
+		if err := ctx.checkPermissions(); err != nil {
+			return err
+		}
+		revokeSecond, err := parseRevokeSecond(value)
+		if err != nil {
+			return err
+		}
+		ctx.groupModel.RevokeSecond = revokeSecond
+		err = ctx.updateGroup()
+		if err != nil {
+			return err
+		}
+		return ctx.g.ctx.SendChannelUpdateToGroup(ctx.groupModel.GroupNo)
+	},
+	GroupAttrKeyManagerRevokeSecond: func(ctx *groupUpdateContext, value interface{}) error { // 管理员消息可撤回时长（仅群主可设置）
+		if err := ctx.checkCreator("只有群主才能设置管理员消息可撤回时长！"); err != nil {
+			return err
+		}
+		revokeSecond, err := parseRevokeSecond(value)
+		if err != nil {
+			return err
+		}
+		ctx.groupModel.ManagerRevokeSecond = revokeSecond
+		err = ctx.updateGroup()
+		if err != nil {
+			return err
+		}
+		return ctx.g.ctx.SendChannelUpdateToGroup(ctx.groupModel.GroupNo)
+	},
+	GroupAttrKeyCreatorRevokeSecond: func(ctx *groupUpdateContext, value interface{}) error { // 群主消息可撤回时长（仅群主可设置）
+		if err := ctx.checkCreator("只有群主才能设置群主消息可撤回时长！"); err != nil {
+			return err
+		}
+		revokeSecond, err := parseRevokeSecond(value)
+		if err != nil {
+			return err
+		}
+		ctx.groupModel.CreatorRevokeSecond = revokeSecond
+		err = ctx.updateGroup()
+		if err != nil {
+			return err
+		}
+		return ctx.g.ctx.SendChannelUpdateToGroup(ctx.groupModel.GroupNo)
+	},
+	GroupAttrKeyJoinQuestions: func(ctx *groupUpdateContext, value interface{}) error { // 入群问题
+		if err := ctx.checkPermissions(); err != nil {
+			return err
+		}
+		questionObjs, _ := value.([]interface{})
+		if len(questionObjs) > JoinQuestionMaxCount {
+			return fmt.Errorf("入群问题最多设置%d个！", JoinQuestionMaxCount)
+		}
+		questions := make([]string, 0, len(questionObjs))
+		for _, questionObj := range questionObjs {
+			question, _ := questionObj.(string)
+			if strings.TrimSpace(question) == "" {
+				return errors.New("入群问题不能为空！")
+			}
+			questions = append(questions, question)
+		}
+		ctx.groupModel.JoinQuestions = ""
+		if len(questions) > 0 {
+			ctx.groupModel.JoinQuestions = util.ToJson(questions)
+		}
+		err := ctx.updateGroup()
+		if err != nil {
+			return err
+		}
+		return ctx.g.ctx.SendChannelUpdateToGroup(ctx.groupModel.GroupNo)
+	},
+}
+
+// 解析消息可撤回时长（0表示使用全局配置）
+func parseRevokeSecond(value interface{}) (int, error) {
+	revokeSecond, ok := value.(float64)
+	if !ok || revokeSecond < 0 || revokeSecond > RevokeSecondMax {
+		return 0, fmt.Errorf("消息可撤回时长需在0到%d秒之间！", RevokeSecondMax)
+	}
+	return int(revokeSecond), nil
 }
@@ -2,6 +2,7 @@ package group
 
 import (
 	"bytes"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -9,6 +10,7 @@ import (
 	"time"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/testutil"
 	"github.com/stretchr/testify/assert"
@@ -319,3 +321,576 @@ func TestList(t *testing.T) {
 	assert.Equal(t, true, strings.Contains(w.Body.String(), `"name":`))
 
 }
+
+// 准备一个正常状态的群及群成员（key为成员uid value为成员角色）
+func prepareGroup(t *testing.T, f *Group, groupNo string, members map[string]int) {
+	err := f.db.Insert(&Model{
+		GroupNo: groupNo,
+		Name:    "test",
+		Creator: testutil.UID,
+		Version: 1,
+		Status:  GroupStatusNormal,
+	})
+	assert.NoError(t, err)
+	for memberUID, role := range members {
+		err = f.db.InsertMember(&MemberModel{
+			GroupNo: groupNo,
+			UID:     memberUID,
+			Role:    role,
+			Status:  int(common.GroupMemberStatusNormal),
+		})
+		assert.NoError(t, err)
+	}
+}
+
+func serveGroup(s http.Handler, method string, path string, body interface{}, token string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	var reader *bytes.Reader
+	if body != nil {
+		reader = bytes.NewReader([]byte(util.ToJson(body)))
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, _ := http.NewRequest(method, path, reader)
+	req.Header.Set("token", token)
+	s.ServeHTTP(w, req)
+	return w
+}
+
+func TestSlowMode(t *testing.T) {
+	s, ctx := testutil.NewTestServer()
+	f := New(ctx)
+	f.Route(s.GetRoute())
+	prepareGroup(t, f, "g1", map[string]int{testutil.UID: MemberRoleCommon})
+
+	// 普通成员不能设置慢速模式
+	w := serveGroup(s.GetRoute(), "PUT", "/v1/groups/g1/setting", map[string]interface{}{GroupAttrKeySlowMode: 10}, testutil.Token)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 间隔超出范围
+	err := f.db.UpdateMember(&MemberModel{GroupNo: "g1", UID: testutil.UID, Role: MemberRoleCreator, Status: int(common.GroupMemberStatusNormal)})
+	assert.NoError(t, err)
+	w = serveGroup(s.GetRoute(), "PUT", "/v1/groups/g1/setting", map[string]interface{}{GroupAttrKeySlowMode: SlowModeMaxSecond + 1}, testutil.Token)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	groupModel, err := f.db.QueryWithGroupNo("g1")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, groupModel.SlowMode)
+
+	// 限制时间内返回解除时间，到期后返回0
+	service := NewService(ctx)
+	releaseAt := time.Now().Unix() + 30
+	err = ctx.GetRedisConn().Hset(SlowModeLimitCachePrefix+"g1", "u1", fmt.Sprintf("%d", releaseAt))
+	assert.NoError(t, err)
+	err = ctx.GetRedisConn().Hset(SlowModeLimitCachePrefix+"g1", "u2", fmt.Sprintf("%d", time.Now().Unix()-1))
+	assert.NoError(t, err)
+	limitReleaseAt, err := service.GetSlowModeReleaseAt("g1", "u1")
+	assert.NoError(t, err)
+	assert.Equal(t, releaseAt, limitReleaseAt)
+	limitReleaseAt, err = service.GetSlowModeReleaseAt("g1", "u2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), limitReleaseAt)
+	limitReleaseAt, err = service.GetSlowModeReleaseAt("g1", "u3")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), limitReleaseAt)
+	uids, err := f.getSlowModeLimitedUIDs("g1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"u1"}, uids)
+}
+
+func TestTopicDelete(t *testing.T) {
+	s, ctx := testutil.NewTestServer()
+	f := New(ctx)
+	f.Route(s.GetRoute())
+	prepareGroup(t, f, "g1", map[string]int{testutil.UID: MemberRoleCommon})
+	tx, err := ctx.DB().Begin()
+	assert.NoError(t, err)
+	err = f.db.insertTopicTx(&TopicModel{
+		TopicNo: "t1",
+		GroupNo: "g1",
+		Title:   "话题",
+		Creator: testutil.UID,
+		Status:  TopicStatusOpen,
+	}, tx)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit())
+
+	// 没有管理话题权限的成员不能删除
+	w := serveGroup(s.GetRoute(), "DELETE", "/v1/groups/g1/topics/t1", nil, testutil.Token)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "没有管理话题的权限")
+
+	err = f.db.UpdateMember(&MemberModel{GroupNo: "g1", UID: testutil.UID, Role: MemberRoleCreator})
+	assert.NoError(t, err)
+	w = serveGroup(s.GetRoute(), "DELETE", "/v1/groups/g1/topics/t1", nil, testutil.Token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	topicModel, err := f.db.queryTopicWithTopicNo("t1")
+	assert.NoError(t, err)
+	assert.Nil(t, topicModel)
+}
+
+func TestMemberMaxCount(t *testing.T) {
+	s, ctx := testutil.NewTestServer()
+	f := New(ctx)
+	f.Route(s.GetRoute())
+	prepareGroup(t, f, "g1", map[string]int{testutil.UID: MemberRoleCreator})
+	groupModel, err := f.db.QueryWithGroupNo("g1")
+	assert.NoError(t, err)
+	groupModel.MemberMaxCount = 2
+
+	tx, err := ctx.DB().Begin()
+	assert.NoError(t, err)
+	memberCount, err := f.checkMemberMaxCountTx(groupModel, 1, tx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), memberCount)
+
+	// 超出上限
+	_, err = f.checkMemberMaxCountTx(groupModel, 2, tx)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "群成员数量已达上限")
+	assert.NoError(t, tx.Rollback())
+}
+
+func TestInviteLinkCheckAvailable(t *testing.T) {
+	link := &InviteLinkModel{Status: InviteLinkStatusNormal}
+	assert.NoError(t, link.CheckAvailable())
+
+	link.ExpireAt = time.Now().Add(-time.Minute).Unix()
+	assert.EqualError(t, link.CheckAvailable(), "邀请链接已过期！")
+
+	link.ExpireAt = time.Now().Add(time.Minute).Unix()
+	link.MaxUses = 2
+	link.JoinCount = 2
+	assert.EqualError(t, link.CheckAvailable(), "邀请链接已达到使用次数上限！")
+
+	link.JoinCount = 1
+	assert.NoError(t, link.CheckAvailable())
+
+	link.Status = InviteLinkStatusRevoked
+	assert.EqualError(t, link.CheckAvailable(), "邀请链接已被撤销！")
+}
+
+func TestScanJoinInviteLinkCreatorLeft(t *testing.T) {
+	s, ctx := testutil.NewTestServer()
+	f := New(ctx)
+	f.Route(s.GetRoute())
+	prepareGroup(t, f, "g1", map[string]int{"10001": MemberRoleCreator})
+	err := f.db.insertInviteLink(&InviteLinkModel{
+		LinkNo:  "l1",
+		GroupNo: "g1",
+		Creator: "10002", // 创建者已退群
+		Status:  InviteLinkStatusNormal,
+	})
+	assert.NoError(t, err)
+	err = ctx.GetRedisConn().SetAndExpire(common.AuthCodeCachePrefix+"code1", util.ToJson(map[string]interface{}{
+		"type":      common.AuthCodeTypeJoinGroup,
+		"group_no":  "g1",
+		"generator": "10002",
+		"scaner":    testutil.UID,
+		"link_no":   "l1",
+	}), time.Minute)
+	assert.NoError(t, err)
+
+	w := serveGroup(s.GetRoute(), "GET", "/v1/groups/g1/scanjoin?auth_code=code1", nil, testutil.Token)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "邀请链接已失效")
+}
+
+func TestDirectory(t *testing.T) {
+	s, ctx := testutil.NewTestServer()
+	f := New(ctx)
+	f.Route(s.GetRoute())
+	groups := []*Model{
+		{GroupNo: "g1", Name: "golang交流", Category: "tech", IsPublic: 1},
+		{GroupNo: "g2", Name: "读书会", Category: "life", IsPublic: 1, JoinApply: 1},
+		{GroupNo: "g3", Name: "golang内部", Category: "tech"},                            // 未公开
+		{GroupNo: "g4", Name: "golang违规", Category: "tech", IsPublic: 1, PublicBan: 1}, // 被后台禁止展示
+	}
+	for _, group := range groups {
+		group.Creator = "10001"
+		group.Version = 1
+		group.Status = GroupStatusNormal
+		err := f.db.Insert(group)
+		assert.NoError(t, err)
+	}
+	for _, memberUID := range []string{"10001", "10002"} {
+		err := f.db.InsertMember(&MemberModel{
+			GroupNo: "g1",
+			UID:     memberUID,
+			Role:    MemberRoleCommon,
+			Status:  int(common.GroupMemberStatusNormal),
+		})
+		assert.NoError(t, err)
+	}
+
+	// 只展示公开且未被禁止的群
+	w := serveGroup(s.GetRoute(), "GET", "/v1/group/directory?keyword=golang", nil, testutil.Token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"count":1`)
+	assert.Contains(t, w.Body.String(), `"group_no":"g1"`)
+	assert.Contains(t, w.Body.String(), `"join_apply":0`)
+
+	w = serveGroup(s.GetRoute(), "GET", "/v1/group/directory?category=life", nil, testutil.Token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"group_no":"g2"`)
+	assert.NotContains(t, w.Body.String(), `"group_no":"g1"`)
+
+	w = serveGroup(s.GetRoute(), "GET", "/v1/group/directory?min_member_count=2", nil, testutil.Token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"count":1`)
+	assert.Contains(t, w.Body.String(), `"member_count":2`)
+
+	w = serveGroup(s.GetRoute(), "GET", "/v1/group/directory?min_member_count=5&max_member_count=1", nil, testutil.Token)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 开启入群申请的群只能申请，未开启的群不能申请
+	w = serveGroup(s.GetRoute(), "POST", "/v1/groups/g2/join", nil, testutil.Token)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "该群需要申请入群")
+	w = serveGroup(s.GetRoute(), "POST", "/v1/groups/g1/join_apply", map[string]interface{}{}, testutil.Token)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "该群未开启申请入群")
+
+	// 未公开的群不能直接加入
+	w = serveGroup(s.GetRoute(), "POST", "/v1/groups/g3/join", nil, testutil.Token)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 公开且无需申请的群可直接加入
+	w = serveGroup(s.GetRoute(), "POST", "/v1/groups/g1/join", nil, testutil.Token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	exist, err := f.db.ExistMember(testutil.UID, "g1")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+	w = serveGroup(s.GetRoute(), "GET", "/v1/group/directory?keyword=golang", nil, testutil.Token)
+	assert.Contains(t, w.Body.String(), `"joined":1`)
+}
+
+func TestMuteScheduleSkipArchived(t *testing.T) {
+	_, ctx := testutil.NewTestServer()
+	f := New(ctx)
+	err := f.db.Insert(&Model{
+		GroupNo: "g1",
+		Name:    "test",
+		Creator: testutil.UID,
+		Version: 1,
+		Status:  GroupStatusArchived,
+	})
+	assert.NoError(t, err)
+	now := time.Now()
+	model := &MuteScheduleModel{
+		ScheduleNo:  "s1",
+		GroupNo:     "g1",
+		Creator:     testutil.UID,
+		Name:        "夜间禁言",
+		Type:        MuteScheduleTypeRecurring,
+		Cron:        "0 23 * * *",
+		Duration:    60,
+		Timezone:    "Asia/Shanghai",
+		NextStartAt: now.Add(-time.Minute).Unix(),
+		NextEndAt:   now.Add(time.Minute).Unix(),
+		Status:      MuteScheduleStatusNormal,
+	}
+	err = f.db.insertMuteSchedule(model)
+	assert.NoError(t, err)
+
+	// 已归档的群跳过本次禁言，计划顺延到下一个时间段
+	f.startMuteSchedule(model, now)
+	groupModel, err := f.db.QueryWithGroupNo("g1")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, groupModel.Forbidden)
+	model, err = f.db.queryMuteScheduleWithScheduleNo("s1")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, model.Active)
+	assert.Equal(t, MuteScheduleStatusNormal, model.Status)
+	assert.True(t, model.NextStartAt > now.Unix())
+}
+
+func TestReleaseRulesPendingMemberWithSlowMode(t *testing.T) {
+	_, ctx := testutil.NewTestServer()
+	f := New(ctx)
+	prepareGroup(t, f, "g1", map[string]int{testutil.UID: MemberRoleCommon})
+	err := ctx.GetRedisConn().Hset(fmt.Sprintf("%s%s", SlowModeLimitCachePrefix, "g1"), testutil.UID, fmt.Sprintf("%d", time.Now().Add(time.Minute).Unix()))
+	assert.NoError(t, err)
+	defer ctx.GetRedisConn().Hdel(fmt.Sprintf("%s%s", SlowModeLimitCachePrefix, "g1"), testutil.UID)
+
+	// 仍在慢速模式限制中，同意群规后不移出IM黑名单，也不会报错
+	releaseAt, err := f.groupService.GetSlowModeReleaseAt("g1", testutil.UID)
+	assert.NoError(t, err)
+	assert.True(t, releaseAt > time.Now().Unix())
+	err = f.releaseRulesPendingMember(&MemberModel{GroupNo: "g1", UID: testutil.UID})
+	assert.NoError(t, err)
+}
+
+func TestJoinApplyReview(t *testing.T) {
+	s, ctx := testutil.NewTestServer()
+	f := New(ctx)
+	f.Route(s.GetRoute())
+	prepareGroup(t, f, "g1", map[string]int{"10001": MemberRoleCreator, testutil.UID: MemberRoleCommon})
+	now := time.Now()
+	for applyNo, expireAt := range map[string]int64{"a1": now.Add(time.Hour).Unix(), "a2": now.Add(-time.Minute).Unix()} {
+		err := f.db.InsertJoinApply(&JoinApplyModel{
+			ApplyNo:  applyNo,
+			GroupNo:  "g1",
+			UID:      "1000" + applyNo[1:],
+			Status:   JoinApplyStatusWait,
+			ExpireAt: expireAt,
+		})
+		assert.NoError(t, err)
+	}
+
+	// 普通成员没有邀请成员权限时不能查看和审核入群申请
+	err := f.db.insertOrUpdateRole(&RoleModel{GroupNo: "g1", RoleNo: RoleNoMember, Name: "成员", Permissions: util.ToJson([]Permission{PermissionMentionAll})})
+	assert.NoError(t, err)
+	w := serveGroup(s.GetRoute(), "GET", "/v1/groups/g1/join_applys", nil, testutil.Token)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "没有邀请成员的权限")
+	w = serveGroup(s.GetRoute(), "POST", "/v1/groups/g1/join_applys/a1/refuse", map[string]interface{}{}, testutil.Token)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 拥有邀请成员权限的普通成员可以审核
+	err = f.db.insertOrUpdateRole(&RoleModel{GroupNo: "g1", RoleNo: RoleNoMember, Name: "成员", Permissions: util.ToJson([]Permission{PermissionInvite})})
+	assert.NoError(t, err)
+	w = serveGroup(s.GetRoute(), "GET", "/v1/groups/g1/join_applys", nil, testutil.Token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"apply_no":"a1"`)
+
+	// 已过期的申请不能审核，过期任务将其标记为已过期
+	w = serveGroup(s.GetRoute(), "POST", "/v1/groups/g1/join_applys/a2/refuse", map[string]interface{}{}, testutil.Token)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "入群申请已过期")
+	count, err := f.db.updateJoinApplyExpired(now.Unix())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	applyModel, err := f.db.QueryJoinApplyWithApplyNo("a2")
+	assert.NoError(t, err)
+	assert.Equal(t, JoinApplyStatusExpired, applyModel.Status)
+
+	// 拒绝后不能重复审核
+	w = serveGroup(s.GetRoute(), "POST", "/v1/groups/g1/join_applys/a1/refuse", map[string]interface{}{"reason": "不符合要求"}, testutil.Token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	applyModel, err = f.db.QueryJoinApplyWithApplyNo("a1")
+	assert.NoError(t, err)
+	assert.Equal(t, JoinApplyStatusRefuse, applyModel.Status)
+	assert.Equal(t, testutil.UID, applyModel.Reviewer)
+	w = serveGroup(s.GetRoute(), "POST", "/v1/groups/g1/join_applys/a1/approve", nil, testutil.Token)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "入群申请已处理")
+}
+
+func TestPermissionMatrix(t *testing.T) {
+	s, ctx := testutil.NewTestServer()
+	f := New(ctx)
+	f.Route(s.GetRoute())
+	prepareGroup(t, f, "g1", map[string]int{testutil.UID: MemberRoleCreator, "10001": MemberRoleManager, "10002": MemberRoleCommon})
+
+	// 群主拥有所有权限，管理员和成员使用内置角色的默认权限
+	for _, permission := range AllPermissions {
+		ok, err := f.groupService.HasPermission("g1", testutil.UID, permission)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	}
+	ok, err := f.groupService.HasPermission("g1", "10002", PermissionInvite)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = f.groupService.HasPermission("g1", "10002", PermissionRemoveMember)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// 修改成员角色的权限
+	w := serveGroup(s.GetRoute(), "PUT", "/v1/groups/g1/permissions", map[string]interface{}{"role_no": RoleNoMember, "permissions": map[string]int{"invite": 0}}, testutil.Token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	ok, err = f.groupService.HasPermission("g1", "10002", PermissionInvite)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = f.groupService.HasPermission("g1", "10002", PermissionMentionAll)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	w = serveGroup(s.GetRoute(), "PUT", "/v1/groups/g1/permissions", map[string]interface{}{"role_no": RoleNoMember, "permissions": map[string]int{"unknown": 1}}, testutil.Token)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 管理员子角色只拥有配置的权限
+	w = serveGroup(s.GetRoute(), "POST", "/v1/groups/g1/roles", map[string]interface{}{"name": "审核员", "permissions": []string{"invite"}}, testutil.Token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var role roleResp
+	err = util.ReadJsonByByte(w.Body.Bytes(), &role)
+	assert.NoError(t, err)
+	err = f.db.updateMemberRoleNo("g1", "10001", role.RoleNo)
+	assert.NoError(t, err)
+	ok, err = f.groupService.HasPermission("g1", "10001", PermissionInvite)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = f.groupService.HasPermission("g1", "10001", PermissionMuteMember)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// 群主不可被操作，管理员只能被群主操作
+	ok, err = f.groupService.CanOperateMember("g1", testutil.UID, "10001", PermissionMuteMember)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	err = f.db.updateMemberRoleNo("g1", "10001", "")
+	assert.NoError(t, err)
+	ok, err = f.groupService.CanOperateMember("g1", "10001", testutil.UID, PermissionMuteMember)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = f.groupService.CanOperateMember("g1", "10001", "10002", PermissionMuteMember)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	w = serveGroup(s.GetRoute(), "GET", "/v1/groups/g1/permissions", nil, testutil.Token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "审核员")
+}
+
+func TestAnnouncementPublishAndAck(t *testing.T) {
+	s, ctx := testutil.NewTestServer()
+	f := New(ctx)
+	f.Route(s.GetRoute())
+	prepareGroup(t, f, "g1", map[string]int{testutil.UID: MemberRoleCreator, "10001": MemberRoleCommon})
+
+	// 定时公告到达发布时间前不能确认
+	publishAt := time.Now().Add(time.Hour).Unix()
+	w := serveGroup(s.GetRoute(), "POST", "/v1/groups/g1/announcements", map[string]interface{}{"content": "明天停电", "require_ack": 1, "publish_at": publishAt}, testutil.Token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var scheduled announcementResp
+	assert.NoError(t, util.ReadJsonByByte(w.Body.Bytes(), &scheduled))
+	assert.Equal(t, AnnouncementStatusScheduled, scheduled.Status)
+	w = serveGroup(s.GetRoute(), "POST", fmt.Sprintf("/v1/groups/g1/announcements/%s/ack", scheduled.AnnouncementNo), nil, testutil.Token)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "群公告还未发布")
+	models, err := f.db.queryAnnouncementsToPublish(time.Now().Unix(), 10)
+	assert.NoError(t, err)
+	assert.Len(t, models, 0)
+	models, err = f.db.queryAnnouncementsToPublish(publishAt, 10)
+	assert.NoError(t, err)
+	assert.Len(t, models, 1)
+
+	// 立即发布的置顶公告同步为群公告
+	w = serveGroup(s.GetRoute(), "POST", "/v1/groups/g1/announcements", map[string]interface{}{"content": "欢迎新同学", "require_ack": 1, "pinned": 1}, testutil.Token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var published announcementResp
+	assert.NoError(t, util.ReadJsonByByte(w.Body.Bytes(), &published))
+	assert.Equal(t, AnnouncementStatusPublished, published.Status)
+	groupModel, err := f.db.QueryWithGroupNo("g1")
+	assert.NoError(t, err)
+	assert.Equal(t, "欢迎新同学", groupModel.Notice)
+
+	// 重复确认只记录一次，确认情况区分已确认和未确认成员
+	for i := 0; i < 2; i++ {
+		w = serveGroup(s.GetRoute(), "POST", fmt.Sprintf("/v1/groups/g1/announcements/%s/ack", published.AnnouncementNo), nil, testutil.Token)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	acked, err := f.db.queryAnnouncementAckedMembers(published.AnnouncementNo, "g1")
+	assert.NoError(t, err)
+	assert.Len(t, acked, 1)
+	assert.Equal(t, testutil.UID, acked[0].UID)
+	unacked, err := f.db.queryAnnouncementUnackedMembers(published.AnnouncementNo, "g1")
+	assert.NoError(t, err)
+	assert.Len(t, unacked, 1)
+	assert.Equal(t, "10001", unacked[0].UID)
+}
+
+func TestArchivedGroup(t *testing.T) {
+	s, ctx := testutil.NewTestServer()
+	f := New(ctx)
+	f.Route(s.GetRoute())
+	prepareGroup(t, f, "g1", map[string]int{testutil.UID: MemberRoleCreator, "10001": MemberRoleCommon})
+	err := f.userDB.Insert(&user.Model{UID: "10002", Name: "张二"})
+	assert.NoError(t, err)
+	groupModel, err := f.db.QueryWithGroupNo("g1")
+	assert.NoError(t, err)
+	groupModel.Status = GroupStatusArchived
+	err = f.db.Update(groupModel)
+	assert.NoError(t, err)
+
+	// 已归档的群只有系统账号可发言
+	assert.Equal(t, []string{ctx.GetConfig().Account.SystemUID}, f.archivedWhitelist())
+
+	// 已归档的群不能变更群成员
+	w := serveGroup(s.GetRoute(), "POST", "/v1/groups/g1/members", map[string]interface{}{"members": []string{"10002"}}, testutil.Token)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "群已归档")
+	w = serveGroup(s.GetRoute(), "DELETE", "/v1/groups/g1/members", map[string]interface{}{"members": []string{"10001"}}, testutil.Token)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "群已归档")
+	_, err = f.addJoinApply(groupModel, "10002", "张二", nil, "", "")
+	assert.Error(t, err)
+	exist, err := f.db.ExistMember("10001", "g1")
+	assert.NoError(t, err)
+	assert.True(t, exist)
+
+	// 已归档的群在我的归档列表中
+	w = serveGroup(s.GetRoute(), "GET", "/v1/group/archived", nil, testutil.Token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"group_no":"g1"`)
+}
+
+func TestAuditLog(t *testing.T) {
+	s, ctx := testutil.NewTestServer()
+	f := New(ctx)
+	f.Route(s.GetRoute())
+	prepareGroup(t, f, "g1", map[string]int{testutil.UID: MemberRoleManager, "10001": MemberRoleCommon})
+	f.addAuditLog("g1", testutil.UID, AuditActionMemberMute, []string{"10001"}, map[string]interface{}{"seconds": 60})
+	f.addAuditLog("g1", testutil.UID, AuditActionMemberRemove, []string{"10001", "10002"}, nil)
+	f.addAuditLog("g2", testutil.UID, AuditActionMemberRemove, []string{"10001"}, nil)
+
+	// 按操作类型和操作对象过滤
+	count, err := f.db.queryAuditLogCount(auditLogQuery{GroupNo: "g1"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+	count, err = f.db.queryAuditLogCount(auditLogQuery{GroupNo: "g1", Action: string(AuditActionMemberRemove)})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	count, err = f.db.queryAuditLogCount(auditLogQuery{GroupNo: "g1", Target: "10002"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	count, err = f.db.queryAuditLogCount(auditLogQuery{GroupNo: "g1", StartAt: time.Now().Add(time.Hour).Unix()})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	// 管理员可以查看操作日志
+	w := serveGroup(s.GetRoute(), "GET", "/v1/groups/g1/audit_logs?action=member_mute", nil, testutil.Token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"count":1`)
+}
+
+func TestQuerySuccessor(t *testing.T) {
+	_, ctx := testutil.NewTestServer()
+	f := New(ctx)
+	for _, uid := range []string{"10001", "10002", "10003"} {
+		err := f.userDB.Insert(&user.Model{UID: uid, Name: uid, Status: int(common.UserAvailable)})
+		assert.NoError(t, err)
+	}
+	err := f.db.Insert(&Model{GroupNo: "g1", Name: "test", Creator: testutil.UID, Version: 1, Status: GroupStatusNormal})
+	assert.NoError(t, err)
+	// 按入群先后添加成员，10003已被拉黑不能继承
+	members := []*MemberModel{
+		{UID: testutil.UID, Role: MemberRoleCreator, Status: int(common.GroupMemberStatusNormal)},
+		{UID: "10003", Role: MemberRoleCommon, Status: int(common.GroupMemberStatusBlacklist)},
+		{UID: "10001", Role: MemberRoleCommon, Status: int(common.GroupMemberStatusNormal)},
+		{UID: "10002", Role: MemberRoleManager, Status: int(common.GroupMemberStatusNormal)},
+	}
+	for _, member := range members {
+		member.GroupNo = "g1"
+		err = f.db.InsertMember(member)
+		assert.NoError(t, err)
+	}
+	groupModel, err := f.db.QueryWithGroupNo("g1")
+	assert.NoError(t, err)
+
+	// 优先管理员
+	groupModel.SuccessionPolicy = SuccessionPolicyManagerFirst
+	successor, err := f.querySuccessor(groupModel, testutil.UID)
+	assert.NoError(t, err)
+	assert.Equal(t, "10002", successor.UID)
+
+	// 入群最久的成员
+	groupModel.SuccessionPolicy = SuccessionPolicyMember
+	successor, err = f.querySuccessor(groupModel, testutil.UID)
+	assert.NoError(t, err)
+	assert.Equal(t, "10001", successor.UID)
+
+	// 解散群时没有继承人
+	groupModel.SuccessionPolicy = SuccessionPolicyDisband
+	successor, err = f.querySuccessor(groupModel, testutil.UID)
+	assert.NoError(t, err)
+	assert.Nil(t, successor)
+}
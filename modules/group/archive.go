@@ -0,0 +1,129 @@
+package group
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// 群是否已归档
+func (m *Model) isArchived() bool {
+	return m.Status == GroupStatusArchived
+}
+
+// 已归档的群的IM白名单（只有系统账号可发言）
+func (g *Group) archivedWhitelist() []string {
+	return []string{g.ctx.GetConfig().Account.SystemUID}
+}
+
+// 归档或取消归档群（归档后成员只能查看历史消息，不能发言和变更群成员）
+func (g *Group) groupArchive(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	loginName := c.GetLoginName()
+	groupNo := c.Param("group_no")
+	on, _ := strconv.Atoi(c.Param("on"))
+	groupModel, err := g.getGroupInfo(groupNo)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	// 这里要兼容后台管理系统的归档操作
+	if c.CheckLoginRoleIsSuperAdmin() != nil {
+		// 归档影响整个群，不在权限矩阵中，只允许群主和管理员操作
+		isManager, err := g.db.QueryIsGroupManagerOrCreator(groupNo, loginUID)
+		if err != nil {
+			g.Error("查询是否是群管理者失败！", zap.Error(err))
+			c.ResponseError(errors.New("查询是否是群管理者失败！"))
+			return
+		}
+		if !isManager {
+			c.ResponseError(errors.New("只有群主或管理员才能归档群！"))
+			return
+		}
+	}
+	status := GroupStatusNormal
+	if on == 1 {
+		status = GroupStatusArchived
+	}
+	if groupModel.Status == status {
+		c.ResponseOK()
+		return
+	}
+	if groupModel.Status != GroupStatusNormal && groupModel.Status != GroupStatusArchived {
+		c.ResponseError(errors.New("群已被禁用，不能归档！"))
+		return
+	}
+	groupModel.Status = status
+	groupModel.Version = g.ctx.GenSeq(common.GroupSeqKey)
+	err = g.db.Update(groupModel)
+	if err != nil {
+		g.Error("修改群归档状态失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改群归档状态失败！"))
+		return
+	}
+	// 重置IM白名单（归档时只有系统账号可发言，取消归档时恢复全员禁言状态）
+	if groupModel.Forbidden == 1 {
+		err = g.setIMWhitelistForGroupManager(groupNo)
+	} else {
+		err = g.resetIMWhitelist(make([]string, 0), groupNo)
+	}
+	if err != nil {
+		g.Error("设置白名单失败！", zap.Error(err))
+		c.ResponseError(errors.New("设置白名单失败！"))
+		return
+	}
+	content := "{0}取消了群归档"
+	action := AuditActionGroupUnarchive
+	if status == GroupStatusArchived {
+		content = "{0}归档了本群，群聊已变为只读"
+		action = AuditActionGroupArchive
+	}
+	err = g.ctx.SendMessage(&config.MsgSendReq{
+		Header: config.MsgHeader{
+			RedDot: 1,
+		},
+		ChannelID:   groupNo,
+		ChannelType: common.ChannelTypeGroup.Uint8(),
+		Payload: []byte(util.ToJson(map[string]interface{}{
+			"content": content,
+			"extra": []config.UserBaseVo{
+				{
+					UID:  loginUID,
+					Name: loginName,
+				},
+			},
+			"type": common.Tip,
+		})),
+	})
+	if err != nil {
+		g.Warn("发送群归档提示失败！", zap.Error(err))
+	}
+	err = g.ctx.SendChannelUpdateToGroup(groupNo)
+	if err != nil {
+		g.Warn("发送频道更新命令失败！", zap.Error(err))
+	}
+	g.addAuditLog(groupNo, loginUID, action, nil, nil)
+	c.ResponseOK()
+}
+
+// 我加入的已归档群
+func (g *Group) archivedList(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	models, err := g.db.queryArchivedGroups(loginUID)
+	if err != nil {
+		g.Error("查询已归档的群失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询已归档的群失败！"))
+		return
+	}
+	resps := make([]*GroupResp, 0, len(models))
+	for _, model := range models {
+		groupResp := &GroupResp{}
+		resps = append(resps, groupResp.from(model))
+	}
+	c.Response(resps)
+}
@@ -0,0 +1,8 @@
+package group
+
+// queryArchivedGroups 查询用户加入的已归档群
+func (d *DB) queryArchivedGroups(uid string) ([]*DetailModel, error) {
+	var detailModels []*DetailModel
+	_, err := d.session.Select("`group`.*,IFNULL(group_setting.version,0) + `group`.version  version,IFNULL(group_setting.chat_pwd_on,0) chat_pwd_on,IFNULL(group_setting.mute,0) mute,IFNULL(group_setting.top,0) top,IFNULL(group_setting.show_nick,0) show_nick,IFNULL(group_setting.save,0) save,IFNULL(group_setting.remark,'') remark").From("group_member").Join("`group`", "`group`.group_no=group_member.group_no").LeftJoin("group_setting", "group_member.group_no=group_setting.group_no and group_setting.uid=group_member.uid").Where("group_member.uid=? and group_member.is_deleted=0 and `group`.status=?", uid, GroupStatusArchived).OrderDir("`group`.updated_at", false).Load(&detailModels)
+	return detailModels, err
+}
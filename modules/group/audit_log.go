@@ -0,0 +1,170 @@
+package group
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// AddAuditLog 记录群管理操作日志
+func (s *Service) AddAuditLog(req *AuditLogReq) error {
+	content := ""
+	if len(req.Data) > 0 {
+		content = util.ToJson(req.Data)
+	}
+	return s.db.insertAuditLog(&AuditLogModel{
+		GroupNo:  req.GroupNo,
+		Operator: req.Operator,
+		Action:   string(req.Action),
+		Targets:  strings.Join(req.Targets, ","),
+		Content:  content,
+	})
+}
+
+// 记录群管理操作日志（记录失败不影响操作本身）
+func (g *Group) addAuditLog(groupNo string, operator string, action AuditAction, targets []string, data map[string]interface{}) {
+	err := g.groupService.AddAuditLog(&AuditLogReq{
+		GroupNo:  groupNo,
+		Operator: operator,
+		Action:   action,
+		Targets:  targets,
+		Data:     data,
+	})
+	if err != nil {
+		g.Warn("记录群管理操作日志失败", zap.Error(err), zap.String("group_no", groupNo), zap.String("action", string(action)))
+	}
+}
+
+// 群管理操作日志
+func (g *Group) auditLogList(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	groupNo := c.Param("group_no")
+	_, err := g.getGroupInfo(groupNo)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	// 操作日志只对群主和管理员可见，不在权限矩阵中
+	isManager, err := g.db.QueryIsGroupManagerOrCreator(groupNo, loginUID)
+	if err != nil {
+		g.Error("查询是否是群管理者失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询是否是群管理者失败！"))
+		return
+	}
+	if !isManager {
+		c.ResponseError(errors.New("只有群主或管理员才能查看操作日志！"))
+		return
+	}
+	query := newAuditLogQuery(c, groupNo)
+	pageIndex, pageSize := c.GetPage()
+	models, err := g.db.queryAuditLogsWithPage(query, uint64(pageSize), uint64(pageIndex))
+	if err != nil {
+		g.Error("查询群操作日志失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询群操作日志失败！"))
+		return
+	}
+	count, err := g.db.queryAuditLogCount(query)
+	if err != nil {
+		g.Error("查询群操作日志数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询群操作日志数量失败！"))
+		return
+	}
+	list, err := newAuditLogResps(models, g.userDB)
+	if err != nil {
+		g.Error("查询操作对象失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询操作对象失败！"))
+		return
+	}
+	c.Response(map[string]interface{}{
+		"count": count,
+		"list":  list,
+	})
+}
+
+func newAuditLogQuery(c *wkhttp.Context, groupNo string) auditLogQuery {
+	query := auditLogQuery{
+		GroupNo:  groupNo,
+		Operator: strings.TrimSpace(c.Query("operator")),
+		Action:   strings.TrimSpace(c.Query("action")),
+		Target:   strings.TrimSpace(c.Query("target")),
+	}
+	query.StartAt, _ = strconv.ParseInt(c.Query("start_at"), 10, 64)
+	query.EndAt, _ = strconv.ParseInt(c.Query("end_at"), 10, 64)
+	return query
+}
+
+// AuditLogReq 群管理操作日志
+type AuditLogReq struct {
+	GroupNo  string                 // 群编号
+	Operator string                 // 操作者uid
+	Action   AuditAction            // 操作类型
+	Targets  []string               // 操作对象uid
+	Data     map[string]interface{} // 操作详情
+}
+
+type auditLogUserResp struct {
+	UID  string `json:"uid"`
+	Name string `json:"name"`
+}
+
+type auditLogResp struct {
+	ID           int64                  `json:"id"`
+	GroupNo      string                 `json:"group_no"`      // 群编号
+	Operator     string                 `json:"operator"`      // 操作者uid
+	OperatorName string                 `json:"operator_name"` // 操作者名字
+	Action       string                 `json:"action"`        // 操作类型
+	Targets      []*auditLogUserResp    `json:"targets"`       // 操作对象
+	Data         map[string]interface{} `json:"data"`          // 操作详情
+	CreatedAt    string                 `json:"created_at"`
+}
+
+func newAuditLogResps(models []*AuditLogDetailModel, userDB *user.DB) ([]*auditLogResp, error) {
+	targetUIDs := make([]string, 0)
+	for _, model := range models {
+		if model.Targets != "" {
+			targetUIDs = append(targetUIDs, strings.Split(model.Targets, ",")...)
+		}
+	}
+	nameMap := map[string]string{}
+	if len(targetUIDs) > 0 {
+		users, err := userDB.QueryByUIDs(util.RemoveRepeatedElement(targetUIDs))
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			nameMap[u.UID] = u.Name
+		}
+	}
+	list := make([]*auditLogResp, 0, len(models))
+	for _, model := range models {
+		targets := make([]*auditLogUserResp, 0)
+		if model.Targets != "" {
+			for _, uid := range strings.Split(model.Targets, ",") {
+				targets = append(targets, &auditLogUserResp{
+					UID:  uid,
+					Name: nameMap[uid],
+				})
+			}
+		}
+		var data map[string]interface{}
+		if model.Content != "" {
+			_ = util.ReadJsonByByte([]byte(model.Content), &data)
+		}
+		list = append(list, &auditLogResp{
+			ID:           model.Id,
+			GroupNo:      model.GroupNo,
+			Operator:     model.Operator,
+			OperatorName: model.OperatorName,
+			Action:       model.Action,
+			Targets:      targets,
+			Data:         data,
+			CreatedAt:    model.CreatedAt.String(),
+		})
+	}
+	return list, nil
+}
@@ -0,0 +1,76 @@
+package group
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+// insertAuditLog 添加群管理操作日志
+func (d *DB) insertAuditLog(model *AuditLogModel) error {
+	_, err := d.session.InsertInto("group_audit_log").Columns(util.AttrToUnderscore(model)...).Record(model).Exec()
+	return err
+}
+
+// queryAuditLogsWithPage 分页查询群管理操作日志
+func (d *DB) queryAuditLogsWithPage(query auditLogQuery, pageSize, page uint64) ([]*AuditLogDetailModel, error) {
+	var models []*AuditLogDetailModel
+	builder := d.session.Select("group_audit_log.*,IFNULL(user.name,'') operator_name").From("group_audit_log").LeftJoin("user", "group_audit_log.operator=user.uid")
+	builder = query.where(builder)
+	_, err := builder.OrderDir("group_audit_log.id", false).Offset((page - 1) * pageSize).Limit(pageSize).Load(&models)
+	return models, err
+}
+
+// queryAuditLogCount 查询群管理操作日志数量
+func (d *DB) queryAuditLogCount(query auditLogQuery) (int64, error) {
+	var count int64
+	builder := d.session.Select("count(*)").From("group_audit_log")
+	builder = query.where(builder)
+	_, err := builder.Load(&count)
+	return count, err
+}
+
+type auditLogQuery struct {
+	GroupNo  string // 群编号
+	Operator string // 操作者uid
+	Action   string // 操作类型
+	Target   string // 操作对象uid
+	StartAt  int64  // 开始时间
+	EndAt    int64  // 结束时间
+}
+
+func (q auditLogQuery) where(builder *dbr.SelectStmt) *dbr.SelectStmt {
+	builder = builder.Where("group_audit_log.group_no=?", q.GroupNo)
+	if q.Operator != "" {
+		builder = builder.Where("group_audit_log.operator=?", q.Operator)
+	}
+	if q.Action != "" {
+		builder = builder.Where("group_audit_log.action=?", q.Action)
+	}
+	if q.Target != "" {
+		builder = builder.Where("FIND_IN_SET(?,group_audit_log.targets)", q.Target)
+	}
+	if q.StartAt > 0 {
+		builder = builder.Where("group_audit_log.created_at>=FROM_UNIXTIME(?)", q.StartAt)
+	}
+	if q.EndAt > 0 {
+		builder = builder.Where("group_audit_log.created_at<FROM_UNIXTIME(?)", q.EndAt)
+	}
+	return builder
+}
+
+// AuditLogModel 群管理操作日志
+type AuditLogModel struct {
+	GroupNo  string // 群编号
+	Operator string // 操作者uid
+	Action   string // 操作类型
+	Targets  string // 操作对象uid（多个用逗号分隔）
+	Content  string // 操作详情（json）
+	db.BaseModel
+}
+
+// AuditLogDetailModel 群管理操作日志详情
+type AuditLogDetailModel struct {
+	AuditLogModel
+	OperatorName string // 操作者名字
+}
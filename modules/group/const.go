@@ -1,5 +1,7 @@
 package group
 
+import "time"
+
 // 群状态
 const (
 	// GroupStatusDisabled 已禁用
@@ -8,6 +10,8 @@ const (
 	GroupStatusNormal = 1
 	// GroupStatusDisband 解散
 	GroupStatusDisband = 2
+	// GroupStatusArchived 已归档（只读，成员可查看历史消息但不能发言）
+	GroupStatusArchived = 3
 )
 
 // 群成员角色
@@ -38,3 +42,274 @@ const (
 const (
 	ChannelServiceName = "channel"
 )
+
+// 群属性
+const (
+	// GroupAttrKeyJoinApply 是否允许申请入群
+	GroupAttrKeyJoinApply = "join_apply"
+	// GroupAttrKeyJoinQuestions 入群问题
+	GroupAttrKeyJoinQuestions = "join_questions"
+	// GroupAttrKeyIsPublic 是否公开到群目录
+	GroupAttrKeyIsPublic = "is_public"
+	// GroupAttrKeySlowMode 慢速模式
+	GroupAttrKeySlowMode = "slow_mode"
+	// GroupAttrKeySuccessionPolicy 群主继承策略
+	GroupAttrKeySuccessionPolicy = "succession_policy"
+	// GroupAttrKeyTopicMode 话题模式
+	GroupAttrKeyTopicMode = "topic_mode"
+	// GroupAttrKeyRevokeSecond 普通成员消息可撤回时长
+	GroupAttrKeyRevokeSecond = "revoke_second"
+	// GroupAttrKeyManagerRevokeSecond 管理员消息可撤回时长
+	GroupAttrKeyManagerRevokeSecond = "manager_revoke_second"
+	// GroupAttrKeyCreatorRevokeSecond 群主消息可撤回时长
+	GroupAttrKeyCreatorRevokeSecond = "creator_revoke_second"
+)
+
+// 入群申请状态
+const (
+	// JoinApplyStatusWait 待审核
+	JoinApplyStatusWait = 0
+	// JoinApplyStatusOK 已通过
+	JoinApplyStatusOK = 1
+	// JoinApplyStatusRefuse 已拒绝
+	JoinApplyStatusRefuse = 2
+	// JoinApplyStatusExpired 已过期
+	JoinApplyStatusExpired = 3
+)
+
+const (
+	// JoinApplyExpire 入群申请有效期
+	JoinApplyExpire = time.Hour * 24 * 7
+	// JoinQuestionMaxCount 入群问题最大数量
+	JoinQuestionMaxCount = 5
+)
+
+const (
+	// CMDGroupJoinApply 入群申请（通知群主和管理员）
+	CMDGroupJoinApply = "groupJoinApply"
+	// CMDGroupJoinApplyResult 入群申请审核结果（通知申请者）
+	CMDGroupJoinApplyResult = "groupJoinApplyResult"
+	// CMDGroupPermissionUpdate 群权限变更（客户端重新获取群权限）
+	CMDGroupPermissionUpdate = "groupPermissionUpdate"
+)
+
+// 群邀请链接状态
+const (
+	// InviteLinkStatusRevoked 已撤销
+	InviteLinkStatusRevoked = 0
+	// InviteLinkStatusNormal 有效
+	InviteLinkStatusNormal = 1
+)
+
+const (
+	// InviteLinkCodePrefix 邀请链接二维码code前缀 格式：grouplink_xxxx
+	InviteLinkCodePrefix = "grouplink_"
+)
+
+// Permission 群权限
+type Permission string
+
+const (
+	// PermissionInvite 邀请成员
+	PermissionInvite Permission = "invite"
+	// PermissionEditName 修改群名称
+	PermissionEditName Permission = "edit_name"
+	// PermissionEditNotice 修改群公告
+	PermissionEditNotice Permission = "edit_notice"
+	// PermissionPinMessage 置顶消息
+	PermissionPinMessage Permission = "pin_message"
+	// PermissionMuteMember 禁言成员
+	PermissionMuteMember Permission = "mute_member"
+	// PermissionRemoveMember 移除成员
+	PermissionRemoveMember Permission = "remove_member"
+	// PermissionRevokeMessage 撤回他人消息
+	PermissionRevokeMessage Permission = "revoke_message"
+	// PermissionMentionAll @所有人
+	PermissionMentionAll Permission = "mention_all"
+	// PermissionManageTopic 管理话题
+	PermissionManageTopic Permission = "manage_topic"
+)
+
+// AllPermissions 所有群权限
+var AllPermissions = []Permission{
+	PermissionInvite,
+	PermissionEditName,
+	PermissionEditNotice,
+	PermissionPinMessage,
+	PermissionMuteMember,
+	PermissionRemoveMember,
+	PermissionRevokeMessage,
+	PermissionMentionAll,
+	PermissionManageTopic,
+}
+
+// 内置角色编号（自定义子角色编号为uuid）
+const (
+	// RoleNoManager 管理员
+	RoleNoManager = "manager"
+	// RoleNoMember 普通成员
+	RoleNoMember = "member"
+)
+
+// 内置角色的默认权限
+var (
+	defaultManagerPermissions = AllPermissions
+	defaultMemberPermissions  = []Permission{PermissionInvite, PermissionMentionAll}
+)
+
+const (
+	// RoleMaxCount 每个群最多可自定义的管理员子角色数量
+	RoleMaxCount = 10
+)
+
+const (
+	// RevokeSecondMax 群内消息可撤回时长的最大值（秒）
+	RevokeSecondMax = 3600 * 24 * 30
+	// SlowModeMaxSecond 慢速模式最大间隔（秒）
+	SlowModeMaxSecond = 3600
+	// SlowModeLimitCachePrefix 慢速模式下被限制发言的成员（hash key为群编号 field为uid value为解除时间）
+	SlowModeLimitCachePrefix = "groupSlowModeLimit:"
+	// SlowModeReleaseCacheKey 慢速模式待解除限制的成员（zset score为解除时间 member为群编号@uid）
+	SlowModeReleaseCacheKey = "groupSlowModeRelease"
+	// CMDGroupSlowModeLimit 慢速模式发言限制（发给发送者，客户端展示倒计时）
+	CMDGroupSlowModeLimit = "groupSlowModeLimit"
+	// SlowModeReleaseLeaseCacheKey 慢速模式解除调度租约（多实例部署时只有持有租约的实例解除限制）
+	SlowModeReleaseLeaseCacheKey = "groupSlowModeReleaseLease"
+	// SlowModeReleaseLeaseTTL 调度租约有效期
+	SlowModeReleaseLeaseTTL = time.Second * 30
+)
+
+// 群定时禁言计划类型
+const (
+	// MuteScheduleTypeOnce 单次
+	MuteScheduleTypeOnce = 1
+	// MuteScheduleTypeRecurring 重复
+	MuteScheduleTypeRecurring = 2
+)
+
+// 群定时禁言计划状态
+const (
+	// MuteScheduleStatusFinished 已结束
+	MuteScheduleStatusFinished = 0
+	// MuteScheduleStatusNormal 有效
+	MuteScheduleStatusNormal = 1
+)
+
+const (
+	// MuteScheduleMaxCount 每个群最多的定时禁言计划数量
+	MuteScheduleMaxCount = 10
+	// MuteScheduleMaxDuration 重复禁言每次最长持续时长（分钟）
+	MuteScheduleMaxDuration = 60 * 24 * 7
+	// MuteScheduleDefaultTimezone 默认时区
+	MuteScheduleDefaultTimezone = "Asia/Shanghai"
+	// MuteScheduleLeaseCacheKey 定时禁言调度租约（多实例部署时只有持有租约的实例执行定时禁言）
+	MuteScheduleLeaseCacheKey = "groupMuteScheduleLease"
+	// MuteScheduleLeaseTTL 调度租约有效期
+	MuteScheduleLeaseTTL = time.Second * 30
+)
+
+const (
+	// AnnouncementPublishLeaseCacheKey 定时公告发布调度租约（多实例部署时只有持有租约的实例发布定时公告）
+	AnnouncementPublishLeaseCacheKey = "groupAnnouncementPublishLease"
+	// AnnouncementPublishLeaseTTL 调度租约有效期
+	AnnouncementPublishLeaseTTL = time.Second * 30
+)
+
+// 群公告状态
+const (
+	// AnnouncementStatusScheduled 待发布（定时发布）
+	AnnouncementStatusScheduled = 0
+	// AnnouncementStatusPublished 已发布
+	AnnouncementStatusPublished = 1
+	// AnnouncementStatusFailed 发布失败（群已解散或重试次数用完）
+	AnnouncementStatusFailed = 2
+)
+
+const (
+	// AnnouncementContentMaxLength 公告内容最大长度
+	AnnouncementContentMaxLength = 5000
+	// AnnouncementScheduleMaxDays 定时发布最远时间（天）
+	AnnouncementScheduleMaxDays = 90
+	// AnnouncementPublishMaxRetry 定时公告发布失败最多重试次数
+	AnnouncementPublishMaxRetry = 5
+)
+
+const (
+	// WelcomeMsgMaxLength 欢迎语最大长度
+	WelcomeMsgMaxLength = 500
+	// RulesMaxLength 群规最大长度
+	RulesMaxLength = 5000
+)
+
+// AuditAction 群管理操作类型
+type AuditAction string
+
+const (
+	// AuditActionDisband 解散群
+	AuditActionDisband AuditAction = "disband"
+	// AuditActionMemberRemove 移除成员
+	AuditActionMemberRemove AuditAction = "member_remove"
+	// AuditActionBlacklistAdd 拉黑成员
+	AuditActionBlacklistAdd AuditAction = "blacklist_add"
+	// AuditActionBlacklistRemove 移出黑名单
+	AuditActionBlacklistRemove AuditAction = "blacklist_remove"
+	// AuditActionMemberMute 禁言成员
+	AuditActionMemberMute AuditAction = "member_mute"
+	// AuditActionMemberUnmute 解除成员禁言
+	AuditActionMemberUnmute AuditAction = "member_unmute"
+	// AuditActionGroupForbidden 开启或关闭全员禁言
+	AuditActionGroupForbidden AuditAction = "group_forbidden"
+	// AuditActionTransferGrouper 转让群主
+	AuditActionTransferGrouper AuditAction = "transfer_grouper"
+	// AuditActionGroupUpdate 修改群信息
+	AuditActionGroupUpdate AuditAction = "group_update"
+	// AuditActionSettingUpdate 修改群设置
+	AuditActionSettingUpdate AuditAction = "setting_update"
+	// AuditActionManagerAdd 添加管理员
+	AuditActionManagerAdd AuditAction = "manager_add"
+	// AuditActionManagerRemove 移除管理员
+	AuditActionManagerRemove AuditAction = "manager_remove"
+	// AuditActionMessageRevoke 撤回成员消息
+	AuditActionMessageRevoke AuditAction = "message_revoke"
+	// AuditActionGroupUpgrade 升级为超级群
+	AuditActionGroupUpgrade AuditAction = "group_upgrade"
+	// AuditActionGroupArchive 归档群
+	AuditActionGroupArchive AuditAction = "group_archive"
+	// AuditActionGroupUnarchive 取消归档
+	AuditActionGroupUnarchive AuditAction = "group_unarchive"
+)
+
+// 群主继承策略（群主退出、被封禁或注销时）
+const (
+	// SuccessionPolicyManagerFirst 优先最早的管理员，没有管理员时为入群最久的成员
+	SuccessionPolicyManagerFirst = 0
+	// SuccessionPolicyMember 入群最久的成员
+	SuccessionPolicyMember = 1
+	// SuccessionPolicyDisband 解散群
+	SuccessionPolicyDisband = 2
+)
+
+// 群主离开原因
+const (
+	// GrouperLeaveReasonExit 退出群聊
+	GrouperLeaveReasonExit = 1
+	// GrouperLeaveReasonDisable 被后台封禁
+	GrouperLeaveReasonDisable = 2
+	// GrouperLeaveReasonDestroy 注销账号
+	GrouperLeaveReasonDestroy = 3
+)
+
+// 话题状态
+const (
+	// TopicStatusOpen 开启
+	TopicStatusOpen = 1
+	// TopicStatusClosed 关闭（仅群主和管理员可发言）
+	TopicStatusClosed = 2
+)
+
+const (
+	// TopicTitleMaxLength 话题标题最大长度
+	TopicTitleMaxLength = 50
+	// TopicMaxCount 每个群最多可创建的话题数量
+	TopicMaxCount = 100
+)
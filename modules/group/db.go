@@ -48,6 +48,24 @@ func (d *DB) UpdateGroupType(groupNo string, groupType GroupType) error {
 	return err
 }
 
+// 修改群类型并更新群数据版本
+func (d *DB) updateGroupTypeAndVersion(groupNo string, groupType GroupType, version int64) error {
+	_, err := d.session.Update("group").SetMap(map[string]interface{}{
+		"group_type": int(groupType),
+		"version":    version,
+	}).Where("group_no=?", groupNo).Exec()
+	return err
+}
+
+// 修改群成员数量上限
+func (d *DB) updateMemberMaxCount(groupNo string, memberMaxCount int, version int64) error {
+	_, err := d.session.Update("group").SetMap(map[string]interface{}{
+		"member_max_count": memberMaxCount,
+		"version":          version,
+	}).Where("group_no=?", groupNo).Exec()
+	return err
+}
+
 // InsertMemberTx 插入群成员信息(带事务)
 func (d *DB) InsertMemberTx(m *MemberModel, tx *dbr.Tx) error {
 	_, err := tx.InsertInto("group_member").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
@@ -143,7 +161,7 @@ func (d *DB) UpdateMembersToManager(groupNo string, members []string, version in
 	if len(members) <= 0 {
 		return nil
 	}
-	_, err := d.session.Update("group_member").Set("role", MemberRoleManager).Set("version", version).Where("group_no=? and uid in ? and is_deleted=0", groupNo, members).Exec()
+	_, err := d.session.Update("group_member").Set("role", MemberRoleManager).Set("role_no", "").Set("version", version).Where("group_no=? and uid in ? and is_deleted=0", groupNo, members).Exec()
 	return err
 }
 
@@ -191,12 +209,13 @@ func (d *DB) UpdateMemberTx(member *MemberModel, tx *dbr.Tx) error {
 // recoverMemberTx 恢复成员信息
 func (d *DB) recoverMemberTx(member *MemberModel, tx *dbr.Tx) error {
 	_, err := tx.Update("group_member").SetMap(map[string]interface{}{
-		"remark":     member.Remark,
-		"role":       member.Role,
-		"version":    member.Version,
-		"is_deleted": 0,
-		"invite_uid": member.InviteUID,
-		"created_at": dbr.Expr("Now()"),
+		"remark":        member.Remark,
+		"role":          member.Role,
+		"version":       member.Version,
+		"is_deleted":    0,
+		"invite_uid":    member.InviteUID,
+		"rules_pending": member.RulesPending,
+		"created_at":    dbr.Expr("Now()"),
 	}).Where("group_no=? and uid=?", member.GroupNo, member.UID).Exec()
 	return err
 }
@@ -239,7 +258,7 @@ func (d *DB) QueryWithGroupNos(groupNos []string) ([]*Model, error) {
 
 func (d *DB) queryUserSupers(uid string) ([]*Model, error) {
 	var models []*Model
-	_, err := d.session.Select("`group`.*").From("group_member").LeftJoin("group", "group.group_no=group_member.group_no").Where("group.group_type=? and group.status=? and group_member.is_deleted=0 and group_member.uid=?", GroupTypeSuper, GroupStatusNormal, uid).Load(&models)
+	_, err := d.session.Select("`group`.*").From("group_member").LeftJoin("group", "group.group_no=group_member.group_no").Where("group.group_type=? and group.status in ? and group_member.is_deleted=0 and group_member.uid=?", GroupTypeSuper, []int{GroupStatusNormal, GroupStatusArchived}, uid).Load(&models)
 	return models, err
 }
 
@@ -270,6 +289,19 @@ func (d *DB) Update(model *Model) error {
 		"forbidden_add_friend":        model.ForbiddenAddFriend,
 		"allow_view_history_msg":      model.AllowViewHistoryMsg,
 		"allow_member_pinned_message": model.AllowMemberPinnedMessage,
+		"join_apply":                  model.JoinApply,
+		"join_questions":              model.JoinQuestions,
+		"is_public":                   model.IsPublic,
+		"slow_mode":                   model.SlowMode,
+		"succession_policy":           model.SuccessionPolicy,
+		"topic_mode":                  model.TopicMode,
+		"member_max_count":            model.MemberMaxCount,
+		"welcome_msg":                 model.WelcomeMsg,
+		"rules":                       model.Rules,
+		"rules_required":              model.RulesRequired,
+		"revoke_second":               model.RevokeSecond,
+		"manager_revoke_second":       model.ManagerRevokeSecond,
+		"creator_revoke_second":       model.CreatorRevokeSecond,
 	}).Where("id=?", model.Id).Exec()
 	return err
 }
@@ -326,6 +358,16 @@ func (d *DB) QueryMembersWithUids(uids []string, groupNo string) ([]*MemberModel
 	return memberModels, err
 }
 
+// queryMembersWithGroupNosAndUIDs 查询多个群内的指定成员
+func (d *DB) queryMembersWithGroupNosAndUIDs(groupNos []string, uids []string) ([]*MemberModel, error) {
+	if len(groupNos) == 0 || len(uids) == 0 {
+		return nil, nil
+	}
+	var memberModels []*MemberModel
+	_, err := d.session.Select("*").From("group_member").Where("group_no in ? and uid in ? and is_deleted=0", groupNos, uids).Load(&memberModels)
+	return memberModels, err
+}
+
 // QueryMembersWithStatus 通过成员状态查询成员
 func (d *DB) QueryMembersWithStatus(groupNo string, status int) ([]*MemberModel, error) {
 	var memberModels []*MemberModel
@@ -384,7 +426,7 @@ func (d *DB) queryMembersWithGroupNo(groupNo string) ([]*MemberDetailModel, erro
 
 func (d *DB) queryMemberWithGroupNoAndUID(groupNo, uid string) (*MemberDetailModel, error) {
 	var detail *MemberDetailModel
-	_, err := d.session.Select("group_member.id,group_member.vercode,group_member.uid,group_member.status,group_member.group_no,group_member.remark,group_member.role,group_member.invite_uid,IFNULL(user.name,'') name,group_member.is_deleted,group_member.version,group_member.forbidden_expir_time,group_member.created_at,group_member.updated_at").From("group_member").LeftJoin("user", "group_member.uid=user.uid").Where("group_member.group_no=? and group_member.uid=? and group_member.is_deleted=0", groupNo, uid).Load(&detail)
+	_, err := d.session.Select("group_member.id,group_member.vercode,group_member.uid,group_member.status,group_member.group_no,group_member.remark,group_member.role,group_member.invite_uid,IFNULL(user.name,'') name,group_member.is_deleted,group_member.robot,group_member.rules_pending,group_member.version,group_member.forbidden_expir_time,group_member.created_at,group_member.updated_at").From("group_member").LeftJoin("user", "group_member.uid=user.uid").Where("group_member.group_no=? and group_member.uid=? and group_member.is_deleted=0", groupNo, uid).Load(&detail)
 	return detail, err
 }
 func (d *DB) queryBlacklistMemberUIDsWithGroupNo(groupNo string) ([]string, error) {
@@ -434,6 +476,18 @@ func (d *DB) QueryMemberCount(groupNo string) (int64, error) {
 	return count, err
 }
 
+// 锁定群后查询成员数量（并发加入的请求在事务内串行计数，防止超出成员数量上限）
+func (d *DB) queryMemberCountForUpdateTx(groupNo string, tx *dbr.Tx) (int64, error) {
+	var groupID int64
+	_, err := tx.SelectBySql("select id from `group` where group_no=? for update", groupNo).Load(&groupID)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	_, err = tx.Select("count(*)").From("group_member").Where("group_no=? and is_deleted=0", groupNo).Load(&count)
+	return count, err
+}
+
 // 查询群总数
 func (d *DB) queryGroupCount() (int64, error) {
 	var count int64
@@ -518,6 +572,20 @@ type Model struct {
 	AllowViewHistoryMsg      int    // 是否允许新成员查看历史消息
 	AllowMemberPinnedMessage int    // 是否允许群成员置顶消息
 	Category                 string // 群分类
+	JoinApply                int    // 是否允许申请入群 0.否 1.是
+	JoinQuestions            string // 入群问题(JSON数组)
+	IsPublic                 int    // 是否公开到群目录 0.否 1.是
+	PublicBan                int    // 是否被后台禁止在群目录展示 0.否 1.是
+	SlowMode                 int    // 慢速模式（成员每N秒只能发送一条消息） 0.关闭
+	SuccessionPolicy         int    // 群主继承策略 0.优先最早的管理员其次入群最久的成员 1.入群最久的成员 2.解散群
+	TopicMode                int    // 是否开启话题模式 0.否 1.是
+	MemberMaxCount           int    // 群成员数量上限 0.使用全局配置
+	WelcomeMsg               string // 新成员欢迎语模版
+	Rules                    string // 群规
+	RulesRequired            int    // 新成员是否需要同意群规后才能发言 0.否 1.是
+	RevokeSecond             int    // 普通成员消息可撤回时长 0.使用全局配置
+	ManagerRevokeSecond      int    // 管理员消息可撤回时长 0.使用全局配置
+	CreatorRevokeSecond      int    // 群主消息可撤回时长 0.使用全局配置
 	db.BaseModel
 }
 
@@ -534,6 +602,8 @@ type MemberModel struct {
 	InviteUID          string // 邀请者
 	Robot              int    // 机器人
 	ForbiddenExpirTime int64  // 禁言时长
+	RoleNo             string // 管理员的自定义子角色编号
+	RulesPending       int    // 是否待同意群规 0.否 1.是
 	db.BaseModel
 }
 
@@ -552,6 +622,7 @@ type MemberDetailModel struct {
 	Username           string
 	Robot              int   // 机器人标识0.否1.是
 	ForbiddenExpirTime int64 // 禁言时长
+	RulesPending       int   // 是否待同意群规 0.否 1.是
 	db.BaseModel
 }
 
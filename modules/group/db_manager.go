@@ -108,6 +108,34 @@ func (m *managerDB) queryGroupMemberCountWithStatus(groupNo string, status int)
 	return count, err
 }
 
+// 查询公开群列表
+func (m *managerDB) queryPublicGroupsWithPage(keyword string, pageSize, page uint64) ([]*managerGroupModel, error) {
+	var list []*managerGroupModel
+	builder := m.session.Select("*").From("`group`").Where("is_public=1")
+	if keyword != "" {
+		builder = builder.Where("name like ? or group_no like ?", "%"+keyword+"%", "%"+keyword+"%")
+	}
+	_, err := builder.Offset((page-1)*pageSize).Limit(pageSize).OrderDir("created_at", false).Load(&list)
+	return list, err
+}
+
+// 查询公开群数量
+func (m *managerDB) queryPublicGroupCount(keyword string) (int64, error) {
+	var count int64
+	builder := m.session.Select("count(*)").From("`group`").Where("is_public=1")
+	if keyword != "" {
+		builder = builder.Where("name like ? or group_no like ?", "%"+keyword+"%", "%"+keyword+"%")
+	}
+	_, err := builder.Load(&count)
+	return count, err
+}
+
+// 修改群是否禁止在群目录展示
+func (m *managerDB) updatePublicBan(groupNo string, publicBan int) error {
+	_, err := m.session.Update("group").Set("public_ban", publicBan).Where("group_no=?", groupNo).Exec()
+	return err
+}
+
 type managerGroupModel struct {
 	GroupNo            string // 群编号
 	Name               string // 群名称
@@ -118,6 +146,8 @@ type managerGroupModel struct {
 	Forbidden          int    // 是否全员禁言
 	Invite             int    // 是否开启邀请确认 0.否 1.是
 	ForbiddenAddFriend int    //群内禁止加好友
+	IsPublic           int    // 是否公开到群目录
+	PublicBan          int    // 是否被禁止在群目录展示
 	db.BaseModel
 }
 type managerGroupCountModel struct {
@@ -0,0 +1,141 @@
+package group
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkevent"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// 群目录（公开群搜索）
+func (g *Group) directory(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	query := directoryQuery{
+		Keyword:  strings.TrimSpace(c.Query("keyword")),
+		Category: strings.TrimSpace(c.Query("category")),
+	}
+	query.MinMemberCount, _ = strconv.ParseInt(c.Query("min_member_count"), 10, 64)
+	query.MaxMemberCount, _ = strconv.ParseInt(c.Query("max_member_count"), 10, 64)
+	if query.MaxMemberCount > 0 && query.MinMemberCount > query.MaxMemberCount {
+		c.ResponseError(errors.New("最小成员数量不能大于最大成员数量！"))
+		return
+	}
+	pageIndex, pageSize := c.GetPage()
+	models, err := g.db.queryDirectoryWithPage(query, uint64(pageSize), uint64(pageIndex))
+	if err != nil {
+		g.Error("查询群目录失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询群目录失败！"))
+		return
+	}
+	count, err := g.db.queryDirectoryCount(query)
+	if err != nil {
+		g.Error("查询群目录数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询群目录数量失败！"))
+		return
+	}
+	joinedGroupNos := make([]string, 0)
+	if len(models) > 0 {
+		groupNos := make([]string, 0, len(models))
+		for _, model := range models {
+			groupNos = append(groupNos, model.GroupNo)
+		}
+		joinedGroupNos, err = g.db.existMembers(groupNos, loginUID)
+		if err != nil {
+			g.Error("查询是否在群内失败！", zap.Error(err))
+			c.ResponseError(errors.New("查询是否在群内失败！"))
+			return
+		}
+	}
+	list := make([]*directoryResp, 0, len(models))
+	for _, model := range models {
+		joined := 0
+		for _, joinedGroupNo := range joinedGroupNos {
+			if joinedGroupNo == model.GroupNo {
+				joined = 1
+				break
+			}
+		}
+		list = append(list, &directoryResp{
+			GroupNo:       model.GroupNo,
+			Name:          model.Name,
+			Notice:        model.Notice,
+			Category:      model.Category,
+			MemberCount:   model.MemberCount,
+			JoinApply:     model.JoinApply,
+			JoinQuestions: parseJoinQuestions(model.JoinQuestions),
+			Joined:        joined,
+		})
+	}
+	c.Response(map[string]interface{}{
+		"count": count,
+		"list":  list,
+	})
+}
+
+// 通过群目录直接加入公开群（开启了入群申请的群需走申请入群）
+func (g *Group) directoryJoin(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	loginName := c.GetLoginName()
+	groupNo := c.Param("group_no")
+	group, err := g.getGroupInfo(groupNo)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if !group.isListedInDirectory() {
+		c.ResponseError(errors.New("该群未公开，不能直接加入"))
+		return
+	}
+	if group.JoinApply == 1 {
+		c.ResponseError(errors.New("该群需要申请入群"))
+		return
+	}
+	if group.Invite == 1 {
+		c.ResponseError(errors.New("群开启了邀请模式，不能直接加入群聊"))
+		return
+	}
+	existMember, err := g.db.ExistMember(loginUID, groupNo)
+	if err != nil {
+		g.Error("查询是否在群内失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询是否在群内失败！"))
+		return
+	}
+	if existMember {
+		c.ResponseError(errors.New("已经在群内，不能再加入！"))
+		return
+	}
+	err = g.joinGroup(group, loginUID, "", nil, &wkevent.Data{
+		Event: event.GroupMemberDirectoryJoin,
+		Type:  wkevent.Message,
+		Data: map[string]interface{}{
+			"group_no": groupNo,
+			"uid":      loginUID,
+			"name":     loginName,
+		},
+	})
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
+
+// 是否在群目录中展示
+func (m *Model) isListedInDirectory() bool {
+	return m.IsPublic == 1 && m.PublicBan == 0 && m.Status == GroupStatusNormal
+}
+
+type directoryResp struct {
+	GroupNo       string   `json:"group_no"`       // 群编号
+	Name          string   `json:"name"`           // 群名称
+	Notice        string   `json:"notice"`         // 群公告
+	Category      string   `json:"category"`       // 群分类
+	MemberCount   int64    `json:"member_count"`   // 成员数量
+	JoinApply     int      `json:"join_apply"`     // 是否需要申请入群（0.直接加入 1.需提交入群申请）
+	JoinQuestions []string `json:"join_questions"` // 入群问题（申请入群时需回答）
+	Joined        int      `json:"joined"`         // 我是否已在群内
+}
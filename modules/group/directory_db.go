@@ -0,0 +1,50 @@
+package group
+
+import (
+	"github.com/gocraft/dbr/v2"
+)
+
+// directoryQuery 群目录查询条件
+type directoryQuery struct {
+	Keyword        string // 群名称关键字
+	Category       string // 群分类
+	MinMemberCount int64  // 最小成员数量
+	MaxMemberCount int64  // 最大成员数量（0表示不限）
+}
+
+func (d *DB) directoryBuilder(query directoryQuery) *dbr.SelectStmt {
+	builder := d.session.Select("`group`.*,count(group_member.id) member_count").From("`group`").LeftJoin("group_member", "group_member.group_no=`group`.group_no and group_member.is_deleted=0").Where("`group`.is_public=1 and `group`.public_ban=0 and `group`.status=?", GroupStatusNormal)
+	if query.Keyword != "" {
+		builder = builder.Where("`group`.name like ?", "%"+query.Keyword+"%")
+	}
+	if query.Category != "" {
+		builder = builder.Where("`group`.category=?", query.Category)
+	}
+	builder = builder.GroupBy("`group`.id")
+	if query.MinMemberCount > 0 {
+		builder = builder.Having("member_count>=?", query.MinMemberCount)
+	}
+	if query.MaxMemberCount > 0 {
+		builder = builder.Having("member_count<=?", query.MaxMemberCount)
+	}
+	return builder
+}
+
+// queryDirectoryWithPage 分页查询群目录
+func (d *DB) queryDirectoryWithPage(query directoryQuery, pageSize, page uint64) ([]*directoryModel, error) {
+	var models []*directoryModel
+	_, err := d.directoryBuilder(query).OrderDesc("member_count").OrderDesc("`group`.id").Offset((page - 1) * pageSize).Limit(pageSize).Load(&models)
+	return models, err
+}
+
+// queryDirectoryCount 查询群目录数量
+func (d *DB) queryDirectoryCount(query directoryQuery) (int64, error) {
+	var count int64
+	_, err := d.session.Select("count(*)").From(d.directoryBuilder(query).As("t")).Load(&count)
+	return count, err
+}
+
+type directoryModel struct {
+	Model
+	MemberCount int64 // 成员数量
+}
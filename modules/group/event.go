@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/pool"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkevent"
 	"go.uber.org/zap"
 )
 
@@ -183,6 +185,10 @@ func (g *Group) handleGroupMemberAddEvent(data []byte, commit config.EventCommit
 				return
 			}
 			_ = g.ctx.SendGroupMemberAdd(req)
+			err = g.groupService.SendWelcome(req.GroupNo, req.Members)
+			if err != nil {
+				g.Warn("发送群欢迎语失败！", zap.Error(err), zap.String("group_no", req.GroupNo))
+			}
 		},
 	}
 }
@@ -508,6 +514,7 @@ func (g *Group) handleOrgOrDeptEmployeeUpdate(data []byte, commit config.EventCo
 			commit(err)
 			return
 		}
+		g.syncTopicSubscribers(m.GroupNo, uids, true)
 		content := fmt.Sprintf("欢迎%s 加入 %s，新成员入群可查看所有历史消息", strings.Join(params, ","), groupName)
 		err = g.ctx.SendMessage(&config.MsgSendReq{
 			Header: config.MsgHeader{
@@ -555,6 +562,7 @@ func (g *Group) handleOrgOrDeptEmployeeUpdate(data []byte, commit config.EventCo
 				commit(err)
 				return
 			}
+			g.syncTopicSubscribers(m.GroupNo, members, false)
 			// 发送群成员更新命令
 			err = g.ctx.SendCMD(config.MsgCMDReq{
 				ChannelID:   m.GroupNo,
@@ -627,6 +635,70 @@ func (g *Group) handleOrgOrDeptEmployeeUpdate(data []byte, commit config.EventCo
 // 	commit(nil)
 // }
 
+// 处理部门删除事件（解散部门群）
+func (g *Group) handleOrgDeptDeleteEvent(data []byte, commit config.EventCommit) {
+	var req config.MsgGroupDisband
+	err := util.ReadJsonByByte(data, &req)
+	if err != nil {
+		g.Error("解析JSON失败！", zap.Error(err))
+		commit(nil)
+		return
+	}
+	groupModel, err := g.db.QueryWithGroupNo(req.GroupNo)
+	if err != nil {
+		g.Error("查询群详情失败", zap.Error(err))
+		commit(err)
+		return
+	}
+	if groupModel == nil || groupModel.Status == GroupStatusDisband {
+		commit(nil)
+		return
+	}
+	tx, err := g.db.session.Begin()
+	if err != nil {
+		g.Error("开启事物失败", zap.Error(err))
+		commit(err)
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.RollbackUnlessCommitted()
+			commit(err.(error))
+			panic(err)
+		}
+	}()
+	groupModel.Status = GroupStatusDisband
+	err = g.db.UpdateTx(groupModel, tx)
+	if err != nil {
+		tx.Rollback()
+		g.Error("修改群状态错误", zap.Error(err))
+		commit(err)
+		return
+	}
+	// 复用群解散事件发送解散提示并删除IM频道
+	eventID, err := g.ctx.EventBegin(&wkevent.Data{
+		Event: event.GroupDisband,
+		Type:  wkevent.Message,
+		Data:  &req,
+	}, tx)
+	if err != nil {
+		tx.Rollback()
+		g.Error("开启事件失败！", zap.Error(err))
+		commit(err)
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		tx.RollbackUnlessCommitted()
+		g.Error("事物提交失败", zap.Error(err))
+		commit(err)
+		return
+	}
+	g.ctx.EventCommit(eventID)
+	g.addAuditLog(req.GroupNo, req.Operator, AuditActionDisband, nil, nil)
+	commit(nil)
+}
+
 // 处理组织成员退出
 func (g *Group) handleOrgEmployeeExit(data []byte, commit config.EventCommit) {
 	var req config.OrgEmployeeExitReq
@@ -711,6 +783,7 @@ func (g *Group) handleOrgEmployeeExit(data []byte, commit config.EventCommit) {
 			commit(err)
 			return
 		}
+		g.syncTopicSubscribers(groupNo, members, false)
 		// 发送群成员更新命令
 		err = g.ctx.SendCMD(config.MsgCMDReq{
 			ChannelID:   groupNo,
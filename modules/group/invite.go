@@ -32,11 +32,25 @@ func (g *Group) groupMemberInviteAdd(c *wkhttp.Context) {
 		return
 	}
 
-	_, err := g.getGroupInfo(groupNo)
+	group, err := g.getGroupInfo(groupNo)
 	if err != nil {
 		c.ResponseError(err)
 		return
 	}
+	if group.isArchived() {
+		c.ResponseError(errors.New("群已归档，不能变更群成员！"))
+		return
+	}
+	canInvite, err := g.groupService.HasPermission(groupNo, loginUID, PermissionInvite)
+	if err != nil {
+		g.Error("查询群权限失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询群权限失败！"))
+		return
+	}
+	if !canInvite {
+		c.ResponseError(errors.New("没有邀请成员的权限！"))
+		return
+	}
 
 	creatorOrManagerUIDS, err := g.db.QueryGroupManagerOrCreatorUIDS(groupNo)
 	if err != nil {
@@ -133,14 +147,14 @@ func (g *Group) getToGroupMemberConfirmInviteDetailH5(c *wkhttp.Context) {
 		return
 	}
 
-	managerOrCreator, err := g.db.QueryIsGroupManagerOrCreator(groupNo, loginUID)
+	canInvite, err := g.groupService.HasPermission(groupNo, loginUID, PermissionInvite)
 	if err != nil {
-		g.Error("查询是否管理者或创建者失败！")
-		c.ResponseError(errors.New("查询是否管理者或创建者失败！"))
+		g.Error("查询群权限失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询群权限失败！"))
 		return
 	}
-	if !managerOrCreator {
-		c.ResponseError(errors.New("你不是群主或管理员！"))
+	if !canInvite {
+		c.ResponseError(errors.New("没有邀请成员的权限！"))
 		return
 	}
 	authCode := util.GenerUUID()
@@ -270,7 +284,7 @@ func (g *Group) groupMemberInviteSure(c *wkhttp.Context) {
 	if err != nil {
 		tx.Rollback()
 		g.Error("添加成员失败！", zap.Error(err))
-		c.ResponseError(errors.New("添加成员失败！"))
+		c.ResponseError(err)
 		return
 	}
 	if err := tx.Commit(); err != nil {
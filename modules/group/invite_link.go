@@ -0,0 +1,187 @@
+package group
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// 创建邀请链接
+func (g *Group) inviteLinkCreate(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	groupNo := c.Param("group_no")
+	var req inviteLinkReq
+	if err := c.BindJSON(&req); err != nil {
+		g.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if err := g.checkInviteLinkManager(groupNo, loginUID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var expireAt int64
+	if req.ExpireSeconds > 0 {
+		expireAt = time.Now().Unix() + req.ExpireSeconds
+	}
+	model := &InviteLinkModel{
+		LinkNo:      util.GenerUUID(),
+		GroupNo:     groupNo,
+		Creator:     loginUID,
+		Name:        req.Name,
+		ExpireAt:    expireAt,
+		MaxUses:     req.MaxUses,
+		NeedApprove: req.NeedApprove,
+		Status:      InviteLinkStatusNormal,
+	}
+	err := g.db.insertInviteLink(model)
+	if err != nil {
+		g.Error("添加邀请链接失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加邀请链接失败！"))
+		return
+	}
+	c.Response(g.newInviteLinkResp(model))
+}
+
+// 邀请链接列表
+func (g *Group) inviteLinkList(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	groupNo := c.Param("group_no")
+	if err := g.checkInviteLinkManager(groupNo, loginUID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	models, err := g.db.queryInviteLinksWithGroupNo(groupNo)
+	if err != nil {
+		g.Error("查询邀请链接失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询邀请链接失败！"))
+		return
+	}
+	list := make([]*inviteLinkResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, g.newInviteLinkResp(model))
+	}
+	c.Response(list)
+}
+
+// 撤销邀请链接
+func (g *Group) inviteLinkRevoke(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	groupNo := c.Param("group_no")
+	linkNo := c.Param("link_no")
+	if err := g.checkInviteLinkManager(groupNo, loginUID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	model, err := g.db.QueryInviteLinkWithLinkNo(linkNo)
+	if err != nil {
+		g.Error("查询邀请链接失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询邀请链接失败！"))
+		return
+	}
+	if model == nil || model.GroupNo != groupNo {
+		c.ResponseError(errors.New("邀请链接不存在！"))
+		return
+	}
+	if model.Status == InviteLinkStatusRevoked {
+		c.ResponseOK()
+		return
+	}
+	err = g.db.revokeInviteLink(linkNo)
+	if err != nil {
+		g.Error("撤销邀请链接失败！", zap.Error(err))
+		c.ResponseError(errors.New("撤销邀请链接失败！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 校验群是否存在以及操作者是否拥有邀请成员权限
+func (g *Group) checkInviteLinkManager(groupNo string, uid string) error {
+	group, err := g.getGroupInfo(groupNo)
+	if err != nil {
+		return err
+	}
+	if group.isArchived() {
+		return errors.New("群已归档，不能变更群成员！")
+	}
+	canInvite, err := g.groupService.HasPermission(groupNo, uid, PermissionInvite)
+	if err != nil {
+		g.Error("查询群权限失败！", zap.Error(err))
+		return errors.New("查询群权限失败！")
+	}
+	if !canInvite {
+		return errors.New("没有邀请成员的权限，不能管理邀请链接！")
+	}
+	return nil
+}
+
+type inviteLinkReq struct {
+	Name          string `json:"name"`           // 链接名称
+	ExpireSeconds int64  `json:"expire_seconds"` // 有效时长（秒） 0.永不过期
+	MaxUses       int    `json:"max_uses"`       // 最大使用次数 0.不限
+	NeedApprove   int    `json:"need_approve"`   // 是否需要审核 0.否 1.是
+}
+
+func (r inviteLinkReq) check() error {
+	if len(r.Name) > 100 {
+		return errors.New("链接名称过长！")
+	}
+	if r.ExpireSeconds < 0 {
+		return errors.New("有效时长不能小于0！")
+	}
+	if r.MaxUses < 0 {
+		return errors.New("最大使用次数不能小于0！")
+	}
+	if r.NeedApprove != 0 && r.NeedApprove != 1 {
+		return errors.New("是否需要审核参数有误！")
+	}
+	return nil
+}
+
+type inviteLinkResp struct {
+	LinkNo      string `json:"link_no"`      // 链接编号
+	GroupNo     string `json:"group_no"`     // 群编号
+	Creator     string `json:"creator"`      // 创建者uid
+	Name        string `json:"name"`         // 链接名称
+	URL         string `json:"url"`          // 链接地址（可生成二维码）
+	ExpireAt    int64  `json:"expire_at"`    // 过期时间 0.永不过期
+	MaxUses     int    `json:"max_uses"`     // 最大使用次数 0.不限
+	NeedApprove int    `json:"need_approve"` // 是否需要审核
+	JoinCount   int    `json:"join_count"`   // 通过此链接入群人数
+	ApplyCount  int    `json:"apply_count"`  // 通过此链接申请入群次数
+	Status      int    `json:"status"`       // 状态 0.已撤销 1.有效
+	Available   int    `json:"available"`    // 当前是否可用
+	CreatedAt   string `json:"created_at"`
+}
+
+func (g *Group) newInviteLinkResp(m *InviteLinkModel) *inviteLinkResp {
+	available := 0
+	if m.CheckAvailable() == nil {
+		available = 1
+	}
+	return &inviteLinkResp{
+		LinkNo:      m.LinkNo,
+		GroupNo:     m.GroupNo,
+		Creator:     m.Creator,
+		Name:        m.Name,
+		URL:         fmt.Sprintf("%s/%s", g.ctx.GetConfig().External.BaseURL, strings.ReplaceAll(g.ctx.GetConfig().QRCodeInfoURL, ":code", InviteLinkCodePrefix+m.LinkNo)),
+		ExpireAt:    m.ExpireAt,
+		MaxUses:     m.MaxUses,
+		NeedApprove: m.NeedApprove,
+		JoinCount:   m.JoinCount,
+		ApplyCount:  m.ApplyCount,
+		Status:      m.Status,
+		Available:   available,
+		CreatedAt:   m.CreatedAt.String(),
+	}
+}
@@ -0,0 +1,90 @@
+package group
+
+import (
+	"errors"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+// insertInviteLink 添加邀请链接
+func (d *DB) insertInviteLink(model *InviteLinkModel) error {
+	_, err := d.session.InsertInto("group_invite_link").Columns(util.AttrToUnderscore(model)...).Record(model).Exec()
+	return err
+}
+
+// QueryInviteLinkWithLinkNo 通过链接编号查询邀请链接
+func (d *DB) QueryInviteLinkWithLinkNo(linkNo string) (*InviteLinkModel, error) {
+	var model *InviteLinkModel
+	_, err := d.session.Select("*").From("group_invite_link").Where("link_no=?", linkNo).Load(&model)
+	return model, err
+}
+
+// queryInviteLinksWithGroupNo 查询群的邀请链接
+func (d *DB) queryInviteLinksWithGroupNo(groupNo string) ([]*InviteLinkModel, error) {
+	var models []*InviteLinkModel
+	_, err := d.session.Select("*").From("group_invite_link").Where("group_no=?", groupNo).OrderDir("id", false).Load(&models)
+	return models, err
+}
+
+// revokeInviteLink 撤销邀请链接
+func (d *DB) revokeInviteLink(linkNo string) error {
+	_, err := d.session.Update("group_invite_link").Set("status", InviteLinkStatusRevoked).Where("link_no=?", linkNo).Exec()
+	return err
+}
+
+// incrInviteLinkJoinCountTx 邀请链接入群人数+1（受最大使用次数限制）返回是否增加成功
+func (d *DB) incrInviteLinkJoinCountTx(linkNo string, tx *dbr.Tx) (bool, error) {
+	result, err := tx.UpdateBySql("update group_invite_link set join_count=join_count+1 where link_no=? and status=? and (max_uses=0 or join_count<max_uses)", linkNo, InviteLinkStatusNormal).Exec()
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// incrInviteLinkJoinCountForceTx 邀请链接入群人数+1（审核通过的申请不受最大使用次数限制）
+func (d *DB) incrInviteLinkJoinCountForceTx(linkNo string, tx *dbr.Tx) error {
+	_, err := tx.UpdateBySql("update group_invite_link set join_count=join_count+1 where link_no=?", linkNo).Exec()
+	return err
+}
+
+// incrInviteLinkApplyCount 邀请链接申请次数+1
+func (d *DB) incrInviteLinkApplyCount(linkNo string) error {
+	_, err := d.session.UpdateBySql("update group_invite_link set apply_count=apply_count+1 where link_no=?", linkNo).Exec()
+	return err
+}
+
+// InviteLinkModel 群邀请链接
+type InviteLinkModel struct {
+	LinkNo      string // 链接唯一编号
+	GroupNo     string // 群编号
+	Creator     string // 创建者uid
+	Name        string // 链接名称
+	ExpireAt    int64  // 过期时间 0.永不过期
+	MaxUses     int    // 最大使用次数 0.不限
+	NeedApprove int    // 是否需要审核
+	JoinCount   int    // 通过此链接入群人数
+	ApplyCount  int    // 通过此链接申请入群次数
+	Status      int    // 状态 0.已撤销 1.有效
+	db.BaseModel
+}
+
+// CheckAvailable 检查邀请链接是否可用
+func (m *InviteLinkModel) CheckAvailable() error {
+	if m.Status == InviteLinkStatusRevoked {
+		return errors.New("邀请链接已被撤销！")
+	}
+	if m.ExpireAt > 0 && m.ExpireAt <= time.Now().Unix() {
+		return errors.New("邀请链接已过期！")
+	}
+	if m.MaxUses > 0 && m.JoinCount >= m.MaxUses {
+		return errors.New("邀请链接已达到使用次数上限！")
+	}
+	return nil
+}
@@ -0,0 +1,409 @@
+package group
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// 申请入群
+func (g *Group) joinApply(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	loginName := c.GetLoginName()
+	groupNo := c.Param("group_no")
+	var req joinApplyReq
+	if err := c.BindJSON(&req); err != nil {
+		g.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	group, err := g.getGroupInfo(groupNo)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if group.JoinApply != 1 {
+		c.ResponseError(errors.New("该群未开启申请入群"))
+		return
+	}
+	applyModel, err := g.addJoinApply(group, loginUID, loginName, req.Answers, req.Remark, "")
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.Response(newJoinApplyResp(&JoinApplyDetailModel{JoinApplyModel: *applyModel, Name: loginName}))
+}
+
+// addJoinApply 添加入群申请并通知群主和管理员审核（linkNo为通过的邀请链接编号，可为空）
+func (g *Group) addJoinApply(group *Model, uid string, name string, answers []string, remark string, linkNo string) (*JoinApplyModel, error) {
+	groupNo := group.GroupNo
+	if group.isArchived() {
+		return nil, errors.New("群已归档，不能变更群成员！")
+	}
+	questions := parseJoinQuestions(group.JoinQuestions)
+	if len(questions) > 0 {
+		if len(answers) != len(questions) {
+			return nil, errors.New("请回答全部入群问题")
+		}
+		for _, answer := range answers {
+			if strings.TrimSpace(answer) == "" {
+				return nil, errors.New("入群问题回答不能为空")
+			}
+		}
+	}
+	existMember, err := g.db.ExistMember(uid, groupNo)
+	if err != nil {
+		g.Error("查询是否在群内失败！", zap.Error(err))
+		return nil, errors.New("查询是否在群内失败！")
+	}
+	if existMember {
+		return nil, errors.New("已经在群内，不能再申请！")
+	}
+	now := time.Now().Unix()
+	waitApply, err := g.db.QueryWaitJoinApply(groupNo, uid, now)
+	if err != nil {
+		g.Error("查询待审核的入群申请失败！", zap.Error(err))
+		return nil, errors.New("查询待审核的入群申请失败！")
+	}
+	if waitApply != nil {
+		return nil, errors.New("已提交入群申请，请等待审核")
+	}
+	managerUIDs, err := g.db.QueryGroupManagerOrCreatorUIDS(groupNo)
+	if err != nil {
+		g.Error("查询创建者或管理员的uid失败！", zap.String("group_no", groupNo), zap.Error(err))
+		return nil, errors.New("查询创建者或管理员的uid失败！")
+	}
+	answersJSON := ""
+	if len(answers) > 0 {
+		answersJSON = util.ToJson(answers)
+	}
+	applyModel := &JoinApplyModel{
+		ApplyNo:  util.GenerUUID(),
+		GroupNo:  groupNo,
+		UID:      uid,
+		Answers:  answersJSON,
+		Remark:   remark,
+		Status:   JoinApplyStatusWait,
+		ExpireAt: now + int64(JoinApplyExpire.Seconds()),
+		LinkNo:   linkNo,
+	}
+	err = g.db.InsertJoinApply(applyModel)
+	if err != nil {
+		g.Error("添加入群申请失败！", zap.Error(err))
+		return nil, errors.New("添加入群申请失败！")
+	}
+	if len(managerUIDs) > 0 {
+		err = g.ctx.SendCMD(config.MsgCMDReq{
+			NoPersist:   true,
+			Subscribers: managerUIDs,
+			CMD:         CMDGroupJoinApply,
+			Param: map[string]interface{}{
+				"group_no":   groupNo,
+				"apply_no":   applyModel.ApplyNo,
+				"apply_uid":  uid,
+				"apply_name": name,
+				"link_no":    linkNo,
+			},
+		})
+		if err != nil {
+			g.Warn("发送入群申请cmd失败！", zap.Error(err))
+		}
+	}
+	return applyModel, nil
+}
+
+// 入群申请列表
+func (g *Group) joinApplyList(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	groupNo := c.Param("group_no")
+	status := JoinApplyStatusWait
+	if strings.TrimSpace(c.Query("status")) != "" {
+		status, _ = strconv.Atoi(c.Query("status"))
+	}
+	_, err := g.getGroupInfo(groupNo)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	canInvite, err := g.groupService.HasPermission(groupNo, loginUID, PermissionInvite)
+	if err != nil {
+		g.Error("查询群权限失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询群权限失败！"))
+		return
+	}
+	if !canInvite {
+		c.ResponseError(errors.New("没有邀请成员的权限，不能查看入群申请！"))
+		return
+	}
+	pageIndex, pageSize := c.GetPage()
+	models, err := g.db.QueryJoinApplysWithPage(groupNo, status, uint64(pageSize), uint64(pageIndex))
+	if err != nil {
+		g.Error("查询入群申请列表失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询入群申请列表失败！"))
+		return
+	}
+	count, err := g.db.QueryJoinApplyCount(groupNo, status)
+	if err != nil {
+		g.Error("查询入群申请数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询入群申请数量失败！"))
+		return
+	}
+	list := make([]*joinApplyResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newJoinApplyResp(model))
+	}
+	c.Response(map[string]interface{}{
+		"count": count,
+		"list":  list,
+	})
+}
+
+// 通过入群申请
+func (g *Group) joinApplyApprove(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	loginName := c.GetLoginName()
+	groupNo := c.Param("group_no")
+	applyModel, err := g.getWaitJoinApplyForReview(groupNo, c.Param("apply_no"), loginUID)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	existMember, err := g.db.ExistMember(applyModel.UID, groupNo)
+	if err != nil {
+		g.Error("查询是否在群内失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询是否在群内失败！"))
+		return
+	}
+	applyModel.Status = JoinApplyStatusOK
+	applyModel.Reviewer = loginUID
+	if existMember {
+		ok, err := g.db.UpdateJoinApplyStatus(applyModel)
+		if err != nil {
+			g.Error("更新入群申请状态失败！", zap.Error(err))
+			c.ResponseError(errors.New("更新入群申请状态失败！"))
+			return
+		}
+		if !ok {
+			c.ResponseError(errors.New("入群申请已被处理！"))
+			return
+		}
+		c.ResponseOK()
+		return
+	}
+	tx, err := g.ctx.DB().Begin()
+	if err != nil {
+		g.Error("开启事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("开启事务失败！"))
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.RollbackUnlessCommitted()
+			panic(err)
+		}
+	}()
+	ok, err := g.db.UpdateJoinApplyStatusTx(applyModel, tx)
+	if err != nil {
+		tx.Rollback()
+		g.Error("更新入群申请状态失败！", zap.Error(err))
+		c.ResponseError(errors.New("更新入群申请状态失败！"))
+		return
+	}
+	if !ok { // 并发审核时只有一个能成功
+		tx.Rollback()
+		c.ResponseError(errors.New("入群申请已被处理！"))
+		return
+	}
+	commitCallback, err := g.addMembersTx([]string{applyModel.UID}, groupNo, loginUID, loginName, tx)
+	if err != nil {
+		tx.Rollback()
+		g.Error("添加成员失败！", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if applyModel.LinkNo != "" {
+		err = g.db.incrInviteLinkJoinCountForceTx(applyModel.LinkNo, tx)
+		if err != nil {
+			tx.Rollback()
+			g.Error("更新邀请链接入群人数失败！", zap.Error(err))
+			c.ResponseError(errors.New("更新邀请链接入群人数失败！"))
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		g.Error("提交事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("提交事务失败！"))
+		return
+	}
+	commitCallback()
+
+	g.sendJoinApplyResult(applyModel)
+
+	c.ResponseOK()
+}
+
+// 拒绝入群申请
+func (g *Group) joinApplyRefuse(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	groupNo := c.Param("group_no")
+	var req struct {
+		Reason string `json:"reason"` // 拒绝理由
+	}
+	if err := c.BindJSON(&req); err != nil {
+		g.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	applyModel, err := g.getWaitJoinApplyForReview(groupNo, c.Param("apply_no"), loginUID)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	applyModel.Status = JoinApplyStatusRefuse
+	applyModel.Reviewer = loginUID
+	applyModel.Reason = req.Reason
+	ok, err := g.db.UpdateJoinApplyStatus(applyModel)
+	if err != nil {
+		g.Error("更新入群申请状态失败！", zap.Error(err))
+		c.ResponseError(errors.New("更新入群申请状态失败！"))
+		return
+	}
+	if !ok {
+		c.ResponseError(errors.New("入群申请已被处理！"))
+		return
+	}
+
+	g.sendJoinApplyResult(applyModel)
+
+	c.ResponseOK()
+}
+
+// 获取待审核的入群申请（并校验审核者是否有邀请成员权限）
+func (g *Group) getWaitJoinApplyForReview(groupNo string, applyNo string, reviewer string) (*JoinApplyModel, error) {
+	if strings.TrimSpace(applyNo) == "" {
+		return nil, errors.New("申请编号不能为空！")
+	}
+	_, err := g.getGroupInfo(groupNo)
+	if err != nil {
+		return nil, err
+	}
+	canInvite, err := g.groupService.HasPermission(groupNo, reviewer, PermissionInvite)
+	if err != nil {
+		g.Error("查询群权限失败！", zap.Error(err))
+		return nil, errors.New("查询群权限失败！")
+	}
+	if !canInvite {
+		return nil, errors.New("没有邀请成员的权限，不能审核入群申请！")
+	}
+	applyModel, err := g.db.QueryJoinApplyWithApplyNo(applyNo)
+	if err != nil {
+		g.Error("查询入群申请失败！", zap.Error(err))
+		return nil, errors.New("查询入群申请失败！")
+	}
+	if applyModel == nil || applyModel.GroupNo != groupNo {
+		return nil, errors.New("入群申请不存在！")
+	}
+	if applyModel.Status != JoinApplyStatusWait {
+		return nil, errors.New("入群申请已处理！")
+	}
+	if applyModel.ExpireAt <= time.Now().Unix() {
+		return nil, errors.New("入群申请已过期！")
+	}
+	return applyModel, nil
+}
+
+// 通知申请者审核结果
+func (g *Group) sendJoinApplyResult(applyModel *JoinApplyModel) {
+	err := g.ctx.SendCMD(config.MsgCMDReq{
+		NoPersist:   true,
+		ChannelID:   applyModel.UID,
+		ChannelType: common.ChannelTypePerson.Uint8(),
+		CMD:         CMDGroupJoinApplyResult,
+		Param: map[string]interface{}{
+			"group_no": applyModel.GroupNo,
+			"apply_no": applyModel.ApplyNo,
+			"status":   applyModel.Status,
+			"reason":   applyModel.Reason,
+		},
+	})
+	if err != nil {
+		g.Warn("发送入群申请审核结果cmd失败！", zap.Error(err))
+	}
+}
+
+// CheckJoinApplyExpireLoop 检查入群申请是否过期
+func (g *Group) CheckJoinApplyExpireLoop() {
+	var errSleep = time.Second * 5
+	var checkSleep = time.Minute * 1
+	for {
+		_, err := g.db.updateJoinApplyExpired(time.Now().Unix())
+		if err != nil {
+			g.Warn("更新过期入群申请失败", zap.Error(err))
+			time.Sleep(errSleep)
+			continue
+		}
+		time.Sleep(checkSleep)
+	}
+}
+
+// 解析入群问题
+func parseJoinQuestions(joinQuestions string) []string {
+	questions := make([]string, 0)
+	if strings.TrimSpace(joinQuestions) == "" {
+		return questions
+	}
+	err := util.ReadJsonByByte([]byte(joinQuestions), &questions)
+	if err != nil {
+		return make([]string, 0)
+	}
+	return questions
+}
+
+type joinApplyReq struct {
+	Answers []string `json:"answers"` // 入群问题回答
+	Remark  string   `json:"remark"`  // 申请备注
+}
+
+type joinApplyResp struct {
+	ApplyNo   string   `json:"apply_no"`  // 申请编号
+	GroupNo   string   `json:"group_no"`  // 群编号
+	UID       string   `json:"uid"`       // 申请者uid
+	Name      string   `json:"name"`      // 申请者名称
+	Answers   []string `json:"answers"`   // 入群问题回答
+	Remark    string   `json:"remark"`    // 申请备注
+	Status    int      `json:"status"`    // 状态 0.待审核 1.已通过 2.已拒绝 3.已过期
+	Reviewer  string   `json:"reviewer"`  // 审核者
+	Reason    string   `json:"reason"`    // 审核理由
+	ExpireAt  int64    `json:"expire_at"` // 过期时间
+	LinkNo    string   `json:"link_no"`   // 通过的邀请链接编号
+	CreatedAt string   `json:"created_at"`
+}
+
+func newJoinApplyResp(m *JoinApplyDetailModel) *joinApplyResp {
+	status := m.Status
+	if status == JoinApplyStatusWait && m.ExpireAt <= time.Now().Unix() {
+		status = JoinApplyStatusExpired
+	}
+	return &joinApplyResp{
+		ApplyNo:   m.ApplyNo,
+		GroupNo:   m.GroupNo,
+		UID:       m.UID,
+		Name:      m.Name,
+		Answers:   parseJoinQuestions(m.Answers),
+		Remark:    m.Remark,
+		Status:    status,
+		Reviewer:  m.Reviewer,
+		Reason:    m.Reason,
+		ExpireAt:  m.ExpireAt,
+		LinkNo:    m.LinkNo,
+		CreatedAt: m.CreatedAt.String(),
+	}
+}
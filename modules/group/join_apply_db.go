@@ -0,0 +1,113 @@
+package group
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+// InsertJoinApply 添加入群申请
+func (d *DB) InsertJoinApply(model *JoinApplyModel) error {
+	_, err := d.session.InsertInto("group_join_apply").Columns(util.AttrToUnderscore(model)...).Record(model).Exec()
+	return err
+}
+
+// QueryJoinApplyWithApplyNo 通过申请编号查询入群申请
+func (d *DB) QueryJoinApplyWithApplyNo(applyNo string) (*JoinApplyModel, error) {
+	var model *JoinApplyModel
+	_, err := d.session.Select("*").From("group_join_apply").Where("apply_no=?", applyNo).Load(&model)
+	return model, err
+}
+
+// QueryWaitJoinApply 查询用户在某群的待审核申请
+func (d *DB) QueryWaitJoinApply(groupNo string, uid string, now int64) (*JoinApplyModel, error) {
+	var model *JoinApplyModel
+	_, err := d.session.Select("*").From("group_join_apply").Where("group_no=? and uid=? and status=? and expire_at>?", groupNo, uid, JoinApplyStatusWait, now).OrderDir("id", false).Limit(1).Load(&model)
+	return model, err
+}
+
+// QueryJoinApplysWithPage 分页查询群的入群申请
+func (d *DB) QueryJoinApplysWithPage(groupNo string, status int, pageSize, page uint64) ([]*JoinApplyDetailModel, error) {
+	var models []*JoinApplyDetailModel
+	builder := d.session.Select("group_join_apply.*,IFNULL(user.name,'') name").From("group_join_apply").LeftJoin("user", "group_join_apply.uid=user.uid").Where("group_join_apply.group_no=?", groupNo)
+	if status >= 0 {
+		builder = builder.Where("group_join_apply.status=?", status)
+	}
+	_, err := builder.OrderDir("group_join_apply.id", false).Offset((page - 1) * pageSize).Limit(pageSize).Load(&models)
+	return models, err
+}
+
+// QueryJoinApplyCount 查询群的入群申请数量
+func (d *DB) QueryJoinApplyCount(groupNo string, status int) (int64, error) {
+	var count int64
+	builder := d.session.Select("count(*)").From("group_join_apply").Where("group_no=?", groupNo)
+	if status >= 0 {
+		builder = builder.Where("status=?", status)
+	}
+	_, err := builder.Load(&count)
+	return count, err
+}
+
+// UpdateJoinApplyStatusTx 更新待审核的入群申请状态 返回是否更新成功（申请已被处理时返回false）
+func (d *DB) UpdateJoinApplyStatusTx(model *JoinApplyModel, tx *dbr.Tx) (bool, error) {
+	result, err := tx.Update("group_join_apply").SetMap(map[string]interface{}{
+		"status":   model.Status,
+		"reviewer": model.Reviewer,
+		"reason":   model.Reason,
+	}).Where("id=? and status=?", model.Id, JoinApplyStatusWait).Exec()
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// UpdateJoinApplyStatus 更新待审核的入群申请状态 返回是否更新成功（申请已被处理时返回false）
+func (d *DB) UpdateJoinApplyStatus(model *JoinApplyModel) (bool, error) {
+	result, err := d.session.Update("group_join_apply").SetMap(map[string]interface{}{
+		"status":   model.Status,
+		"reviewer": model.Reviewer,
+		"reason":   model.Reason,
+	}).Where("id=? and status=?", model.Id, JoinApplyStatusWait).Exec()
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// updateJoinApplyExpired 将已过期的待审核申请改为过期状态
+func (d *DB) updateJoinApplyExpired(now int64) (int64, error) {
+	result, err := d.session.Update("group_join_apply").Set("status", JoinApplyStatusExpired).Where("status=? and expire_at<=?", JoinApplyStatusWait, now).Exec()
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// JoinApplyModel 入群申请
+type JoinApplyModel struct {
+	ApplyNo  string // 申请唯一编号
+	GroupNo  string // 群编号
+	UID      string // 申请者uid
+	Answers  string // 入群问题回答(JSON数组)
+	Remark   string // 申请备注
+	Status   int    // 状态 0.待审核 1.已通过 2.已拒绝 3.已过期
+	Reviewer string // 审核者uid
+	Reason   string // 审核理由
+	ExpireAt int64  // 过期时间
+	LinkNo   string // 通过的邀请链接编号
+	db.BaseModel
+}
+
+// JoinApplyDetailModel 入群申请详情
+type JoinApplyDetailModel struct {
+	JoinApplyModel
+	Name string // 申请者名称
+}
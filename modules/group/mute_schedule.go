@@ -0,0 +1,440 @@
+package group
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/robfig/cron"
+	"go.uber.org/zap"
+)
+
+// 添加定时禁言计划
+func (g *Group) muteScheduleAdd(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	groupNo := c.Param("group_no")
+	var req muteScheduleReq
+	if err := c.BindJSON(&req); err != nil {
+		g.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if strings.TrimSpace(req.Timezone) == "" {
+		req.Timezone = MuteScheduleDefaultTimezone
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if err := g.checkMuteScheduleManager(groupNo, loginUID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	count, err := g.db.queryMuteScheduleCount(groupNo)
+	if err != nil {
+		g.Error("查询定时禁言计划数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询定时禁言计划数量失败！"))
+		return
+	}
+	if count >= MuteScheduleMaxCount {
+		c.ResponseError(fmt.Errorf("每个群最多设置%d个定时禁言计划！", MuteScheduleMaxCount))
+		return
+	}
+	model := &MuteScheduleModel{
+		ScheduleNo: util.GenerUUID(),
+		GroupNo:    groupNo,
+		Creator:    loginUID,
+		Name:       req.Name,
+		Type:       req.Type,
+		StartAt:    req.StartAt,
+		EndAt:      req.EndAt,
+		Cron:       strings.TrimSpace(req.Cron),
+		Duration:   req.Duration,
+		Timezone:   req.Timezone,
+		Status:     MuteScheduleStatusNormal,
+	}
+	// 从当前时间往前推一个禁言时长计算，创建时正处于禁言时间段内的计划会立即生效
+	now := time.Now()
+	startAt, endAt, ok := model.nextWindow(now.Add(-time.Duration(model.Duration) * time.Minute))
+	if !ok {
+		c.ResponseError(errors.New("没有可执行的禁言时间段！"))
+		return
+	}
+	model.NextStartAt = startAt
+	model.NextEndAt = endAt
+	err = g.db.insertMuteSchedule(model)
+	if err != nil {
+		g.Error("添加定时禁言计划失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加定时禁言计划失败！"))
+		return
+	}
+	c.Response(newMuteScheduleResp(model))
+}
+
+// 定时禁言计划列表
+func (g *Group) muteScheduleList(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	groupNo := c.Param("group_no")
+	if err := g.checkMuteScheduleManager(groupNo, loginUID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	models, err := g.db.queryMuteSchedulesWithGroupNo(groupNo)
+	if err != nil {
+		g.Error("查询定时禁言计划失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询定时禁言计划失败！"))
+		return
+	}
+	list := make([]*muteScheduleResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newMuteScheduleResp(model))
+	}
+	c.Response(list)
+}
+
+// 删除定时禁言计划（正在禁言中的会立即解除）
+func (g *Group) muteScheduleDelete(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	groupNo := c.Param("group_no")
+	scheduleNo := c.Param("schedule_no")
+	if err := g.checkMuteScheduleManager(groupNo, loginUID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	model, err := g.db.queryMuteScheduleWithScheduleNo(scheduleNo)
+	if err != nil {
+		g.Error("查询定时禁言计划失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询定时禁言计划失败！"))
+		return
+	}
+	if model == nil || model.GroupNo != groupNo || model.Status != MuteScheduleStatusNormal {
+		c.ResponseError(errors.New("定时禁言计划不存在！"))
+		return
+	}
+	if model.Active == 1 {
+		err = g.liftMuteSchedule(model)
+		if err != nil {
+			g.Error("解除定时禁言失败！", zap.Error(err))
+			c.ResponseError(errors.New("解除定时禁言失败！"))
+			return
+		}
+	}
+	model.Active = 0
+	model.Applied = 0
+	model.Status = MuteScheduleStatusFinished
+	err = g.db.updateMuteSchedule(model)
+	if err != nil {
+		g.Error("删除定时禁言计划失败！", zap.Error(err))
+		c.ResponseError(errors.New("删除定时禁言计划失败！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// CheckMuteScheduleLoop 执行定时禁言计划
+// 多实例部署时只有持有调度租约的实例执行，避免重复开启禁言和重复发送提示
+func (g *Group) CheckMuteScheduleLoop() {
+	var limit uint64 = 100
+	var errSleep = time.Second * 5
+	var checkSleep = time.Second * 10
+	for {
+		ok, err := g.leaseRedis.HoldLease(MuteScheduleLeaseCacheKey, g.leaseID, MuteScheduleLeaseTTL)
+		if err != nil {
+			g.Warn("获取定时禁言调度租约失败", zap.Error(err))
+		}
+		if !ok {
+			time.Sleep(checkSleep)
+			continue
+		}
+		now := time.Now()
+		endModels, err := g.db.queryMuteSchedulesToEnd(now.Unix(), limit)
+		if err != nil {
+			g.Warn("查询到期的定时禁言计划失败", zap.Error(err))
+			time.Sleep(errSleep)
+			continue
+		}
+		for _, model := range endModels {
+			g.endMuteSchedule(model)
+		}
+		startModels, err := g.db.queryMuteSchedulesToStart(now.Unix(), limit)
+		if err != nil {
+			g.Warn("查询待开始的定时禁言计划失败", zap.Error(err))
+			time.Sleep(errSleep)
+			continue
+		}
+		for _, model := range startModels {
+			g.startMuteSchedule(model, now)
+		}
+		time.Sleep(checkSleep)
+	}
+}
+
+// 开始禁言
+func (g *Group) startMuteSchedule(model *MuteScheduleModel, now time.Time) {
+	if model.NextEndAt <= now.Unix() { // 错过了禁言时间段（如服务停机），直接计算下一次
+		g.advanceMuteSchedule(model, now)
+		return
+	}
+	groupModel, err := g.db.QueryWithGroupNo(model.GroupNo)
+	if err != nil {
+		g.Warn("查询群信息失败", zap.Error(err))
+		return
+	}
+	if groupModel == nil || groupModel.Status == GroupStatusDisband {
+		model.Status = MuteScheduleStatusFinished
+		if err = g.db.updateMuteSchedule(model); err != nil {
+			g.Warn("更新定时禁言计划失败", zap.Error(err))
+		}
+		return
+	}
+	if groupModel.isArchived() { // 已归档的群跳过本次禁言（取消归档后按下一个时间段继续执行）
+		g.advanceMuteSchedule(model, time.Unix(model.NextEndAt, 0))
+		return
+	}
+	model.Active = 1
+	model.Applied = 0
+	if groupModel.Forbidden == 0 {
+		err = g.setGroupForbiddenWithSchedule(groupModel, 1)
+		if err != nil {
+			g.Warn("开启定时禁言失败", zap.Error(err), zap.String("group_no", model.GroupNo))
+			return
+		}
+		model.Applied = 1
+	}
+	err = g.db.updateMuteSchedule(model)
+	if err != nil {
+		g.Warn("更新定时禁言计划失败", zap.Error(err))
+		return
+	}
+	if model.Applied == 1 {
+		loc := model.location()
+		g.sendMuteScheduleTip(model.GroupNo, fmt.Sprintf("已按计划“%s”开启全员禁言，将于%s解除", model.Name, time.Unix(model.NextEndAt, 0).In(loc).Format("01月02日 15:04")))
+	}
+}
+
+// 结束禁言
+func (g *Group) endMuteSchedule(model *MuteScheduleModel) {
+	if err := g.liftMuteSchedule(model); err != nil {
+		g.Warn("解除定时禁言失败", zap.Error(err), zap.String("group_no", model.GroupNo))
+		return
+	}
+	g.advanceMuteSchedule(model, time.Unix(model.NextEndAt, 0))
+}
+
+// 解除计划开启的群禁言（群内还有其他禁言中的计划时交由其解除）
+func (g *Group) liftMuteSchedule(model *MuteScheduleModel) error {
+	if model.Applied != 1 {
+		return nil
+	}
+	now := time.Now().Unix()
+	otherModel, err := g.db.queryOtherActiveMuteSchedule(model.GroupNo, model.ScheduleNo, now)
+	if err != nil {
+		return err
+	}
+	if otherModel != nil {
+		otherModel.Applied = 1
+		return g.db.updateMuteSchedule(otherModel)
+	}
+	groupModel, err := g.db.QueryWithGroupNo(model.GroupNo)
+	if err != nil {
+		return err
+	}
+	if groupModel == nil || groupModel.Forbidden == 0 { // 已被手动解除
+		return nil
+	}
+	err = g.setGroupForbiddenWithSchedule(groupModel, 0)
+	if err != nil {
+		return err
+	}
+	g.sendMuteScheduleTip(model.GroupNo, fmt.Sprintf("计划“%s”已结束，已解除全员禁言", model.Name))
+	return nil
+}
+
+// 计算下一次禁言时间段
+func (g *Group) advanceMuteSchedule(model *MuteScheduleModel, after time.Time) {
+	model.Active = 0
+	model.Applied = 0
+	startAt, endAt, ok := model.nextWindow(after)
+	if ok && endAt > time.Now().Unix() {
+		model.NextStartAt = startAt
+		model.NextEndAt = endAt
+	} else if model.Type == MuteScheduleTypeRecurring {
+		startAt, endAt, ok = model.nextWindow(time.Now())
+		model.NextStartAt = startAt
+		model.NextEndAt = endAt
+	}
+	if !ok {
+		model.Status = MuteScheduleStatusFinished
+	}
+	err := g.db.updateMuteSchedule(model)
+	if err != nil {
+		g.Warn("更新定时禁言计划失败", zap.Error(err))
+	}
+}
+
+// 设置群全员禁言（由定时计划触发）
+func (g *Group) setGroupForbiddenWithSchedule(groupModel *Model, forbidden int) error {
+	var err error
+	if forbidden == 1 {
+		err = g.setIMWhitelistForGroupManager(groupModel.GroupNo)
+	} else {
+		err = g.resetIMWhitelist(make([]string, 0), groupModel.GroupNo)
+	}
+	if err != nil {
+		return err
+	}
+	groupModel.Forbidden = forbidden
+	groupModel.Version = g.ctx.GenSeq(common.GroupSeqKey)
+	err = g.db.Update(groupModel)
+	if err != nil {
+		return err
+	}
+	return g.ctx.SendChannelUpdateToGroup(groupModel.GroupNo)
+}
+
+// 发送定时禁言提示
+func (g *Group) sendMuteScheduleTip(groupNo string, content string) {
+	err := g.ctx.SendMessage(&config.MsgSendReq{
+		Header: config.MsgHeader{
+			RedDot: 1,
+		},
+		ChannelID:   groupNo,
+		ChannelType: common.ChannelTypeGroup.Uint8(),
+		Payload: []byte(util.ToJson(map[string]interface{}{
+			"content": content,
+			"type":    common.Tip,
+		})),
+	})
+	if err != nil {
+		g.Warn("发送定时禁言提示失败", zap.Error(err))
+	}
+}
+
+// 校验群是否存在以及操作者是否拥有禁言权限
+func (g *Group) checkMuteScheduleManager(groupNo string, uid string) error {
+	_, err := g.getGroupInfo(groupNo)
+	if err != nil {
+		return err
+	}
+	canMute, err := g.groupService.HasPermission(groupNo, uid, PermissionMuteMember)
+	if err != nil {
+		g.Error("查询群权限失败！", zap.Error(err))
+		return errors.New("查询群权限失败！")
+	}
+	if !canMute {
+		return errors.New("没有禁言权限，不能设置定时禁言！")
+	}
+	return nil
+}
+
+func (m *MuteScheduleModel) location() *time.Location {
+	loc, err := time.LoadLocation(m.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// nextWindow 获取after之后开始的禁言时间段
+func (m *MuteScheduleModel) nextWindow(after time.Time) (int64, int64, bool) {
+	if m.Type == MuteScheduleTypeOnce {
+		if m.NextStartAt != 0 { // 单次计划只执行一次
+			return 0, 0, false
+		}
+		return m.StartAt, m.EndAt, m.EndAt > after.Unix()
+	}
+	schedule, err := cron.ParseStandard(m.Cron)
+	if err != nil {
+		return 0, 0, false
+	}
+	start := schedule.Next(after.In(m.location()))
+	if start.IsZero() {
+		return 0, 0, false
+	}
+	return start.Unix(), start.Add(time.Duration(m.Duration) * time.Minute).Unix(), true
+}
+
+type muteScheduleReq struct {
+	Name     string `json:"name"`     // 计划名称
+	Type     int    `json:"type"`     // 类型 1.单次 2.重复
+	StartAt  int64  `json:"start_at"` // 单次禁言开始时间
+	EndAt    int64  `json:"end_at"`   // 单次禁言结束时间
+	Cron     string `json:"cron"`     // 重复禁言的开始时间cron表达式（分 时 日 月 周） 如每晚23点：0 23 * * *
+	Duration int    `json:"duration"` // 重复禁言每次持续时长（分钟）
+	Timezone string `json:"timezone"` // 时区 默认Asia/Shanghai
+}
+
+func (r muteScheduleReq) check() error {
+	if len(r.Name) > 100 {
+		return errors.New("计划名称过长！")
+	}
+	if _, err := time.LoadLocation(r.Timezone); err != nil {
+		return errors.New("时区格式有误！")
+	}
+	switch r.Type {
+	case MuteScheduleTypeOnce:
+		if r.StartAt <= 0 || r.EndAt <= r.StartAt {
+			return errors.New("禁言结束时间必须大于开始时间！")
+		}
+		if r.EndAt <= time.Now().Unix() {
+			return errors.New("禁言结束时间已过！")
+		}
+	case MuteScheduleTypeRecurring:
+		schedule, err := cron.ParseStandard(strings.TrimSpace(r.Cron))
+		if err != nil {
+			return errors.New("cron表达式格式有误！")
+		}
+		if r.Duration <= 0 || r.Duration > MuteScheduleMaxDuration {
+			return fmt.Errorf("禁言时长需在1到%d分钟之间！", MuteScheduleMaxDuration)
+		}
+		first := schedule.Next(time.Now())
+		second := schedule.Next(first)
+		if !second.IsZero() && second.Sub(first) < time.Duration(r.Duration)*time.Minute {
+			return errors.New("禁言时长不能超过重复间隔！")
+		}
+	default:
+		return errors.New("计划类型有误！")
+	}
+	return nil
+}
+
+type muteScheduleResp struct {
+	ScheduleNo  string `json:"schedule_no"`   // 计划编号
+	GroupNo     string `json:"group_no"`      // 群编号
+	Creator     string `json:"creator"`       // 创建者uid
+	Name        string `json:"name"`          // 计划名称
+	Type        int    `json:"type"`          // 类型 1.单次 2.重复
+	StartAt     int64  `json:"start_at"`      // 单次禁言开始时间
+	EndAt       int64  `json:"end_at"`        // 单次禁言结束时间
+	Cron        string `json:"cron"`          // 重复禁言的cron表达式
+	Duration    int    `json:"duration"`      // 重复禁言每次持续时长（分钟）
+	Timezone    string `json:"timezone"`      // 时区
+	NextStartAt int64  `json:"next_start_at"` // 下一次禁言开始时间
+	NextEndAt   int64  `json:"next_end_at"`   // 下一次禁言结束时间
+	Active      int    `json:"active"`        // 是否正在禁言中
+	CreatedAt   string `json:"created_at"`
+}
+
+func newMuteScheduleResp(m *MuteScheduleModel) *muteScheduleResp {
+	return &muteScheduleResp{
+		ScheduleNo:  m.ScheduleNo,
+		GroupNo:     m.GroupNo,
+		Creator:     m.Creator,
+		Name:        m.Name,
+		Type:        m.Type,
+		StartAt:     m.StartAt,
+		EndAt:       m.EndAt,
+		Cron:        m.Cron,
+		Duration:    m.Duration,
+		Timezone:    m.Timezone,
+		NextStartAt: m.NextStartAt,
+		NextEndAt:   m.NextEndAt,
+		Active:      m.Active,
+		CreatedAt:   m.CreatedAt.String(),
+	}
+}
@@ -0,0 +1,86 @@
+package group
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+)
+
+// insertMuteSchedule 添加定时禁言计划
+func (d *DB) insertMuteSchedule(model *MuteScheduleModel) error {
+	_, err := d.session.InsertInto("group_mute_schedule").Columns(util.AttrToUnderscore(model)...).Record(model).Exec()
+	return err
+}
+
+// queryMuteScheduleWithScheduleNo 通过计划编号查询定时禁言计划
+func (d *DB) queryMuteScheduleWithScheduleNo(scheduleNo string) (*MuteScheduleModel, error) {
+	var model *MuteScheduleModel
+	_, err := d.session.Select("*").From("group_mute_schedule").Where("schedule_no=?", scheduleNo).Load(&model)
+	return model, err
+}
+
+// queryMuteSchedulesWithGroupNo 查询群的有效定时禁言计划
+func (d *DB) queryMuteSchedulesWithGroupNo(groupNo string) ([]*MuteScheduleModel, error) {
+	var models []*MuteScheduleModel
+	_, err := d.session.Select("*").From("group_mute_schedule").Where("group_no=? and status=?", groupNo, MuteScheduleStatusNormal).OrderDir("id", false).Load(&models)
+	return models, err
+}
+
+// queryMuteScheduleCount 查询群的有效定时禁言计划数量
+func (d *DB) queryMuteScheduleCount(groupNo string) (int64, error) {
+	var count int64
+	_, err := d.session.Select("count(*)").From("group_mute_schedule").Where("group_no=? and status=?", groupNo, MuteScheduleStatusNormal).Load(&count)
+	return count, err
+}
+
+// queryMuteSchedulesToStart 查询到达开始时间的定时禁言计划
+func (d *DB) queryMuteSchedulesToStart(now int64, limit uint64) ([]*MuteScheduleModel, error) {
+	var models []*MuteScheduleModel
+	_, err := d.session.Select("*").From("group_mute_schedule").Where("status=? and active=0 and next_start_at<=?", MuteScheduleStatusNormal, now).OrderAsc("next_start_at").Limit(limit).Load(&models)
+	return models, err
+}
+
+// queryMuteSchedulesToEnd 查询到达结束时间的禁言中的计划
+func (d *DB) queryMuteSchedulesToEnd(now int64, limit uint64) ([]*MuteScheduleModel, error) {
+	var models []*MuteScheduleModel
+	_, err := d.session.Select("*").From("group_mute_schedule").Where("status=? and active=1 and next_end_at<=?", MuteScheduleStatusNormal, now).OrderAsc("next_end_at").Limit(limit).Load(&models)
+	return models, err
+}
+
+// queryOtherActiveMuteSchedule 查询群内其他禁言中的计划
+func (d *DB) queryOtherActiveMuteSchedule(groupNo string, scheduleNo string, now int64) (*MuteScheduleModel, error) {
+	var model *MuteScheduleModel
+	_, err := d.session.Select("*").From("group_mute_schedule").Where("group_no=? and schedule_no<>? and status=? and active=1 and next_end_at>?", groupNo, scheduleNo, MuteScheduleStatusNormal, now).OrderDir("next_end_at", false).Limit(1).Load(&model)
+	return model, err
+}
+
+// updateMuteSchedule 更新定时禁言计划的执行状态
+func (d *DB) updateMuteSchedule(model *MuteScheduleModel) error {
+	_, err := d.session.Update("group_mute_schedule").SetMap(map[string]interface{}{
+		"next_start_at": model.NextStartAt,
+		"next_end_at":   model.NextEndAt,
+		"active":        model.Active,
+		"applied":       model.Applied,
+		"status":        model.Status,
+	}).Where("id=?", model.Id).Exec()
+	return err
+}
+
+// MuteScheduleModel 群定时禁言计划
+type MuteScheduleModel struct {
+	ScheduleNo  string // 计划唯一编号
+	GroupNo     string // 群编号
+	Creator     string // 创建者uid
+	Name        string // 计划名称
+	Type        int    // 类型 1.单次 2.重复
+	StartAt     int64  // 单次禁言开始时间
+	EndAt       int64  // 单次禁言结束时间
+	Cron        string // 重复禁言的开始时间cron表达式（分 时 日 月 周）
+	Duration    int    // 重复禁言每次持续时长（分钟）
+	Timezone    string // 时区
+	NextStartAt int64  // 下一次禁言开始时间
+	NextEndAt   int64  // 下一次禁言结束时间
+	Active      int    // 是否正在禁言中
+	Applied     int    // 是否由此计划开启的群禁言
+	Status      int    // 状态 0.已结束 1.有效
+	db.BaseModel
+}
@@ -0,0 +1,474 @@
+package group
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// -------------------- 权限服务 --------------------
+
+// GetPermissions 获取用户在群内拥有的权限（不在群内返回空）
+func (s *Service) GetPermissions(groupNo string, uid string) ([]Permission, error) {
+	member, err := s.db.QueryMemberWithUID(uid, groupNo)
+	if err != nil {
+		return nil, err
+	}
+	return s.getPermissionsWithMember(member)
+}
+
+// HasPermission 用户在群内是否拥有指定权限
+func (s *Service) HasPermission(groupNo string, uid string, permission Permission) (bool, error) {
+	permissions, err := s.GetPermissions(groupNo, uid)
+	if err != nil {
+		return false, err
+	}
+	return containPermission(permissions, permission), nil
+}
+
+// CanOperateMember 操作者是否可以对目标成员执行指定权限的操作（如移除、禁言、撤回消息）
+// 操作者需拥有对应权限，且群主不可被操作，管理员只能被群主操作；目标已不在群内时只校验权限
+func (s *Service) CanOperateMember(groupNo string, operator string, target string, permission Permission) (bool, error) {
+	operatorMember, err := s.db.QueryMemberWithUID(operator, groupNo)
+	if err != nil {
+		return false, err
+	}
+	permissions, err := s.getPermissionsWithMember(operatorMember)
+	if err != nil {
+		return false, err
+	}
+	if !containPermission(permissions, permission) {
+		return false, nil
+	}
+	targetMember, err := s.db.QueryMemberWithUID(target, groupNo)
+	if err != nil {
+		return false, err
+	}
+	if targetMember == nil {
+		return true, nil
+	}
+	switch targetMember.Role {
+	case MemberRoleCreator:
+		return false, nil
+	case MemberRoleManager:
+		return operatorMember.Role == MemberRoleCreator, nil
+	}
+	return true, nil
+}
+
+func (s *Service) getPermissionsWithMember(member *MemberModel) ([]Permission, error) {
+	if member == nil {
+		return []Permission{}, nil
+	}
+	switch member.Role {
+	case MemberRoleCreator:
+		return AllPermissions, nil
+	case MemberRoleManager:
+		if member.RoleNo != "" {
+			roleModel, err := s.db.queryRole(member.GroupNo, member.RoleNo)
+			if err != nil {
+				return nil, err
+			}
+			if roleModel != nil {
+				return parsePermissions(roleModel.Permissions), nil
+			}
+		}
+		return s.getBuiltinRolePermissions(member.GroupNo, RoleNoManager)
+	}
+	return s.getBuiltinRolePermissions(member.GroupNo, RoleNoMember)
+}
+
+// 获取内置角色的权限（群未设置则使用默认权限）
+func (s *Service) getBuiltinRolePermissions(groupNo string, roleNo string) ([]Permission, error) {
+	roleModel, err := s.db.queryRole(groupNo, roleNo)
+	if err != nil {
+		return nil, err
+	}
+	if roleModel != nil {
+		return parsePermissions(roleModel.Permissions), nil
+	}
+	return defaultRolePermissions(roleNo), nil
+}
+
+// -------------------- 权限配置接口 --------------------
+
+// 群权限矩阵
+func (g *Group) permissionList(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	groupNo := c.Param("group_no")
+	_, err := g.getGroupInfo(groupNo)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	loginMember, err := g.db.QueryMemberWithUID(loginUID, groupNo)
+	if err != nil {
+		g.Error("查询用户群内身份错误", zap.Error(err))
+		c.ResponseError(errors.New("查询用户群内身份错误"))
+		return
+	}
+	if loginMember == nil {
+		c.ResponseError(errors.New("不在群内，无法查看群权限！"))
+		return
+	}
+	myPermissions, err := g.groupService.GetPermissions(groupNo, loginUID)
+	if err != nil {
+		g.Error("查询群权限失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询群权限失败！"))
+		return
+	}
+	roleModels, err := g.db.queryRoles(groupNo)
+	if err != nil {
+		g.Error("查询群角色失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询群角色失败！"))
+		return
+	}
+	managers, err := g.db.queryManagersWithRoleNo(groupNo)
+	if err != nil {
+		g.Error("查询管理员子角色失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询管理员子角色失败！"))
+		return
+	}
+	roles := []*roleResp{
+		{RoleNo: RoleNoManager, Name: "管理员", Builtin: 1, Permissions: defaultRolePermissions(RoleNoManager), UIDs: make([]string, 0)},
+		{RoleNo: RoleNoMember, Name: "成员", Builtin: 1, Permissions: defaultRolePermissions(RoleNoMember), UIDs: make([]string, 0)},
+	}
+	for _, roleModel := range roleModels {
+		var role *roleResp
+		for _, builtinRole := range roles[:2] {
+			if builtinRole.RoleNo == roleModel.RoleNo {
+				role = builtinRole
+				break
+			}
+		}
+		if role == nil {
+			role = &roleResp{RoleNo: roleModel.RoleNo, Name: roleModel.Name, UIDs: make([]string, 0)}
+			roles = append(roles, role)
+		}
+		role.Permissions = parsePermissions(roleModel.Permissions)
+	}
+	for _, manager := range managers {
+		for _, role := range roles {
+			if role.Builtin == 0 && role.RoleNo == manager.RoleNo {
+				role.UIDs = append(role.UIDs, manager.UID)
+				break
+			}
+		}
+	}
+	c.Response(&permissionListResp{
+		Permissions: AllPermissions,
+		Roles:       roles,
+		My:          myPermissions,
+	})
+}
+
+// 修改角色权限
+func (g *Group) permissionUpdate(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	groupNo := c.Param("group_no")
+	var req struct {
+		RoleNo      string         `json:"role_no"`     // 角色编号
+		Permissions map[string]int `json:"permissions"` // 权限 key为权限 value 0.关闭 1.开启
+	}
+	if err := c.BindJSON(&req); err != nil {
+		g.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if len(req.Permissions) == 0 {
+		c.ResponseError(errors.New("没有需要更新的权限！"))
+		return
+	}
+	if err := g.checkGroupCreator(groupNo, loginUID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	roleModel, err := g.db.queryRole(groupNo, req.RoleNo)
+	if err != nil {
+		g.Error("查询群角色失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询群角色失败！"))
+		return
+	}
+	if roleModel == nil {
+		if req.RoleNo != RoleNoManager && req.RoleNo != RoleNoMember {
+			c.ResponseError(errors.New("角色不存在！"))
+			return
+		}
+		roleModel = &RoleModel{
+			GroupNo:     groupNo,
+			RoleNo:      req.RoleNo,
+			Permissions: util.ToJson(defaultRolePermissions(req.RoleNo)),
+		}
+	}
+	permissions := parsePermissions(roleModel.Permissions)
+	for key, value := range req.Permissions {
+		permission := Permission(key)
+		if !containPermission(AllPermissions, permission) {
+			c.ResponseError(errors.New("不支持的权限：" + key))
+			return
+		}
+		permissions = removePermission(permissions, permission)
+		if value == 1 {
+			permissions = append(permissions, permission)
+		}
+	}
+	roleModel.Permissions = util.ToJson(permissions)
+	err = g.db.insertOrUpdateRole(roleModel)
+	if err != nil {
+		g.Error("更新群角色权限失败！", zap.Error(err))
+		c.ResponseError(errors.New("更新群角色权限失败！"))
+		return
+	}
+	g.sendPermissionUpdateCMD(groupNo)
+	c.ResponseOK()
+}
+
+// 添加管理员子角色
+func (g *Group) roleAdd(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	groupNo := c.Param("group_no")
+	var req struct {
+		Name        string       `json:"name"`        // 角色名称
+		Permissions []Permission `json:"permissions"` // 权限
+	}
+	if err := c.BindJSON(&req); err != nil {
+		g.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		c.ResponseError(errors.New("角色名称不能为空！"))
+		return
+	}
+	for _, permission := range req.Permissions {
+		if !containPermission(AllPermissions, permission) {
+			c.ResponseError(errors.New("不支持的权限：" + string(permission)))
+			return
+		}
+	}
+	if err := g.checkGroupCreator(groupNo, loginUID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	count, err := g.db.queryCustomRoleCount(groupNo)
+	if err != nil {
+		g.Error("查询群角色数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询群角色数量失败！"))
+		return
+	}
+	if count >= RoleMaxCount {
+		c.ResponseError(errors.New("群角色数量已达上限！"))
+		return
+	}
+	if req.Permissions == nil {
+		req.Permissions = make([]Permission, 0)
+	}
+	roleModel := &RoleModel{
+		GroupNo:     groupNo,
+		RoleNo:      util.GenerUUID(),
+		Name:        req.Name,
+		Permissions: util.ToJson(req.Permissions),
+	}
+	err = g.db.insertOrUpdateRole(roleModel)
+	if err != nil {
+		g.Error("添加群角色失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加群角色失败！"))
+		return
+	}
+	c.Response(&roleResp{
+		RoleNo:      roleModel.RoleNo,
+		Name:        roleModel.Name,
+		Permissions: req.Permissions,
+		UIDs:        make([]string, 0),
+	})
+}
+
+// 删除管理员子角色
+func (g *Group) roleDelete(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	groupNo := c.Param("group_no")
+	roleNo := c.Param("role_no")
+	if roleNo == RoleNoManager || roleNo == RoleNoMember {
+		c.ResponseError(errors.New("内置角色不能删除！"))
+		return
+	}
+	if err := g.checkGroupCreator(groupNo, loginUID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	tx, err := g.ctx.DB().Begin()
+	if err != nil {
+		g.Error("开启事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("开启事务失败！"))
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.RollbackUnlessCommitted()
+			panic(err)
+		}
+	}()
+	err = g.db.deleteRoleTx(groupNo, roleNo, tx)
+	if err != nil {
+		tx.Rollback()
+		g.Error("删除群角色失败！", zap.Error(err))
+		c.ResponseError(errors.New("删除群角色失败！"))
+		return
+	}
+	err = g.db.clearMemberRoleNoTx(groupNo, roleNo, tx)
+	if err != nil {
+		tx.Rollback()
+		g.Error("清除成员角色失败！", zap.Error(err))
+		c.ResponseError(errors.New("清除成员角色失败！"))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		g.Error("提交事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("提交事务失败！"))
+		return
+	}
+	g.sendPermissionUpdateCMD(groupNo)
+	c.ResponseOK()
+}
+
+// 设置管理员的子角色（role_no为空则恢复为默认管理员权限）
+func (g *Group) managerRoleSet(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	groupNo := c.Param("group_no")
+	uid := c.Param("uid")
+	var req struct {
+		RoleNo string `json:"role_no"` // 子角色编号
+	}
+	if err := c.BindJSON(&req); err != nil {
+		g.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if err := g.checkGroupCreator(groupNo, loginUID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	member, err := g.db.QueryMemberWithUID(uid, groupNo)
+	if err != nil {
+		g.Error("查询成员信息失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询成员信息失败！"))
+		return
+	}
+	if member == nil || member.Role != MemberRoleManager {
+		c.ResponseError(errors.New("只能为管理员设置角色！"))
+		return
+	}
+	if req.RoleNo == RoleNoManager {
+		req.RoleNo = ""
+	}
+	if req.RoleNo != "" {
+		roleModel, err := g.db.queryRole(groupNo, req.RoleNo)
+		if err != nil {
+			g.Error("查询群角色失败！", zap.Error(err))
+			c.ResponseError(errors.New("查询群角色失败！"))
+			return
+		}
+		if roleModel == nil || req.RoleNo == RoleNoMember {
+			c.ResponseError(errors.New("角色不存在！"))
+			return
+		}
+	}
+	err = g.db.updateMemberRoleNo(groupNo, uid, req.RoleNo)
+	if err != nil {
+		g.Error("设置管理员角色失败！", zap.Error(err))
+		c.ResponseError(errors.New("设置管理员角色失败！"))
+		return
+	}
+	g.sendPermissionUpdateCMD(groupNo)
+	c.ResponseOK()
+}
+
+// 通知群成员群权限已变更（权限已保存，通知失败只记录日志）
+func (g *Group) sendPermissionUpdateCMD(groupNo string) {
+	err := g.ctx.SendCMD(config.MsgCMDReq{
+		ChannelID:   groupNo,
+		ChannelType: common.ChannelTypeGroup.Uint8(),
+		CMD:         CMDGroupPermissionUpdate,
+		Param: map[string]interface{}{
+			"group_no": groupNo,
+		},
+	})
+	if err != nil {
+		g.Warn("发送群权限变更命令失败！", zap.Error(err), zap.String("group_no", groupNo))
+	}
+}
+
+// 校验群是否存在以及操作者是否是群主
+func (g *Group) checkGroupCreator(groupNo string, uid string) error {
+	_, err := g.getGroupInfo(groupNo)
+	if err != nil {
+		return err
+	}
+	isCreator, err := g.db.QueryIsGroupCreator(groupNo, uid)
+	if err != nil {
+		g.Error("查询是否是群主失败！", zap.Error(err))
+		return errors.New("查询是否是群主失败！")
+	}
+	if !isCreator {
+		return errors.New("只有群主才能设置群权限！")
+	}
+	return nil
+}
+
+func defaultRolePermissions(roleNo string) []Permission {
+	if roleNo == RoleNoManager {
+		return defaultManagerPermissions
+	}
+	return defaultMemberPermissions
+}
+
+func parsePermissions(permissionsJSON string) []Permission {
+	permissions := make([]Permission, 0)
+	if strings.TrimSpace(permissionsJSON) == "" {
+		return permissions
+	}
+	err := util.ReadJsonByByte([]byte(permissionsJSON), &permissions)
+	if err != nil {
+		return make([]Permission, 0)
+	}
+	return permissions
+}
+
+func containPermission(permissions []Permission, permission Permission) bool {
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func removePermission(permissions []Permission, permission Permission) []Permission {
+	newPermissions := make([]Permission, 0, len(permissions))
+	for _, p := range permissions {
+		if p != permission {
+			newPermissions = append(newPermissions, p)
+		}
+	}
+	return newPermissions
+}
+
+type roleResp struct {
+	RoleNo      string       `json:"role_no"`     // 角色编号
+	Name        string       `json:"name"`        // 角色名称
+	Builtin     int          `json:"builtin"`     // 是否内置角色
+	Permissions []Permission `json:"permissions"` // 角色拥有的权限
+	UIDs        []string     `json:"uids"`        // 使用此子角色的管理员
+}
+
+type permissionListResp struct {
+	Permissions []Permission `json:"permissions"` // 所有权限
+	Roles       []*roleResp  `json:"roles"`       // 角色列表
+	My          []Permission `json:"my"`          // 我拥有的权限
+}
@@ -0,0 +1,67 @@
+package group
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/gocraft/dbr/v2"
+)
+
+// queryRole 查询群角色
+func (d *DB) queryRole(groupNo string, roleNo string) (*RoleModel, error) {
+	var model *RoleModel
+	_, err := d.session.Select("*").From("group_role").Where("group_no=? and role_no=?", groupNo, roleNo).Load(&model)
+	return model, err
+}
+
+// queryRoles 查询群的所有角色
+func (d *DB) queryRoles(groupNo string) ([]*RoleModel, error) {
+	var models []*RoleModel
+	_, err := d.session.Select("*").From("group_role").Where("group_no=?", groupNo).OrderAsc("id").Load(&models)
+	return models, err
+}
+
+// queryCustomRoleCount 查询群自定义子角色数量
+func (d *DB) queryCustomRoleCount(groupNo string) (int64, error) {
+	var count int64
+	_, err := d.session.Select("count(*)").From("group_role").Where("group_no=? and role_no not in ?", groupNo, []string{RoleNoManager, RoleNoMember}).Load(&count)
+	return count, err
+}
+
+// insertOrUpdateRole 添加或更新群角色
+func (d *DB) insertOrUpdateRole(model *RoleModel) error {
+	_, err := d.session.InsertBySql("insert into group_role(group_no,role_no,name,permissions) values(?,?,?,?) ON DUPLICATE KEY UPDATE name=VALUES(name),permissions=VALUES(permissions)", model.GroupNo, model.RoleNo, model.Name, model.Permissions).Exec()
+	return err
+}
+
+// deleteRoleTx 删除群角色
+func (d *DB) deleteRoleTx(groupNo string, roleNo string, tx *dbr.Tx) error {
+	_, err := tx.DeleteFrom("group_role").Where("group_no=? and role_no=?", groupNo, roleNo).Exec()
+	return err
+}
+
+// clearMemberRoleNoTx 清除使用了某个子角色的成员角色
+func (d *DB) clearMemberRoleNoTx(groupNo string, roleNo string, tx *dbr.Tx) error {
+	_, err := tx.Update("group_member").Set("role_no", "").Where("group_no=? and role_no=?", groupNo, roleNo).Exec()
+	return err
+}
+
+// updateMemberRoleNo 设置管理员的子角色
+func (d *DB) updateMemberRoleNo(groupNo string, uid string, roleNo string) error {
+	_, err := d.session.Update("group_member").Set("role_no", roleNo).Where("group_no=? and uid=? and is_deleted=0 and role=?", groupNo, uid, MemberRoleManager).Exec()
+	return err
+}
+
+// queryManagersWithRoleNo 查询设置了子角色的管理员
+func (d *DB) queryManagersWithRoleNo(groupNo string) ([]*MemberModel, error) {
+	var models []*MemberModel
+	_, err := d.session.Select("*").From("group_member").Where("group_no=? and is_deleted=0 and role=? and role_no<>''", groupNo, MemberRoleManager).Load(&models)
+	return models, err
+}
+
+// RoleModel 群角色
+type RoleModel struct {
+	GroupNo     string // 群编号
+	RoleNo      string // 角色编号
+	Name        string // 角色名称
+	Permissions string // 权限列表(JSON数组)
+	db.BaseModel
+}
@@ -36,6 +36,9 @@ type IService interface {
 	GetSettingsWithUIDs(groupNo string, uids []string) ([]*SettingResp, error)
 
 	// -------------------- 群成员 --------------------
+	// SendWelcome 向新加入的成员发送群欢迎语（未设置欢迎语时不发送）
+	SendWelcome(groupNo string, members []*config.UserBaseVo) error
+
 	// 获取指定群的群成员列表
 	GetMembers(groupNo string) ([]*MemberResp, error)
 	// 获取指定群的指定成员信息
@@ -66,6 +69,22 @@ type IService interface {
 	GetMembersWithUIDAndGroupIds(uid string, groupNos []string) ([]*MemberResp, error)
 	// 查询一批群的管理员及群主
 	GetManagersWithGroupNos(groupNos []string) ([]*MemberResp, error)
+	// GetSlowModeReleaseAt 成员因慢速模式被限制发言的解除时间（10位时间戳，未被限制返回0）
+	GetSlowModeReleaseAt(groupNo string, uid string) (int64, error)
+
+	// -------------------- 群权限 --------------------
+	// GetPermissions 获取用户在群内拥有的权限
+	GetPermissions(groupNo string, uid string) ([]Permission, error)
+	// HasPermission 用户在群内是否拥有指定权限
+	HasPermission(groupNo string, uid string, permission Permission) (bool, error)
+	// CanOperateMember 操作者是否可以对目标成员执行指定权限的操作
+	CanOperateMember(groupNo string, operator string, target string, permission Permission) (bool, error)
+
+	// -------------------- 群操作日志 --------------------
+	// AddAuditLog 记录群管理操作日志
+	AddAuditLog(req *AuditLogReq) error
+	// GetTopic 查询话题
+	GetTopic(topicNo string) (*TopicResp, error)
 }
 
 // Service Service
@@ -415,6 +434,9 @@ type InfoResp struct {
 	Invite              int       `json:"invite"`                 // 是否开启邀请确认 0.否 1.是
 	ForbiddenAddFriend  int       `json:"forbidden_add_friend"`   //群内禁止加好友
 	AllowViewHistoryMsg int       `json:"allow_view_history_msg"` // 是否允许新成员查看历史记录
+	RevokeSecond        int       `json:"revoke_second"`          // 普通成员消息可撤回时长 0.使用全局配置
+	ManagerRevokeSecond int       `json:"manager_revoke_second"`  // 管理员消息可撤回时长 0.使用全局配置
+	CreatorRevokeSecond int       `json:"creator_revoke_second"`  // 群主消息可撤回时长 0.使用全局配置
 	CreatedAt           string    `json:"created_at"`
 	UpdatedAt           string    `json:"updated_at"`
 	Version             int64     `json:"version"` // 群数据版本
@@ -432,6 +454,9 @@ func toInfoResp(m *Model) *InfoResp {
 		Invite:              m.Invite,
 		ForbiddenAddFriend:  m.ForbiddenAddFriend,
 		AllowViewHistoryMsg: m.AllowViewHistoryMsg,
+		RevokeSecond:        m.RevokeSecond,
+		ManagerRevokeSecond: m.ManagerRevokeSecond,
+		CreatorRevokeSecond: m.CreatorRevokeSecond,
 		CreatedAt:           m.CreatedAt.String(),
 		UpdatedAt:           m.UpdatedAt.String(),
 		Version:             m.Version,
@@ -451,6 +476,8 @@ type MemberResp struct {
 	IsDeleted          int    //是否已删除
 	ForbiddenExpirTime int64  // 禁言时长
 	Status             int    // 成员状态
+	Robot              int    // 是否是机器人
+	RulesPending       int    // 是否待同意群规
 }
 
 func newMemberResp(m *MemberDetailModel) *MemberResp {
@@ -466,6 +493,8 @@ func newMemberResp(m *MemberDetailModel) *MemberResp {
 		IsDeleted:          m.IsDeleted,
 		ForbiddenExpirTime: m.ForbiddenExpirTime,
 		Status:             m.Status,
+		Robot:              m.Robot,
+		RulesPending:       m.RulesPending,
 		CreatedAt:          time.Time(m.CreatedAt).Unix(),
 	}
 }
@@ -534,6 +563,17 @@ type GroupResp struct {
 	Role                     int       `json:"role"`                        // 我在群聊里的角色
 	ForbiddenExpirTime       int64     `json:"forbidden_expir_time"`        // 我在此群的禁言过期时间
 	AllowMemberPinnedMessage int       `json:"allow_member_pinned_message"` //是否允许群成员置顶消息
+	JoinApply                int       `json:"join_apply"`                  // 是否允许申请入群
+	JoinQuestions            []string  `json:"join_questions"`              // 入群问题
+	IsPublic                 int       `json:"is_public"`                   // 是否公开到群目录
+	SlowMode                 int       `json:"slow_mode"`                   // 慢速模式（秒） 0.关闭
+	SuccessionPolicy         int       `json:"succession_policy"`           // 群主继承策略
+	TopicMode                int       `json:"topic_mode"`                  // 是否开启话题模式
+	MemberMaxCount           int       `json:"member_max_count"`            // 群成员数量上限 0.使用全局配置
+	RulesRequired            int       `json:"rules_required"`              // 新成员是否需要同意群规后才能发言
+	RevokeSecond             int       `json:"revoke_second"`               // 普通成员消息可撤回时长 0.使用全局配置
+	ManagerRevokeSecond      int       `json:"manager_revoke_second"`       // 管理员消息可撤回时长 0.使用全局配置
+	CreatorRevokeSecond      int       `json:"creator_revoke_second"`       // 群主消息可撤回时长 0.使用全局配置
 	CreatedAt                string    `json:"created_at"`
 	UpdatedAt                string    `json:"updated_at"`
 	Version                  int64     `json:"version"` // 群数据版本
@@ -565,6 +605,17 @@ func (g *GroupResp) from(model *DetailModel) *GroupResp {
 		Status:                   model.Status,
 		AllowViewHistoryMsg:      model.AllowViewHistoryMsg,
 		AllowMemberPinnedMessage: model.AllowMemberPinnedMessage,
+		JoinApply:                model.JoinApply,
+		JoinQuestions:            parseJoinQuestions(model.JoinQuestions),
+		IsPublic:                 model.IsPublic,
+		SlowMode:                 model.SlowMode,
+		SuccessionPolicy:         model.SuccessionPolicy,
+		TopicMode:                model.TopicMode,
+		MemberMaxCount:           model.MemberMaxCount,
+		RulesRequired:            model.RulesRequired,
+		RevokeSecond:             model.RevokeSecond,
+		ManagerRevokeSecond:      model.ManagerRevokeSecond,
+		CreatorRevokeSecond:      model.CreatorRevokeSecond,
 		CreatedAt:                model.CreatedAt.String(),
 		UpdatedAt:                model.UpdatedAt.String(),
 	}
@@ -0,0 +1,276 @@
+package group
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/go-redis/redis"
+	"go.uber.org/zap"
+)
+
+// 慢速模式：普通成员在群（或群内话题）发送消息后，将其加入IM黑名单N秒，期间IM拒绝投递其消息，到期后自动解除（群主和管理员不受限制）
+// 限制是收到消息通知后才加上的（事后限制），通知到达前成员短时间内连续发送的消息仍会被投递
+func (g *Group) slowModeMessagesListen(messages []*config.MessageResp) {
+	var (
+		senders  = make([]*config.MessageResp, 0, len(messages))
+		topicNos = make([]string, 0)
+	)
+	for _, message := range messages {
+		if message.ChannelType != common.ChannelTypeGroup.Uint8() && message.ChannelType != common.ChannelTypeCommunityTopic.Uint8() {
+			continue
+		}
+		if strings.TrimSpace(message.FromUID) == "" || message.Header.NoPersist == 1 || message.Header.SyncOnce == 1 || message.FromUID == g.ctx.GetConfig().Account.SystemUID {
+			continue
+		}
+		senders = append(senders, message)
+		if message.ChannelType == common.ChannelTypeCommunityTopic.Uint8() {
+			topicNos = append(topicNos, message.ChannelID)
+		}
+	}
+	if len(senders) == 0 {
+		return
+	}
+	// 话题消息按所属群的慢速模式限制
+	topicGroupMap := map[string]string{}
+	if len(topicNos) > 0 {
+		topicModels, err := g.db.queryTopicsWithTopicNos(topicNos)
+		if err != nil {
+			g.Warn("查询群话题失败！", zap.Error(err))
+			return
+		}
+		for _, topicModel := range topicModels {
+			topicGroupMap[topicModel.TopicNo] = topicModel.GroupNo
+		}
+	}
+	groupUIDsMap := map[string]map[string]bool{} // 群编号 -> 发送者
+	groupNos := make([]string, 0)
+	uids := make([]string, 0)
+	for _, message := range senders {
+		groupNo := message.ChannelID
+		if message.ChannelType == common.ChannelTypeCommunityTopic.Uint8() {
+			groupNo = topicGroupMap[message.ChannelID]
+			if groupNo == "" {
+				continue
+			}
+		}
+		uidMap := groupUIDsMap[groupNo]
+		if uidMap == nil {
+			uidMap = map[string]bool{}
+			groupUIDsMap[groupNo] = uidMap
+			groupNos = append(groupNos, groupNo)
+		}
+		if !uidMap[message.FromUID] {
+			uidMap[message.FromUID] = true
+			uids = append(uids, message.FromUID)
+		}
+	}
+	if len(groupNos) == 0 {
+		return
+	}
+	groups, err := g.db.QueryWithGroupNos(groupNos)
+	if err != nil {
+		g.Warn("查询群信息失败！", zap.Error(err))
+		return
+	}
+	slowModeGroupMap := map[string]*Model{}
+	slowModeGroupNos := make([]string, 0, len(groups))
+	for _, group := range groups {
+		if group.SlowMode > 0 {
+			slowModeGroupMap[group.GroupNo] = group
+			slowModeGroupNos = append(slowModeGroupNos, group.GroupNo)
+		}
+	}
+	if len(slowModeGroupNos) == 0 {
+		return
+	}
+	members, err := g.db.queryMembersWithGroupNosAndUIDs(slowModeGroupNos, uids)
+	if err != nil {
+		g.Warn("查询群成员信息失败！", zap.Error(err))
+		return
+	}
+	for _, member := range members {
+		group := slowModeGroupMap[member.GroupNo]
+		if group == nil || !groupUIDsMap[member.GroupNo][member.UID] {
+			continue
+		}
+		if member.Role != MemberRoleCommon || member.Robot == 1 {
+			continue
+		}
+		err = g.limitSlowModeMember(group.GroupNo, member.UID, group.SlowMode)
+		if err != nil {
+			g.Warn("慢速模式限制成员发言失败！", zap.Error(err), zap.String("group_no", group.GroupNo), zap.String("uid", member.UID))
+		}
+	}
+}
+
+// 限制成员发言直到慢速模式间隔结束
+func (g *Group) limitSlowModeMember(groupNo string, uid string, slowMode int) error {
+	releaseAt := time.Now().Unix() + int64(slowMode)
+	err := g.ctx.GetRedisConn().Hset(fmt.Sprintf("%s%s", SlowModeLimitCachePrefix, groupNo), uid, fmt.Sprintf("%d", releaseAt))
+	if err != nil {
+		return err
+	}
+	err = g.ctx.GetRedisConn().ZAdd(SlowModeReleaseCacheKey, float64(releaseAt), fmt.Sprintf("%s@%s", groupNo, uid))
+	if err != nil {
+		return err
+	}
+	err = g.setGroupBlacklist(groupNo, []string{uid}, true)
+	if err != nil {
+		return err
+	}
+	return g.ctx.SendCMD(config.MsgCMDReq{
+		NoPersist:   true,
+		ChannelID:   uid,
+		ChannelType: common.ChannelTypePerson.Uint8(),
+		CMD:         CMDGroupSlowModeLimit,
+		Param: map[string]interface{}{
+			"group_no":   groupNo,
+			"slow_mode":  slowMode,
+			"release_at": releaseAt,
+		},
+	})
+}
+
+// 获取群内因慢速模式被限制发言的成员
+func (g *Group) getSlowModeLimitedUIDs(groupNo string) ([]string, error) {
+	limitMap, err := g.ctx.GetRedisConn().Hgetall(fmt.Sprintf("%s%s", SlowModeLimitCachePrefix, groupNo))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	uids := make([]string, 0, len(limitMap))
+	for uid, releaseAtStr := range limitMap {
+		releaseAt, _ := strconv.ParseInt(releaseAtStr, 10, 64)
+		if releaseAt > now {
+			uids = append(uids, uid)
+		}
+	}
+	return uids, nil
+}
+
+// GetSlowModeReleaseAt 成员因慢速模式被限制发言的解除时间（未被限制返回0）
+func (s *Service) GetSlowModeReleaseAt(groupNo string, uid string) (int64, error) {
+	releaseAtStr, err := s.ctx.GetRedisConn().Hget(fmt.Sprintf("%s%s", SlowModeLimitCachePrefix, groupNo), uid)
+	if err != nil {
+		return 0, err
+	}
+	releaseAt, _ := strconv.ParseInt(releaseAtStr, 10, 64)
+	if releaseAt <= time.Now().Unix() {
+		return 0, nil
+	}
+	return releaseAt, nil
+}
+
+// 解除成员的慢速模式限制（成员本身被禁言、拉黑或待同意群规时不移出IM黑名单）
+func (g *Group) releaseSlowModeMember(groupNo string, uid string) error {
+	member, err := g.db.QueryMemberWithUID(uid, groupNo)
+	if err != nil {
+		return err
+	}
+	if member != nil && member.Status != int(common.GroupMemberStatusBlacklist) && member.ForbiddenExpirTime <= time.Now().Unix() && member.RulesPending == 0 {
+		err = g.setGroupBlacklist(groupNo, []string{uid}, false)
+		if err != nil {
+			return err
+		}
+	}
+	return g.ctx.GetRedisConn().Hdel(fmt.Sprintf("%s%s", SlowModeLimitCachePrefix, groupNo), uid)
+}
+
+// 关闭慢速模式时解除群内所有成员的限制
+func (g *Group) releaseSlowModeGroup(groupNo string) {
+	uids, err := g.getSlowModeLimitedUIDs(groupNo)
+	if err != nil {
+		g.Warn("查询慢速模式限制成员失败！", zap.Error(err))
+		return
+	}
+	for _, uid := range uids {
+		err = g.releaseSlowModeMember(groupNo, uid)
+		if err != nil {
+			g.Warn("解除慢速模式限制失败！", zap.Error(err))
+			continue
+		}
+		_ = g.ctx.GetRedisConn().ZRem(SlowModeReleaseCacheKey, fmt.Sprintf("%s@%s", groupNo, uid))
+	}
+}
+
+// CheckSlowModeReleaseLoop 检查慢速模式限制是否到期
+// 多实例部署时只有持有调度租约的实例执行解除，解除失败的成员保留在待解除集合中，等待一段时间后重试
+func (g *Group) CheckSlowModeReleaseLoop() {
+	var errSleep = time.Second * 5
+	var checkSleep = time.Second * 1
+	for {
+		ok, err := g.leaseRedis.HoldLease(SlowModeReleaseLeaseCacheKey, g.leaseID, SlowModeReleaseLeaseTTL)
+		if err != nil {
+			g.Warn("获取慢速模式解除调度租约失败", zap.Error(err))
+		}
+		if !ok {
+			time.Sleep(checkSleep)
+			continue
+		}
+		items, err := g.ctx.GetRedisConn().ZRangeByScore(SlowModeReleaseCacheKey, redis.ZRangeBy{
+			Min:   "0",
+			Max:   fmt.Sprintf("%d", time.Now().Unix()),
+			Count: 100,
+		})
+		if err != nil {
+			g.Warn("查询慢速模式待解除成员失败", zap.Error(err))
+			time.Sleep(errSleep)
+			continue
+		}
+		failed := false
+		for _, item := range items {
+			groupNoAndUID := strings.Split(item, "@")
+			if len(groupNoAndUID) == 2 {
+				err = g.releaseSlowModeMember(groupNoAndUID[0], groupNoAndUID[1])
+				if err != nil {
+					g.Warn("解除慢速模式限制失败", zap.Error(err), zap.String("item", item))
+					failed = true
+					continue
+				}
+			}
+			err = g.ctx.GetRedisConn().ZRem(SlowModeReleaseCacheKey, item)
+			if err != nil {
+				g.Warn("移除慢速模式待解除成员失败", zap.Error(err))
+				failed = true
+			}
+		}
+		if failed {
+			time.Sleep(errSleep)
+		} else if len(items) == 0 {
+			time.Sleep(checkSleep)
+		}
+	}
+}
+
+// 发送慢速模式开关提示
+func (g *Group) sendSlowModeTip(groupNo string, operator string, operatorName string, slowMode int) error {
+	content := "{0}关闭了慢速模式"
+	if slowMode > 0 {
+		content = fmt.Sprintf("{0}开启了慢速模式，成员每%d秒只能发送一条消息", slowMode)
+	}
+	return g.ctx.SendMessage(&config.MsgSendReq{
+		Header: config.MsgHeader{
+			RedDot: 1,
+		},
+		ChannelID:   groupNo,
+		ChannelType: common.ChannelTypeGroup.Uint8(),
+		Payload: []byte(util.ToJson(map[string]interface{}{
+			"content": content,
+			"extra": []config.UserBaseVo{
+				{
+					UID:  operator,
+					Name: operatorName,
+				},
+			},
+			"data": map[string]string{
+				GroupAttrKeySlowMode: fmt.Sprintf("%d", slowMode),
+			},
+			"type": common.GroupUpdate,
+		})),
+	})
+}
@@ -0,0 +1,24 @@
+-- +migrate Up
+
+ALTER TABLE `group` ADD COLUMN join_apply smallint not null DEFAULT 0 COMMENT '是否允许申请入群 0.不允许 1.允许';
+ALTER TABLE `group` ADD COLUMN join_questions VARCHAR(2000) not null DEFAULT '' COMMENT '入群问题(JSON数组)';
+
+-- 入群申请
+create table `group_join_apply`
+(
+  id         bigint        not null primary key AUTO_INCREMENT,
+  apply_no   VARCHAR(40)   not null default '' comment '申请唯一编号',
+  group_no   VARCHAR(40)   not null default '' comment '群编号',
+  uid        VARCHAR(40)   not null default '' comment '申请者uid',
+  answers    VARCHAR(2000) not null default '' comment '入群问题回答(JSON数组)',
+  remark     VARCHAR(200)  not null default '' comment '申请备注',
+  status     smallint      not null default 0 comment '状态 0.待审核 1.已通过 2.已拒绝 3.已过期',
+  reviewer   VARCHAR(40)   not null default '' comment '审核者uid',
+  reason     VARCHAR(200)  not null default '' comment '审核理由',
+  expire_at  bigint        not null default 0 comment '过期时间（10位时间戳）',
+  created_at timeStamp     not null DEFAULT CURRENT_TIMESTAMP comment '创建时间',
+  updated_at timeStamp     not null DEFAULT CURRENT_TIMESTAMP comment '更新时间'
+);
+CREATE UNIQUE INDEX `group_join_apply_apply_no` on `group_join_apply` (`apply_no`);
+CREATE INDEX `group_join_apply_group_no_status` on `group_join_apply` (`group_no`, `status`);
+CREATE INDEX `group_join_apply_uid` on `group_join_apply` (`uid`);
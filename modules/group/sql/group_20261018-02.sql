@@ -0,0 +1,5 @@
+-- +migrate Up
+
+ALTER TABLE `group` ADD COLUMN is_public smallint not null DEFAULT 0 COMMENT '是否公开到群目录 0.否 1.是';
+ALTER TABLE `group` ADD COLUMN public_ban smallint not null DEFAULT 0 COMMENT '是否被后台禁止在群目录展示 0.否 1.是';
+CREATE INDEX `group_is_public` on `group` (`is_public`, `public_ban`, `status`);
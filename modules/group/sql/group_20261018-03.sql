@@ -0,0 +1,23 @@
+-- +migrate Up
+
+-- 群邀请链接
+create table `group_invite_link`
+(
+  id           bigint        not null primary key AUTO_INCREMENT,
+  link_no      VARCHAR(40)   not null default '' comment '链接唯一编号',
+  group_no     VARCHAR(40)   not null default '' comment '群编号',
+  creator      VARCHAR(40)   not null default '' comment '创建者uid',
+  name         VARCHAR(100)  not null default '' comment '链接名称',
+  expire_at    bigint        not null default 0 comment '过期时间（10位时间戳） 0.永不过期',
+  max_uses     integer       not null default 0 comment '最大使用次数 0.不限',
+  need_approve smallint      not null default 0 comment '是否需要审核 0.否 1.是',
+  join_count   integer       not null default 0 comment '通过此链接入群人数',
+  apply_count  integer       not null default 0 comment '通过此链接申请入群次数',
+  status       smallint      not null default 1 comment '状态 0.已撤销 1.有效',
+  created_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP comment '创建时间',
+  updated_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP comment '更新时间'
+);
+CREATE UNIQUE INDEX `group_invite_link_link_no` on `group_invite_link` (`link_no`);
+CREATE INDEX `group_invite_link_group_no` on `group_invite_link` (`group_no`);
+
+ALTER TABLE `group_join_apply` ADD COLUMN link_no VARCHAR(40) not null DEFAULT '' COMMENT '通过的邀请链接编号';
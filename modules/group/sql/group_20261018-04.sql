@@ -0,0 +1,16 @@
+-- +migrate Up
+
+-- 群角色权限（内置角色manager、member的权限覆盖以及自定义的管理员子角色）
+create table `group_role`
+(
+  id          bigint         not null primary key AUTO_INCREMENT,
+  group_no    VARCHAR(40)    not null default '' comment '群编号',
+  role_no     VARCHAR(40)    not null default '' comment '角色编号 manager.管理员 member.普通成员 其他为自定义子角色',
+  name        VARCHAR(100)   not null default '' comment '角色名称',
+  permissions VARCHAR(1000)  not null default '' comment '权限列表(JSON数组)',
+  created_at  timeStamp      not null DEFAULT CURRENT_TIMESTAMP comment '创建时间',
+  updated_at  timeStamp      not null DEFAULT CURRENT_TIMESTAMP comment '更新时间'
+);
+CREATE UNIQUE INDEX `group_role_group_no_role_no` on `group_role` (`group_no`, `role_no`);
+
+ALTER TABLE `group_member` ADD COLUMN role_no VARCHAR(40) not null DEFAULT '' COMMENT '管理员的自定义子角色编号';
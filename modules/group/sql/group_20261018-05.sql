@@ -0,0 +1,3 @@
+-- +migrate Up
+
+ALTER TABLE `group` ADD COLUMN slow_mode integer not null DEFAULT 0 COMMENT '慢速模式（成员每N秒只能发送一条消息） 0.关闭';
@@ -0,0 +1,27 @@
+-- +migrate Up
+
+-- 群定时禁言计划
+create table `group_mute_schedule`
+(
+  id            bigint        not null primary key AUTO_INCREMENT,
+  schedule_no   VARCHAR(40)   not null default '' comment '计划唯一编号',
+  group_no      VARCHAR(40)   not null default '' comment '群编号',
+  creator       VARCHAR(40)   not null default '' comment '创建者uid',
+  name          VARCHAR(100)  not null default '' comment '计划名称',
+  type          smallint      not null default 0 comment '类型 1.单次 2.重复',
+  start_at      bigint        not null default 0 comment '单次禁言开始时间（10位时间戳）',
+  end_at        bigint        not null default 0 comment '单次禁言结束时间（10位时间戳）',
+  cron          VARCHAR(100)  not null default '' comment '重复禁言的开始时间cron表达式（分 时 日 月 周）',
+  duration      integer       not null default 0 comment '重复禁言每次持续时长（分钟）',
+  timezone      VARCHAR(40)   not null default '' comment '时区 如：Asia/Shanghai',
+  next_start_at bigint        not null default 0 comment '下一次禁言开始时间',
+  next_end_at   bigint        not null default 0 comment '下一次禁言结束时间',
+  active        smallint      not null default 0 comment '是否正在禁言中',
+  applied       smallint      not null default 0 comment '是否由此计划开启的群禁言（结束时由此计划解除）',
+  status        smallint      not null default 1 comment '状态 0.已结束 1.有效',
+  created_at    timeStamp     not null DEFAULT CURRENT_TIMESTAMP comment '创建时间',
+  updated_at    timeStamp     not null DEFAULT CURRENT_TIMESTAMP comment '更新时间'
+);
+CREATE UNIQUE INDEX `group_mute_schedule_schedule_no` on `group_mute_schedule` (`schedule_no`);
+CREATE INDEX `group_mute_schedule_group_no` on `group_mute_schedule` (`group_no`);
+CREATE INDEX `group_mute_schedule_next_start_at` on `group_mute_schedule` (`status`, `active`, `next_start_at`);
@@ -0,0 +1,33 @@
+-- +migrate Up
+
+-- 群公告
+create table `group_announcement`
+(
+  id              bigint        not null primary key AUTO_INCREMENT,
+  announcement_no VARCHAR(40)   not null default '' comment '公告唯一编号',
+  group_no        VARCHAR(40)   not null default '' comment '群编号',
+  creator         VARCHAR(40)   not null default '' comment '发布者uid',
+  content         text          comment '公告内容',
+  require_ack     smallint      not null default 0 comment '是否需要成员确认已读 0.否 1.是',
+  pinned          smallint      not null default 0 comment '是否置顶（置顶公告同步为群公告）',
+  publish_at      bigint        not null default 0 comment '发布时间（10位时间戳）',
+  status          smallint      not null default 0 comment '状态 0.待发布 1.已发布',
+  is_deleted      smallint      not null default 0 comment '是否已删除',
+  created_at      timeStamp     not null DEFAULT CURRENT_TIMESTAMP comment '创建时间',
+  updated_at      timeStamp     not null DEFAULT CURRENT_TIMESTAMP comment '更新时间'
+);
+CREATE UNIQUE INDEX `group_announcement_announcement_no` on `group_announcement` (`announcement_no`);
+CREATE INDEX `group_announcement_group_no` on `group_announcement` (`group_no`, `is_deleted`, `status`);
+CREATE INDEX `group_announcement_publish_at` on `group_announcement` (`status`, `publish_at`);
+
+-- 群公告已读确认
+create table `group_announcement_ack`
+(
+  id              bigint        not null primary key AUTO_INCREMENT,
+  announcement_no VARCHAR(40)   not null default '' comment '公告编号',
+  group_no        VARCHAR(40)   not null default '' comment '群编号',
+  uid             VARCHAR(40)   not null default '' comment '确认成员uid',
+  created_at      timeStamp     not null DEFAULT CURRENT_TIMESTAMP comment '创建时间',
+  updated_at      timeStamp     not null DEFAULT CURRENT_TIMESTAMP comment '更新时间'
+);
+CREATE UNIQUE INDEX `group_announcement_ack_uid` on `group_announcement_ack` (`announcement_no`, `uid`);
@@ -0,0 +1,16 @@
+-- +migrate Up
+
+-- 群管理操作日志
+create table `group_audit_log`
+(
+  id           bigint        not null primary key AUTO_INCREMENT,
+  group_no     VARCHAR(40)   not null default '' comment '群编号',
+  operator     VARCHAR(40)   not null default '' comment '操作者uid',
+  action       VARCHAR(40)   not null default '' comment '操作类型',
+  targets      VARCHAR(1000) not null default '' comment '操作对象uid（多个用逗号分隔）',
+  content      text          comment '操作详情（json）',
+  created_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP comment '创建时间',
+  updated_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP comment '更新时间'
+);
+CREATE INDEX `group_audit_log_group_no` on `group_audit_log` (`group_no`, `created_at`);
+CREATE INDEX `group_audit_log_operator` on `group_audit_log` (`group_no`, `operator`);
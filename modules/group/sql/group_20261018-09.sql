@@ -0,0 +1,3 @@
+-- +migrate Up
+
+ALTER TABLE `group` ADD COLUMN succession_policy smallint not null DEFAULT 0 COMMENT '群主继承策略 0.优先最早的管理员其次入群最久的成员 1.入群最久的成员 2.解散群';
@@ -0,0 +1,20 @@
+-- +migrate Up
+
+ALTER TABLE `group` ADD COLUMN topic_mode smallint not null DEFAULT 0 COMMENT '是否开启话题模式 0.否 1.是';
+
+-- 群话题（话题为群下的子频道，频道ID为话题编号）
+create table `group_topic`
+(
+  id              bigint        not null primary key AUTO_INCREMENT,
+  topic_no        VARCHAR(40)   not null default '' comment '话题唯一编号（话题频道ID）',
+  group_no        VARCHAR(40)   not null default '' comment '所属群编号',
+  title           VARCHAR(100)  not null default '' comment '话题标题',
+  icon            VARCHAR(255)  not null default '' comment '话题图标',
+  creator         VARCHAR(40)   not null default '' comment '创建者uid',
+  status          smallint      not null default 1 comment '状态 1.开启 2.关闭',
+  is_deleted      smallint      not null default 0 comment '是否已删除',
+  created_at      timeStamp     not null DEFAULT CURRENT_TIMESTAMP comment '创建时间',
+  updated_at      timeStamp     not null DEFAULT CURRENT_TIMESTAMP comment '更新时间'
+);
+CREATE UNIQUE INDEX `group_topic_topic_no` on `group_topic` (`topic_no`);
+CREATE INDEX `group_topic_group_no` on `group_topic` (`group_no`, `is_deleted`);
@@ -0,0 +1,3 @@
+-- +migrate Up
+
+ALTER TABLE `group` ADD COLUMN member_max_count integer not null DEFAULT 0 COMMENT '群成员数量上限 0.使用全局配置';
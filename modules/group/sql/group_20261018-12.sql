@@ -0,0 +1,6 @@
+-- +migrate Up
+
+ALTER TABLE `group` ADD COLUMN welcome_msg VARCHAR(1000) not null DEFAULT '' COMMENT '新成员欢迎语模版';
+ALTER TABLE `group` ADD COLUMN rules VARCHAR(5000) not null DEFAULT '' COMMENT '群规';
+ALTER TABLE `group` ADD COLUMN rules_required smallint not null DEFAULT 0 COMMENT '新成员是否需要同意群规后才能发言 0.否 1.是';
+ALTER TABLE `group_member` ADD COLUMN rules_pending smallint not null DEFAULT 0 COMMENT '是否待同意群规（待同意前不能发言） 0.否 1.是';
@@ -0,0 +1,5 @@
+-- +migrate Up
+
+ALTER TABLE `group` ADD COLUMN revoke_second integer not null DEFAULT 0 COMMENT '普通成员消息可撤回时长 0.使用全局配置';
+ALTER TABLE `group` ADD COLUMN manager_revoke_second integer not null DEFAULT 0 COMMENT '管理员消息可撤回时长 0.使用全局配置';
+ALTER TABLE `group` ADD COLUMN creator_revoke_second integer not null DEFAULT 0 COMMENT '群主消息可撤回时长 0.使用全局配置';
@@ -0,0 +1,4 @@
+-- +migrate Up
+
+ALTER TABLE `group_announcement` ADD COLUMN retry_count integer not null DEFAULT 0 COMMENT '定时发布失败重试次数';
+ALTER TABLE `group_announcement` MODIFY COLUMN status smallint not null default 0 comment '状态 0.待发布 1.已发布 2.发布失败';
@@ -0,0 +1,229 @@
+package group
+
+import (
+	"errors"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkevent"
+	"github.com/gocraft/dbr/v2"
+	"go.uber.org/zap"
+)
+
+// 处理用户注销事件
+func (g *Group) handleUserDestroyEvent(data []byte, commit config.EventCommit) {
+	g.handleGrouperLeaveEvent(data, GrouperLeaveReasonDestroy, commit)
+}
+
+// 处理用户被后台封禁事件
+func (g *Group) handleUserDisableEvent(data []byte, commit config.EventCommit) {
+	g.handleGrouperLeaveEvent(data, GrouperLeaveReasonDisable, commit)
+}
+
+func (g *Group) handleGrouperLeaveEvent(data []byte, reason int, commit config.EventCommit) {
+	var req map[string]interface{}
+	err := util.ReadJsonByByte(data, &req)
+	if err != nil {
+		g.Error("处理群主继承参数有误", zap.Error(err))
+		commit(err)
+		return
+	}
+	uid, _ := req["uid"].(string)
+	if uid == "" {
+		g.Error("处理群主继承UID不能为空")
+		commit(errors.New("处理群主继承UID不能为空"))
+		return
+	}
+	groupNos, err := g.db.queryGroupNosWithCreator(uid)
+	if err != nil {
+		g.Error("查询用户创建的群失败", zap.Error(err))
+		commit(err)
+		return
+	}
+	if len(groupNos) == 0 {
+		commit(nil)
+		return
+	}
+	name := ""
+	userModel, err := g.userDB.QueryByUID(uid)
+	if err != nil {
+		g.Error("查询用户信息失败", zap.Error(err))
+		commit(err)
+		return
+	}
+	if userModel != nil {
+		name = userModel.Name
+	}
+	for _, groupNo := range groupNos {
+		err = g.succeedGrouper(groupNo, uid, name, reason)
+		if err != nil {
+			g.Error("群主继承失败", zap.Error(err), zap.String("group_no", groupNo), zap.String("uid", uid))
+			commit(err)
+			return
+		}
+	}
+	commit(nil)
+}
+
+// 群主（仍在群内）被封禁或注销后按继承策略处理群主身份
+func (g *Group) succeedGrouper(groupNo string, oldGrouper string, oldGrouperName string, reason int) error {
+	groupModel, err := g.db.QueryWithGroupNo(groupNo)
+	if err != nil {
+		return err
+	}
+	if groupModel == nil || groupModel.Status == GroupStatusDisband {
+		return nil
+	}
+	successor, err := g.querySuccessor(groupModel, oldGrouper)
+	if err != nil {
+		return err
+	}
+	tx, err := g.db.session.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.RollbackUnlessCommitted()
+			panic(err)
+		}
+	}()
+	eventID, err := g.succeedGrouperTx(groupModel, oldGrouper, oldGrouperName, successor, true, tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		tx.RollbackUnlessCommitted()
+		return err
+	}
+	if eventID > 0 {
+		g.ctx.EventCommit(eventID)
+	}
+	g.afterGrouperSucceeded(groupModel, oldGrouper, oldGrouperName, successor, reason)
+	return nil
+}
+
+// 按群主继承策略查询继承人（策略为解散群或没有合适的成员时返回nil）
+func (g *Group) querySuccessor(groupModel *Model, oldGrouper string) (*SuccessorModel, error) {
+	if groupModel.SuccessionPolicy == SuccessionPolicyDisband {
+		return nil, nil
+	}
+	if groupModel.SuccessionPolicy == SuccessionPolicyManagerFirst {
+		successor, err := g.db.querySuccessorWithRole(groupModel.GroupNo, MemberRoleManager, oldGrouper)
+		if err != nil {
+			return nil, err
+		}
+		if successor != nil {
+			return successor, nil
+		}
+	}
+	return g.db.querySuccessorWithRole(groupModel.GroupNo, MemberRoleCommon, oldGrouper)
+}
+
+// 在事务内移交群主（keepOldGrouper为false时原群主的成员记录由调用方删除），策略为解散群时解散群
+// 返回需要在事务提交后提交的事件ID
+func (g *Group) succeedGrouperTx(groupModel *Model, oldGrouper string, oldGrouperName string, successor *SuccessorModel, keepOldGrouper bool, tx *dbr.Tx) (int64, error) {
+	if groupModel.SuccessionPolicy == SuccessionPolicyDisband {
+		groupModel.Status = GroupStatusDisband
+		groupModel.Version = g.ctx.GenSeq(common.GroupSeqKey)
+		err := g.db.UpdateTx(groupModel, tx)
+		if err != nil {
+			return 0, err
+		}
+		return g.ctx.EventBegin(&wkevent.Data{
+			Event: event.GroupDisband,
+			Type:  wkevent.Message,
+			Data: &config.MsgGroupDisband{
+				GroupNo:      groupModel.GroupNo,
+				Operator:     oldGrouper,
+				OperatorName: oldGrouperName,
+			},
+		}, tx)
+	}
+	if successor == nil {
+		return 0, nil
+	}
+	version := g.ctx.GenSeq(common.GroupMemberSeqKey)
+	if keepOldGrouper {
+		err := g.db.UpdateMemberRoleTx(groupModel.GroupNo, oldGrouper, MemberRoleCommon, version, tx)
+		if err != nil {
+			return 0, err
+		}
+	}
+	err := g.db.UpdateMemberRoleTx(groupModel.GroupNo, successor.UID, MemberRoleCreator, version, tx)
+	if err != nil {
+		return 0, err
+	}
+	err = g.db.updateMemberForbiddenExpirTimeTx(groupModel.GroupNo, successor.UID, 0, version, tx)
+	if err != nil {
+		return 0, err
+	}
+	return g.ctx.EventBegin(&wkevent.Data{
+		Event: event.GroupMemberTransferGrouper,
+		Type:  wkevent.Message,
+		Data: config.MsgGroupTransferGrouper{
+			GroupNo:        groupModel.GroupNo,
+			OldGrouper:     oldGrouper,
+			OldGrouperName: oldGrouperName,
+			NewGrouper:     successor.UID,
+			NewGrouperName: successor.Name,
+		},
+	}, tx)
+}
+
+// 群主移交后重置IM黑白名单并发送群提示
+func (g *Group) afterGrouperSucceeded(groupModel *Model, oldGrouper string, oldGrouperName string, successor *SuccessorModel, reason int) {
+	if successor == nil {
+		return
+	}
+	groupNo := groupModel.GroupNo
+	if groupModel.Forbidden == 1 { // 如果是禁言状态，则重置管理员白名单
+		err := g.setIMWhitelistForGroupManager(groupNo)
+		if err != nil {
+			g.Warn("设置白名单失败！", zap.Error(err))
+		}
+	}
+	if successor.ForbiddenExpirTime > 0 {
+		err := g.setGroupBlacklist(groupNo, []string{successor.UID}, false)
+		if err != nil {
+			g.Warn("新群主移出黑名单失败！", zap.Error(err))
+		}
+	}
+	content := "{0}已退出群聊，{1}自动成为新群主"
+	switch reason {
+	case GrouperLeaveReasonDisable:
+		content = "{0}的账号已被封禁，{1}自动成为新群主"
+	case GrouperLeaveReasonDestroy:
+		content = "{0}已注销账号，{1}自动成为新群主"
+	}
+	err := g.ctx.SendMessage(&config.MsgSendReq{
+		Header: config.MsgHeader{
+			RedDot: 1,
+		},
+		ChannelID:   groupNo,
+		ChannelType: common.ChannelTypeGroup.Uint8(),
+		Payload: []byte(util.ToJson(map[string]interface{}{
+			"content": content,
+			"extra": []config.UserBaseVo{
+				{
+					UID:  oldGrouper,
+					Name: oldGrouperName,
+				},
+				{
+					UID:  successor.UID,
+					Name: successor.Name,
+				},
+			},
+			"type": common.Tip,
+		})),
+	})
+	if err != nil {
+		g.Warn("发送群主继承提示失败！", zap.Error(err))
+	}
+	g.addAuditLog(groupNo, oldGrouper, AuditActionTransferGrouper, []string{successor.UID}, map[string]interface{}{
+		"reason": reason,
+	})
+}
@@ -0,0 +1,26 @@
+package group
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+)
+
+// querySuccessorWithRole 查询指定角色中入群最早的可用成员（排除机器人、黑名单以及已封禁或注销的用户）
+func (d *DB) querySuccessorWithRole(groupNo string, role int, excludeUID string) (*SuccessorModel, error) {
+	var model *SuccessorModel
+	_, err := d.session.Select("group_member.uid,IFNULL(user.name,'') name,group_member.forbidden_expir_time").From("group_member").Join("user", "group_member.uid=user.uid").Where("group_member.group_no=? and group_member.role=? and group_member.uid<>? and group_member.is_deleted=0 and group_member.robot=0 and group_member.status=? and user.is_destroy=0 and user.status=?", groupNo, role, excludeUID, common.GroupMemberStatusNormal, common.UserAvailable).OrderAsc("group_member.created_at").OrderAsc("group_member.id").Limit(1).Load(&model)
+	return model, err
+}
+
+// queryGroupNosWithCreator 查询用户作为群主的正常群和已归档的群
+func (d *DB) queryGroupNosWithCreator(uid string) ([]string, error) {
+	var groupNos []string
+	_, err := d.session.Select("group_member.group_no").From("group_member").Join("group", "group.group_no=group_member.group_no").Where("group_member.uid=? and group_member.role=? and group_member.is_deleted=0 and group.status in ?", uid, MemberRoleCreator, []int{GroupStatusNormal, GroupStatusArchived}).Load(&groupNos)
+	return groupNos, err
+}
+
+// SuccessorModel 群主继承人
+type SuccessorModel struct {
+	UID                string
+	Name               string
+	ForbiddenExpirTime int64
+}
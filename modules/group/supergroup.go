@@ -0,0 +1,132 @@
+package group
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+	"go.uber.org/zap"
+)
+
+// 群成员数量上限（群未单独设置时使用全局配置） 0.不限制
+func (g *Group) getMemberMaxCount(groupModel *Model) (int64, error) {
+	if groupModel.MemberMaxCount > 0 {
+		return int64(groupModel.MemberMaxCount), nil
+	}
+	appConfig, err := g.commonService.GetAppConfig()
+	if err != nil {
+		return 0, err
+	}
+	if appConfig == nil {
+		return 0, nil
+	}
+	return int64(appConfig.GroupMemberMaxCount), nil
+}
+
+// 校验加入addCount个成员后是否超出群成员数量上限，返回当前成员数量
+// 在加入成员的事务内锁定群后计数，事务提交前其他加入请求会等待，保证不会并发超出上限
+func (g *Group) checkMemberMaxCountTx(groupModel *Model, addCount int, tx *dbr.Tx) (int64, error) {
+	memberCount, err := g.db.queryMemberCountForUpdateTx(groupModel.GroupNo, tx)
+	if err != nil {
+		g.Error("查询群成员数量失败！", zap.Error(err))
+		return 0, errors.New("查询群成员数量失败！")
+	}
+	maxCount, err := g.getMemberMaxCount(groupModel)
+	if err != nil {
+		g.Error("查询群成员数量上限失败！", zap.Error(err))
+		return 0, errors.New("查询群成员数量上限失败！")
+	}
+	if maxCount > 0 && memberCount+int64(addCount) > maxCount {
+		return 0, fmt.Errorf("群成员数量已达上限（%d人）！", maxCount)
+	}
+	return memberCount, nil
+}
+
+// 普通群成员数量达到配置的数量时自动升级为超级群
+func (g *Group) upgradeToSuperGroupIfNeed(groupNo string) {
+	upgradeCount := g.ctx.GetConfig().GroupUpgradeWhenMemberCount
+	if upgradeCount <= 0 {
+		return
+	}
+	groupModel, err := g.db.QueryWithGroupNo(groupNo)
+	if err != nil {
+		g.Warn("查询群信息失败！", zap.Error(err))
+		return
+	}
+	if groupModel == nil || groupModel.GroupType != int(GroupTypeCommon) {
+		return
+	}
+	memberCount, err := g.db.QueryMemberCount(groupNo)
+	if err != nil {
+		g.Warn("查询群成员数量失败！", zap.Error(err))
+		return
+	}
+	if memberCount < int64(upgradeCount) {
+		return
+	}
+	err = upgradeToSuperGroup(g.ctx, g.db, groupModel, fmt.Sprintf("群成员超过%d，已自动升级为超级群", upgradeCount))
+	if err != nil {
+		g.Error("普通群升级为超级群失败！", zap.Error(err), zap.String("group_no", groupNo))
+		return
+	}
+	g.addAuditLog(groupNo, "", AuditActionGroupUpgrade, nil, map[string]interface{}{
+		"member_count": memberCount,
+	})
+}
+
+// 将普通群升级为超级群（IM频道及群下话题频道改为超大频道，更新群类型并通知群成员）
+func upgradeToSuperGroup(ctx *config.Context, db *DB, groupModel *Model, content string) error {
+	groupNo := groupModel.GroupNo
+	var ban = 0
+	if groupModel.Status == GroupStatusDisabled {
+		ban = 1
+	}
+	err := ctx.IMCreateOrUpdateChannel(&config.ChannelCreateReq{
+		ChannelID:   groupNo,
+		ChannelType: common.ChannelTypeGroup.Uint8(),
+		Ban:         ban,
+		Large:       1,
+	})
+	if err != nil {
+		return err
+	}
+	topicNos, err := db.queryTopicNosWithGroupNo(groupNo)
+	if err != nil {
+		return err
+	}
+	for _, topicNo := range topicNos {
+		err = ctx.IMCreateOrUpdateChannel(&config.ChannelCreateReq{
+			ChannelID:   topicNo,
+			ChannelType: common.ChannelTypeCommunityTopic.Uint8(),
+			Ban:         ban,
+			Large:       1,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	err = db.updateGroupTypeAndVersion(groupNo, GroupTypeSuper, ctx.GenSeq(common.GroupSeqKey))
+	if err != nil {
+		return err
+	}
+	groupModel.GroupType = int(GroupTypeSuper)
+	// 发送群升级通知
+	err = ctx.SendMessage(&config.MsgSendReq{
+		Header: config.MsgHeader{
+			RedDot: 1,
+		},
+		ChannelID:   groupNo,
+		ChannelType: common.ChannelTypeGroup.Uint8(),
+		Payload: []byte(util.ToJson(map[string]interface{}{
+			"content": content,
+			"type":    common.GroupUpgrade,
+		})),
+	})
+	if err != nil {
+		return err
+	}
+	return ctx.SendChannelUpdateToGroup(groupNo)
+}
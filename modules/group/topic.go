@@ -0,0 +1,689 @@
+package group
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/model"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkevent"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// 创建话题（话题为群下的子频道，成员与群成员一致）
+func (g *Group) topicAdd(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	loginName := c.GetLoginName()
+	groupNo := c.Param("group_no")
+	var req topicReq
+	if err := c.BindJSON(&req); err != nil {
+		g.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	groupModel, err := g.checkTopicPermission(groupNo, loginUID)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if groupModel.TopicMode != 1 {
+		c.ResponseError(errors.New("群未开启话题模式！"))
+		return
+	}
+	count, err := g.db.queryTopicCount(groupNo)
+	if err != nil {
+		g.Error("查询话题数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询话题数量失败！"))
+		return
+	}
+	if count >= TopicMaxCount {
+		c.ResponseError(fmt.Errorf("每个群最多创建%d个话题！", TopicMaxCount))
+		return
+	}
+	subscribers, err := g.getTopicSubscribers(groupNo)
+	if err != nil {
+		g.Error("查询群成员失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询群成员失败！"))
+		return
+	}
+	topicModel := &TopicModel{
+		TopicNo: util.GenerUUID(),
+		GroupNo: groupNo,
+		Title:   strings.TrimSpace(req.Title),
+		Icon:    req.Icon,
+		Creator: loginUID,
+		Status:  TopicStatusOpen,
+	}
+	tx, err := g.ctx.DB().Begin()
+	if err != nil {
+		g.Error("开启事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("开启事务失败！"))
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.Rollback()
+			panic(err)
+		}
+	}()
+	err = g.db.insertTopicTx(topicModel, tx)
+	if err != nil {
+		tx.Rollback()
+		g.Error("添加话题失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加话题失败！"))
+		return
+	}
+	// 由频道模块记录话题频道的父频道
+	eventID, err := g.ctx.EventBegin(&wkevent.Data{
+		Event: event.GroupTopicCreate,
+		Type:  wkevent.Message,
+		Data: map[string]interface{}{
+			"topic_no": topicModel.TopicNo,
+			"group_no": groupNo,
+		},
+	}, tx)
+	if err != nil {
+		tx.Rollback()
+		g.Error("开启话题创建事件失败！", zap.Error(err))
+		c.ResponseError(errors.New("开启话题创建事件失败！"))
+		return
+	}
+	large := 0
+	if groupModel.GroupType == int(GroupTypeSuper) {
+		large = 1
+	}
+	err = g.ctx.IMCreateOrUpdateChannel(&config.ChannelCreateReq{
+		ChannelID:   topicModel.TopicNo,
+		ChannelType: common.ChannelTypeCommunityTopic.Uint8(),
+		Large:       large,
+		Subscribers: subscribers,
+	})
+	if err != nil {
+		tx.Rollback()
+		g.Error("创建话题频道失败！", zap.Error(err))
+		c.ResponseError(errors.New("创建话题频道失败！"))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tx.RollbackUnlessCommitted()
+		g.Error("提交事务失败！", zap.Error(err))
+		// 话题未保存，删除已创建的话题频道
+		if err := g.ctx.IMDelChannel(&config.ChannelDeleteReq{
+			ChannelID:   topicModel.TopicNo,
+			ChannelType: common.ChannelTypeCommunityTopic.Uint8(),
+		}); err != nil {
+			g.Warn("删除话题频道失败！", zap.Error(err), zap.String("topic_no", topicModel.TopicNo))
+		}
+		c.ResponseError(errors.New("提交事务失败！"))
+		return
+	}
+	g.ctx.EventCommit(eventID)
+
+	// 继承群的黑名单和禁言白名单
+	blacklist, err := g.groupService.GetBlacklistMemberUIDs(groupNo)
+	if err != nil {
+		g.Warn("查询群黑名单失败！", zap.Error(err))
+	} else if len(blacklist) > 0 {
+		err = g.ctx.IMBlacklistSet(config.ChannelBlacklistReq{
+			ChannelReq: config.ChannelReq{
+				ChannelID:   topicModel.TopicNo,
+				ChannelType: common.ChannelTypeCommunityTopic.Uint8(),
+			},
+			UIDs: blacklist,
+		})
+		if err != nil {
+			g.Warn("设置话题黑名单失败！", zap.Error(err))
+		}
+	}
+	if groupModel.Forbidden == 1 {
+		err = g.setTopicIMWhitelistForGroupManager(groupNo, topicModel.TopicNo)
+		if err != nil {
+			g.Warn("设置话题白名单失败！", zap.Error(err))
+		}
+	}
+	err = g.sendTopicTip(topicModel.TopicNo, "{0}创建了话题", loginUID, loginName)
+	if err != nil {
+		g.Warn("发送话题创建提示失败！", zap.Error(err))
+	}
+	c.Response(newTopicResp(&TopicDetailModel{TopicModel: *topicModel, CreatorName: loginName}))
+}
+
+// 群内话题列表
+func (g *Group) topicList(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	groupNo := c.Param("group_no")
+	if err := g.checkGroupMember(groupNo, loginUID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	models, err := g.db.queryTopicsWithGroupNo(groupNo)
+	if err != nil {
+		g.Error("查询话题失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询话题失败！"))
+		return
+	}
+	list := make([]*topicResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newTopicResp(model))
+	}
+	c.Response(list)
+}
+
+// 修改话题标题和图标（有话题管理权限的成员或话题创建者可修改）
+func (g *Group) topicUpdate(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	groupNo := c.Param("group_no")
+	topicNo := c.Param("topic_no")
+	var req topicReq
+	if err := c.BindJSON(&req); err != nil {
+		g.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	topicModel, err := g.getTopic(groupNo, topicNo)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if topicModel.Creator != loginUID {
+		_, err = g.checkTopicPermission(groupNo, loginUID)
+		if err != nil {
+			c.ResponseError(err)
+			return
+		}
+	}
+	topicModel.Title = strings.TrimSpace(req.Title)
+	topicModel.Icon = req.Icon
+	err = g.db.updateTopic(topicModel)
+	if err != nil {
+		g.Error("修改话题失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改话题失败！"))
+		return
+	}
+	err = g.sendTopicChannelUpdate(topicNo)
+	if err != nil {
+		g.Warn("发送话题更新命令失败！", zap.Error(err))
+	}
+	c.ResponseOK()
+}
+
+// 开启或关闭话题（关闭后仅群主和管理员可在话题内发言）
+func (g *Group) topicStatusUpdate(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	loginName := c.GetLoginName()
+	groupNo := c.Param("group_no")
+	topicNo := c.Param("topic_no")
+	var req struct {
+		Status int `json:"status"` // 状态 1.开启 2.关闭
+	}
+	if err := c.BindJSON(&req); err != nil {
+		g.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if req.Status != TopicStatusOpen && req.Status != TopicStatusClosed {
+		c.ResponseError(errors.New("话题状态有误！"))
+		return
+	}
+	groupModel, err := g.checkTopicPermission(groupNo, loginUID)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	topicModel, err := g.getTopic(groupNo, topicNo)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if topicModel.Status == req.Status {
+		c.ResponseOK()
+		return
+	}
+	topicModel.Status = req.Status
+	err = g.db.updateTopic(topicModel)
+	if err != nil {
+		g.Error("修改话题状态失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改话题状态失败！"))
+		return
+	}
+	if req.Status == TopicStatusClosed || groupModel.Forbidden == 1 {
+		err = g.setTopicIMWhitelistForGroupManager(groupNo, topicNo)
+	} else {
+		err = g.resetTopicIMWhitelist(topicNo, make([]string, 0))
+	}
+	if err != nil {
+		g.Error("设置话题白名单失败！", zap.Error(err))
+		c.ResponseError(errors.New("设置话题白名单失败！"))
+		return
+	}
+	content := "{0}重新开启了话题"
+	if req.Status == TopicStatusClosed {
+		content = "{0}关闭了话题"
+	}
+	err = g.sendTopicTip(topicNo, content, loginUID, loginName)
+	if err != nil {
+		g.Warn("发送话题状态提示失败！", zap.Error(err))
+	}
+	err = g.sendTopicChannelUpdate(topicNo)
+	if err != nil {
+		g.Warn("发送话题更新命令失败！", zap.Error(err))
+	}
+	c.ResponseOK()
+}
+
+// 删除话题（同时删除话题频道，话题的置顶消息、最近会话和频道设置由对应模块监听事件清理）
+func (g *Group) topicDelete(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	groupNo := c.Param("group_no")
+	topicNo := c.Param("topic_no")
+	_, err := g.checkTopicPermission(groupNo, loginUID)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	_, err = g.getTopic(groupNo, topicNo)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	tx, err := g.ctx.DB().Begin()
+	if err != nil {
+		g.Error("开启事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("开启事务失败！"))
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.Rollback()
+			panic(err)
+		}
+	}()
+	err = g.db.deleteTopicTx(topicNo, tx)
+	if err != nil {
+		tx.Rollback()
+		g.Error("删除话题失败！", zap.Error(err))
+		c.ResponseError(errors.New("删除话题失败！"))
+		return
+	}
+	eventID, err := g.ctx.EventBegin(&wkevent.Data{
+		Event: event.GroupTopicDelete,
+		Type:  wkevent.Message,
+		Data: map[string]interface{}{
+			"topic_no": topicNo,
+			"group_no": groupNo,
+		},
+	}, tx)
+	if err != nil {
+		tx.Rollback()
+		g.Error("开启话题删除事件失败！", zap.Error(err))
+		c.ResponseError(errors.New("开启话题删除事件失败！"))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tx.RollbackUnlessCommitted()
+		g.Error("提交事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("提交事务失败！"))
+		return
+	}
+	g.ctx.EventCommit(eventID)
+	err = g.ctx.IMDelChannel(&config.ChannelDeleteReq{
+		ChannelID:   topicNo,
+		ChannelType: common.ChannelTypeCommunityTopic.Uint8(),
+	})
+	if err != nil {
+		g.Warn("删除话题频道失败！", zap.Error(err))
+	}
+	// 话题频道已删除，通过群频道通知成员更新
+	err = g.ctx.SendChannelUpdate(config.ChannelReq{
+		ChannelID:   groupNo,
+		ChannelType: common.ChannelTypeGroup.Uint8(),
+	}, config.ChannelReq{
+		ChannelID:   topicNo,
+		ChannelType: common.ChannelTypeCommunityTopic.Uint8(),
+	})
+	if err != nil {
+		g.Warn("发送话题更新命令失败！", zap.Error(err))
+	}
+	c.ResponseOK()
+}
+
+// GetTopic 查询话题
+func (s *Service) GetTopic(topicNo string) (*TopicResp, error) {
+	model, err := s.db.queryTopicWithTopicNo(topicNo)
+	if err != nil {
+		return nil, err
+	}
+	if model == nil {
+		return nil, nil
+	}
+	return &TopicResp{
+		TopicNo: model.TopicNo,
+		GroupNo: model.GroupNo,
+		Title:   model.Title,
+		Icon:    model.Icon,
+		Creator: model.Creator,
+		Status:  model.Status,
+	}, nil
+}
+
+// 话题频道信息（频道设置继承所属群）
+func (g *Group) topicChannelGet(topicNo string, loginUID string) (*model.ChannelResp, error) {
+	topicModel, err := g.db.queryTopicWithTopicNo(topicNo)
+	if err != nil {
+		return nil, err
+	}
+	if topicModel == nil {
+		return nil, nil
+	}
+	groupResp, err := g.groupService.GetGroupDetail(topicModel.GroupNo, loginUID)
+	if err != nil {
+		return nil, err
+	}
+	if groupResp == nil {
+		return nil, nil
+	}
+	resp := &model.ChannelResp{}
+	resp.Channel.ChannelID = topicModel.TopicNo
+	resp.Channel.ChannelType = common.ChannelTypeCommunityTopic.Uint8()
+	resp.ParentChannel = &struct {
+		ChannelID   string `json:"channel_id"`
+		ChannelType uint8  `json:"channel_type"`
+	}{
+		ChannelID:   topicModel.GroupNo,
+		ChannelType: common.ChannelTypeGroup.Uint8(),
+	}
+	resp.Name = topicModel.Title
+	resp.Logo = topicModel.Icon
+	resp.Status = groupResp.Status
+	resp.Mute = groupResp.Mute
+	resp.ShowNick = groupResp.ShowNick
+	resp.Receipt = groupResp.Receipt
+	resp.Forbidden = groupResp.Forbidden
+	if topicModel.Status == TopicStatusClosed {
+		resp.Forbidden = 1
+	}
+	extraMap := make(map[string]interface{})
+	extraMap["topic_status"] = topicModel.Status
+	extraMap["creator"] = topicModel.Creator
+	extraMap["group_type"] = groupResp.GroupType
+	if groupResp.Quit != 0 {
+		extraMap["quit"] = groupResp.Quit
+	}
+	if groupResp.Role != 0 {
+		extraMap["role"] = groupResp.Role
+	}
+	if groupResp.ForbiddenExpirTime != 0 {
+		extraMap["forbidden_expir_time"] = groupResp.ForbiddenExpirTime
+	}
+	resp.Extra = extraMap
+	return resp, nil
+}
+
+// 话题频道对应的群编号（不是话题频道时返回频道ID）
+func (g *Group) getChannelGroupNo(channelID string, channelType uint8) (string, error) {
+	if channelType != common.ChannelTypeCommunityTopic.Uint8() {
+		return channelID, nil
+	}
+	topicModel, err := g.db.queryTopicWithTopicNo(channelID)
+	if err != nil {
+		return "", err
+	}
+	if topicModel == nil {
+		return "", nil
+	}
+	return topicModel.GroupNo, nil
+}
+
+// 话题频道的订阅者（即群成员）
+func (g *Group) getTopicSubscribers(groupNo string) ([]string, error) {
+	members, err := g.groupService.GetMembers(groupNo)
+	if err != nil {
+		return nil, err
+	}
+	subscribers := make([]string, 0, len(members))
+	for _, member := range members {
+		subscribers = append(subscribers, member.UID)
+	}
+	return subscribers, nil
+}
+
+// 群成员变化时同步群内所有话题频道的订阅者
+func (g *Group) syncTopicSubscribers(groupNo string, uids []string, isAdd bool) {
+	topicNos, err := g.db.queryTopicNosWithGroupNo(groupNo)
+	if err != nil {
+		g.Warn("查询群话题失败！", zap.Error(err))
+		return
+	}
+	for _, topicNo := range topicNos {
+		if isAdd {
+			err = g.ctx.IMAddSubscriber(&config.SubscriberAddReq{
+				ChannelID:   topicNo,
+				ChannelType: common.ChannelTypeCommunityTopic.Uint8(),
+				Subscribers: uids,
+			})
+		} else {
+			err = g.ctx.IMRemoveSubscriber(&config.SubscriberRemoveReq{
+				ChannelID:   topicNo,
+				ChannelType: common.ChannelTypeCommunityTopic.Uint8(),
+				Subscribers: uids,
+			})
+		}
+		if err != nil {
+			g.Warn("同步话题订阅者失败！", zap.Error(err), zap.String("topic_no", topicNo))
+		}
+	}
+}
+
+// 群黑名单变化时同步群内所有话题频道的黑名单
+func (g *Group) syncTopicBlacklist(groupNo string, uids []string, isAdd bool) {
+	topicNos, err := g.db.queryTopicNosWithGroupNo(groupNo)
+	if err != nil {
+		g.Warn("查询群话题失败！", zap.Error(err))
+		return
+	}
+	for _, topicNo := range topicNos {
+		req := config.ChannelBlacklistReq{
+			ChannelReq: config.ChannelReq{
+				ChannelID:   topicNo,
+				ChannelType: common.ChannelTypeCommunityTopic.Uint8(),
+			},
+			UIDs: uids,
+		}
+		if isAdd {
+			err = g.ctx.IMBlacklistAdd(req)
+		} else {
+			err = g.ctx.IMBlacklistRemove(req)
+		}
+		if err != nil {
+			g.Warn("同步话题黑名单失败！", zap.Error(err), zap.String("topic_no", topicNo))
+		}
+	}
+}
+
+// 群白名单变化时同步群内所有话题频道的白名单（已关闭的话题始终只有群主和管理员可发言）
+func (g *Group) syncTopicWhitelist(groupNo string, whitelist []string) {
+	topicNos, err := g.db.queryTopicNosWithGroupNo(groupNo)
+	if err != nil {
+		g.Warn("查询群话题失败！", zap.Error(err))
+		return
+	}
+	if len(topicNos) == 0 {
+		return
+	}
+	closedTopicNos, err := g.db.queryClosedTopicNosWithGroupNo(groupNo)
+	if err != nil {
+		g.Warn("查询已关闭的群话题失败！", zap.Error(err))
+		return
+	}
+	closedTopicMap := make(map[string]bool, len(closedTopicNos))
+	for _, closedTopicNo := range closedTopicNos {
+		closedTopicMap[closedTopicNo] = true
+	}
+	var managerUIDs []string
+	if len(closedTopicNos) > 0 {
+		managerUIDs, err = g.db.QueryGroupManagerOrCreatorUIDS(groupNo)
+		if err != nil {
+			g.Warn("查询群管理员失败！", zap.Error(err))
+			return
+		}
+	}
+	for _, topicNo := range topicNos {
+		topicWhitelist := whitelist
+		if closedTopicMap[topicNo] {
+			topicWhitelist = managerUIDs
+		}
+		err = g.resetTopicIMWhitelist(topicNo, topicWhitelist)
+		if err != nil {
+			g.Warn("同步话题白名单失败！", zap.Error(err), zap.String("topic_no", topicNo))
+		}
+	}
+}
+
+// 设置群管理员（包含创建者）列表作为话题白名单
+func (g *Group) setTopicIMWhitelistForGroupManager(groupNo string, topicNo string) error {
+	managerOrCreaterUIDs, err := g.db.QueryGroupManagerOrCreatorUIDS(groupNo)
+	if err != nil {
+		return err
+	}
+	return g.resetTopicIMWhitelist(topicNo, managerOrCreaterUIDs)
+}
+
+func (g *Group) resetTopicIMWhitelist(topicNo string, whitelist []string) error {
+	return g.ctx.IMWhitelistSet(config.ChannelWhitelistReq{
+		ChannelReq: config.ChannelReq{
+			ChannelID:   topicNo,
+			ChannelType: common.ChannelTypeCommunityTopic.Uint8(),
+		},
+		UIDs: whitelist,
+	})
+}
+
+// 发送话题频道更新命令
+func (g *Group) sendTopicChannelUpdate(topicNo string) error {
+	topicChannel := config.ChannelReq{
+		ChannelID:   topicNo,
+		ChannelType: common.ChannelTypeCommunityTopic.Uint8(),
+	}
+	return g.ctx.SendChannelUpdate(topicChannel, topicChannel)
+}
+
+// 在话题内发送提示消息
+func (g *Group) sendTopicTip(topicNo string, content string, operator string, operatorName string) error {
+	return g.ctx.SendMessage(&config.MsgSendReq{
+		Header: config.MsgHeader{
+			RedDot: 1,
+		},
+		ChannelID:   topicNo,
+		ChannelType: common.ChannelTypeCommunityTopic.Uint8(),
+		Payload: []byte(util.ToJson(map[string]interface{}{
+			"content": content,
+			"extra": []config.UserBaseVo{
+				{
+					UID:  operator,
+					Name: operatorName,
+				},
+			},
+			"type": common.Tip,
+		})),
+	})
+}
+
+// 查询群内的话题
+func (g *Group) getTopic(groupNo string, topicNo string) (*TopicModel, error) {
+	model, err := g.db.queryTopicWithTopicNo(topicNo)
+	if err != nil {
+		g.Error("查询话题失败！", zap.Error(err))
+		return nil, errors.New("查询话题失败！")
+	}
+	if model == nil || model.GroupNo != groupNo {
+		return nil, errors.New("话题不存在！")
+	}
+	return model, nil
+}
+
+// 校验群是否存在以及操作者是否有话题管理权限
+func (g *Group) checkTopicPermission(groupNo string, uid string) (*Model, error) {
+	groupModel, err := g.getGroupInfo(groupNo)
+	if err != nil {
+		return nil, err
+	}
+	if groupModel.isArchived() {
+		return nil, errors.New("群已归档，不能管理话题！")
+	}
+	hasPermission, err := g.groupService.HasPermission(groupNo, uid, PermissionManageTopic)
+	if err != nil {
+		g.Error("查询群权限失败！", zap.Error(err))
+		return nil, errors.New("查询群权限失败！")
+	}
+	if !hasPermission {
+		return nil, errors.New("没有管理话题的权限！")
+	}
+	return groupModel, nil
+}
+
+type topicReq struct {
+	Title string `json:"title"` // 话题标题
+	Icon  string `json:"icon"`  // 话题图标
+}
+
+func (r topicReq) check() error {
+	if strings.TrimSpace(r.Title) == "" {
+		return errors.New("话题标题不能为空！")
+	}
+	if len([]rune(strings.TrimSpace(r.Title))) > TopicTitleMaxLength {
+		return fmt.Errorf("话题标题不能超过%d个字！", TopicTitleMaxLength)
+	}
+	if len(r.Icon) > 255 {
+		return errors.New("话题图标地址过长！")
+	}
+	return nil
+}
+
+// TopicResp 群话题
+type TopicResp struct {
+	TopicNo string // 话题编号（话题频道ID）
+	GroupNo string // 所属群编号
+	Title   string // 话题标题
+	Icon    string // 话题图标
+	Creator string // 创建者uid
+	Status  int    // 状态 1.开启 2.关闭
+}
+
+type topicResp struct {
+	TopicNo     string `json:"topic_no"`     // 话题编号（话题频道ID）
+	ChannelType uint8  `json:"channel_type"` // 话题频道类型
+	GroupNo     string `json:"group_no"`     // 所属群编号
+	Title       string `json:"title"`        // 话题标题
+	Icon        string `json:"icon"`         // 话题图标
+	Creator     string `json:"creator"`      // 创建者uid
+	CreatorName string `json:"creator_name"` // 创建者名字
+	Status      int    `json:"status"`       // 状态 1.开启 2.关闭
+	CreatedAt   string `json:"created_at"`
+}
+
+func newTopicResp(m *TopicDetailModel) *topicResp {
+	return &topicResp{
+		TopicNo:     m.TopicNo,
+		ChannelType: common.ChannelTypeCommunityTopic.Uint8(),
+		GroupNo:     m.GroupNo,
+		Title:       m.Title,
+		Icon:        m.Icon,
+		Creator:     m.Creator,
+		CreatorName: m.CreatorName,
+		Status:      m.Status,
+		CreatedAt:   m.CreatedAt.String(),
+	}
+}
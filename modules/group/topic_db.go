@@ -0,0 +1,92 @@
+package group
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+// insertTopicTx 添加群话题
+func (d *DB) insertTopicTx(model *TopicModel, tx *dbr.Tx) error {
+	_, err := tx.InsertInto("group_topic").Columns(util.AttrToUnderscore(model)...).Record(model).Exec()
+	return err
+}
+
+// queryTopicWithTopicNo 通过话题编号查询群话题
+func (d *DB) queryTopicWithTopicNo(topicNo string) (*TopicModel, error) {
+	var model *TopicModel
+	_, err := d.session.Select("*").From("group_topic").Where("topic_no=? and is_deleted=0", topicNo).Load(&model)
+	return model, err
+}
+
+// queryTopicsWithGroupNo 查询群内的话题（开启的在前）
+func (d *DB) queryTopicsWithGroupNo(groupNo string) ([]*TopicDetailModel, error) {
+	var models []*TopicDetailModel
+	_, err := d.session.Select("group_topic.*,IFNULL(user.name,'') creator_name").From("group_topic").LeftJoin("user", "group_topic.creator=user.uid").Where("group_topic.group_no=? and group_topic.is_deleted=0", groupNo).OrderAsc("group_topic.status").OrderDir("group_topic.id", false).Load(&models)
+	return models, err
+}
+
+// queryTopicsWithTopicNos 通过话题编号查询群话题
+func (d *DB) queryTopicsWithTopicNos(topicNos []string) ([]*TopicModel, error) {
+	if len(topicNos) == 0 {
+		return nil, nil
+	}
+	var models []*TopicModel
+	_, err := d.session.Select("*").From("group_topic").Where("topic_no in ? and is_deleted=0", topicNos).Load(&models)
+	return models, err
+}
+
+// queryTopicCount 查询群内的话题数量
+func (d *DB) queryTopicCount(groupNo string) (int64, error) {
+	var count int64
+	_, err := d.session.Select("count(*)").From("group_topic").Where("group_no=? and is_deleted=0", groupNo).Load(&count)
+	return count, err
+}
+
+// queryTopicNosWithGroupNo 查询群内所有话题编号
+func (d *DB) queryTopicNosWithGroupNo(groupNo string) ([]string, error) {
+	var topicNos []string
+	_, err := d.session.Select("topic_no").From("group_topic").Where("group_no=? and is_deleted=0", groupNo).Load(&topicNos)
+	return topicNos, err
+}
+
+// queryClosedTopicNosWithGroupNo 查询群内已关闭的话题编号
+func (d *DB) queryClosedTopicNosWithGroupNo(groupNo string) ([]string, error) {
+	var topicNos []string
+	_, err := d.session.Select("topic_no").From("group_topic").Where("group_no=? and is_deleted=0 and status=?", groupNo, TopicStatusClosed).Load(&topicNos)
+	return topicNos, err
+}
+
+// updateTopic 更新群话题的标题、图标和状态
+func (d *DB) updateTopic(model *TopicModel) error {
+	_, err := d.session.Update("group_topic").SetMap(map[string]interface{}{
+		"title":  model.Title,
+		"icon":   model.Icon,
+		"status": model.Status,
+	}).Where("topic_no=?", model.TopicNo).Exec()
+	return err
+}
+
+// deleteTopicTx 删除群话题
+func (d *DB) deleteTopicTx(topicNo string, tx *dbr.Tx) error {
+	_, err := tx.Update("group_topic").Set("is_deleted", 1).Where("topic_no=?", topicNo).Exec()
+	return err
+}
+
+// TopicModel 群话题
+type TopicModel struct {
+	TopicNo   string // 话题编号（话题频道ID）
+	GroupNo   string // 所属群编号
+	Title     string // 话题标题
+	Icon      string // 话题图标
+	Creator   string // 创建者uid
+	Status    int    // 状态 1.开启 2.关闭
+	IsDeleted int    // 是否已删除
+	db.BaseModel
+}
+
+// TopicDetailModel 群话题详情
+type TopicDetailModel struct {
+	TopicModel
+	CreatorName string // 创建者名字
+}
@@ -0,0 +1,265 @@
+package group
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// 欢迎语模版中的变量
+const (
+	welcomeVarName      = "{name}"       // 新成员（替换为@新成员）
+	welcomeVarGroupName = "{group_name}" // 群名称
+)
+
+// 获取群欢迎语和群规（rules_pending表示登录用户是否还未同意群规）
+func (g *Group) welcomeGet(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	groupNo := c.Param("group_no")
+	groupModel, err := g.getGroupInfo(groupNo)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	member, err := g.db.QueryMemberWithUID(loginUID, groupNo)
+	if err != nil {
+		g.Error("查询群成员信息失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询群成员信息失败！"))
+		return
+	}
+	if member == nil {
+		c.ResponseError(errors.New("不是群成员！"))
+		return
+	}
+	c.Response(&welcomeResp{
+		WelcomeMsg:    groupModel.WelcomeMsg,
+		Rules:         groupModel.Rules,
+		RulesRequired: groupModel.RulesRequired,
+		RulesPending:  member.RulesPending,
+	})
+}
+
+// 设置群欢迎语和群规
+func (g *Group) welcomeUpdate(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	groupNo := c.Param("group_no")
+	var req welcomeReq
+	if err := c.BindJSON(&req); err != nil {
+		g.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	groupModel, err := g.getGroupInfo(groupNo)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	// 欢迎语和群规属于群设置，不在权限矩阵中，只允许群主和管理员设置
+	isManager, err := g.db.QueryIsGroupManagerOrCreator(groupNo, loginUID)
+	if err != nil {
+		g.Error("查询是否是群管理者失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询是否是群管理者失败！"))
+		return
+	}
+	if !isManager {
+		c.ResponseError(errors.New("只有群主或管理员才能设置欢迎语和群规！"))
+		return
+	}
+	oldRulesRequired := groupModel.RulesRequired
+	groupModel.WelcomeMsg = strings.TrimSpace(req.WelcomeMsg)
+	groupModel.Rules = strings.TrimSpace(req.Rules)
+	groupModel.RulesRequired = req.RulesRequired
+	groupModel.Version = g.ctx.GenSeq(common.GroupSeqKey)
+	err = g.db.Update(groupModel)
+	if err != nil {
+		g.Error("设置欢迎语和群规失败！", zap.Error(err))
+		c.ResponseError(errors.New("设置欢迎语和群规失败！"))
+		return
+	}
+	if oldRulesRequired == 1 && groupModel.RulesRequired == 0 {
+		err = g.releaseRulesPendingMembers(groupNo)
+		if err != nil {
+			g.Error("解除待同意群规成员的发言限制失败！", zap.Error(err))
+			c.ResponseError(errors.New("解除待同意群规成员的发言限制失败！"))
+			return
+		}
+	}
+	err = g.ctx.SendChannelUpdateToGroup(groupNo)
+	if err != nil {
+		g.Warn("发送频道更新命令失败！", zap.Error(err))
+	}
+	g.addAuditLog(groupNo, loginUID, AuditActionGroupUpdate, nil, map[string]interface{}{
+		"welcome_msg":    groupModel.WelcomeMsg,
+		"rules":          groupModel.Rules,
+		"rules_required": groupModel.RulesRequired,
+	})
+	c.ResponseOK()
+}
+
+// 同意群规（同意后才能在群内发言）
+func (g *Group) rulesAccept(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	groupNo := c.Param("group_no")
+	_, err := g.getGroupInfo(groupNo)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	member, err := g.db.QueryMemberWithUID(loginUID, groupNo)
+	if err != nil {
+		g.Error("查询群成员信息失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询群成员信息失败！"))
+		return
+	}
+	if member == nil {
+		c.ResponseError(errors.New("不是群成员！"))
+		return
+	}
+	if member.RulesPending == 0 {
+		c.ResponseOK()
+		return
+	}
+	err = g.db.updateMemberRulesPending(groupNo, loginUID, 0, g.ctx.GenSeq(common.GroupMemberSeqKey))
+	if err != nil {
+		g.Error("同意群规失败！", zap.Error(err))
+		c.ResponseError(errors.New("同意群规失败！"))
+		return
+	}
+	member.RulesPending = 0
+	err = g.releaseRulesPendingMember(member)
+	if err != nil {
+		g.Error("解除成员发言限制失败！", zap.Error(err))
+		c.ResponseError(errors.New("解除成员发言限制失败！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 限制待同意群规的新成员发言
+func (g *Group) limitRulesPendingMembers(groupNo string, uids []string) {
+	err := g.setGroupBlacklist(groupNo, uids, true)
+	if err != nil {
+		g.Warn("限制待同意群规成员发言失败！", zap.Error(err), zap.String("group_no", groupNo))
+	}
+}
+
+// 解除成员因群规的发言限制（成员本身被禁言、拉黑或仍在慢速模式限制中时不移出IM黑名单）
+func (g *Group) releaseRulesPendingMember(member *MemberModel) error {
+	if member.Status == int(common.GroupMemberStatusBlacklist) || member.ForbiddenExpirTime > time.Now().Unix() {
+		return nil
+	}
+	slowModeReleaseAt, err := g.groupService.GetSlowModeReleaseAt(member.GroupNo, member.UID)
+	if err != nil {
+		return err
+	}
+	if slowModeReleaseAt > 0 { // 慢速模式限制到期后由解除任务移出IM黑名单
+		return nil
+	}
+	return g.setGroupBlacklist(member.GroupNo, []string{member.UID}, false)
+}
+
+// 群不再要求同意群规时解除所有待同意成员的发言限制
+func (g *Group) releaseRulesPendingMembers(groupNo string) error {
+	members, err := g.db.queryRulesPendingMembers(groupNo)
+	if err != nil {
+		return err
+	}
+	if len(members) == 0 {
+		return nil
+	}
+	err = g.db.clearRulesPending(groupNo, g.ctx.GenSeq(common.GroupMemberSeqKey))
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		err = g.releaseRulesPendingMember(member)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendWelcome 向新加入的成员发送群欢迎语
+func (s *Service) SendWelcome(groupNo string, members []*config.UserBaseVo) error {
+	if len(members) == 0 {
+		return nil
+	}
+	groupModel, err := s.db.QueryWithGroupNo(groupNo)
+	if err != nil {
+		return err
+	}
+	if groupModel == nil || groupModel.Status != GroupStatusNormal || strings.TrimSpace(groupModel.WelcomeMsg) == "" {
+		return nil
+	}
+	mentionUIDs := make([]string, 0, len(members))
+	mentionNames := make([]string, 0, len(members))
+	for _, member := range members {
+		mentionUIDs = append(mentionUIDs, member.UID)
+		mentionNames = append(mentionNames, fmt.Sprintf("@%s", member.Name))
+	}
+	content := strings.ReplaceAll(groupModel.WelcomeMsg, welcomeVarGroupName, groupModel.Name)
+	if strings.Contains(content, welcomeVarName) {
+		content = strings.ReplaceAll(content, welcomeVarName, strings.Join(mentionNames, " "))
+	} else {
+		content = fmt.Sprintf("%s %s", strings.Join(mentionNames, " "), content)
+	}
+	if groupModel.RulesRequired == 1 {
+		content = fmt.Sprintf("%s\n请先阅读并同意群规后再发言", content)
+	}
+	return s.ctx.SendMessage(&config.MsgSendReq{
+		Header: config.MsgHeader{
+			RedDot: 1,
+		},
+		FromUID:     s.ctx.GetConfig().Account.SystemUID,
+		ChannelID:   groupNo,
+		ChannelType: common.ChannelTypeGroup.Uint8(),
+		Payload: []byte(util.ToJson(map[string]interface{}{
+			"content": content,
+			"mention": map[string]interface{}{
+				"uids": mentionUIDs,
+			},
+			"type": common.Text,
+		})),
+	})
+}
+
+type welcomeReq struct {
+	WelcomeMsg    string `json:"welcome_msg"`    // 欢迎语模版（支持{name}、{group_name}变量） 为空表示不发送欢迎语
+	Rules         string `json:"rules"`          // 群规
+	RulesRequired int    `json:"rules_required"` // 新成员是否需要同意群规后才能发言 0.否 1.是
+}
+
+func (r welcomeReq) check() error {
+	if len([]rune(r.WelcomeMsg)) > WelcomeMsgMaxLength {
+		return fmt.Errorf("欢迎语不能超过%d个字！", WelcomeMsgMaxLength)
+	}
+	if len([]rune(r.Rules)) > RulesMaxLength {
+		return fmt.Errorf("群规不能超过%d个字！", RulesMaxLength)
+	}
+	if r.RulesRequired != 0 && r.RulesRequired != 1 {
+		return errors.New("是否需要同意群规参数有误！")
+	}
+	if r.RulesRequired == 1 && strings.TrimSpace(r.Rules) == "" {
+		return errors.New("要求新成员同意群规时群规不能为空！")
+	}
+	return nil
+}
+
+type welcomeResp struct {
+	WelcomeMsg    string `json:"welcome_msg"`    // 欢迎语模版
+	Rules         string `json:"rules"`          // 群规
+	RulesRequired int    `json:"rules_required"` // 新成员是否需要同意群规后才能发言 0.否 1.是
+	RulesPending  int    `json:"rules_pending"`  // 登录用户是否待同意群规 0.否 1.是
+}
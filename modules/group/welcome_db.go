@@ -0,0 +1,33 @@
+package group
+
+// queryRulesPendingUIDs 查询群内待同意群规的成员uid
+func (d *DB) queryRulesPendingUIDs(groupNo string) ([]string, error) {
+	var uids []string
+	_, err := d.session.Select("uid").From("group_member").Where("group_no=? and is_deleted=0 and rules_pending=1", groupNo).Load(&uids)
+	return uids, err
+}
+
+// queryRulesPendingMembers 查询群内待同意群规的成员
+func (d *DB) queryRulesPendingMembers(groupNo string) ([]*MemberModel, error) {
+	var models []*MemberModel
+	_, err := d.session.Select("*").From("group_member").Where("group_no=? and is_deleted=0 and rules_pending=1", groupNo).Load(&models)
+	return models, err
+}
+
+// updateMemberRulesPending 修改成员的待同意群规状态
+func (d *DB) updateMemberRulesPending(groupNo string, uid string, rulesPending int, version int64) error {
+	_, err := d.session.Update("group_member").SetMap(map[string]interface{}{
+		"rules_pending": rulesPending,
+		"version":       version,
+	}).Where("group_no=? and uid=?", groupNo, uid).Exec()
+	return err
+}
+
+// clearRulesPending 清除群内所有成员的待同意群规状态
+func (d *DB) clearRulesPending(groupNo string, version int64) error {
+	_, err := d.session.Update("group_member").SetMap(map[string]interface{}{
+		"rules_pending": 0,
+		"version":       version,
+	}).Where("group_no=? and rules_pending=1", groupNo).Exec()
+	return err
+}
@@ -0,0 +1,25 @@
+package linkpreview
+
+import (
+	_ "embed"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/register"
+)
+
+//go:embed swagger/api.yaml
+var swaggerContent string
+
+func init() {
+
+	register.AddModule(func(ctx interface{}) register.Module {
+
+		return register.Module{
+			Name: "linkpreview",
+			SetupAPI: func() register.APIRouter {
+				return New(ctx.(*config.Context))
+			},
+			Swagger: swaggerContent,
+		}
+	})
+}
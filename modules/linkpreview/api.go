@@ -0,0 +1,122 @@
+package linkpreview
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/file"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// LinkPreview 链接预览
+type LinkPreview struct {
+	ctx *config.Context
+	log.Log
+	fetcher     *fetcher
+	fileService file.IService
+}
+
+// New New
+func New(ctx *config.Context) *LinkPreview {
+	return &LinkPreview{
+		ctx:         ctx,
+		Log:         log.NewTLog("LinkPreview"),
+		fetcher:     newFetcher(isPublicIP),
+		fileService: file.NewService(ctx),
+	}
+}
+
+// Route 路由配置
+func (l *LinkPreview) Route(r *wkhttp.WKHttp) {
+	link := r.Group("/v1/link", l.ctx.AuthMiddleware(r))
+	{
+		link.GET("/preview", l.preview) // 获取链接预览
+	}
+}
+
+// 获取链接预览
+func (l *LinkPreview) preview(c *wkhttp.Context) {
+	u, err := parseURL(strings.TrimSpace(c.Query("url")))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	cacheKey := fmt.Sprintf("%s%s", CacheKeyPrefix, util.MD5(u.String()))
+	cacheValue, err := l.ctx.GetRedisConn().GetString(cacheKey)
+	if err != nil {
+		l.Error("获取链接预览缓存失败！", zap.Error(err))
+		c.ResponseError(errors.New("获取链接预览缓存失败！"))
+		return
+	}
+	if cacheValue == FailCacheValue {
+		c.ResponseError(errors.New("无法获取链接预览！"))
+		return
+	}
+	if cacheValue != "" {
+		var preview *Preview
+		if err = util.ReadJsonByByte([]byte(cacheValue), &preview); err == nil {
+			c.Response(preview)
+			return
+		}
+		l.Warn("链接预览缓存格式有误！", zap.Error(err), zap.String("url", u.String()))
+	}
+
+	preview, err := l.fetcher.fetchPreview(c.Request.Context(), u)
+	if err != nil {
+		l.Warn("获取链接预览失败！", zap.Error(err), zap.String("url", u.String()))
+		if errors.Is(err, ErrForbiddenAddress) {
+			c.ResponseError(err)
+			return
+		}
+		err = l.ctx.GetRedisConn().SetAndExpire(cacheKey, FailCacheValue, FailCacheExpire)
+		if err != nil {
+			l.Warn("缓存链接预览失败结果失败！", zap.Error(err))
+		}
+		c.ResponseError(errors.New("无法获取链接预览！"))
+		return
+	}
+	if preview.imageURL != "" {
+		// 预览图转存失败不影响预览结果
+		imagePath, err := l.storeImage(c.Request.Context(), preview.imageURL)
+		if err != nil {
+			l.Warn("转存链接预览图失败！", zap.Error(err), zap.String("image", preview.imageURL))
+		} else {
+			preview.Image = imagePath
+		}
+	}
+	err = l.ctx.GetRedisConn().SetAndExpire(cacheKey, util.ToJson(preview), CacheExpire)
+	if err != nil {
+		l.Warn("缓存链接预览失败！", zap.Error(err))
+	}
+	c.Response(preview)
+}
+
+// 下载预览图并上传到文件服务，返回预览地址
+func (l *LinkPreview) storeImage(ctx context.Context, imageURL string) (string, error) {
+	data, contentType, err := l.fetcher.fetchImage(ctx, imageURL)
+	if err != nil {
+		return "", err
+	}
+	ext := ""
+	if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+		ext = exts[0]
+	}
+	path := fmt.Sprintf("/%s%s", util.MD5(imageURL), ext)
+	_, err = l.fileService.UploadFile(fmt.Sprintf("%s%s", file.TypeLinkPreview, path), contentType, func(w io.Writer) error {
+		_, err := io.Copy(w, bytes.NewReader(data))
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("file/preview/%s%s", file.TypeLinkPreview, path), nil
+}
@@ -0,0 +1,29 @@
+package linkpreview
+
+import "time"
+
+const (
+	// CacheKeyPrefix 链接预览缓存前缀
+	CacheKeyPrefix = "linkPreview:"
+	// CacheExpire 链接预览缓存时间
+	CacheExpire = time.Hour * 24
+	// FailCacheExpire 获取失败的链接缓存时间（避免短时间内重复抓取）
+	FailCacheExpire = time.Minute * 10
+	// FailCacheValue 获取失败的缓存值
+	FailCacheValue = "fail"
+
+	// FetchTimeout 抓取超时时间（包含重定向和读取内容）
+	FetchTimeout = time.Second * 10
+	// MaxRedirects 最大重定向次数
+	MaxRedirects = 3
+	// MaxHTMLSize 网页内容最大大小
+	MaxHTMLSize int64 = 2 * 1024 * 1024
+	// MaxImageSize 预览图最大大小
+	MaxImageSize int64 = 5 * 1024 * 1024
+	// MaxURLLength 链接最大长度
+	MaxURLLength = 2048
+	// MaxTitleLength 标题最大长度（字符）
+	MaxTitleLength = 200
+	// MaxDescriptionLength 描述最大长度（字符）
+	MaxDescriptionLength = 500
+)
@@ -0,0 +1,330 @@
+package linkpreview
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	// ErrInvalidURL 链接格式有误
+	ErrInvalidURL = errors.New("链接格式有误！")
+	// ErrForbiddenAddress 不允许访问的地址（内网、回环等）
+	ErrForbiddenAddress = errors.New("不允许访问该地址！")
+	// ErrResponseTooLarge 响应内容过大
+	ErrResponseTooLarge = errors.New("响应内容过大！")
+	// ErrUnsupportedContentType 不支持的内容类型
+	ErrUnsupportedContentType = errors.New("不支持的内容类型！")
+)
+
+// 非公网地址段（ip.IsPrivate等未覆盖的部分）
+var reservedNets = mustParseCIDRs(
+	"0.0.0.0/8",       // 本网络
+	"100.64.0.0/10",   // 运营商级NAT
+	"192.0.0.0/24",    // IETF协议分配
+	"192.0.2.0/24",    // 文档示例
+	"198.18.0.0/15",   // 基准测试
+	"198.51.100.0/24", // 文档示例
+	"203.0.113.0/24",  // 文档示例
+	"240.0.0.0/4",     // 保留地址（含广播地址）
+	"64:ff9b::/96",    // NAT64
+	"2001:db8::/32",   // 文档示例
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+// isPublicIP 是否是公网地址
+func isPublicIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, ipNet := range reservedNets {
+		if ipNet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseURL 解析并校验链接（仅支持http和https）
+func parseURL(rawURL string) (*url.URL, error) {
+	if rawURL == "" || len(rawURL) > MaxURLLength {
+		return nil, ErrInvalidURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, ErrInvalidURL
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, ErrInvalidURL
+	}
+	if u.Hostname() == "" || u.User != nil {
+		return nil, ErrInvalidURL
+	}
+	u.Fragment = ""
+	return u, nil
+}
+
+// Preview 链接预览
+type Preview struct {
+	URL         string `json:"url"`         // 链接地址（优先og:url）
+	Title       string `json:"title"`       // 标题
+	Description string `json:"description"` // 描述
+	SiteName    string `json:"site_name"`   // 站点名称
+	Image       string `json:"image"`       // 预览图（转存后的地址）
+	imageURL    string // 网页内的预览图原地址
+}
+
+// fetcher 链接抓取（连接建立时校验目标地址，重定向和DNS重绑定同样会被拦截）
+type fetcher struct {
+	client       *http.Client
+	maxHTMLSize  int64
+	maxImageSize int64
+}
+
+func newFetcher(allowIP func(ip net.IP) bool) *fetcher {
+	dialer := &net.Dialer{
+		Timeout: FetchTimeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !allowIP(ip) {
+				return ErrForbiddenAddress
+			}
+			return nil
+		},
+	}
+	transport := &http.Transport{
+		Proxy:                 nil, // 不走代理，否则无法校验实际连接的地址
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   FetchTimeout,
+		ResponseHeaderTimeout: FetchTimeout,
+		MaxIdleConns:          10,
+	}
+	return &fetcher{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   FetchTimeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) > MaxRedirects {
+					return errors.New("重定向次数过多！")
+				}
+				if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+					return ErrInvalidURL
+				}
+				return nil
+			},
+		},
+		maxHTMLSize:  MaxHTMLSize,
+		maxImageSize: MaxImageSize,
+	}
+}
+
+func (f *fetcher) get(ctx context.Context, u string, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; TangSengDaoDaoBot/1.0)")
+	req.Header.Set("Accept", accept)
+	resp, err := f.client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrForbiddenAddress) {
+			return nil, ErrForbiddenAddress
+		}
+		return nil, err
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		resp.Body.Close()
+		return nil, fmt.Errorf("请求失败，状态码：%d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// readLimit 读取响应内容，超过最大大小返回ErrResponseTooLarge
+func readLimit(resp *http.Response, maxSize int64) ([]byte, error) {
+	if resp.ContentLength > maxSize {
+		return nil, ErrResponseTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, ErrResponseTooLarge
+	}
+	return data, nil
+}
+
+// fetchPreview 抓取网页并解析预览信息
+func (f *fetcher) fetchPreview(ctx context.Context, u *url.URL) (*Preview, error) {
+	resp, err := f.get(ctx, u.String(), "text/html,application/xhtml+xml")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, ErrUnsupportedContentType
+	}
+	data, err := readLimit(resp, f.maxHTMLSize)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := charset.NewReader(bytes.NewReader(data), contentType)
+	if err != nil {
+		return nil, err
+	}
+	return parseHTML(reader, resp.Request.URL)
+}
+
+// fetchImage 下载预览图
+func (f *fetcher) fetchImage(ctx context.Context, imageURL string) ([]byte, string, error) {
+	if _, err := parseURL(imageURL); err != nil {
+		return nil, "", err
+	}
+	resp, err := f.get(ctx, imageURL, "image/*")
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !strings.HasPrefix(mediaType, "image/") || mediaType == "image/svg+xml" {
+		return nil, "", ErrUnsupportedContentType
+	}
+	data, err := readLimit(resp, f.maxImageSize)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, mediaType, nil
+}
+
+// parseHTML 解析网页的OpenGraph、Twitter和基础meta信息
+func parseHTML(r io.Reader, baseURL *url.URL) (*Preview, error) {
+	metas := map[string]string{}
+	var title string
+	inTitle := false
+	tokenizer := html.NewTokenizer(r)
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			if tokenizer.Err() != io.EOF {
+				return nil, tokenizer.Err()
+			}
+			return buildPreview(metas, title, baseURL), nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.Data {
+			case "title":
+				inTitle = tokenType == html.StartTagToken && title == ""
+			case "meta":
+				var key, content string
+				for _, attr := range token.Attr {
+					switch strings.ToLower(attr.Key) {
+					case "property", "name":
+						if key == "" {
+							key = strings.ToLower(strings.TrimSpace(attr.Val))
+						}
+					case "content":
+						content = strings.TrimSpace(attr.Val)
+					}
+				}
+				if key != "" && content != "" {
+					if _, ok := metas[key]; !ok {
+						metas[key] = content
+					}
+				}
+			case "body":
+				// 预览信息都在head内，不再解析body
+				return buildPreview(metas, title, baseURL), nil
+			}
+		case html.TextToken:
+			if inTitle {
+				title = strings.TrimSpace(string(tokenizer.Text()))
+				inTitle = false
+			}
+		case html.EndTagToken:
+			inTitle = false
+		}
+	}
+}
+
+func buildPreview(metas map[string]string, title string, baseURL *url.URL) *Preview {
+	first := func(keys ...string) string {
+		for _, key := range keys {
+			if v := metas[key]; v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	preview := &Preview{
+		URL:         baseURL.String(),
+		Title:       truncate(first("og:title", "twitter:title"), MaxTitleLength),
+		Description: truncate(first("og:description", "twitter:description", "description"), MaxDescriptionLength),
+		SiteName:    truncate(first("og:site_name", "application-name"), MaxTitleLength),
+	}
+	if preview.Title == "" {
+		preview.Title = truncate(title, MaxTitleLength)
+	}
+	if ogURL := resolveURL(baseURL, first("og:url")); ogURL != "" {
+		preview.URL = ogURL
+	}
+	preview.imageURL = resolveURL(baseURL, first("og:image:secure_url", "og:image", "og:image:url", "twitter:image", "twitter:image:src"))
+	return preview
+}
+
+// resolveURL 相对地址转换为绝对地址（仅保留http和https）
+func resolveURL(baseURL *url.URL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	u := baseURL.ResolveReference(refURL)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+	return u.String()
+}
+
+func truncate(s string, maxLength int) string {
+	if utf8.RuneCountInString(s) <= maxLength {
+		return s
+	}
+	return string([]rune(s)[:maxLength])
+}
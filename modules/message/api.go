@@ -10,6 +10,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/elastic"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/channel"
 	chservice "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/channel/service"
@@ -17,6 +18,7 @@ import (
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/file"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/redis"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
@@ -25,6 +27,7 @@ import (
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkevent"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
 	"github.com/gocraft/dbr/v2"
+	"github.com/gookit/goutil/maputil"
 	"github.com/pkg/errors"
 	"github.com/sendgrid/rest"
 	"go.uber.org/zap"
@@ -45,11 +48,21 @@ type Message struct {
 	messageUserExtraDB  *messageUserExtraDB
 	remindersDB         *remindersDB
 	pinnedDB            *pinnedDB
+	editHistoryDB       *editHistoryDB
+	moderationDB        *moderationDB
+	favoriteDB          *favoriteDB
+	pollDB              *pollDB
+	threadDB            *threadDB
+	prohibitWordFilter  *prohibitWordFilter
+	scheduledMessageDB  *scheduledMessageDB
+	leaseID             string      // 调度租约（定时消息、自定义提醒、投票截止）的持有者标识（每个实例唯一）
+	leaseRedis          *redis.Conn // 调度租约使用的redis连接（需要SET NX和lua脚本保证原子性）
 	userService         user.IService
 	groupService        group.IService
 	commonService       commonapi.IService
 	fileService         file.IService
 	channelService      chservice.IService
+	searchService       *elastic.Service
 	mutex               sync.Mutex
 }
 
@@ -72,13 +85,25 @@ func New(ctx *config.Context) *Message {
 		deviceOffsetDB:      newDeviceOffsetDB(ctx.DB()),
 		remindersDB:         newRemindersDB(ctx),
 		pinnedDB:            newPinnedDB(ctx),
+		editHistoryDB:       newEditHistoryDB(ctx),
+		moderationDB:        newModerationDB(ctx),
+		favoriteDB:          newFavoriteDB(ctx),
+		pollDB:              newPollDB(ctx),
+		threadDB:            newThreadDB(ctx),
+		prohibitWordFilter:  newProhibitWordFilter(ctx),
+		scheduledMessageDB:  newScheduledMessageDB(ctx),
+		leaseID:             util.GenerUUID(),
+		leaseRedis:          redis.New(ctx.GetConfig().DB.RedisAddr, ctx.GetConfig().DB.RedisPass),
 		userService:         user.NewService(ctx),
 		commonService:       commonapi.NewService(ctx),
 		fileService:         file.NewService(ctx),
 		channelService:      channel.NewService(ctx),
+		searchService:       elastic.NewService(ctx),
 	}
 	m.ctx.AddEventListener(event.GroupMemberAdd, m.handleGroupMemberAddEvent)
 	m.ctx.AddEventListener(event.GroupMemberScanJoin, m.handleGroupMemberScanJoinEvent)
+	m.ctx.AddEventListener(event.GroupMemberDirectoryJoin, m.handleGroupMemberDirectoryJoinEvent)
+	m.ctx.AddEventListener(event.GroupTopicDelete, m.handleGroupTopicDeleteEvent)
 	return m
 }
 
@@ -101,12 +126,45 @@ func (m *Message) Route(r *wkhttp.WKHttp) {
 		message.POST("/readed", m.messageReaded)                  // 消息已读
 		message.GET("/sync/sensitivewords", m.syncSensitiveWords) // 同步敏感词
 		message.POST("/edit", m.messageEdit)                      // 消息编辑
+		message.GET("/edit/history", m.messageEditHistory)        // 消息编辑历史
 		message.POST("/reminder/sync", m.reminderSync)            // 同步提醒
 		message.POST("/reminder/done", m.reminderDone)            // 提醒已处理完成
+		message.POST("/reminder", m.reminderAdd)                  // 添加自定义提醒
+		message.DELETE("/reminder/:id", m.reminderCancel)         // 取消自定义提醒
 		message.GET("/prohibit_words/sync", m.syncProhibitWords)  // 同步违禁词
 		message.POST("/pinned", m.pinnedMessage)                  // 置顶消息
 		message.POST("/pinned/sync", m.syncPinnedMessage)         // 同步置顶消息
 		message.POST("/pinned/clear", m.clearPinnedMessage)       // 删除所有置顶消息
+
+		message.POST("/scheduled", m.scheduledAdd)                    // 添加定时消息
+		message.GET("/scheduled", m.scheduledList)                    // 频道内的定时消息
+		message.PUT("/scheduled/:scheduled_no", m.scheduledUpdate)    // 修改定时消息
+		message.DELETE("/scheduled/:scheduled_no", m.scheduledCancel) // 取消定时消息
+
+		message.GET("/thread/replies", m.threadReplies)    // 话题回复
+		message.POST("/thread/follow", m.threadFollow)     // 关注话题
+		message.DELETE("/thread/follow", m.threadUnfollow) // 取消关注话题
+		message.POST("/thread/read", m.threadRead)         // 话题回复已读
+		message.GET("/thread/followed", m.threadFollowed)  // 关注的话题
+	}
+	// 收藏
+	favorites := r.Group("/v1/favorites", m.ctx.AuthMiddleware(r))
+	{
+		favorites.POST("", m.favoriteAdd)                         // 收藏消息
+		favorites.POST("/sync", m.favoriteSync)                   // 同步收藏
+		favorites.GET("/search", m.favoriteSearch)                // 搜索收藏
+		favorites.GET("/tags", m.favoriteTags)                    // 收藏标签
+		favorites.PUT("/:favorite_no/tags", m.favoriteUpdateTags) // 修改收藏标签
+		favorites.DELETE("/:favorite_no", m.favoriteDelete)       // 删除收藏
+	}
+	// 投票
+	polls := r.Group("/v1/polls", m.ctx.AuthMiddleware(r))
+	{
+		polls.POST("", m.pollCreate)                  // 发起投票
+		polls.GET("/:poll_no", m.pollDetail)          // 投票详情
+		polls.POST("/:poll_no/vote", m.pollVote)      // 投票
+		polls.DELETE("/:poll_no/vote", m.pollRetract) // 撤回投票
+		polls.PUT("/:poll_no/close", m.pollClose)     // 结束投票
 	}
 	messages := r.Group("/v1/messages", m.ctx.AuthMiddleware(r))
 	{
@@ -128,6 +186,9 @@ func (m *Message) Route(r *wkhttp.WKHttp) {
 	}
 	m.ctx.AddMessagesListener(m.listenerMessages) // 监听消息
 	m.syncMessageReadedCount()
+	go m.CheckScheduledMessageLoop()
+	go m.CheckCustomReminderLoop()
+	go m.CheckPollDeadlineLoop()
 }
 
 func (m *Message) sendMsg(c *wkhttp.Context) {
@@ -211,8 +272,30 @@ func (m *Message) sendMsg(c *wkhttp.Context) {
 			c.ResponseError(errors.New("未在群内"))
 			return
 		}
+		if m.isMentionAll(req.Payload) {
+			canMentionAll, err := m.groupService.HasPermission(req.ReceiveChannelID, uid, group.PermissionMentionAll)
+			if err != nil {
+				m.Error("查询群权限错误", zap.Error(err))
+				c.ResponseError(errors.New("查询群权限错误"))
+				return
+			}
+			if !canMentionAll {
+				c.ResponseError(errors.New("没有@所有人的权限"))
+				return
+			}
+		}
+	}
+	err = m.checkCardShare(uid, req.Payload)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	payload, err := m.filterProhibitWords(req.Payload)
+	if err != nil {
+		c.ResponseError(err)
+		return
 	}
-	err = m.sendMessage(req.ReceiveChannelID, req.ReceiveChannelType, uid, req.Payload)
+	err = m.sendMessage(req.ReceiveChannelID, req.ReceiveChannelType, uid, payload)
 	if err != nil {
 		c.ResponseError(err)
 		return
@@ -220,6 +303,73 @@ func (m *Message) sendMsg(c *wkhttp.Context) {
 	c.ResponseOK()
 }
 
+// 发送前过滤消息中的违禁词
+func (m *Message) filterProhibitWords(payload map[string]interface{}) (map[string]interface{}, error) {
+	newPayload, err := m.prohibitWordFilter.filterPayload(payload)
+	if err != nil {
+		if errors.Is(err, ErrProhibitWordsBlocked) {
+			return nil, err
+		}
+		m.Error("检查违禁词失败！", zap.Error(err))
+		return nil, errors.New("检查违禁词失败！")
+	}
+	return newPayload, nil
+}
+
+// 消息是否@所有人
+func (m *Message) isMentionAll(payload map[string]interface{}) bool {
+	mentionMap, _ := payload["mention"].(map[string]interface{})
+	if mentionMap == nil {
+		return false
+	}
+	switch all := mentionMap["all"].(type) {
+	case float64:
+		return all == 1
+	case json.Number:
+		allI, _ := all.Int64()
+		return allI == 1
+	}
+	return false
+}
+
+// 频道所属的群（话题频道返回话题所属的群，话题的权限和成员都继承所属群）
+func (m *Message) getTopicGroupNo(channelID string, channelType uint8) (string, error) {
+	if channelType != common.ChannelTypeCommunityTopic.Uint8() {
+		return channelID, nil
+	}
+	topic, err := m.groupService.GetTopic(channelID)
+	if err != nil {
+		return "", err
+	}
+	if topic == nil {
+		return "", errors.New("话题不存在")
+	}
+	return topic.GroupNo, nil
+}
+
+// 检查名片消息是否允许分享（名片所属用户关闭分享后，除本人外不允许发送其名片）
+func (m *Message) checkCardShare(fromUID string, payload map[string]interface{}) error {
+	if common.ContentType(maputil.Data(payload).Int("type")) != common.Card {
+		return nil
+	}
+	cardUID, _ := payload["uid"].(string)
+	if strings.TrimSpace(cardUID) == "" {
+		return errors.New("名片用户不能为空")
+	}
+	if cardUID == fromUID {
+		return nil
+	}
+	allow, err := m.userService.AllowShareCard(cardUID)
+	if err != nil {
+		m.Error("查询用户是否允许分享名片错误", zap.Error(err), zap.String("uid", cardUID))
+		return errors.New("查询用户是否允许分享名片错误")
+	}
+	if !allow {
+		return errors.New("该用户不允许分享名片")
+	}
+	return nil
+}
+
 func (m *Message) sendMessage(channelID string, channelType uint8, fromUID string, payload map[string]interface{}) error {
 	err := m.ctx.SendMessage(&config.MsgSendReq{
 		Header: config.MsgHeader{
@@ -262,6 +412,46 @@ func (m *Message) messageEdit(c *wkhttp.Context) {
 		c.ResponseError(errors.New("频道ID不能为空！"))
 		return
 	}
+	fakeChannelID := req.ChannelID
+	if req.ChannelType == common.ChannelTypePerson.Uint8() {
+		fakeChannelID = common.GetFakeChannelIDWith(c.GetLoginUID(), req.ChannelID)
+	}
+	messageM, err := m.db.queryMessageWithMessageID(fakeChannelID, req.MessageID)
+	if err != nil {
+		m.Error("查询消息失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询消息失败！"))
+		return
+	}
+	if messageM == nil {
+		c.ResponseError(errors.New("消息不存在！"))
+		return
+	}
+	if messageM.FromUID != c.GetLoginUID() {
+		c.ResponseError(errors.New("只能编辑自己发送的消息！"))
+		return
+	}
+	expired, err := m.isRevokeExpired(c, messageM)
+	if err != nil {
+		m.Error("查询消息可编辑时长失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询消息可编辑时长失败！"))
+		return
+	}
+	if expired {
+		c.ResponseErrorWithStatus(errors.New("消息已超过可编辑时长！"), StatusMessageEditExpired)
+		return
+	}
+	// 编辑后的正文和发送消息一样过滤违禁词
+	var contentEditMap map[string]interface{}
+	if err := util.ReadJsonByByte([]byte(req.ContentEdit), &contentEditMap); err != nil || contentEditMap == nil {
+		c.ResponseError(errors.New("编辑正文格式有误！"))
+		return
+	}
+	contentEditMap, err = m.filterProhibitWords(contentEditMap)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	req.ContentEdit = util.ToJson(contentEditMap)
 	contentEdit := dbr.NewNullString(req.ContentEdit).String
 	contentMD5 := util.MD5(contentEdit)
 
@@ -289,11 +479,8 @@ func (m *Message) messageEdit(c *wkhttp.Context) {
 			panic(err)
 		}
 	}()
-	fakeChannelID := req.ChannelID
-	if req.ChannelType == common.ChannelTypePerson.Uint8() {
-		fakeChannelID = common.GetFakeChannelIDWith(c.GetLoginUID(), req.ChannelID)
-	}
 
+	editedAt := int(time.Now().Unix())
 	version := m.genMessageExtraSeq(fakeChannelID)
 	err = m.messageExtraDB.insertOrUpdateContentEditTx(&messageExtraModel{
 		MessageID:       req.MessageID,
@@ -302,7 +489,7 @@ func (m *Message) messageEdit(c *wkhttp.Context) {
 		ChannelType:     req.ChannelType,
 		ContentEdit:     dbr.NewNullString(req.ContentEdit),
 		ContentEditHash: contentMD5,
-		EditedAt:        int(time.Now().Unix()),
+		EditedAt:        editedAt,
 		Version:         version,
 	}, tx)
 	if err != nil {
@@ -310,6 +497,22 @@ func (m *Message) messageEdit(c *wkhttp.Context) {
 		c.ResponseError(errors.New("添加或修改编辑内容失败！"))
 		return
 	}
+	// 每次编辑都保存一个版本，便于查看编辑前的内容
+	err = m.editHistoryDB.insertTx(&editHistoryModel{
+		MessageID:       req.MessageID,
+		ChannelID:       fakeChannelID,
+		ChannelType:     req.ChannelType,
+		Editor:          c.GetLoginUID(),
+		ContentEdit:     dbr.NewNullString(req.ContentEdit),
+		ContentEditHash: contentMD5,
+		EditedAt:        editedAt,
+	}, tx)
+	if err != nil {
+		tx.Rollback()
+		m.Error("添加消息编辑历史失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加消息编辑历史失败！"))
+		return
+	}
 	msgIds := make([]string, 0)
 	msgIds = append(msgIds, req.MessageID)
 	// 发布编辑事件
@@ -355,6 +558,70 @@ func (m *Message) messageEdit(c *wkhttp.Context) {
 	c.ResponseOK()
 }
 
+// 消息编辑历史（与同步频道消息的访问权限一致）
+func (m *Message) messageEditHistory(c *wkhttp.Context) {
+	messageID := c.Query("message_id")
+	channelID := c.Query("channel_id")
+	channelTypeI, _ := strconv.ParseUint(c.Query("channel_type"), 10, 8)
+	channelType := uint8(channelTypeI)
+	if strings.TrimSpace(messageID) == "" {
+		c.ResponseError(errors.New("消息ID不能为空！"))
+		return
+	}
+	if strings.TrimSpace(channelID) == "" {
+		c.ResponseError(errors.New("频道ID不能为空！"))
+		return
+	}
+	if channelType == common.ChannelTypeGroup.Uint8() || channelType == common.ChannelTypeCommunityTopic.Uint8() {
+		// 话题的成员为所属群的成员
+		groupNo, err := m.getTopicGroupNo(channelID, channelType)
+		if err != nil {
+			m.Error("查询话题信息错误", zap.Error(err))
+			c.ResponseError(errors.New("查询话题信息错误"))
+			return
+		}
+		exist, err := m.groupService.ExistMember(groupNo, c.GetLoginUID())
+		if err != nil {
+			m.Error("查询是否在群内存在失败！", zap.Error(err))
+			c.ResponseError(errors.New("查询是否在群内存在失败！"))
+			return
+		}
+		if !exist {
+			c.ResponseError(errors.New("不在群内，无法查看消息编辑历史！"))
+			return
+		}
+	}
+	fakeChannelID := channelID
+	if channelType == common.ChannelTypePerson.Uint8() {
+		fakeChannelID = common.GetFakeChannelIDWith(c.GetLoginUID(), channelID)
+	}
+	messageM, err := m.db.queryMessageWithMessageID(fakeChannelID, messageID)
+	if err != nil {
+		m.Error("查询消息失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询消息失败！"))
+		return
+	}
+	if messageM == nil || messageM.ChannelID != fakeChannelID || messageM.ChannelType != channelType {
+		c.ResponseError(errors.New("消息不存在！"))
+		return
+	}
+	models, err := m.editHistoryDB.queryWithMessageID(messageID)
+	if err != nil {
+		m.Error("查询消息编辑历史失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询消息编辑历史失败！"))
+		return
+	}
+	// 版本0为原始正文，之后按编辑先后依次为版本1、2...
+	list := make([]*editHistoryResp, 0, len(models)+1)
+	list = append(list, newOriginalEditHistoryResp(messageM))
+	for i, model := range models {
+		resp := newEditHistoryResp(model)
+		resp.Version = i + 1
+		list = append(list, resp)
+	}
+	c.Response(list)
+}
+
 // 消息已读
 func (m *Message) messageReaded(c *wkhttp.Context) {
 	loginUID := c.GetLoginUID()
@@ -688,13 +955,7 @@ func (m *Message) typing(c *wkhttp.Context) {
 
 // 搜索消息
 func (m *Message) search(c *wkhttp.Context) {
-	var req struct {
-		UID         string `json:"uid"` // 搜索的消息限定这某个用户内
-		ChannelID   string `json:"channel_id"`
-		ChannelType uint8  `json:"channel_type"`
-		ContentType int    `json:"content_type"` // 正文类型
-		Keyword     string `json:"keyword"`
-	}
+	var req *messageSearchReq
 	if err := c.BindJSON(&req); err != nil {
 		m.Error("数据格式有误！", zap.Error(err))
 		c.ResponseError(err)
@@ -702,7 +963,11 @@ func (m *Message) search(c *wkhttp.Context) {
 	}
 	uid := c.MustGet("uid").(string)
 	req.UID = uid
-	fmt.Println("req->", req)
+	if m.searchService.On() {
+		m.searchWithIndex(c, req)
+		return
+	}
+	// 未开启消息搜索时由悟空IM搜索
 	resp, err := network.Post(fmt.Sprintf("%s/message/search", m.ctx.GetConfig().WuKongIM.APIURL), []byte(util.ToJson(req)), nil)
 	if err != nil {
 		m.Error("调用搜索失败！", zap.Error(err))
@@ -1053,16 +1318,15 @@ func (m *Message) mutualDelete(c *wkhttp.Context) {
 		return
 	}
 	isCanDelete := true
-	if req.ChannelType == common.ChannelTypeGroup.Uint8() {
-		isManager, err := m.groupService.IsCreatorOrManager(req.ChannelID, loginUID)
+	if req.ChannelType == common.ChannelTypeGroup.Uint8() && resp.Messages[0].FromUID != loginUID {
+		// 删除他人的消息需要撤回消息权限（群主不可被操作，管理员只能被群主操作）
+		canRevoke, err := m.groupService.CanOperateMember(req.ChannelID, loginUID, resp.Messages[0].FromUID, group.PermissionRevokeMessage)
 		if err != nil {
 			m.Error("查询登录用户群内权限错误", zap.Error(err))
 			c.ResponseError(errors.New("查询登录用户群内权限错误"))
 			return
 		}
-		if resp.Messages[0].FromUID != loginUID && !isManager {
-			isCanDelete = false
-		}
+		isCanDelete = canRevoke
 	}
 	if !isCanDelete {
 		c.ResponseError(errors.New("用户无权删除此消息"))
@@ -1081,6 +1345,10 @@ func (m *Message) mutualDelete(c *wkhttp.Context) {
 		c.ResponseError(errors.New("删除消息错误"))
 		return
 	}
+	m.searchService.DeleteMessages([]string{req.MessageID})
+	if err := removeThreadReplies(m.ctx, []string{req.MessageID}); err != nil {
+		m.Warn("更新话题回复数量失败！", zap.Error(err))
+	}
 	err = m.ctx.SendCMD(config.MsgCMDReq{
 		NoPersist:   true,
 		ChannelID:   req.ChannelID,
@@ -1314,33 +1582,67 @@ func (m *Message) hasRevokePermission(messageM *messageModel, loginUID string) (
 	if messageM.FromUID == loginUID { // 自己发的消息允许被撤回
 		return true, nil
 	}
-	if messageM.ChannelType == common.ChannelTypeGroup.Uint8() { // 管理者或创建者可以撤回其他成员的消息
-		loginMember, err := m.groupService.GetMember(messageM.ChannelID, loginUID)
-		if err != nil {
-			return false, err
-		}
-		if loginMember == nil {
-			return false, nil
-		}
-		fromMember, err := m.groupService.GetMember(messageM.ChannelID, messageM.FromUID)
-		if err != nil {
-			return false, err
-		}
-		if fromMember == nil && loginMember.Role != int(common.GroupMemberRoleNormal) {
-			return true, nil
-		}
-		if fromMember.Role == int(common.GroupMemberRoleCreater) || loginMember.Role == int(common.GroupMemberRoleNormal) {
-			return false, nil
-		}
-		if loginMember.Role == int(common.GroupMemberRoleCreater) || (loginMember.Role == int(common.GroupMemberRoleManager) && fromMember.Role == int(common.GroupMemberRoleNormal)) {
-			return true, nil
-		}
-
+	if messageM.ChannelType == common.ChannelTypeGroup.Uint8() { // 拥有撤回权限的成员可以撤回其他成员的消息
+		return m.groupService.CanOperateMember(messageM.ChannelID, loginUID, messageM.FromUID, group.PermissionRevokeMessage)
 	}
 
 	return false, nil
 }
 
+// 获取操作者的消息可撤回时长（秒） 0.不限制
+// 群聊按操作者在群内的角色取对应的时长，群设置了时长时取群设置与全局配置中较短的一个
+func (m *Message) getRevokeSecond(channelID string, channelType uint8, loginUID string) (int, error) {
+	appConfig, err := m.commonService.GetAppConfig()
+	if err != nil {
+		return 0, err
+	}
+	revokeSecond := appConfig.RevokeSecond
+	if channelType != common.ChannelTypeGroup.Uint8() {
+		return revokeSecond, nil
+	}
+	groupInfo, err := m.groupService.GetGroupWithGroupNo(channelID)
+	if err != nil {
+		return 0, err
+	}
+	if groupInfo == nil {
+		return revokeSecond, nil
+	}
+	groupRevokeSecond := groupInfo.RevokeSecond
+	member, err := m.groupService.GetMember(channelID, loginUID)
+	if err != nil {
+		return 0, err
+	}
+	if member != nil {
+		switch member.Role {
+		case group.MemberRoleCreator:
+			revokeSecond = appConfig.CreatorRevokeSecond
+			groupRevokeSecond = groupInfo.CreatorRevokeSecond
+		case group.MemberRoleManager:
+			revokeSecond = appConfig.ManagerRevokeSecond
+			groupRevokeSecond = groupInfo.ManagerRevokeSecond
+		}
+	}
+	if groupRevokeSecond > 0 && (revokeSecond <= 0 || groupRevokeSecond < revokeSecond) {
+		return groupRevokeSecond, nil
+	}
+	return revokeSecond, nil
+}
+
+// 消息是否已超过可撤回（编辑）时长，系统管理员不受时长限制
+func (m *Message) isRevokeExpired(c *wkhttp.Context, messageM *messageModel) (bool, error) {
+	if c.CheckLoginRole() == nil {
+		return false, nil
+	}
+	revokeSecond, err := m.getRevokeSecond(messageM.ChannelID, messageM.ChannelType, c.GetLoginUID())
+	if err != nil {
+		return false, err
+	}
+	if revokeSecond <= 0 {
+		return false, nil
+	}
+	return time.Now().Unix()-messageM.Timestamp > int64(revokeSecond), nil
+}
+
 func (m *Message) cancelMentionReminderIfNeed(message *messageModel) {
 	setting := config.SettingFromUint8(message.Setting)
 	//  如果撤回的是@消息，需要取消提醒
@@ -1461,6 +1763,16 @@ func (m *Message) revoke(c *wkhttp.Context) {
 				c.ResponseError(errors.New("无权限撤回此消息！"))
 				return
 			}
+			expired, err := m.isRevokeExpired(c, message)
+			if err != nil {
+				m.Error("查询消息可撤回时长失败！", zap.Error(err))
+				c.ResponseError(errors.New("查询消息可撤回时长失败！"))
+				return
+			}
+			if expired {
+				c.ResponseErrorWithStatus(errors.New("消息已超过可撤回时长！"), StatusMessageRevokeExpired)
+				return
+			}
 
 			m.cancelMentionReminderIfNeed(message)
 
@@ -1581,6 +1893,9 @@ func (m *Message) revoke(c *wkhttp.Context) {
 		return
 	}
 	m.ctx.EventCommit(eventID)
+	if err := removeThreadReplies(m.ctx, messageIDs); err != nil {
+		m.Warn("更新话题回复数量失败！", zap.Error(err))
+	}
 	// err = m.ctx.SendCMD(config.MsgCMDReq{
 	// 	NoPersist:   true,
 	// 	ChannelID:   channelID,
@@ -1610,6 +1925,23 @@ func (m *Message) revoke(c *wkhttp.Context) {
 			return
 		}
 	}
+	// 撤回群内其他成员的消息记录到群操作日志
+	if uint8(channelTypeI) == common.ChannelTypeGroup.Uint8() && len(messages) > 0 && messages[0].FromUID != loginUID {
+		err = m.groupService.AddAuditLog(&group.AuditLogReq{
+			GroupNo:  channelID,
+			Operator: loginUID,
+			Action:   group.AuditActionMessageRevoke,
+			Targets:  []string{messages[0].FromUID},
+			Data: map[string]interface{}{
+				"message_id":    messages[0].MessageID,
+				"message_seq":   messages[0].MessageSeq,
+				"client_msg_no": clientMsgNo,
+			},
+		})
+		if err != nil {
+			m.Warn("记录群操作日志失败！", zap.Error(err))
+		}
+	}
 
 	c.ResponseOK()
 
@@ -2062,9 +2394,53 @@ type messageExtraResp struct {
 	IsPinned        int                    `json:"is_pinned,omitempty"`         // 是否置顶
 	ContentEdit     map[string]interface{} `json:"content_edit,omitempty"`      // 编辑后的正文
 	EditedAt        int                    `json:"edited_at,omitempty"`         // 编辑时间 例如 12:23
+	EditCount       int                    `json:"edit_count,omitempty"`        // 编辑次数
+	PollResult      map[string]interface{} `json:"poll_result,omitempty"`       // 投票结果
+	ReplyCount      int                    `json:"reply_count,omitempty"`       // 话题回复数量
+	LastReplyUID    string                 `json:"last_reply_uid,omitempty"`    // 最后回复者uid
+	LastReplyAt     int64                  `json:"last_reply_at,omitempty"`     // 最后回复时间
 	ExtraVersion    int64                  `json:"extra_version"`               // 数据版本
 }
 
+type editHistoryResp struct {
+	Version     int                    `json:"version"`      // 版本（0为原始正文）
+	Editor      string                 `json:"editor"`       // 编辑者uid
+	ContentEdit map[string]interface{} `json:"content_edit"` // 编辑后的正文
+	EditedAt    int                    `json:"edited_at"`    // 编辑时间 时间戳（秒）
+}
+
+// 原始正文作为编辑历史的版本0，编辑者为发送者，编辑时间为发送时间
+func newOriginalEditHistoryResp(m *messageModel) *editHistoryResp {
+	var payloadMap map[string]interface{}
+	if len(m.Payload) > 0 {
+		err := util.ReadJsonByByte(m.Payload, &payloadMap)
+		if err != nil {
+			log.Warn("负荷数据不是json格式！", zap.Error(err), zap.String("payload", string(m.Payload)))
+		}
+	}
+	return &editHistoryResp{
+		Version:     0,
+		Editor:      m.FromUID,
+		ContentEdit: payloadMap,
+		EditedAt:    int(m.Timestamp),
+	}
+}
+
+func newEditHistoryResp(m *editHistoryModel) *editHistoryResp {
+	var contentEditMap map[string]interface{}
+	if m.ContentEdit.String != "" {
+		err := util.ReadJsonByByte([]byte(m.ContentEdit.String), &contentEditMap)
+		if err != nil {
+			log.Warn("负荷数据不是json格式！", zap.Error(err), zap.String("payload", m.ContentEdit.String))
+		}
+	}
+	return &editHistoryResp{
+		Editor:      m.Editor,
+		ContentEdit: contentEditMap,
+		EditedAt:    m.EditedAt,
+	}
+}
+
 func newMessageExtraResp(m *messageExtraDetailModel) *messageExtraResp {
 
 	messageID, _ := strconv.ParseInt(m.MessageID, 10, 64)
@@ -2077,6 +2453,14 @@ func newMessageExtraResp(m *messageExtraDetailModel) *messageExtraResp {
 		}
 	}
 
+	var pollResultMap map[string]interface{}
+	if m.PollResult.String != "" {
+		err := util.ReadJsonByByte([]byte(m.PollResult.String), &pollResultMap)
+		if err != nil {
+			log.Warn("投票结果不是json格式！", zap.Error(err), zap.String("pollResult", m.PollResult.String))
+		}
+	}
+
 	var readedAt int64 = 0
 	if m.ReadedAt.Valid {
 		readedAt = m.ReadedAt.Time.Unix()
@@ -2092,6 +2476,11 @@ func newMessageExtraResp(m *messageExtraDetailModel) *messageExtraResp {
 		ReadedCount:     m.ReadedCount,
 		ContentEdit:     contentEditMap,
 		EditedAt:        m.EditedAt,
+		EditCount:       m.EditCount,
+		PollResult:      pollResultMap,
+		ReplyCount:      m.ReplyCount,
+		LastReplyUID:    m.LastReplyUID,
+		LastReplyAt:     m.LastReplyAt,
 		IsMutualDeleted: m.IsDeleted,
 		IsPinned:        m.IsPinned,
 		ExtraVersion:    m.Version,
@@ -442,9 +442,11 @@ func (co *Conversation) syncUserConversation(c *wkhttp.Context) {
 		return
 	}
 	channelSettingMessageOffsetMap := make(map[string]uint32)
+	channelSettingMap := make(map[string]*chservice.ChannelSettingResp)
 	if len(channelSettings) > 0 {
 		for _, channelSetting := range channelSettings {
 			channelSettingMessageOffsetMap[fmt.Sprintf("%s-%d", channelSetting.ChannelID, channelSetting.ChannelType)] = channelSetting.OffsetMessageSeq
+			channelSettingMap[fmt.Sprintf("%s-%d", channelSetting.ChannelID, channelSetting.ChannelType)] = channelSetting
 		}
 	}
 
@@ -484,7 +486,7 @@ func (co *Conversation) syncUserConversation(c *wkhttp.Context) {
 			}
 			channelKey := fmt.Sprintf("%s-%d", conversation.ChannelID, conversation.ChannelType)
 			var channelOffsetMessageSeq = channelSettingMessageOffsetMap[channelKey]
-			// channelSetting := channelSettingMap[channelKey]
+			channelSetting := channelSettingMap[channelKey]
 			channelOffsetM := channelOffsetModelMap[channelKey]
 			deviceOffsetM := deviceOffsetModelMap[channelKey]
 			extra := conversationExtraMap[channelKey]
@@ -492,10 +494,10 @@ func (co *Conversation) syncUserConversation(c *wkhttp.Context) {
 			if len(syncUserConversationResp.Recents) > 0 {
 				syncUserConversationResps = append(syncUserConversationResps, syncUserConversationResp)
 			}
-			// if channelSetting != nil {
-			// 	syncUserConversationResp.ParentChannelID = channelSetting.ParentChannelID
-			// 	syncUserConversationResp.ParentChannelType = channelSetting.ParentChannelType
-			// }
+			if channelSetting != nil {
+				syncUserConversationResp.ParentChannelID = channelSetting.ParentChannelID
+				syncUserConversationResp.ParentChannelType = channelSetting.ParentChannelType
+			}
 
 			// 缓存频道对应的最新的消息messageSeq
 			if !co.ctx.GetConfig().MessageSaveAcrossDevice {
@@ -1072,18 +1074,20 @@ func (u userResp) from(user *user.Detail, avatarPath string) userResp {
 
 // SyncUserConversationResp 最近会话离线返回
 type SyncUserConversationResp struct {
-	ChannelID       string                 `json:"channel_id"`         // 频道ID
-	ChannelType     uint8                  `json:"channel_type"`       // 频道类型
-	Unread          int                    `json:"unread,omitempty"`   // 未读消息
-	Mute            int                    `json:"mute,omitempty"`     // 免打扰
-	Stick           int                    `json:"stick,omitempty"`    //  置顶
-	Timestamp       int64                  `json:"timestamp"`          // 最后一次会话时间
-	LastMsgSeq      int64                  `json:"last_msg_seq"`       // 最后一条消息seq
-	LastClientMsgNo string                 `json:"last_client_msg_no"` // 最后一条客户端消息编号
-	OffsetMsgSeq    int64                  `json:"offset_msg_seq"`     // 偏移位的消息seq
-	Version         int64                  `json:"version,omitempty"`  // 数据版本
-	Recents         []*MsgSyncResp         `json:"recents,omitempty"`  // 最近N条消息
-	Extra           *conversationExtraResp `json:"extra,omitempty"`    // 扩展
+	ChannelID         string                 `json:"channel_id"`                    // 频道ID
+	ChannelType       uint8                  `json:"channel_type"`                  // 频道类型
+	ParentChannelID   string                 `json:"parent_channel_id,omitempty"`   // 父频道ID（如话题所属的群）
+	ParentChannelType uint8                  `json:"parent_channel_type,omitempty"` // 父频道类型
+	Unread            int                    `json:"unread,omitempty"`              // 未读消息
+	Mute              int                    `json:"mute,omitempty"`                // 免打扰
+	Stick             int                    `json:"stick,omitempty"`               //  置顶
+	Timestamp         int64                  `json:"timestamp"`                     // 最后一次会话时间
+	LastMsgSeq        int64                  `json:"last_msg_seq"`                  // 最后一条消息seq
+	LastClientMsgNo   string                 `json:"last_client_msg_no"`            // 最后一条客户端消息编号
+	OffsetMsgSeq      int64                  `json:"offset_msg_seq"`                // 偏移位的消息seq
+	Version           int64                  `json:"version,omitempty"`             // 数据版本
+	Recents           []*MsgSyncResp         `json:"recents,omitempty"`             // 最近N条消息
+	Extra             *conversationExtraResp `json:"extra,omitempty"`               // 扩展
 }
 
 func newSyncUserConversationResp(resp *config.SyncUserConversationResp, extra *conversationExtraResp, loginUID string, messageExtraDB *messageExtraDB, messageReactionDB *messageReactionDB, messageUserExtraDB *messageUserExtraDB, mute int, stick int, channelOffsetM *channelOffsetModel, deviceOffsetM *deviceOffsetModel, channelOffsetMessageSeq uint32) *SyncUserConversationResp {
@@ -0,0 +1,363 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// 收藏消息（只有收藏者自己可见，与频道内所有人可见的置顶消息不同）
+func (m *Message) favoriteAdd(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	var req struct {
+		MessageID   string   `json:"message_id"`   // 消息唯一ID
+		ChannelID   string   `json:"channel_id"`   // 频道唯一ID
+		ChannelType uint8    `json:"channel_type"` // 频道类型
+		Tags        []string `json:"tags"`         // 标签
+	}
+	if err := c.BindJSON(&req); err != nil {
+		m.Error(common.ErrData.Error(), zap.Error(err))
+		c.ResponseError(common.ErrData)
+		return
+	}
+	if strings.TrimSpace(req.ChannelID) == "" {
+		c.ResponseError(errors.New("频道ID不能为空！"))
+		return
+	}
+	if strings.TrimSpace(req.MessageID) == "" {
+		c.ResponseError(errors.New("消息ID不能为空！"))
+		return
+	}
+	tags, err := parseFavoriteTags(req.Tags)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	messageM, messageExtra, err := m.getVisibleMessage(loginUID, req.ChannelID, req.ChannelType, req.MessageID)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if messageM.Signal == 1 {
+		c.ResponseError(errors.New("加密消息不支持收藏！"))
+		return
+	}
+	payload := string(messageM.Payload)
+	if messageExtra != nil && messageExtra.ContentEdit.Valid && messageExtra.ContentEdit.String != "" {
+		payload = messageExtra.ContentEdit.String
+	}
+	var payloadMap map[string]interface{}
+	if err := util.ReadJsonByByte([]byte(payload), &payloadMap); err != nil {
+		m.Error("解析消息内容失败！", zap.Error(err), zap.String("messageID", req.MessageID))
+		c.ResponseError(errors.New("解析消息内容失败！"))
+		return
+	}
+	fromName := ""
+	if messageM.FromUID != "" {
+		fromUser, err := m.userService.GetUser(messageM.FromUID)
+		if err != nil {
+			m.Error("查询消息发送者失败！", zap.Error(err))
+			c.ResponseError(errors.New("查询消息发送者失败！"))
+			return
+		}
+		if fromUser != nil {
+			fromName = fromUser.Name
+		}
+	}
+	err = m.favoriteDB.insertOrUpdate(&favoriteModel{
+		FavoriteNo:       util.GenerUUID(),
+		UID:              loginUID,
+		MessageID:        req.MessageID,
+		MessageSeq:       messageM.MessageSeq,
+		ChannelID:        req.ChannelID,
+		ChannelType:      req.ChannelType,
+		FromUID:          messageM.FromUID,
+		FromName:         fromName,
+		Payload:          payload,
+		SearchText:       getFavoriteSearchText(payloadMap),
+		Tags:             strings.Join(tags, ","),
+		MessageTimestamp: messageM.Timestamp,
+		Version:          m.ctx.GenSeq(FavoriteSeqKey),
+	})
+	if err != nil {
+		m.Error("添加收藏失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加收藏失败！"))
+		return
+	}
+	favoriteM, err := m.favoriteDB.queryWithUIDAndMessageID(loginUID, req.MessageID)
+	if err != nil {
+		m.Error("查询收藏失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询收藏失败！"))
+		return
+	}
+	if favoriteM == nil {
+		c.ResponseError(errors.New("添加收藏失败！"))
+		return
+	}
+	m.sendFavoriteSyncCMD(loginUID)
+	c.Response(newFavoriteResp(favoriteM))
+}
+
+// 同步收藏
+func (m *Message) favoriteSync(c *wkhttp.Context) {
+	var req struct {
+		Version int64  `json:"version"` // 客户端本地最大版本号
+		Limit   uint64 `json:"limit"`   // 数量限制
+	}
+	if err := c.BindJSON(&req); err != nil {
+		m.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if req.Limit <= 0 || req.Limit > FavoriteSyncMaxLimit {
+		req.Limit = FavoriteSyncMaxLimit
+	}
+	models, err := m.favoriteDB.queryWithUIDAndVersion(c.GetLoginUID(), req.Version, req.Limit)
+	if err != nil {
+		m.Error("同步收藏失败！", zap.Error(err))
+		c.ResponseError(errors.New("同步收藏失败！"))
+		return
+	}
+	list := make([]*favoriteResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newFavoriteResp(model))
+	}
+	c.Response(list)
+}
+
+// 搜索收藏
+func (m *Message) favoriteSearch(c *wkhttp.Context) {
+	keyword := strings.TrimSpace(strings.ReplaceAll(c.Query("keyword"), "\"", ""))
+	tag := strings.TrimSpace(c.Query("tag"))
+	pageIndex, pageSize := c.GetPage()
+	models, err := m.favoriteDB.search(c.GetLoginUID(), keyword, tag, uint64(pageSize), uint64(pageIndex))
+	if err != nil {
+		m.Error("搜索收藏失败！", zap.Error(err))
+		c.ResponseError(errors.New("搜索收藏失败！"))
+		return
+	}
+	list := make([]*favoriteResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newFavoriteResp(model))
+	}
+	c.Response(list)
+}
+
+// 收藏中使用过的标签
+func (m *Message) favoriteTags(c *wkhttp.Context) {
+	tagsList, err := m.favoriteDB.queryTagsWithUID(c.GetLoginUID())
+	if err != nil {
+		m.Error("查询收藏标签失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询收藏标签失败！"))
+		return
+	}
+	tags := make([]string, 0)
+	tagMap := make(map[string]bool)
+	for _, tagsStr := range tagsList {
+		for _, tag := range strings.Split(tagsStr, ",") {
+			if tag == "" || tagMap[tag] {
+				continue
+			}
+			tagMap[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	c.Response(tags)
+}
+
+// 修改收藏的标签
+func (m *Message) favoriteUpdateTags(c *wkhttp.Context) {
+	var req struct {
+		Tags []string `json:"tags"` // 标签
+	}
+	if err := c.BindJSON(&req); err != nil {
+		m.Error(common.ErrData.Error(), zap.Error(err))
+		c.ResponseError(common.ErrData)
+		return
+	}
+	tags, err := parseFavoriteTags(req.Tags)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	favoriteM, err := m.getLoginFavorite(c)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	err = m.favoriteDB.updateTags(favoriteM.FavoriteNo, strings.Join(tags, ","), m.ctx.GenSeq(FavoriteSeqKey))
+	if err != nil {
+		m.Error("修改收藏标签失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改收藏标签失败！"))
+		return
+	}
+	m.sendFavoriteSyncCMD(favoriteM.UID)
+	c.ResponseOK()
+}
+
+// 删除收藏
+func (m *Message) favoriteDelete(c *wkhttp.Context) {
+	favoriteM, err := m.getLoginFavorite(c)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	err = m.favoriteDB.delete(favoriteM.FavoriteNo, m.ctx.GenSeq(FavoriteSeqKey))
+	if err != nil {
+		m.Error("删除收藏失败！", zap.Error(err))
+		c.ResponseError(errors.New("删除收藏失败！"))
+		return
+	}
+	m.sendFavoriteSyncCMD(favoriteM.UID)
+	c.ResponseOK()
+}
+
+// 查询登录用户自己的收藏
+func (m *Message) getLoginFavorite(c *wkhttp.Context) (*favoriteModel, error) {
+	favoriteM, err := m.favoriteDB.queryWithFavoriteNo(c.Param("favorite_no"))
+	if err != nil {
+		m.Error("查询收藏失败！", zap.Error(err))
+		return nil, errors.New("查询收藏失败！")
+	}
+	if favoriteM == nil || favoriteM.IsDeleted == 1 || favoriteM.UID != c.GetLoginUID() {
+		return nil, errors.New("收藏不存在！")
+	}
+	return favoriteM, nil
+}
+
+// 查询登录用户可见的消息（个人频道需要是会话的参与者，群频道需要是群成员，已删除或已撤回的消息视为不存在）
+func (m *Message) getVisibleMessage(loginUID string, channelID string, channelType uint8, messageID string) (*messageModel, *messageExtraModel, error) {
+	fakeChannelID := channelID
+	if channelType == common.ChannelTypePerson.Uint8() {
+		fakeChannelID = common.GetFakeChannelIDWith(loginUID, channelID)
+	} else if channelType == common.ChannelTypeGroup.Uint8() || channelType == common.ChannelTypeCommunityTopic.Uint8() {
+		groupNo, err := m.getTopicGroupNo(channelID, channelType)
+		if err != nil {
+			m.Error("查询话题信息错误", zap.Error(err))
+			return nil, nil, errors.New("查询话题信息错误")
+		}
+		exist, err := m.groupService.ExistMember(groupNo, loginUID)
+		if err != nil {
+			m.Error("查询是否在群内存在失败！", zap.Error(err))
+			return nil, nil, errors.New("查询是否在群内存在失败！")
+		}
+		if !exist {
+			return nil, nil, errors.New("不在群内，无法操作群内的消息！")
+		}
+	} else {
+		return nil, nil, errors.New("不支持的频道类型！")
+	}
+	messageM, err := m.db.queryMessageWithMessageID(fakeChannelID, messageID)
+	if err != nil {
+		m.Error("查询消息失败！", zap.Error(err))
+		return nil, nil, errors.New("查询消息失败！")
+	}
+	if messageM == nil || messageM.IsDeleted == 1 || messageM.ChannelID != fakeChannelID || messageM.ChannelType != channelType {
+		return nil, nil, errors.New("消息不存在或已删除！")
+	}
+	messageExtra, err := m.messageExtraDB.queryWithMessageID(messageID)
+	if err != nil {
+		m.Error("查询消息扩展信息错误", zap.Error(err))
+		return nil, nil, errors.New("查询消息扩展信息错误")
+	}
+	if messageExtra != nil && (messageExtra.IsDeleted == 1 || messageExtra.Revoke == 1) {
+		return nil, nil, errors.New("消息不存在或已删除！")
+	}
+	return messageM, messageExtra, nil
+}
+
+// 通知收藏者的其他设备同步收藏
+func (m *Message) sendFavoriteSyncCMD(uid string) {
+	err := m.ctx.SendCMD(config.MsgCMDReq{
+		NoPersist:   true,
+		ChannelID:   uid,
+		ChannelType: common.ChannelTypePerson.Uint8(),
+		CMD:         CMDSyncFavorite,
+	})
+	if err != nil {
+		m.Warn("发送同步收藏命令失败！", zap.Error(err))
+	}
+}
+
+// 校验标签（去除空白和重复的标签，标签用逗号分隔存储所以不能包含逗号）
+func parseFavoriteTags(tags []string) ([]string, error) {
+	result := make([]string, 0, len(tags))
+	tagMap := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tagMap[tag] {
+			continue
+		}
+		if strings.Contains(tag, ",") {
+			return nil, errors.New("标签不能包含逗号！")
+		}
+		if len([]rune(tag)) > FavoriteTagMaxLength {
+			return nil, fmt.Errorf("标签不能超过%d个字！", FavoriteTagMaxLength)
+		}
+		tagMap[tag] = true
+		result = append(result, tag)
+	}
+	if len(result) > FavoriteMaxTags {
+		return nil, fmt.Errorf("标签不能超过%d个！", FavoriteMaxTags)
+	}
+	return result, nil
+}
+
+// 获取消息内容中可搜索的文本（文本正文、文件名、链接标题等）
+func getFavoriteSearchText(payload map[string]interface{}) string {
+	texts := make([]string, 0)
+	for _, key := range []string{"content", "name", "title"} {
+		if text, ok := payload[key].(string); ok && strings.TrimSpace(text) != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, " ")
+}
+
+type favoriteResp struct {
+	FavoriteNo       string                 `json:"favorite_no"`       // 收藏编号
+	MessageID        string                 `json:"message_id"`        // 消息唯一ID
+	MessageSeq       uint32                 `json:"message_seq"`       // 消息序列号
+	ChannelID        string                 `json:"channel_id"`        // 消息来源频道ID
+	ChannelType      uint8                  `json:"channel_type"`      // 消息来源频道类型
+	FromUID          string                 `json:"from_uid"`          // 消息发送者uid
+	FromName         string                 `json:"from_name"`         // 收藏时消息发送者的名字
+	Payload          map[string]interface{} `json:"payload"`           // 收藏时的消息内容快照
+	Tags             []string               `json:"tags"`              // 标签
+	MessageTimestamp int64                  `json:"message_timestamp"` // 消息发送时间
+	IsDeleted        int                    `json:"is_deleted"`        // 是否已删除
+	Version          int64                  `json:"version"`           // 同步版本号
+	CreatedAt        string                 `json:"created_at"`        // 收藏时间
+}
+
+func newFavoriteResp(m *favoriteModel) *favoriteResp {
+	var payloadMap map[string]interface{}
+	if m.Payload != "" {
+		_ = util.ReadJsonByByte([]byte(m.Payload), &payloadMap)
+	}
+	tags := make([]string, 0)
+	if m.Tags != "" {
+		tags = strings.Split(m.Tags, ",")
+	}
+	return &favoriteResp{
+		FavoriteNo:       m.FavoriteNo,
+		MessageID:        m.MessageID,
+		MessageSeq:       m.MessageSeq,
+		ChannelID:        m.ChannelID,
+		ChannelType:      m.ChannelType,
+		FromUID:          m.FromUID,
+		FromName:         m.FromName,
+		Payload:          payloadMap,
+		Tags:             tags,
+		MessageTimestamp: m.MessageTimestamp,
+		IsDeleted:        m.IsDeleted,
+		Version:          m.Version,
+		CreatedAt:        m.CreatedAt.String(),
+	}
+}
@@ -25,21 +25,29 @@ import (
 type Manager struct {
 	ctx *config.Context
 	log.Log
-	userService  user.IService
-	groupService group.IService
-	managerDB    *managerDB
-	pinnedDB     *pinnedDB
+	userService        user.IService
+	groupService       group.IService
+	managerDB          *managerDB
+	pinnedDB           *pinnedDB
+	scheduledMessageDB *scheduledMessageDB
+	messageExtraDB     *messageExtraDB
+	moderationDB       *moderationDB
+	prohibitWordFilter *prohibitWordFilter
 }
 
 // NewManager NewManager
 func NewManager(ctx *config.Context) *Manager {
 	return &Manager{
-		ctx:          ctx,
-		Log:          log.NewTLog("MessageManager"),
-		userService:  user.NewService(ctx),
-		groupService: group.NewService(ctx),
-		managerDB:    newManagerDB(ctx),
-		pinnedDB:     newPinnedDB(ctx),
+		ctx:                ctx,
+		Log:                log.NewTLog("MessageManager"),
+		userService:        user.NewService(ctx),
+		groupService:       group.NewService(ctx),
+		managerDB:          newManagerDB(ctx),
+		pinnedDB:           newPinnedDB(ctx),
+		scheduledMessageDB: newScheduledMessageDB(ctx),
+		messageExtraDB:     newMessageExtraDB(ctx),
+		moderationDB:       newModerationDB(ctx),
+		prohibitWordFilter: newProhibitWordFilter(ctx),
 	}
 }
 
@@ -57,6 +65,14 @@ func (m *Manager) Route(r *wkhttp.WKHttp) {
 		auth.GET("/message/prohibit_words", m.prohibitWords)          // 查询违禁词
 		auth.DELETE("/message/prohibit_words", m.deleteProhibitWords) // 删除违禁词
 		auth.DELETE("/message", m.delete)                             // 删除消息
+
+		auth.GET("/message/scheduled", m.scheduledList)                    // 后台定时消息列表
+		auth.PUT("/message/scheduled/:scheduled_no", m.scheduledUpdate)    // 修改后台定时消息
+		auth.DELETE("/message/scheduled/:scheduled_no", m.scheduledCancel) // 取消后台定时消息
+
+		auth.GET("/message/moderations", m.moderationList)                           // 违禁词审核队列
+		auth.PUT("/message/moderations/:moderation_no/approve", m.moderationApprove) // 审核通过
+		auth.PUT("/message/moderations/:moderation_no/remove", m.moderationRemove)   // 审核不通过（撤回消息）
 	}
 }
 func (m *Manager) sendMsgToFriends(c *wkhttp.Context) {
@@ -88,7 +104,12 @@ func (m *Manager) sendMsgToFriends(c *wkhttp.Context) {
 		c.ResponseError(errors.New("发送消息的订阅者不能为空"))
 		return
 	}
-	go m.sendMessageToFriends(req.ToUIDs, req.UID, req.Content)
+	content, err := m.filterProhibitWords(req.Content)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	go m.sendMessageToFriends(req.ToUIDs, req.UID, content)
 	c.ResponseOK()
 }
 
@@ -239,6 +260,9 @@ func (m *Manager) delete(c *wkhttp.Context) {
 	if eventID > 0 {
 		m.ctx.EventCommit(eventID)
 	}
+	if err := removeThreadReplies(m.ctx, msgIds); err != nil {
+		m.Warn("更新话题回复数量失败！", zap.Error(err))
+	}
 	if req.ChannelType == common.ChannelTypePerson.Uint8() {
 		err = m.ctx.SendCMD(config.MsgCMDReq{
 			NoPersist:   false,
@@ -304,6 +328,7 @@ func (m *Manager) deleteProhibitWords(c *wkhttp.Context) {
 		c.ResponseError(errors.New("修改违禁词错误"))
 		return
 	}
+	m.prohibitWordFilter.reset()
 	c.ResponseOK()
 }
 
@@ -354,6 +379,7 @@ func (m *Manager) prohibitWords(c *wkhttp.Context) {
 				Content:   word.Content,
 				CreatedAt: word.CreatedAt.String(),
 				IsDeleted: word.IsDeleted,
+				Action:    word.Action,
 				Version:   word.Version,
 				Id:        word.Id,
 			})
@@ -375,6 +401,11 @@ func (m *Manager) addProhibitWords(c *wkhttp.Context) {
 		c.ResponseError(errors.New("违禁词不能为空"))
 		return
 	}
+	action, _ := strconv.Atoi(c.Query("action"))
+	if action != ProhibitWordActionMask && action != ProhibitWordActionBlock && action != ProhibitWordActionReview {
+		c.ResponseError(errors.New("违禁词处理方式有误"))
+		return
+	}
 	model, err := m.managerDB.queryProhibitWordsWithContent(content)
 	if err != nil {
 		m.Error(common.ErrData.Error(), zap.Error(err))
@@ -384,6 +415,7 @@ func (m *Manager) addProhibitWords(c *wkhttp.Context) {
 	version := m.ctx.GenSeq(common.ProhibitWordKey)
 	if model != nil {
 		model.IsDeleted = 0
+		model.Action = action
 		model.Version = version
 		err = m.managerDB.updateProhibitWord(model)
 		if err != nil {
@@ -395,6 +427,7 @@ func (m *Manager) addProhibitWords(c *wkhttp.Context) {
 		err = m.managerDB.insertProhibitWord(&prohibitWordsModel{
 			IsDeleted: 0,
 			Content:   content,
+			Action:    action,
 			Version:   version,
 		})
 		if err != nil {
@@ -403,6 +436,7 @@ func (m *Manager) addProhibitWords(c *wkhttp.Context) {
 			return
 		}
 	}
+	m.prohibitWordFilter.reset()
 	c.ResponseOK()
 }
 func (m *Manager) recordpersonal(c *wkhttp.Context) {
@@ -701,6 +735,11 @@ func (m *Manager) sendMsgToAllUsers(c *wkhttp.Context) {
 		c.ResponseError(common.ErrData)
 		return
 	}
+	content, err := m.filterProhibitWords(req.Content)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
 	userList, err := m.userService.GetAllUsers()
 	if err != nil {
 		c.ResponseError(err)
@@ -718,7 +757,7 @@ func (m *Manager) sendMsgToAllUsers(c *wkhttp.Context) {
 	if len(tempUserList) > 0 {
 		uids = append(uids, tempUserList)
 	}
-	go m.sendMessageBatch(uids, req.Content)
+	go m.sendMessageBatch(uids, content)
 	c.ResponseOK()
 }
 func (m *Manager) sendMessageBatch(uids [][]string, content string) error {
@@ -760,6 +799,11 @@ func (m *Manager) sendMsg(c *wkhttp.Context) {
 		c.ResponseError(err)
 		return
 	}
+	content, err := m.filterProhibitWords(req.Content)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
 	var receiverName string = ""
 	if req.ReceivedChannelType == int(common.ChannelTypePerson) {
 		user, err := m.userService.GetUser(req.ReceivedChannelID)
@@ -787,6 +831,33 @@ func (m *Manager) sendMsg(c *wkhttp.Context) {
 		}
 		receiverName = group.Name
 	}
+	if req.SendAt > 0 { // 定时发送
+		scheduleReq := &ScheduleMessageReq{
+			Source:      ScheduledMessageSourceManager,
+			Creator:     c.GetLoginUID(),
+			FromUID:     req.Sender,
+			ChannelID:   req.ReceivedChannelID,
+			ChannelType: uint8(req.ReceivedChannelType),
+			Payload: map[string]interface{}{
+				"content":  req.Content,
+				"type":     1,
+				"from_uid": req.Sender,
+			},
+			SendAt: req.SendAt,
+		}
+		if err := scheduleReq.check(); err != nil {
+			c.ResponseError(err)
+			return
+		}
+		model, err := addScheduledMessage(m.scheduledMessageDB, scheduleReq)
+		if err != nil {
+			m.Error("添加定时消息失败！", zap.Error(err))
+			c.ResponseError(err)
+			return
+		}
+		c.Response(newScheduledMessageResp(model))
+		return
+	}
 	err = m.ctx.SendMessage(&config.MsgSendReq{
 		Header: config.MsgHeader{
 			RedDot: 1,
@@ -795,7 +866,7 @@ func (m *Manager) sendMsg(c *wkhttp.Context) {
 		ChannelID:   req.ReceivedChannelID,
 		ChannelType: uint8(req.ReceivedChannelType),
 		Payload: []byte(util.ToJson(map[string]interface{}{
-			"content":  req.Content,
+			"content":  content,
 			"type":     1,
 			"from_uid": req.Sender,
 		})),
@@ -814,7 +885,7 @@ func (m *Manager) sendMsg(c *wkhttp.Context) {
 		ReceiverName:        receiverName,
 		HandlerUID:          c.GetLoginUID(),
 		HandlerName:         c.GetLoginName(),
-		Content:             req.Content,
+		Content:             content,
 	})
 	if err != nil {
 		m.Error("添加发送消息记录错误", zap.Error(err))
@@ -824,6 +895,129 @@ func (m *Manager) sendMsg(c *wkhttp.Context) {
 	c.ResponseOK()
 }
 
+// 后台定时消息列表
+func (m *Manager) scheduledList(c *wkhttp.Context) {
+	err := c.CheckLoginRole()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	pageIndex, pageSize := c.GetPage()
+	models, err := m.scheduledMessageDB.queryWithSourcePage(ScheduledMessageSourceManager, uint64(pageSize), uint64(pageIndex))
+	if err != nil {
+		m.Error("查询定时消息失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询定时消息失败！"))
+		return
+	}
+	count, err := m.scheduledMessageDB.queryCountWithSource(ScheduledMessageSourceManager)
+	if err != nil {
+		m.Error("查询定时消息数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询定时消息数量失败！"))
+		return
+	}
+	list := make([]*scheduledMessageResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newScheduledMessageResp(model))
+	}
+	c.Response(map[string]interface{}{
+		"count": count,
+		"list":  list,
+	})
+}
+
+// 修改后台定时消息（发送失败的修改后重新进入待发送）
+func (m *Manager) scheduledUpdate(c *wkhttp.Context) {
+	err := c.CheckLoginRoleIsSuperAdmin()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req managerScheduledUpdateReq
+	if err := c.BindJSON(&req); err != nil {
+		m.Error(common.ErrData.Error(), zap.Error(err))
+		c.ResponseError(common.ErrData)
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		c.ResponseError(errors.New("发送内容不能为空"))
+		return
+	}
+	if _, err := m.filterProhibitWords(req.Content); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	model, err := m.scheduledMessageDB.queryWithScheduledNo(c.Param("scheduled_no"))
+	if err != nil {
+		m.Error("查询定时消息失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询定时消息失败！"))
+		return
+	}
+	if model == nil || model.Source != ScheduledMessageSourceManager {
+		c.ResponseError(errors.New("定时消息不存在！"))
+		return
+	}
+	updateReq := &ScheduleMessageReq{
+		Source:      model.Source,
+		Creator:     model.Creator,
+		FromUID:     model.FromUID,
+		ChannelID:   model.ChannelID,
+		ChannelType: model.ChannelType,
+		Payload: map[string]interface{}{
+			"content":  req.Content,
+			"type":     1,
+			"from_uid": model.FromUID,
+		},
+		SendAt: req.SendAt,
+	}
+	if err := updateReq.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	model.Payload = util.ToJson(updateReq.Payload)
+	model.SendAt = updateReq.SendAt
+	ok, err := m.scheduledMessageDB.updatePending(model)
+	if err != nil {
+		m.Error("修改定时消息失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改定时消息失败！"))
+		return
+	}
+	if !ok {
+		c.ResponseError(errors.New("定时消息已发送或已取消，不能修改！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 取消后台定时消息
+func (m *Manager) scheduledCancel(c *wkhttp.Context) {
+	err := c.CheckLoginRoleIsSuperAdmin()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	model, err := m.scheduledMessageDB.queryWithScheduledNo(c.Param("scheduled_no"))
+	if err != nil {
+		m.Error("查询定时消息失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询定时消息失败！"))
+		return
+	}
+	if model == nil || model.Source != ScheduledMessageSourceManager {
+		c.ResponseError(errors.New("定时消息不存在！"))
+		return
+	}
+	ok, err := m.scheduledMessageDB.cancel(model.ScheduledNo)
+	if err != nil {
+		m.Error("取消定时消息失败！", zap.Error(err))
+		c.ResponseError(errors.New("取消定时消息失败！"))
+		return
+	}
+	if !ok {
+		c.ResponseError(errors.New("定时消息已发送或已取消！"))
+		return
+	}
+	c.ResponseOK()
+}
+
 // 代发消息列表
 func (m *Manager) list(c *wkhttp.Context) {
 	err := c.CheckLoginRole()
@@ -880,6 +1074,19 @@ func (m *managerSendMsgReq) check() error {
 	return nil
 }
 
+// 发送前过滤文本消息中的违禁词
+func (m *Manager) filterProhibitWords(content string) (string, error) {
+	newContent, err := m.prohibitWordFilter.filterContent(content)
+	if err != nil {
+		if errors.Is(err, ErrProhibitWordsBlocked) {
+			return "", err
+		}
+		m.Error("检查违禁词失败！", zap.Error(err))
+		return "", errors.New("检查违禁词失败！")
+	}
+	return newContent, nil
+}
+
 func (m *Manager) genMessageExtraSeq(channelID string) int64 {
 	return m.ctx.GenSeq(fmt.Sprintf("%s:%s", common.MessageExtraSeqKey, channelID))
 }
@@ -890,6 +1097,12 @@ type managerSendMsgReq struct {
 	ReceivedChannelID   string `json:"received_channel_id"`   // 接受者id
 	ReceivedChannelType int    `json:"received_channel_type"` // 接受类型
 	Content             string `json:"content"`               // 发送内容
+	SendAt              int64  `json:"send_at"`               // 定时发送时间（10位时间戳） 为0表示立即发送
+}
+
+type managerScheduledUpdateReq struct {
+	Content string `json:"content"` // 发送内容
+	SendAt  int64  `json:"send_at"` // 定时发送时间（10位时间戳）
 }
 
 type managerSendMsgResp struct {
@@ -927,6 +1140,7 @@ type recordVO struct {
 type prohibitWordsVO struct {
 	Id        int64  `json:"id"`
 	Content   string `json:"content"`    // 违禁词
+	Action    int    `json:"action"`     // 命中后的处理方式 0.替换为* 1.拦截 2.标记待审核
 	IsDeleted int    `json:"is_deleted"` // 是否删除
 	Version   int64  `json:"version"`    // 版本
 	CreatedAt string `json:"created_at"` // 时间
@@ -0,0 +1,303 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/elastic"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/gocraft/dbr/v2"
+	"go.uber.org/zap"
+)
+
+// 检查收到的消息中的违禁词（客户端可以绕过本地的违禁词检查，服务端需要再检查一次）
+func (m *Message) checkProhibitWordsMessages(messages []*config.MessageResp) {
+	for _, message := range messages {
+		if message.FromUID == "" || message.Header.NoPersist == 1 || message.Header.SyncOnce == 1 {
+			continue
+		}
+		if config.SettingFromUint8(message.Setting).Signal { // 加密消息无法检查
+			continue
+		}
+		payloadMap, err := message.GetPayloadMap()
+		if err != nil || payloadMap == nil {
+			continue
+		}
+		result, err := m.prohibitWordFilter.checkPayload(payloadMap)
+		if err != nil {
+			m.Warn("检查违禁词失败！", zap.Error(err))
+			continue
+		}
+		if !result.hit() {
+			continue
+		}
+		m.handleProhibitWordsMessage(message, payloadMap, result)
+	}
+}
+
+// 处理命中违禁词的消息（拦截的撤回消息，需要替换的修改消息正文，需要审核的加入审核队列）
+func (m *Message) handleProhibitWordsMessage(message *config.MessageResp, payloadMap map[string]interface{}, result *prohibitCheckResult) {
+	messageID := fmt.Sprintf("%d", message.MessageID)
+	if result.Block || result.Review {
+		model := &moderationModel{
+			ModerationNo: util.GenerUUID(),
+			MessageID:    messageID,
+			MessageSeq:   message.MessageSeq,
+			FromUID:      message.FromUID,
+			ChannelID:    message.ChannelID,
+			ChannelType:  message.ChannelType,
+			Payload:      string(message.Payload),
+			HitWords:     strings.Join(result.HitWords, ","),
+			Status:       ModerationStatusPending,
+		}
+		if result.Block {
+			model.Status = ModerationStatusRemoved
+			model.HandledAt = time.Now().Unix()
+		}
+		err := m.moderationDB.insert(model)
+		if err != nil {
+			m.Error("添加违禁词审核记录失败！", zap.Error(err), zap.String("messageID", messageID))
+		}
+		if result.Block {
+			err = revokeModerationMessage(m.ctx, m.messageExtraDB, model, m.ctx.GetConfig().Account.SystemUID)
+			if err != nil {
+				m.Error("撤回包含违禁词的消息失败！", zap.Error(err), zap.String("messageID", messageID))
+			}
+			return
+		}
+	}
+	if !result.Masked {
+		return
+	}
+	fakeChannelID := message.ChannelID
+	if message.ChannelType == common.ChannelTypePerson.Uint8() {
+		fakeChannelID = common.GetFakeChannelIDWith(message.FromUID, message.ChannelID)
+	}
+	payloadMap["content"] = result.Content
+	contentEdit := util.ToJson(payloadMap)
+	tx, err := m.db.session.Begin()
+	if err != nil {
+		m.Error("开启事务失败！", zap.Error(err))
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.RollbackUnlessCommitted()
+			panic(err)
+		}
+	}()
+	err = m.messageExtraDB.insertOrUpdateMaskedContentTx(&messageExtraModel{
+		MessageID:       messageID,
+		MessageSeq:      message.MessageSeq,
+		ChannelID:       fakeChannelID,
+		ChannelType:     message.ChannelType,
+		ContentEdit:     dbr.NewNullString(contentEdit),
+		ContentEditHash: util.MD5(contentEdit),
+		Version:         m.genMessageExtraSeq(fakeChannelID),
+	}, tx)
+	if err != nil {
+		tx.Rollback()
+		m.Error("替换消息中的违禁词失败！", zap.Error(err), zap.String("messageID", messageID))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tx.RollbackUnlessCommitted()
+		m.Error("提交事务失败！", zap.Error(err))
+		return
+	}
+	err = m.ctx.SendCMD(config.MsgCMDReq{
+		NoPersist:   true,
+		ChannelID:   message.ChannelID,
+		ChannelType: message.ChannelType,
+		FromUID:     message.FromUID,
+		CMD:         common.CMDSyncMessageExtra,
+	})
+	if err != nil {
+		m.Warn("发送同步消息扩展命令失败！", zap.Error(err))
+	}
+}
+
+// 以系统身份撤回审核记录对应的消息
+func revokeModerationMessage(ctx *config.Context, extraDB *messageExtraDB, model *moderationModel, operator string) error {
+	fakeChannelID := model.ChannelID
+	if model.ChannelType == common.ChannelTypePerson.Uint8() {
+		fakeChannelID = common.GetFakeChannelIDWith(model.FromUID, model.ChannelID)
+	}
+	err := extraDB.insertOrUpdateRevoke(&messageExtraModel{
+		MessageID:   model.MessageID,
+		MessageSeq:  model.MessageSeq,
+		ChannelID:   fakeChannelID,
+		ChannelType: model.ChannelType,
+		Revoke:      1,
+		Revoker:     operator,
+		Version:     ctx.GenSeq(fmt.Sprintf("%s:%s", common.MessageExtraSeqKey, fakeChannelID)),
+	})
+	if err != nil {
+		return err
+	}
+	err = removeThreadReplies(ctx, []string{model.MessageID})
+	if err != nil {
+		return err
+	}
+	elastic.NewService(ctx).DeleteMessages([]string{model.MessageID})
+	messageID, _ := strconv.ParseInt(model.MessageID, 10, 64)
+	return ctx.SendRevoke(&config.MsgRevokeReq{
+		Operator:     operator,
+		OperatorName: "系统",
+		FromUID:      model.FromUID,
+		ChannelID:    model.ChannelID,
+		ChannelType:  model.ChannelType,
+		MessageID:    messageID,
+	})
+}
+
+// 违禁词审核队列
+func (m *Manager) moderationList(c *wkhttp.Context) {
+	err := c.CheckLoginRole()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	status := -1
+	if strings.TrimSpace(c.Query("status")) != "" {
+		status, _ = strconv.Atoi(c.Query("status"))
+	}
+	pageIndex, pageSize := c.GetPage()
+	models, err := m.moderationDB.queryWithStatusPage(status, uint64(pageSize), uint64(pageIndex))
+	if err != nil {
+		m.Error("查询审核记录失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询审核记录失败！"))
+		return
+	}
+	count, err := m.moderationDB.queryCountWithStatus(status)
+	if err != nil {
+		m.Error("查询审核记录数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询审核记录数量失败！"))
+		return
+	}
+	list := make([]*moderationResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newModerationResp(model))
+	}
+	c.Response(map[string]interface{}{
+		"count": count,
+		"list":  list,
+	})
+}
+
+// 审核通过（保留消息）
+func (m *Manager) moderationApprove(c *wkhttp.Context) {
+	err := c.CheckLoginRole()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	model, err := m.getPendingModeration(c.Param("moderation_no"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	handled, err := m.moderationDB.handle(model.ModerationNo, ModerationStatusApproved, c.GetLoginUID(), time.Now().Unix())
+	if err != nil {
+		m.Error("修改审核记录失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改审核记录失败！"))
+		return
+	}
+	if !handled {
+		c.ResponseError(errors.New("审核记录已被处理！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 审核不通过（撤回消息）
+func (m *Manager) moderationRemove(c *wkhttp.Context) {
+	err := c.CheckLoginRole()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	model, err := m.getPendingModeration(c.Param("moderation_no"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	handled, err := m.moderationDB.handle(model.ModerationNo, ModerationStatusRemoved, c.GetLoginUID(), time.Now().Unix())
+	if err != nil {
+		m.Error("修改审核记录失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改审核记录失败！"))
+		return
+	}
+	if !handled {
+		c.ResponseError(errors.New("审核记录已被处理！"))
+		return
+	}
+	err = revokeModerationMessage(m.ctx, m.messageExtraDB, model, c.GetLoginUID())
+	if err != nil {
+		m.Error("撤回消息失败！", zap.Error(err), zap.String("messageID", model.MessageID))
+		c.ResponseError(errors.New("撤回消息失败！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+func (m *Manager) getPendingModeration(moderationNo string) (*moderationModel, error) {
+	model, err := m.moderationDB.queryWithModerationNo(moderationNo)
+	if err != nil {
+		m.Error("查询审核记录失败！", zap.Error(err))
+		return nil, errors.New("查询审核记录失败！")
+	}
+	if model == nil {
+		return nil, errors.New("审核记录不存在！")
+	}
+	if model.Status != ModerationStatusPending {
+		return nil, errors.New("审核记录已被处理！")
+	}
+	return model, nil
+}
+
+type moderationResp struct {
+	ModerationNo string                 `json:"moderation_no"` // 审核编号
+	MessageID    string                 `json:"message_id"`    // 消息ID
+	MessageSeq   uint32                 `json:"message_seq"`   // 消息序号
+	FromUID      string                 `json:"from_uid"`      // 发送者uid
+	ChannelID    string                 `json:"channel_id"`    // 频道ID
+	ChannelType  uint8                  `json:"channel_type"`  // 频道类型
+	Payload      map[string]interface{} `json:"payload"`       // 原始消息内容
+	HitWords     []string               `json:"hit_words"`     // 命中的违禁词
+	Status       int                    `json:"status"`        // 状态 0.待审核 1.审核通过 2.已撤回
+	Handler      string                 `json:"handler"`       // 处理人uid（自动拦截时为空）
+	HandledAt    int64                  `json:"handled_at"`    // 处理时间
+	CreatedAt    string                 `json:"created_at"`    // 创建时间
+}
+
+func newModerationResp(m *moderationModel) *moderationResp {
+	var payloadMap map[string]interface{}
+	if m.Payload != "" {
+		_ = util.ReadJsonByByte([]byte(m.Payload), &payloadMap)
+	}
+	hitWords := make([]string, 0)
+	if m.HitWords != "" {
+		hitWords = strings.Split(m.HitWords, ",")
+	}
+	return &moderationResp{
+		ModerationNo: m.ModerationNo,
+		MessageID:    m.MessageID,
+		MessageSeq:   m.MessageSeq,
+		FromUID:      m.FromUID,
+		ChannelID:    m.ChannelID,
+		ChannelType:  m.ChannelType,
+		Payload:      payloadMap,
+		HitWords:     hitWords,
+		Status:       m.Status,
+		Handler:      m.Handler,
+		HandledAt:    m.HandledAt,
+		CreatedAt:    m.CreatedAt.String(),
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
@@ -47,8 +48,14 @@ func (m *Message) pinnedMessage(c *wkhttp.Context) {
 	fakeChannelID := req.ChannelID
 	if req.ChannelType == common.ChannelTypePerson.Uint8() {
 		fakeChannelID = common.GetFakeChannelIDWith(loginUID, req.ChannelID)
-	} else if req.ChannelType == common.ChannelTypeGroup.Uint8() {
-		groupInfo, err := m.groupService.GetGroupDetail(req.ChannelID, loginUID)
+	} else if req.ChannelType == common.ChannelTypeGroup.Uint8() || req.ChannelType == common.ChannelTypeCommunityTopic.Uint8() {
+		groupNo, err := m.getTopicGroupNo(req.ChannelID, req.ChannelType)
+		if err != nil {
+			m.Error("查询话题信息错误", zap.Error(err))
+			c.ResponseError(errors.New("查询话题信息错误"))
+			return
+		}
+		groupInfo, err := m.groupService.GetGroupDetail(groupNo, loginUID)
 		if err != nil {
 			m.Error("查询群组信息错误", zap.Error(err))
 			c.ResponseError(errors.New("查询群组信息错误"))
@@ -58,13 +65,13 @@ func (m *Message) pinnedMessage(c *wkhttp.Context) {
 			c.ResponseError(errors.New("群不存在或已删除"))
 			return
 		}
-		isCreatorOrManager, err := m.groupService.IsCreatorOrManager(req.ChannelID, loginUID)
+		canPin, err := m.groupService.HasPermission(groupNo, loginUID, group.PermissionPinMessage)
 		if err != nil {
 			m.Error("查询用户在群内权限错误", zap.Error(err))
 			c.ResponseError(errors.New("查询用户在群内权限错误"))
 			return
 		}
-		if !isCreatorOrManager && groupInfo.AllowMemberPinnedMessage == 0 {
+		if !canPin && groupInfo.AllowMemberPinnedMessage == 0 {
 			c.ResponseError(errors.New("普通成员不允许置顶消息"))
 			return
 		}
@@ -266,14 +273,20 @@ func (m *Message) clearPinnedMessage(c *wkhttp.Context) {
 	if req.ChannelType == common.ChannelTypePerson.Uint8() {
 		fakeChannelID = common.GetFakeChannelIDWith(loginUID, req.ChannelID)
 	} else {
+		groupNo, err := m.getTopicGroupNo(req.ChannelID, req.ChannelType)
+		if err != nil {
+			m.Error("查询话题信息错误", zap.Error(err))
+			c.ResponseError(errors.New("查询话题信息错误"))
+			return
+		}
 		// 查询权限
-		isCreatorOrManager, err := m.groupService.IsCreatorOrManager(req.ChannelID, loginUID)
+		canPin, err := m.groupService.HasPermission(groupNo, loginUID, group.PermissionPinMessage)
 		if err != nil {
 			m.Error("查询用户在群内权限错误", zap.Error(err))
 			c.ResponseError(errors.New("查询用户在群内权限错误"))
 			return
 		}
-		if !isCreatorOrManager {
+		if !canPin {
 			c.ResponseError(errors.New("用户无权清空置顶消息"))
 			return
 		}
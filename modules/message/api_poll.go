@@ -0,0 +1,546 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/gocraft/dbr/v2"
+	"go.uber.org/zap"
+)
+
+var errPollMessageRemoved = errors.New("投票消息已撤回或删除！")
+
+// 发起投票（以发起人的身份在群内发送投票消息）
+func (m *Message) pollCreate(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	var req struct {
+		ChannelID   string   `json:"channel_id"`   // 频道ID
+		ChannelType uint8    `json:"channel_type"` // 频道类型
+		Question    string   `json:"question"`     // 投票问题
+		Options     []string `json:"options"`      // 选项
+		Multiple    int      `json:"multiple"`     // 是否多选
+		Anonymous   int      `json:"anonymous"`    // 是否匿名
+		Deadline    int64    `json:"deadline"`     // 截止时间 时间戳（秒） 0表示不截止
+	}
+	if err := c.BindJSON(&req); err != nil {
+		m.Error(common.ErrData.Error(), zap.Error(err))
+		c.ResponseError(common.ErrData)
+		return
+	}
+	if req.ChannelType != common.ChannelTypeGroup.Uint8() {
+		c.ResponseError(errors.New("投票仅支持群聊！"))
+		return
+	}
+	if strings.TrimSpace(req.ChannelID) == "" {
+		c.ResponseError(errors.New("频道ID不能为空！"))
+		return
+	}
+	question := strings.TrimSpace(req.Question)
+	if question == "" {
+		c.ResponseError(errors.New("投票问题不能为空！"))
+		return
+	}
+	if len([]rune(question)) > PollQuestionMaxLength {
+		c.ResponseError(fmt.Errorf("投票问题不能超过%d个字！", PollQuestionMaxLength))
+		return
+	}
+	if len(req.Options) < PollMinOptions || len(req.Options) > PollMaxOptions {
+		c.ResponseError(fmt.Errorf("选项数量必须在%d到%d个之间！", PollMinOptions, PollMaxOptions))
+		return
+	}
+	options := make([]string, 0, len(req.Options))
+	for _, option := range req.Options {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			c.ResponseError(errors.New("选项不能为空！"))
+			return
+		}
+		if len([]rune(option)) > PollOptionMaxLength {
+			c.ResponseError(fmt.Errorf("选项不能超过%d个字！", PollOptionMaxLength))
+			return
+		}
+		filtered, err := m.prohibitWordFilter.filterContent(option)
+		if err != nil {
+			if !errors.Is(err, ErrProhibitWordsBlocked) {
+				m.Error("检查违禁词失败！", zap.Error(err))
+				err = errors.New("检查违禁词失败！")
+			}
+			c.ResponseError(err)
+			return
+		}
+		options = append(options, filtered)
+	}
+	now := time.Now()
+	if req.Deadline != 0 && (req.Deadline <= now.Unix() || req.Deadline > now.Add(PollMaxDuration).Unix()) {
+		c.ResponseError(errors.New("截止时间不合法！"))
+		return
+	}
+	// 投票以发起人身份发送，和普通发言一样需要已同意群规、未被禁言且不在慢速模式的限制时间内
+	if err := m.checkScheduledSendPermission(ScheduledMessageSourceUser, loginUID, req.ChannelID, req.ChannelType); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if err := m.checkSlowModeLimit(req.ChannelID, loginUID); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	model := &pollModel{
+		PollNo:      util.GenerUUID(),
+		ChannelID:   req.ChannelID,
+		ChannelType: req.ChannelType,
+		Creator:     loginUID,
+		Question:    question,
+		Options:     util.ToJson(options),
+		Multiple:    boolToInt(req.Multiple == 1),
+		Anonymous:   boolToInt(req.Anonymous == 1),
+		Deadline:    req.Deadline,
+		Status:      PollStatusOpen,
+	}
+	payload, err := m.filterProhibitWords(map[string]interface{}{
+		"type":      ContentTypePoll,
+		"content":   model.Question, // 不支持投票的客户端显示投票问题
+		"poll_no":   model.PollNo,
+		"question":  model.Question,
+		"options":   options,
+		"multiple":  model.Multiple,
+		"anonymous": model.Anonymous,
+		"deadline":  model.Deadline,
+	})
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	model.Question, _ = payload["content"].(string)
+	payload["question"] = model.Question
+	err = m.pollDB.insert(model)
+	if err != nil {
+		m.Error("添加投票失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加投票失败！"))
+		return
+	}
+	sendResp, err := m.ctx.SendMessageWithResult(&config.MsgSendReq{
+		Header: config.MsgHeader{
+			RedDot: 1,
+		},
+		FromUID:     loginUID,
+		ChannelID:   req.ChannelID,
+		ChannelType: req.ChannelType,
+		Payload:     []byte(util.ToJson(payload)),
+	})
+	if err != nil {
+		m.Error("发送投票消息失败！", zap.Error(err))
+		if err := m.pollDB.deleteWithPollNo(model.PollNo); err != nil {
+			m.Warn("删除发送失败的投票失败！", zap.Error(err))
+		}
+		c.ResponseError(errors.New("发送投票消息失败！"))
+		return
+	}
+	model.MessageID = fmt.Sprintf("%d", sendResp.MessageID)
+	model.MessageSeq = sendResp.MessageSeq
+	// 投票消息已经发出，关联消息失败时重试，不能让请求失败
+	for i := 0; i < PollUpdateMessageRetries; i++ {
+		err = m.pollDB.updateMessage(model.PollNo, model.MessageID, model.MessageSeq)
+		if err == nil {
+			break
+		}
+		m.Warn("更新投票消息失败，重试！", zap.Error(err), zap.String("pollNo", model.PollNo), zap.Int("retry", i+1))
+		time.Sleep(time.Millisecond * 200)
+	}
+	if err != nil {
+		m.Error("更新投票消息失败！", zap.Error(err), zap.String("pollNo", model.PollNo), zap.String("messageID", model.MessageID), zap.Uint32("messageSeq", model.MessageSeq))
+	}
+	c.Response(newPollResp(model, newPollResult(model, nil), nil))
+}
+
+// 投票详情（包含投票结果和自己的选择）
+func (m *Message) pollDetail(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	model, err := m.getMemberPoll(c.Param("poll_no"), loginUID)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	messageExtra, err := m.messageExtraDB.queryWithMessageID(model.MessageID)
+	if err != nil {
+		m.Error("查询消息扩展信息错误", zap.Error(err))
+		c.ResponseError(errors.New("查询消息扩展信息错误"))
+		return
+	}
+	result := newPollResult(model, nil)
+	if messageExtra != nil && messageExtra.PollResult.String != "" {
+		if err := util.ReadJsonByByte([]byte(messageExtra.PollResult.String), result); err != nil {
+			m.Warn("投票结果不是json格式！", zap.Error(err), zap.String("pollNo", model.PollNo))
+		}
+	}
+	votes, err := m.pollDB.queryVotesWithUID(model.PollNo, loginUID)
+	if err != nil {
+		m.Error("查询投票记录失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询投票记录失败！"))
+		return
+	}
+	c.Response(newPollResp(model, result, votes))
+}
+
+// 投票（重新投票时覆盖之前的选择）
+func (m *Message) pollVote(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	var req struct {
+		OptionIndexes []int `json:"option_indexes"` // 选择的选项下标
+	}
+	if err := c.BindJSON(&req); err != nil {
+		m.Error(common.ErrData.Error(), zap.Error(err))
+		c.ResponseError(common.ErrData)
+		return
+	}
+	model, err := m.getMemberPoll(c.Param("poll_no"), loginUID)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	optionCount := len(model.getOptions())
+	indexMap := make(map[int]bool)
+	for _, index := range req.OptionIndexes {
+		if index < 0 || index >= optionCount {
+			c.ResponseError(errors.New("选项不存在！"))
+			return
+		}
+		indexMap[index] = true
+	}
+	if len(indexMap) == 0 {
+		c.ResponseError(errors.New("请选择选项！"))
+		return
+	}
+	if model.Multiple == 0 && len(indexMap) > 1 {
+		c.ResponseError(errors.New("单选投票只能选择一个选项！"))
+		return
+	}
+	err = m.updatePoll(model.PollNo, func(poll *pollModel, tx *dbr.Tx) error {
+		if !poll.isOpen(time.Now().Unix()) {
+			return errors.New("投票已结束！")
+		}
+		if err := m.pollDB.deleteVotesWithUIDTx(poll.PollNo, loginUID, tx); err != nil {
+			m.Error("删除投票记录失败！", zap.Error(err))
+			return errors.New("删除投票记录失败！")
+		}
+		for index := range indexMap {
+			err := m.pollDB.insertVoteTx(&pollVoteModel{
+				PollNo:      poll.PollNo,
+				OptionIndex: index,
+				UID:         loginUID,
+			}, tx)
+			if err != nil {
+				m.Error("添加投票记录失败！", zap.Error(err))
+				return errors.New("添加投票记录失败！")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
+
+// 撤回自己的投票
+func (m *Message) pollRetract(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	model, err := m.getMemberPoll(c.Param("poll_no"), loginUID)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	err = m.updatePoll(model.PollNo, func(poll *pollModel, tx *dbr.Tx) error {
+		if !poll.isOpen(time.Now().Unix()) {
+			return errors.New("投票已结束！")
+		}
+		if err := m.pollDB.deleteVotesWithUIDTx(poll.PollNo, loginUID, tx); err != nil {
+			m.Error("删除投票记录失败！", zap.Error(err))
+			return errors.New("删除投票记录失败！")
+		}
+		return nil
+	})
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
+
+// 发起人结束投票
+func (m *Message) pollClose(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	model, err := m.getMemberPoll(c.Param("poll_no"), loginUID)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if model.Creator != loginUID {
+		c.ResponseError(errors.New("只有发起人才能结束投票！"))
+		return
+	}
+	err = m.updatePoll(model.PollNo, m.closePollTx)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
+
+// CheckPollDeadlineLoop 结束已到截止时间的投票
+func (m *Message) CheckPollDeadlineLoop() {
+	var limit uint64 = 100
+	var errSleep = time.Second * 5
+	var checkSleep = time.Second * 5
+	for {
+		if !m.holdLease(PollDeadlineLeaseCacheKey) {
+			time.Sleep(checkSleep)
+			continue
+		}
+		models, err := m.pollDB.queryExpired(time.Now().Unix(), limit)
+		if err != nil {
+			m.Warn("查询到期的投票失败", zap.Error(err))
+			time.Sleep(errSleep)
+			continue
+		}
+		for _, model := range models {
+			err = m.updatePoll(model.PollNo, m.closePollTx)
+			if errors.Is(err, errPollMessageRemoved) { // 投票消息已撤回或删除，只结束投票不再下发结果
+				err = m.pollDB.close(model.PollNo, time.Now().Unix())
+			}
+			if err != nil {
+				m.Warn("结束到期的投票失败", zap.Error(err), zap.String("pollNo", model.PollNo))
+			}
+		}
+		time.Sleep(checkSleep)
+	}
+}
+
+func (m *Message) closePollTx(poll *pollModel, tx *dbr.Tx) error {
+	if poll.Status == PollStatusClosed {
+		return errors.New("投票已结束！")
+	}
+	poll.Status = PollStatusClosed
+	poll.ClosedAt = time.Now().Unix()
+	if err := m.pollDB.closeTx(poll.PollNo, poll.ClosedAt, tx); err != nil {
+		m.Error("结束投票失败！", zap.Error(err))
+		return errors.New("结束投票失败！")
+	}
+	return nil
+}
+
+// 在事务内锁定投票后修改，然后重新统计投票结果写入消息扩展，并通知频道内成员同步消息扩展
+func (m *Message) updatePoll(pollNo string, fn func(poll *pollModel, tx *dbr.Tx) error) error {
+	tx, err := m.db.session.Begin()
+	if err != nil {
+		m.Error("开启事务失败！", zap.Error(err))
+		return errors.New("开启事务失败！")
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.RollbackUnlessCommitted()
+			panic(err)
+		}
+	}()
+	poll, err := m.pollDB.queryWithPollNoForUpdateTx(pollNo, tx)
+	if err != nil {
+		tx.Rollback()
+		m.Error("查询投票失败！", zap.Error(err))
+		return errors.New("查询投票失败！")
+	}
+	if poll == nil || poll.MessageID == "" {
+		tx.Rollback()
+		return errors.New("投票不存在！")
+	}
+	messageExtra, err := m.messageExtraDB.queryWithMessageIDTx(poll.MessageID, tx)
+	if err != nil {
+		tx.Rollback()
+		m.Error("查询消息扩展信息错误", zap.Error(err))
+		return errors.New("查询消息扩展信息错误")
+	}
+	if isPollMessageRemoved(messageExtra) {
+		tx.Rollback()
+		return errPollMessageRemoved
+	}
+	if err := fn(poll, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	votes, err := m.pollDB.queryVotesTx(poll.PollNo, tx)
+	if err != nil {
+		tx.Rollback()
+		m.Error("查询投票记录失败！", zap.Error(err))
+		return errors.New("查询投票记录失败！")
+	}
+	result := newPollResult(poll, votes)
+	err = m.messageExtraDB.insertOrUpdatePollResultTx(&messageExtraModel{
+		MessageID:   poll.MessageID,
+		MessageSeq:  poll.MessageSeq,
+		ChannelID:   poll.ChannelID,
+		ChannelType: poll.ChannelType,
+		PollResult:  dbr.NewNullString(util.ToJson(result)),
+		Version:     m.genMessageExtraSeq(poll.ChannelID),
+	}, tx)
+	if err != nil {
+		tx.Rollback()
+		m.Error("更新投票结果失败！", zap.Error(err))
+		return errors.New("更新投票结果失败！")
+	}
+	if err := tx.Commit(); err != nil {
+		tx.RollbackUnlessCommitted()
+		m.Error("提交事务失败！", zap.Error(err))
+		return errors.New("提交事务失败！")
+	}
+	err = m.ctx.SendCMD(config.MsgCMDReq{
+		NoPersist:   true,
+		ChannelID:   poll.ChannelID,
+		ChannelType: poll.ChannelType,
+		CMD:         common.CMDSyncMessageExtra,
+	})
+	if err != nil {
+		m.Warn("发送同步消息扩展命令失败！", zap.Error(err))
+	}
+	return nil
+}
+
+// 查询投票（需要是投票所在群的成员）
+func (m *Message) getMemberPoll(pollNo string, loginUID string) (*pollModel, error) {
+	model, err := m.pollDB.queryWithPollNo(pollNo)
+	if err != nil {
+		m.Error("查询投票失败！", zap.Error(err))
+		return nil, errors.New("查询投票失败！")
+	}
+	if model == nil || model.MessageID == "" {
+		return nil, errors.New("投票不存在！")
+	}
+	messageExtra, err := m.messageExtraDB.queryWithMessageID(model.MessageID)
+	if err != nil {
+		m.Error("查询消息扩展信息错误", zap.Error(err))
+		return nil, errors.New("查询消息扩展信息错误")
+	}
+	if isPollMessageRemoved(messageExtra) {
+		return nil, errPollMessageRemoved
+	}
+	exist, err := m.groupService.ExistMember(model.ChannelID, loginUID)
+	if err != nil {
+		m.Error("查询是否在群内存在失败！", zap.Error(err))
+		return nil, errors.New("查询是否在群内存在失败！")
+	}
+	if !exist {
+		return nil, errors.New("不在群内，无法参与投票！")
+	}
+	return model, nil
+}
+
+// 投票消息是否已被撤回或删除（撤回或删除后不能再查看和参与投票）
+func isPollMessageRemoved(messageExtra *messageExtraModel) bool {
+	return messageExtra != nil && (messageExtra.Revoke == 1 || messageExtra.IsDeleted == 1)
+}
+
+func (p *pollModel) getOptions() []string {
+	var options []string
+	if p.Options != "" {
+		_ = util.ReadJsonByByte([]byte(p.Options), &options)
+	}
+	return options
+}
+
+// 是否可以投票（未结束且未到截止时间）
+func (p *pollModel) isOpen(now int64) bool {
+	return p.Status == PollStatusOpen && (p.Deadline == 0 || p.Deadline > now)
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// 投票结果（保存在消息扩展中，通过同步消息扩展下发给客户端）
+type pollResult struct {
+	PollNo     string              `json:"poll_no"`     // 投票编号
+	Status     int                 `json:"status"`      // 状态 0.进行中 1.已结束
+	ClosedAt   int64               `json:"closed_at"`   // 结束时间
+	VoterCount int                 `json:"voter_count"` // 投票人数
+	Options    []*pollOptionResult `json:"options"`     // 各选项的结果
+}
+
+type pollOptionResult struct {
+	Index  int      `json:"index"`            // 选项下标
+	Count  int      `json:"count"`            // 票数
+	Voters []string `json:"voters,omitempty"` // 投票人uid（匿名投票时为空）
+}
+
+func newPollResult(poll *pollModel, votes []*pollVoteModel) *pollResult {
+	options := poll.getOptions()
+	result := &pollResult{
+		PollNo:   poll.PollNo,
+		Status:   poll.Status,
+		ClosedAt: poll.ClosedAt,
+		Options:  make([]*pollOptionResult, 0, len(options)),
+	}
+	for i := range options {
+		result.Options = append(result.Options, &pollOptionResult{
+			Index: i,
+		})
+	}
+	voterMap := make(map[string]bool)
+	for _, vote := range votes {
+		if vote.OptionIndex < 0 || vote.OptionIndex >= len(result.Options) {
+			continue
+		}
+		optionResult := result.Options[vote.OptionIndex]
+		optionResult.Count++
+		if poll.Anonymous == 0 {
+			optionResult.Voters = append(optionResult.Voters, vote.UID)
+		}
+		voterMap[vote.UID] = true
+	}
+	result.VoterCount = len(voterMap)
+	return result
+}
+
+type pollResp struct {
+	PollNo      string      `json:"poll_no"`      // 投票编号
+	MessageID   string      `json:"message_id"`   // 投票消息的唯一ID
+	MessageSeq  uint32      `json:"message_seq"`  // 投票消息的序列号
+	ChannelID   string      `json:"channel_id"`   // 频道ID
+	ChannelType uint8       `json:"channel_type"` // 频道类型
+	Creator     string      `json:"creator"`      // 发起人uid
+	Question    string      `json:"question"`     // 投票问题
+	Options     []string    `json:"options"`      // 选项
+	Multiple    int         `json:"multiple"`     // 是否多选
+	Anonymous   int         `json:"anonymous"`    // 是否匿名
+	Deadline    int64       `json:"deadline"`     // 截止时间
+	Result      *pollResult `json:"result"`       // 投票结果
+	MyVotes     []int       `json:"my_votes"`     // 自己选择的选项下标
+}
+
+func newPollResp(m *pollModel, result *pollResult, myVotes []*pollVoteModel) *pollResp {
+	myVoteIndexes := make([]int, 0, len(myVotes))
+	for _, vote := range myVotes {
+		myVoteIndexes = append(myVoteIndexes, vote.OptionIndex)
+	}
+	return &pollResp{
+		PollNo:      m.PollNo,
+		MessageID:   m.MessageID,
+		MessageSeq:  m.MessageSeq,
+		ChannelID:   m.ChannelID,
+		ChannelType: m.ChannelType,
+		Creator:     m.Creator,
+		Question:    m.Question,
+		Options:     m.getOptions(),
+		Multiple:    m.Multiple,
+		Anonymous:   m.Anonymous,
+		Deadline:    m.Deadline,
+		Result:      result,
+		MyVotes:     myVoteIndexes,
+	}
+}
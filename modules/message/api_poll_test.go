@@ -0,0 +1,201 @@
+package message
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPollResult(t *testing.T) {
+	poll := &pollModel{
+		PollNo:  "p1",
+		Options: util.ToJson([]string{"A", "B", "C"}),
+	}
+	votes := []*pollVoteModel{
+		{PollNo: "p1", OptionIndex: 0, UID: "u1"},
+		{PollNo: "p1", OptionIndex: 1, UID: "u1"},
+		{PollNo: "p1", OptionIndex: 1, UID: "u2"},
+		{PollNo: "p1", OptionIndex: 5, UID: "u3"}, // 不存在的选项不计票
+	}
+
+	result := newPollResult(poll, votes)
+	assert.Len(t, result.Options, 3)
+	assert.Equal(t, 2, result.VoterCount)
+	assert.Equal(t, 1, result.Options[0].Count)
+	assert.Equal(t, 2, result.Options[1].Count)
+	assert.Equal(t, 0, result.Options[2].Count)
+	assert.Equal(t, []string{"u1", "u2"}, result.Options[1].Voters)
+
+	// 匿名投票不返回投票人
+	poll.Anonymous = 1
+	result = newPollResult(poll, votes)
+	assert.Equal(t, 2, result.Options[1].Count)
+	assert.Empty(t, result.Options[0].Voters)
+	assert.Empty(t, result.Options[1].Voters)
+	assert.NotContains(t, util.ToJson(result), "u1")
+}
+
+func TestPollIsOpen(t *testing.T) {
+	now := time.Now().Unix()
+	assert.True(t, (&pollModel{Status: PollStatusOpen}).isOpen(now))
+	assert.True(t, (&pollModel{Status: PollStatusOpen, Deadline: now + 60}).isOpen(now))
+	assert.False(t, (&pollModel{Status: PollStatusOpen, Deadline: now}).isOpen(now))
+	assert.False(t, (&pollModel{Status: PollStatusClosed}).isOpen(now))
+}
+
+func TestIsPollMessageRemoved(t *testing.T) {
+	assert.False(t, isPollMessageRemoved(nil))
+	assert.False(t, isPollMessageRemoved(&messageExtraModel{}))
+	assert.True(t, isPollMessageRemoved(&messageExtraModel{Revoke: 1}))
+	assert.True(t, isPollMessageRemoved(&messageExtraModel{IsDeleted: 1}))
+}
+
+// 准备一个群和一个进行中的投票（发起人为测试用户）
+func preparePoll(t *testing.T, ctx *config.Context, m *Message, poll *pollModel) {
+	groupDB := group.NewDB(ctx)
+	err := groupDB.Insert(&group.Model{
+		GroupNo: "g1",
+		Name:    "投票群",
+		Creator: testutil.UID,
+		Status:  group.GroupStatusNormal,
+	})
+	assert.NoError(t, err)
+	for _, memberUID := range []string{testutil.UID, "10001"} {
+		err = groupDB.InsertMember(&group.MemberModel{
+			GroupNo: "g1",
+			UID:     memberUID,
+			Role:    group.MemberRoleCommon,
+			Status:  int(common.GroupMemberStatusNormal),
+		})
+		assert.NoError(t, err)
+	}
+	poll.ChannelID = "g1"
+	poll.ChannelType = common.ChannelTypeGroup.Uint8()
+	poll.MessageID = "1001"
+	poll.MessageSeq = 1
+	if poll.Creator == "" {
+		poll.Creator = testutil.UID
+	}
+	if poll.Options == "" {
+		poll.Options = util.ToJson([]string{"A", "B", "C"})
+	}
+	err = m.pollDB.insert(poll)
+	assert.NoError(t, err)
+}
+
+func servePoll(s http.Handler, method string, path string, body interface{}) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	var reader *bytes.Reader
+	if body != nil {
+		reader = bytes.NewReader([]byte(util.ToJson(body)))
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, _ := http.NewRequest(method, path, reader)
+	req.Header.Set("token", testutil.Token)
+	s.ServeHTTP(w, req)
+	return w
+}
+
+func TestPollVoteAndRetract(t *testing.T) {
+	s, ctx := testutil.NewTestServer()
+	m := New(ctx)
+	preparePoll(t, ctx, m, &pollModel{PollNo: "p1", Question: "午饭吃什么", Status: PollStatusOpen})
+
+	// 单选投票不能选多个
+	w := servePoll(s.GetRoute(), "POST", "/v1/polls/p1/vote", map[string]interface{}{"option_indexes": []int{0, 1}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 投票并写入消息扩展
+	w = servePoll(s.GetRoute(), "POST", "/v1/polls/p1/vote", map[string]interface{}{"option_indexes": []int{1}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	messageExtra, err := m.messageExtraDB.queryWithMessageID("1001")
+	assert.NoError(t, err)
+	assert.Contains(t, messageExtra.PollResult.String, `"voter_count":1`)
+
+	// 重新投票覆盖之前的选择
+	w = servePoll(s.GetRoute(), "POST", "/v1/polls/p1/vote", map[string]interface{}{"option_indexes": []int{2}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = servePoll(s.GetRoute(), "GET", "/v1/polls/p1", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"my_votes":[2]`)
+
+	// 撤回投票
+	w = servePoll(s.GetRoute(), "DELETE", "/v1/polls/p1/vote", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	messageExtra, err = m.messageExtraDB.queryWithMessageID("1001")
+	assert.NoError(t, err)
+	assert.Contains(t, messageExtra.PollResult.String, `"voter_count":0`)
+}
+
+func TestPollClose(t *testing.T) {
+	s, ctx := testutil.NewTestServer()
+	m := New(ctx)
+	preparePoll(t, ctx, m, &pollModel{PollNo: "p1", Question: "午饭吃什么", Creator: "10001", Status: PollStatusOpen})
+
+	// 只有发起人才能结束投票
+	w := servePoll(s.GetRoute(), "PUT", "/v1/polls/p1/close", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "只有发起人才能结束投票")
+
+	err := m.updatePoll("p1", m.closePollTx)
+	assert.NoError(t, err)
+
+	// 结束后不能再投票
+	w = servePoll(s.GetRoute(), "POST", "/v1/polls/p1/vote", map[string]interface{}{"option_indexes": []int{0}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "投票已结束")
+}
+
+func TestPollDeadline(t *testing.T) {
+	s, ctx := testutil.NewTestServer()
+	m := New(ctx)
+	preparePoll(t, ctx, m, &pollModel{PollNo: "p1", Question: "午饭吃什么", Status: PollStatusOpen, Deadline: time.Now().Add(-time.Minute).Unix()})
+
+	// 已到截止时间不能投票
+	w := servePoll(s.GetRoute(), "POST", "/v1/polls/p1/vote", map[string]interface{}{"option_indexes": []int{0}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "投票已结束")
+
+	// 到期的投票会被查询出来结束
+	models, err := m.pollDB.queryExpired(time.Now().Unix(), 10)
+	assert.NoError(t, err)
+	assert.Len(t, models, 1)
+	err = m.updatePoll("p1", m.closePollTx)
+	assert.NoError(t, err)
+	models, err = m.pollDB.queryExpired(time.Now().Unix(), 10)
+	assert.NoError(t, err)
+	assert.Len(t, models, 0)
+}
+
+func TestPollMessageRevoked(t *testing.T) {
+	s, ctx := testutil.NewTestServer()
+	m := New(ctx)
+	preparePoll(t, ctx, m, &pollModel{PollNo: "p1", Question: "午饭吃什么", Status: PollStatusOpen})
+	err := m.messageExtraDB.insertOrUpdateRevoke(&messageExtraModel{
+		MessageID:   "1001",
+		MessageSeq:  1,
+		ChannelID:   "g1",
+		ChannelType: common.ChannelTypeGroup.Uint8(),
+		Revoke:      1,
+		Revoker:     testutil.UID,
+		Version:     1,
+	})
+	assert.NoError(t, err)
+
+	// 投票消息撤回后不能查看和投票
+	w := servePoll(s.GetRoute(), "GET", "/v1/polls/p1", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = servePoll(s.GetRoute(), "POST", "/v1/polls/p1/vote", map[string]interface{}{"option_indexes": []int{0}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.ErrorIs(t, m.updatePoll("p1", m.closePollTx), errPollMessageRemoved)
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
@@ -128,8 +130,186 @@ func (m *Message) reminderSync(c *wkhttp.Context) {
 	c.JSON(http.StatusOK, reminderResps)
 }
 
+// 添加自定义提醒（到提醒时间后由系统账号通知，并同步到用户的所有设备）
+func (m *Message) reminderAdd(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	var req struct {
+		MessageID   string `json:"message_id"`   // 消息唯一ID
+		ChannelID   string `json:"channel_id"`   // 频道唯一ID
+		ChannelType uint8  `json:"channel_type"` // 频道类型
+		RemindAt    int64  `json:"remind_at"`    // 提醒时间 时间戳（秒）
+		Text        string `json:"text"`         // 提醒内容
+	}
+	if err := c.BindJSON(&req); err != nil {
+		m.Error(common.ErrData.Error(), zap.Error(err))
+		c.ResponseError(common.ErrData)
+		return
+	}
+	if strings.TrimSpace(req.ChannelID) == "" {
+		c.ResponseError(errors.New("频道ID不能为空！"))
+		return
+	}
+	if strings.TrimSpace(req.MessageID) == "" {
+		c.ResponseError(errors.New("消息ID不能为空！"))
+		return
+	}
+	now := time.Now()
+	if req.RemindAt <= now.Unix() {
+		c.ResponseError(errors.New("提醒时间必须晚于当前时间！"))
+		return
+	}
+	if req.RemindAt > now.Add(ReminderCustomMaxDelay).Unix() {
+		c.ResponseError(errors.New("提醒时间超出范围！"))
+		return
+	}
+	if len([]rune(req.Text)) > 100 {
+		c.ResponseError(errors.New("提醒内容不能超过100个字！"))
+		return
+	}
+	messageM, _, err := m.getVisibleMessage(loginUID, req.ChannelID, req.ChannelType, req.MessageID)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	count, err := m.remindersDB.queryCustomPendingCountWithUID(loginUID)
+	if err != nil {
+		m.Error("查询自定义提醒数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询自定义提醒数量失败！"))
+		return
+	}
+	if count >= ReminderCustomMaxPending {
+		c.ResponseError(fmt.Errorf("最多只能设置%d个未到期的提醒！", ReminderCustomMaxPending))
+		return
+	}
+	model := &remindersModel{
+		ChannelID:    req.ChannelID,
+		ChannelType:  req.ChannelType,
+		ClientMsgNo:  messageM.ClientMsgNo,
+		MessageSeq:   messageM.MessageSeq,
+		MessageID:    req.MessageID,
+		ReminderType: ReminderTypeCustom,
+		Publisher:    loginUID,
+		UID:          loginUID,
+		Text:         strings.TrimSpace(req.Text),
+		IsLocate:     1,
+		RemindAt:     req.RemindAt,
+		Version:      m.ctx.GenSeq(common.RemindersKey),
+	}
+	id, err := m.remindersDB.insert(model)
+	if err != nil {
+		m.Error("添加自定义提醒失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加自定义提醒失败！"))
+		return
+	}
+	model.Id = id
+	m.sendReminderSyncCMD(loginUID)
+	c.Response(newReminderResp(&remindersDetailModel{remindersModel: *model}))
+}
+
+// 取消自定义提醒
+func (m *Message) reminderCancel(c *wkhttp.Context) {
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	model, err := m.remindersDB.queryWithID(id)
+	if err != nil {
+		m.Error("查询提醒失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询提醒失败！"))
+		return
+	}
+	if model == nil || model.IsDeleted == 1 || model.ReminderType != ReminderTypeCustom || model.UID != c.GetLoginUID() {
+		c.ResponseError(errors.New("提醒不存在！"))
+		return
+	}
+	err = m.remindersDB.deleteWithID(model.Id, m.ctx.GenSeq(common.RemindersKey))
+	if err != nil {
+		m.Error("取消自定义提醒失败！", zap.Error(err))
+		c.ResponseError(errors.New("取消自定义提醒失败！"))
+		return
+	}
+	m.sendReminderSyncCMD(model.UID)
+	c.ResponseOK()
+}
+
+// CheckCustomReminderLoop 到期的自定义提醒由系统账号通知用户
+// 多实例部署时只有持有调度租约的实例执行提醒，每条提醒通知前还会抢占数据库状态，保证只提醒一次
+func (m *Message) CheckCustomReminderLoop() {
+	var limit uint64 = 100
+	var errSleep = time.Second * 5
+	var checkSleep = time.Second * 5
+	for {
+		if !m.holdLease(ReminderCustomLeaseCacheKey) {
+			time.Sleep(checkSleep)
+			continue
+		}
+		models, err := m.remindersDB.queryCustomDue(time.Now().Unix(), limit)
+		if err != nil {
+			m.Warn("查询到期的自定义提醒失败", zap.Error(err))
+			time.Sleep(errSleep)
+			continue
+		}
+		for _, model := range models {
+			m.fireCustomReminder(model)
+		}
+		time.Sleep(checkSleep)
+	}
+}
+
+func (m *Message) fireCustomReminder(model *remindersModel) {
+	fired, err := m.remindersDB.fireCustom(model.Id, time.Now().Unix(), m.ctx.GenSeq(common.RemindersKey))
+	if err != nil {
+		m.Warn("标记自定义提醒失败", zap.Error(err), zap.Int64("id", model.Id))
+		return
+	}
+	if !fired { // 已被其他实例提醒或已被取消
+		return
+	}
+	content := "[消息提醒] 你设置的消息提醒时间到了"
+	if model.Text != "" {
+		content = fmt.Sprintf("[消息提醒] %s", model.Text)
+	}
+	err = m.ctx.SendMessage(&config.MsgSendReq{
+		Header: config.MsgHeader{
+			RedDot: 1,
+		},
+		FromUID:     m.ctx.GetConfig().Account.SystemUID,
+		ChannelID:   model.UID,
+		ChannelType: common.ChannelTypePerson.Uint8(),
+		Payload: []byte(util.ToJson(map[string]interface{}{
+			"content": content,
+			"type":    common.Text,
+			"reminder": map[string]interface{}{
+				"id":           model.Id,
+				"channel_id":   model.ChannelID,
+				"channel_type": model.ChannelType,
+				"message_id":   model.MessageID,
+				"message_seq":  model.MessageSeq,
+			},
+		})),
+	})
+	if err != nil {
+		m.Warn("发送自定义提醒消息失败", zap.Error(err), zap.Int64("id", model.Id))
+	}
+	m.sendReminderSyncCMD(model.UID)
+}
+
+// 通知用户的所有设备同步提醒项
+func (m *Message) sendReminderSyncCMD(uid string) {
+	err := m.ctx.SendCMD(config.MsgCMDReq{
+		NoPersist:   true,
+		ChannelID:   uid,
+		ChannelType: common.ChannelTypePerson.Uint8(),
+		CMD:         common.CMDSyncReminders,
+	})
+	if err != nil {
+		m.Warn("发送同步提醒项cmd失败！", zap.Error(err))
+	}
+}
+
 func (m *Message) listenerMessages(messages []*config.MessageResp) {
 
+	m.checkProhibitWordsMessages(messages) // 违禁词
+
+	m.handleThreadReplies(messages) // 话题回复
+
 	reminders := m.getReminders(messages) // 提醒
 	if len(reminders) > 0 {
 		m.handleReminders(reminders)
@@ -139,6 +319,8 @@ func (m *Message) listenerMessages(messages []*config.MessageResp) {
 
 func (m *Message) getReminders(messages []*config.MessageResp) []*remindersModel {
 	reminders := make([]*remindersModel, 0, len(messages))
+	// 同一批消息里同一发送者在同一群的@所有人权限只查询一次
+	mentionAllPermissions := map[string]bool{}
 	for _, message := range messages {
 		payloadMap, err := message.GetPayloadMap()
 		if err != nil {
@@ -150,6 +332,20 @@ func (m *Message) getReminders(messages []*config.MessageResp) []*remindersModel
 		}
 		if m.hasMention(payloadMap) {
 			all, uids := m.getMention(payloadMap)
+			if all && message.ChannelType == common.ChannelTypeGroup.Uint8() {
+				permissionKey := fmt.Sprintf("%s@%s", message.ChannelID, message.FromUID)
+				canMentionAll, ok := mentionAllPermissions[permissionKey]
+				if !ok {
+					canMentionAll, err = m.groupService.HasPermission(message.ChannelID, message.FromUID, group.PermissionMentionAll)
+					if err != nil {
+						m.Warn("查询群权限失败！", zap.Error(err))
+					}
+					mentionAllPermissions[permissionKey] = canMentionAll
+				}
+				if !canMentionAll { // 没有@所有人的权限，不产生提醒
+					all = false
+				}
+			}
 			if all {
 				version := m.ctx.GenSeq(common.RemindersKey)
 				reminders = append(reminders, &remindersModel{
@@ -295,6 +491,8 @@ type reminderResp struct {
 	IsLocate     int                    `json:"is_locate"`
 	Version      int64                  `json:"version"`
 	Done         int                    `json:"done"`
+	RemindAt     int64                  `json:"remind_at,omitempty"` // 提醒时间（用户自定义提醒）
+	FiredAt      int64                  `json:"fired_at,omitempty"`  // 实际提醒时间（用户自定义提醒，未到期时为空）
 }
 
 func newReminderResp(m *remindersDetailModel) *reminderResp {
@@ -318,5 +516,7 @@ func newReminderResp(m *remindersDetailModel) *reminderResp {
 		IsLocate:     m.IsLocate,
 		Version:      m.Version,
 		Done:         m.Done,
+		RemindAt:     m.RemindAt,
+		FiredAt:      m.FiredAt,
 	}
 }
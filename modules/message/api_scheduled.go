@@ -0,0 +1,443 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"github.com/gookit/goutil/maputil"
+	"go.uber.org/zap"
+)
+
+// 添加定时消息
+func (m *Message) scheduledAdd(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	var req scheduledMessageReq
+	if err := c.BindJSON(&req); err != nil {
+		m.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	addReq := &ScheduleMessageReq{
+		Source:      ScheduledMessageSourceUser,
+		Creator:     loginUID,
+		FromUID:     loginUID,
+		ChannelID:   req.ChannelID,
+		ChannelType: req.ChannelType,
+		Payload:     req.Payload,
+		SendAt:      req.SendAt,
+	}
+	if err := addReq.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if err := m.checkScheduledSendPermission(addReq.Source, addReq.FromUID, addReq.ChannelID, addReq.ChannelType); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if err := m.checkCardShare(loginUID, addReq.Payload); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	model, err := addScheduledMessage(m.scheduledMessageDB, addReq)
+	if err != nil {
+		m.Error("添加定时消息失败！", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	c.Response(newScheduledMessageResp(model))
+}
+
+// 频道内的定时消息（待发送和发送失败的）
+func (m *Message) scheduledList(c *wkhttp.Context) {
+	channelID := c.Query("channel_id")
+	channelType, _ := strconv.ParseUint(c.Query("channel_type"), 10, 8)
+	if strings.TrimSpace(channelID) == "" {
+		c.ResponseError(errors.New("频道ID不能为空！"))
+		return
+	}
+	models, err := m.scheduledMessageDB.queryWithCreatorAndChannel(c.GetLoginUID(), channelID, uint8(channelType))
+	if err != nil {
+		m.Error("查询定时消息失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询定时消息失败！"))
+		return
+	}
+	list := make([]*scheduledMessageResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newScheduledMessageResp(model))
+	}
+	c.Response(list)
+}
+
+// 修改定时消息（发送失败的修改后重新进入待发送）
+func (m *Message) scheduledUpdate(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	var req scheduledMessageReq
+	if err := c.BindJSON(&req); err != nil {
+		m.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	model, err := m.getScheduledMessage(c.Param("scheduled_no"), loginUID)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	updateReq := &ScheduleMessageReq{
+		Source:      model.Source,
+		Creator:     model.Creator,
+		FromUID:     model.FromUID,
+		ChannelID:   model.ChannelID,
+		ChannelType: model.ChannelType,
+		Payload:     req.Payload,
+		SendAt:      req.SendAt,
+	}
+	if err := updateReq.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if err := m.checkCardShare(loginUID, updateReq.Payload); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	model.Payload = util.ToJson(updateReq.Payload)
+	model.SendAt = updateReq.SendAt
+	ok, err := m.scheduledMessageDB.updatePending(model)
+	if err != nil {
+		m.Error("修改定时消息失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改定时消息失败！"))
+		return
+	}
+	if !ok {
+		c.ResponseError(errors.New("定时消息已发送或已取消，不能修改！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 取消定时消息
+func (m *Message) scheduledCancel(c *wkhttp.Context) {
+	model, err := m.getScheduledMessage(c.Param("scheduled_no"), c.GetLoginUID())
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	ok, err := m.scheduledMessageDB.cancel(model.ScheduledNo)
+	if err != nil {
+		m.Error("取消定时消息失败！", zap.Error(err))
+		c.ResponseError(errors.New("取消定时消息失败！"))
+		return
+	}
+	if !ok {
+		c.ResponseError(errors.New("定时消息已发送或已取消！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+func (m *Message) getScheduledMessage(scheduledNo string, loginUID string) (*scheduledMessageModel, error) {
+	model, err := m.scheduledMessageDB.queryWithScheduledNo(scheduledNo)
+	if err != nil {
+		m.Error("查询定时消息失败！", zap.Error(err))
+		return nil, errors.New("查询定时消息失败！")
+	}
+	if model == nil || model.Creator != loginUID || model.Source != ScheduledMessageSourceUser {
+		return nil, errors.New("定时消息不存在！")
+	}
+	return model, nil
+}
+
+// 校验发送者当前是否还能向频道发送消息（创建时和发送时都会校验）
+// 群聊时发送者都需要仍是群成员且未被拉黑，用户还需要未被禁言且已同意群规
+func (m *Message) checkScheduledSendPermission(source ScheduledMessageSource, fromUID string, channelID string, channelType uint8) error {
+	if channelType == common.ChannelTypePerson.Uint8() {
+		if source != ScheduledMessageSourceUser || channelID == fromUID {
+			return nil
+		}
+		account := m.ctx.GetConfig().Account
+		if channelID == account.SystemUID || channelID == account.FileHelperUID {
+			return nil
+		}
+		isFriend, err := m.userService.IsFriend(fromUID, channelID)
+		if err != nil {
+			m.Error("查询好友关系失败！", zap.Error(err))
+			return errors.New("查询好友关系失败！")
+		}
+		if !isFriend {
+			return errors.New("对方已不是你的好友！")
+		}
+		return nil
+	}
+	groupInfo, err := m.groupService.GetGroupWithGroupNo(channelID)
+	if err != nil {
+		m.Error("查询群信息失败！", zap.Error(err))
+		return errors.New("查询群信息失败！")
+	}
+	if groupInfo == nil || groupInfo.Status != group.GroupStatusNormal {
+		return errors.New("群不存在或已不能发言！")
+	}
+	member, err := m.groupService.GetMember(channelID, fromUID)
+	if err != nil {
+		m.Error("查询群成员信息失败！", zap.Error(err))
+		return errors.New("查询群成员信息失败！")
+	}
+	if member == nil {
+		return errors.New("已不是群成员！")
+	}
+	if member.Status == int(common.GroupMemberStatusBlacklist) {
+		return errors.New("已被群主或管理员拉入黑名单！")
+	}
+	if source != ScheduledMessageSourceUser {
+		return nil
+	}
+	if member.RulesPending == 1 {
+		return errors.New("请先同意群规后再发言！")
+	}
+	if member.ForbiddenExpirTime > time.Now().Unix() {
+		return errors.New("已被禁言！")
+	}
+	if groupInfo.Forbidden == 1 && member.Role == int(common.GroupMemberRoleNormal) {
+		return errors.New("群已开启全员禁言！")
+	}
+	return nil
+}
+
+// 发送时校验用户是否还在慢速模式的限制时间内（创建时不校验，限制到发送时可能已经解除）
+func (m *Message) checkScheduledSlowMode(model *scheduledMessageModel) error {
+	if model.Source != ScheduledMessageSourceUser || model.ChannelType != common.ChannelTypeGroup.Uint8() {
+		return nil
+	}
+	return m.checkSlowModeLimit(model.ChannelID, model.FromUID)
+}
+
+// 校验成员是否在群慢速模式的限制时间内
+func (m *Message) checkSlowModeLimit(groupNo string, uid string) error {
+	releaseAt, err := m.groupService.GetSlowModeReleaseAt(groupNo, uid)
+	if err != nil {
+		m.Error("查询慢速模式限制失败！", zap.Error(err))
+		return errors.New("查询慢速模式限制失败！")
+	}
+	if releaseAt > 0 {
+		return errors.New("群已开启慢速模式，发言过于频繁！")
+	}
+	return nil
+}
+
+// CheckScheduledMessageLoop 发送到期的定时消息
+// 多实例部署时只有持有调度租约的实例执行发送，每条消息发送前还会抢占数据库状态，保证只发送一次
+func (m *Message) CheckScheduledMessageLoop() {
+	var limit uint64 = 100
+	var errSleep = time.Second * 5
+	var checkSleep = time.Second * 5
+	for {
+		if !m.holdLease(ScheduledMessageLeaseCacheKey) {
+			time.Sleep(checkSleep)
+			continue
+		}
+		now := time.Now()
+		err := m.scheduledMessageDB.failStaleSending(now.Add(-ScheduledMessageSendingTimeout).Unix(), "发送中断，请重新发送")
+		if err != nil {
+			m.Warn("处理发送中断的定时消息失败", zap.Error(err))
+		}
+		models, err := m.scheduledMessageDB.queryDue(now.Unix(), limit)
+		if err != nil {
+			m.Warn("查询到期的定时消息失败", zap.Error(err))
+			time.Sleep(errSleep)
+			continue
+		}
+		for _, model := range models {
+			m.sendScheduledMessage(model)
+		}
+		time.Sleep(checkSleep)
+	}
+}
+
+// 获取或续期调度租约（租约过期后其他实例接管）
+func (m *Message) holdLease(cacheKey string) bool {
+	ok, err := m.leaseRedis.HoldLease(cacheKey, m.leaseID, ScheduledMessageLeaseTTL)
+	if err != nil {
+		m.Warn("获取调度租约失败", zap.Error(err), zap.String("key", cacheKey))
+		return false
+	}
+	return ok
+}
+
+// 发送定时消息（发送前重新校验发送权限）
+func (m *Message) sendScheduledMessage(model *scheduledMessageModel) {
+	claimed, err := m.scheduledMessageDB.claim(model.Id, time.Now().Unix())
+	if err != nil {
+		m.Warn("抢占定时消息失败", zap.Error(err), zap.String("scheduled_no", model.ScheduledNo))
+		return
+	}
+	if !claimed { // 已被其他实例发送或已被取消
+		return
+	}
+	err = m.checkScheduledSendPermission(model.Source, model.FromUID, model.ChannelID, model.ChannelType)
+	if err == nil {
+		err = m.checkScheduledSlowMode(model)
+	}
+	var payload map[string]interface{}
+	if err == nil {
+		err = util.ReadJsonByByte([]byte(model.Payload), &payload)
+		if err != nil {
+			m.Warn("定时消息内容格式有误", zap.Error(err), zap.String("scheduled_no", model.ScheduledNo))
+			err = errors.New("消息内容格式有误！")
+		}
+	}
+	if err == nil && model.Source == ScheduledMessageSourceUser {
+		err = m.checkCardShare(model.FromUID, payload)
+	}
+	if err == nil {
+		payload, err = m.filterProhibitWords(payload)
+	}
+	if err == nil {
+		err = m.ctx.SendMessage(&config.MsgSendReq{
+			Header: config.MsgHeader{
+				RedDot: 1,
+			},
+			FromUID:     model.FromUID,
+			ChannelID:   model.ChannelID,
+			ChannelType: model.ChannelType,
+			Payload:     []byte(util.ToJson(payload)),
+		})
+		if err != nil {
+			m.Error("发送定时消息失败", zap.Error(err), zap.String("scheduled_no", model.ScheduledNo))
+			err = errors.New("发送消息失败！")
+		}
+	}
+	if err != nil {
+		model.Status = ScheduledMessageStatusFailed
+		model.FailReason = err.Error()
+		err = m.scheduledMessageDB.updateFailed(model.Id, model.FailReason)
+	} else {
+		model.Status = ScheduledMessageStatusSent
+		err = m.scheduledMessageDB.updateSent(model.Id, time.Now().Unix())
+	}
+	if err != nil {
+		m.Warn("更新定时消息状态失败", zap.Error(err), zap.String("scheduled_no", model.ScheduledNo))
+	}
+	if model.Source != ScheduledMessageSourceUser {
+		return
+	}
+	err = m.ctx.SendCMD(config.MsgCMDReq{
+		NoPersist:   true,
+		ChannelID:   model.Creator,
+		ChannelType: common.ChannelTypePerson.Uint8(),
+		CMD:         CMDScheduledMessageUpdate,
+		Param: map[string]interface{}{
+			"scheduled_no": model.ScheduledNo,
+			"channel_id":   model.ChannelID,
+			"channel_type": model.ChannelType,
+			"status":       model.Status,
+			"fail_reason":  model.FailReason,
+		},
+	})
+	if err != nil {
+		m.Warn("发送定时消息状态命令失败", zap.Error(err))
+	}
+}
+
+// 保存定时消息（每个创建者的待发送定时消息数量有上限）
+func addScheduledMessage(d *scheduledMessageDB, req *ScheduleMessageReq) (*scheduledMessageModel, error) {
+	count, err := d.queryPendingCountWithCreator(req.Creator)
+	if err != nil {
+		return nil, errors.New("查询待发送的定时消息数量失败！")
+	}
+	if count >= ScheduledMessageMaxPending {
+		return nil, fmt.Errorf("待发送的定时消息不能超过%d条！", ScheduledMessageMaxPending)
+	}
+	model := &scheduledMessageModel{
+		ScheduledNo: util.GenerUUID(),
+		Source:      req.Source,
+		Creator:     req.Creator,
+		FromUID:     req.FromUID,
+		ChannelID:   req.ChannelID,
+		ChannelType: req.ChannelType,
+		Payload:     util.ToJson(req.Payload),
+		SendAt:      req.SendAt,
+		Status:      ScheduledMessageStatusPending,
+	}
+	err = d.insert(model)
+	if err != nil {
+		return nil, errors.New("添加定时消息失败！")
+	}
+	return model, nil
+}
+
+// ScheduleMessageReq 定时消息
+type ScheduleMessageReq struct {
+	Source      ScheduledMessageSource // 来源
+	Creator     string                 // 创建者uid
+	FromUID     string                 // 发送者uid
+	ChannelID   string                 // 频道ID
+	ChannelType uint8                  // 频道类型（只支持单聊和群聊）
+	Payload     map[string]interface{} // 消息内容
+	SendAt      int64                  // 计划发送时间（10位时间戳）
+}
+
+func (r *ScheduleMessageReq) check() error {
+	if strings.TrimSpace(r.ChannelID) == "" {
+		return errors.New("频道ID不能为空！")
+	}
+	if r.ChannelType != common.ChannelTypePerson.Uint8() && r.ChannelType != common.ChannelTypeGroup.Uint8() {
+		return errors.New("定时消息只支持单聊和群聊！")
+	}
+	if len(r.Payload) == 0 {
+		return errors.New("消息内容不能为空！")
+	}
+	if maputil.Data(r.Payload).Int("type") <= 0 {
+		return errors.New("消息类型不能为空！")
+	}
+	now := time.Now()
+	if r.SendAt <= now.Unix() {
+		return errors.New("发送时间必须晚于当前时间！")
+	}
+	if r.SendAt > now.Add(ScheduledMessageMaxDelay).Unix() {
+		return fmt.Errorf("发送时间不能超过%d天！", int(ScheduledMessageMaxDelay.Hours()/24))
+	}
+	return nil
+}
+
+type scheduledMessageReq struct {
+	ChannelID   string                 `json:"channel_id"`   // 频道ID（修改时无效）
+	ChannelType uint8                  `json:"channel_type"` // 频道类型（修改时无效）
+	Payload     map[string]interface{} `json:"payload"`      // 消息内容
+	SendAt      int64                  `json:"send_at"`      // 计划发送时间（10位时间戳）
+}
+
+type scheduledMessageResp struct {
+	ScheduledNo string                 `json:"scheduled_no"` // 定时消息编号
+	FromUID     string                 `json:"from_uid"`     // 发送者uid
+	ChannelID   string                 `json:"channel_id"`   // 频道ID
+	ChannelType uint8                  `json:"channel_type"` // 频道类型
+	Payload     map[string]interface{} `json:"payload"`      // 消息内容
+	SendAt      int64                  `json:"send_at"`      // 计划发送时间
+	Status      int                    `json:"status"`       // 状态 0.待发送 1.发送中 2.已发送 3.已取消 4.发送失败
+	FailReason  string                 `json:"fail_reason"`  // 发送失败原因
+	SentAt      int64                  `json:"sent_at"`      // 实际发送时间
+	CreatedAt   string                 `json:"created_at"`   // 创建时间
+}
+
+func newScheduledMessageResp(m *scheduledMessageModel) *scheduledMessageResp {
+	var payload map[string]interface{}
+	_ = util.ReadJsonByByte([]byte(m.Payload), &payload)
+	return &scheduledMessageResp{
+		ScheduledNo: m.ScheduledNo,
+		FromUID:     m.FromUID,
+		ChannelID:   m.ChannelID,
+		ChannelType: m.ChannelType,
+		Payload:     payload,
+		SendAt:      m.SendAt,
+		Status:      m.Status,
+		FailReason:  m.FailReason,
+		SentAt:      m.SentAt,
+		CreatedAt:   m.CreatedAt.String(),
+	}
+}
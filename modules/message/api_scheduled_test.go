@@ -0,0 +1,129 @@
+package message
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// 准备一个群（测试用户为普通成员）和一条到期的定时消息
+func prepareScheduledMessage(t *testing.T, ctx *config.Context, m *Message, member *group.MemberModel) *scheduledMessageModel {
+	groupDB := group.NewDB(ctx)
+	err := groupDB.Insert(&group.Model{
+		GroupNo: "g1",
+		Name:    "定时消息群",
+		Creator: "10001",
+		Status:  group.GroupStatusNormal,
+	})
+	assert.NoError(t, err)
+	if member != nil {
+		member.GroupNo = "g1"
+		member.UID = testutil.UID
+		member.Role = group.MemberRoleCommon
+		err = groupDB.InsertMember(member)
+		assert.NoError(t, err)
+	}
+	model := &scheduledMessageModel{
+		ScheduledNo: "s1",
+		Source:      ScheduledMessageSourceUser,
+		Creator:     testutil.UID,
+		FromUID:     testutil.UID,
+		ChannelID:   "g1",
+		ChannelType: common.ChannelTypeGroup.Uint8(),
+		Payload:     `{"type":1,"content":"早上好"}`,
+		SendAt:      time.Now().Add(-time.Second).Unix(),
+		Status:      ScheduledMessageStatusPending,
+	}
+	err = m.scheduledMessageDB.insert(model)
+	assert.NoError(t, err)
+	model, err = m.scheduledMessageDB.queryWithScheduledNo("s1")
+	assert.NoError(t, err)
+	return model
+}
+
+// 发送定时消息并返回发送失败的原因
+func sendScheduledAndGetFailReason(t *testing.T, m *Message, model *scheduledMessageModel) string {
+	m.sendScheduledMessage(model)
+	model, err := m.scheduledMessageDB.queryWithScheduledNo(model.ScheduledNo)
+	assert.NoError(t, err)
+	assert.Equal(t, ScheduledMessageStatusFailed, model.Status)
+	return model.FailReason
+}
+
+func TestScheduledMessageAddPermission(t *testing.T) {
+	s, ctx := testutil.NewTestServer()
+	m := New(ctx)
+	prepareScheduledMessage(t, ctx, m, nil)
+
+	// 不在群内不能添加定时消息
+	w := servePoll(s.GetRoute(), "POST", "/v1/message/scheduled", map[string]interface{}{
+		"channel_id":   "g1",
+		"channel_type": common.ChannelTypeGroup.Uint8(),
+		"payload":      map[string]interface{}{"type": 1, "content": "早上好"},
+		"send_at":      time.Now().Add(time.Hour).Unix(),
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "已不是群成员")
+}
+
+func TestScheduledMessageRecheckNotMember(t *testing.T) {
+	_, ctx := testutil.NewTestServer()
+	m := New(ctx)
+	model := prepareScheduledMessage(t, ctx, m, nil)
+
+	// 发送时已不在群内
+	assert.Equal(t, "已不是群成员！", sendScheduledAndGetFailReason(t, m, model))
+}
+
+func TestScheduledMessageRecheckRulesPending(t *testing.T) {
+	_, ctx := testutil.NewTestServer()
+	m := New(ctx)
+	model := prepareScheduledMessage(t, ctx, m, &group.MemberModel{Status: int(common.GroupMemberStatusNormal), RulesPending: 1})
+
+	// 发送时还未同意群规
+	assert.Equal(t, "请先同意群规后再发言！", sendScheduledAndGetFailReason(t, m, model))
+}
+
+func TestScheduledMessageRecheckBlacklist(t *testing.T) {
+	_, ctx := testutil.NewTestServer()
+	m := New(ctx)
+	model := prepareScheduledMessage(t, ctx, m, &group.MemberModel{Status: int(common.GroupMemberStatusBlacklist)})
+
+	// 发送时已被拉黑
+	assert.Equal(t, "已被群主或管理员拉入黑名单！", sendScheduledAndGetFailReason(t, m, model))
+}
+
+func TestScheduledMessageRecheckSlowMode(t *testing.T) {
+	_, ctx := testutil.NewTestServer()
+	m := New(ctx)
+	model := prepareScheduledMessage(t, ctx, m, &group.MemberModel{Status: int(common.GroupMemberStatusNormal)})
+	limitKey := fmt.Sprintf("%s%s", group.SlowModeLimitCachePrefix, "g1")
+	err := ctx.GetRedisConn().Hset(limitKey, testutil.UID, fmt.Sprintf("%d", time.Now().Add(time.Minute).Unix()))
+	assert.NoError(t, err)
+	defer ctx.GetRedisConn().Hdel(limitKey, testutil.UID)
+
+	// 发送时还在慢速模式的限制时间内
+	assert.Equal(t, "群已开启慢速模式，发言过于频繁！", sendScheduledAndGetFailReason(t, m, model))
+}
+
+func TestScheduledMessageRecheckArchived(t *testing.T) {
+	_, ctx := testutil.NewTestServer()
+	m := New(ctx)
+	model := prepareScheduledMessage(t, ctx, m, &group.MemberModel{Status: int(common.GroupMemberStatusNormal)})
+	groupDB := group.NewDB(ctx)
+	groupModel, err := groupDB.QueryWithGroupNo("g1")
+	assert.NoError(t, err)
+	groupModel.Status = group.GroupStatusArchived
+	err = groupDB.Update(groupModel)
+	assert.NoError(t, err)
+
+	// 发送时群已归档
+	assert.Equal(t, "群不存在或已不能发言！", sendScheduledAndGetFailReason(t, m, model))
+}
@@ -0,0 +1,199 @@
+package message
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/elastic"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+type messageSearchReq struct {
+	UID         string `json:"uid"` // 搜索的消息限定这某个用户内
+	ChannelID   string `json:"channel_id"`
+	ChannelType uint8  `json:"channel_type"`
+	ContentType int    `json:"content_type"` // 正文类型
+	Keyword     string `json:"keyword"`
+	FromUID     string `json:"from_uid,omitempty"`   // 发送者
+	StartTime   int64  `json:"start_time,omitempty"` // 开始时间 时间戳（秒）
+	EndTime     int64  `json:"end_time,omitempty"`   // 结束时间 时间戳（秒）
+	Page        int    `json:"page,omitempty"`       // 页码（从1开始）
+	Limit       int    `json:"limit,omitempty"`      // 每页数量
+}
+
+// 通过搜索索引搜索消息（只能搜索自己参与的单聊和所在的群）
+func (m *Message) searchWithIndex(c *wkhttp.Context, req *messageSearchReq) {
+	loginUID := req.UID
+	req.Keyword = strings.TrimSpace(req.Keyword)
+	if req.Keyword == "" {
+		c.ResponseError(errors.New("搜索关键字不能为空！"))
+		return
+	}
+	searchReq := &elastic.SearchReq{
+		Keyword:     req.Keyword,
+		UID:         loginUID,
+		FromUID:     req.FromUID,
+		ContentType: req.ContentType,
+		StartTime:   req.StartTime,
+		EndTime:     req.EndTime,
+		Page:        req.Page,
+		Limit:       req.Limit,
+	}
+	if req.ChannelID != "" {
+		switch req.ChannelType {
+		case common.ChannelTypePerson.Uint8():
+			searchReq.ChannelID = common.GetFakeChannelIDWith(loginUID, req.ChannelID)
+		case common.ChannelTypeGroup.Uint8():
+			exist, err := m.groupService.ExistMember(req.ChannelID, loginUID)
+			if err != nil {
+				m.Error("查询是否在群内失败！", zap.Error(err))
+				c.ResponseError(errors.New("查询是否在群内失败！"))
+				return
+			}
+			if !exist {
+				c.ResponseError(errors.New("不在群内，不能搜索群消息！"))
+				return
+			}
+			searchReq.ChannelID = req.ChannelID
+		case common.ChannelTypeCommunityTopic.Uint8():
+			// 话题的成员为所属群的成员
+			groupNo, err := m.getTopicGroupNo(req.ChannelID, req.ChannelType)
+			if err != nil {
+				m.Error("查询话题信息错误", zap.Error(err))
+				c.ResponseError(errors.New("查询话题信息错误"))
+				return
+			}
+			exist, err := m.groupService.ExistMember(groupNo, loginUID)
+			if err != nil {
+				m.Error("查询是否在群内失败！", zap.Error(err))
+				c.ResponseError(errors.New("查询是否在群内失败！"))
+				return
+			}
+			if !exist {
+				c.ResponseError(errors.New("不在群内，不能搜索话题消息！"))
+				return
+			}
+			searchReq.ChannelID = req.ChannelID
+		default:
+			c.ResponseError(errors.New("不支持搜索该频道类型！"))
+			return
+		}
+		searchReq.ChannelType = req.ChannelType
+	} else {
+		groups, err := m.groupService.GetGroupsWithMemberUID(loginUID)
+		if err != nil {
+			m.Error("查询用户所在群失败！", zap.Error(err))
+			c.ResponseError(errors.New("查询用户所在群失败！"))
+			return
+		}
+		searchReq.GroupNos = make([]string, 0, len(groups))
+		for _, group := range groups {
+			searchReq.GroupNos = append(searchReq.GroupNos, group.GroupNo)
+		}
+	}
+	err := m.fillSearchExcludes(loginUID, req, searchReq)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	result, err := m.searchService.Search(searchReq)
+	if err != nil {
+		m.Error("搜索消息失败！", zap.Error(err))
+		c.ResponseError(errors.New("搜索消息失败！"))
+		return
+	}
+	c.Header(SearchTotalHeader, strconv.FormatInt(result.Total, 10))
+	c.JSON(http.StatusOK, newMessageSearchResps(loginUID, result.Messages))
+}
+
+// 由搜索后端过滤掉自己删除的消息和清空聊天记录之前的消息，保证分页和总数准确
+func (m *Message) fillSearchExcludes(loginUID string, req *messageSearchReq, searchReq *elastic.SearchReq) error {
+	messageIDs, err := m.messageUserExtraDB.queryDeletedMessageIDsWithUID(loginUID, req.ChannelID, req.ChannelType)
+	if err != nil {
+		m.Error("查询用户删除的消息失败！", zap.Error(err))
+		return errors.New("查询用户删除的消息失败！")
+	}
+	searchReq.ExcludeMessageIDs = messageIDs
+
+	var channelOffsets []*channelOffsetModel
+	if req.ChannelID != "" {
+		channelOffset, err := m.channelOffsetDB.queryWithUIDAndChannel(loginUID, req.ChannelID, req.ChannelType)
+		if err != nil {
+			m.Error("查询频道偏移失败！", zap.Error(err))
+			return errors.New("查询频道偏移失败！")
+		}
+		if channelOffset != nil {
+			channelOffsets = append(channelOffsets, channelOffset)
+		}
+	} else {
+		channelOffsets, err = m.channelOffsetDB.queryWithUID(loginUID)
+		if err != nil {
+			m.Error("查询频道偏移失败！", zap.Error(err))
+			return errors.New("查询频道偏移失败！")
+		}
+	}
+	searchReq.ChannelOffsets = make([]*elastic.ChannelOffset, 0, len(channelOffsets))
+	for _, channelOffset := range channelOffsets {
+		if channelOffset.MessageSeq == 0 {
+			continue
+		}
+		channelID := channelOffset.ChannelID
+		if channelOffset.ChannelType == common.ChannelTypePerson.Uint8() {
+			channelID = common.GetFakeChannelIDWith(loginUID, channelOffset.ChannelID)
+		}
+		searchReq.ChannelOffsets = append(searchReq.ChannelOffsets, &elastic.ChannelOffset{
+			ChannelID:   channelID,
+			ChannelType: channelOffset.ChannelType,
+			MessageSeq:  channelOffset.MessageSeq,
+		})
+	}
+	return nil
+}
+
+func newMessageSearchResps(loginUID string, docs []*elastic.MessageDocument) []*messageSearchResp {
+	list := make([]*messageSearchResp, 0, len(docs))
+	for _, doc := range docs {
+		var payload map[string]interface{}
+		_ = util.ReadJsonByByte([]byte(doc.Payload), &payload)
+		messageID, _ := strconv.ParseInt(doc.MessageID, 10, 64)
+		list = append(list, &messageSearchResp{
+			MessageID:    messageID,
+			MessageIDStr: doc.MessageID,
+			MessageSeq:   doc.MessageSeq,
+			ClientMsgNo:  doc.ClientMsgNo,
+			FromUID:      doc.FromUID,
+			ChannelID:    getSearchChannelID(doc, loginUID),
+			ChannelType:  doc.ChannelType,
+			ContentType:  doc.ContentType,
+			Timestamp:    doc.Timestamp,
+			Payload:      payload,
+		})
+	}
+	return list
+}
+
+// 登录用户视角的频道ID（单聊为对方uid）
+func getSearchChannelID(doc *elastic.MessageDocument, loginUID string) string {
+	if doc.ChannelType == common.ChannelTypePerson.Uint8() {
+		return common.GetToChannelIDWithFakeChannelID(doc.ChannelID, loginUID)
+	}
+	return doc.ChannelID
+}
+
+type messageSearchResp struct {
+	MessageID    int64                  `json:"message_id"`    // 服务端的消息ID(全局唯一)
+	MessageIDStr string                 `json:"message_idstr"` // 服务端的消息ID(全局唯一)字符串形式
+	MessageSeq   uint32                 `json:"message_seq"`   // 消息序列号
+	ClientMsgNo  string                 `json:"client_msg_no"` // 客户端消息唯一编号
+	FromUID      string                 `json:"from_uid"`      // 发送者uid
+	ChannelID    string                 `json:"channel_id"`    // 频道ID（单聊为对方uid）
+	ChannelType  uint8                  `json:"channel_type"`  // 频道类型
+	ContentType  int                    `json:"content_type"`  // 正文类型
+	Timestamp    int64                  `json:"timestamp"`     // 消息时间 时间戳（秒）
+	Payload      map[string]interface{} `json:"payload"`       // 消息内容（编辑过的消息为编辑后的内容）
+}
@@ -3,6 +3,7 @@ package message
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -10,6 +11,8 @@ import (
 	"time"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/group"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
 	_ "github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/webhook"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
@@ -17,6 +20,7 @@ import (
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/server"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/testutil"
+	"github.com/gocraft/dbr/v2"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -232,3 +236,263 @@ func NewTestServer1(args ...string) (*server.Server, *config.Context) {
 	return s, ctx
 
 }
+
+func TestGroupTopicDeleteEvent(t *testing.T) {
+	_, ctx := testutil.NewTestServer()
+	m := New(ctx)
+	topicType := common.ChannelTypeCommunityTopic.Uint8()
+	err := m.pinnedDB.insert(&pinnedMessageModel{
+		MessageId:   "1",
+		MessageSeq:  1,
+		ChannelID:   "t1",
+		ChannelType: topicType,
+		Version:     1,
+	})
+	assert.NoError(t, err)
+	err = m.conversationExtradb.insertOrUpdate(&conversationExtraModel{
+		UID:         testutil.UID,
+		ChannelID:   "t1",
+		ChannelType: topicType,
+		Draft:       "草稿",
+		Version:     1,
+	})
+	assert.NoError(t, err)
+
+	var commitErr error
+	m.handleGroupTopicDeleteEvent([]byte(util.ToJson(map[string]interface{}{
+		"topic_no": "t1",
+		"group_no": "g1",
+	})), func(err error) {
+		commitErr = err
+	})
+	assert.NoError(t, commitErr)
+
+	// 话题的置顶消息和最近会话扩展被清理
+	pinnedCount, err := m.pinnedDB.queryCountWithChannel("t1", topicType)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), pinnedCount)
+	extras, err := m.conversationExtradb.queryWithChannelIDs(testutil.UID, []string{"t1"})
+	assert.NoError(t, err)
+	assert.Len(t, extras, 0)
+}
+
+func TestCustomReminderSync(t *testing.T) {
+	_, ctx := testutil.NewTestServer()
+	m := New(ctx)
+	remindAt := time.Now().Add(time.Hour).Unix()
+	model := &remindersModel{
+		ChannelID:    "g1",
+		ChannelType:  common.ChannelTypeGroup.Uint8(),
+		MessageID:    "1001",
+		MessageSeq:   1,
+		ReminderType: ReminderTypeCustom,
+		Publisher:    testutil.UID,
+		UID:          testutil.UID,
+		Text:         "稍后处理",
+		IsLocate:     1,
+		RemindAt:     remindAt,
+		Version:      1,
+	}
+	id, err := m.remindersDB.insert(model)
+	assert.NoError(t, err)
+
+	// 未到提醒时间的自定义提醒也会同步，fired_at为空
+	list, err := m.remindersDB.sync(testutil.UID, 0, 100, nil)
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, remindAt, list[0].RemindAt)
+	assert.Equal(t, int64(0), list[0].FiredAt)
+
+	// 未到期的不会被提醒
+	dues, err := m.remindersDB.queryCustomDue(time.Now().Unix(), 100)
+	assert.NoError(t, err)
+	assert.Len(t, dues, 0)
+
+	// 提醒后更新版本号重新同步，重复标记不生效
+	firedAt := time.Now().Unix()
+	ok, err := m.remindersDB.fireCustom(id, firedAt, 2)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = m.remindersDB.fireCustom(id, firedAt, 3)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	list, err = m.remindersDB.sync(testutil.UID, 1, 100, nil)
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, firedAt, list[0].FiredAt)
+}
+
+func TestGetRevokeSecond(t *testing.T) {
+	_, ctx := testutil.NewTestServer()
+	m := New(ctx)
+	_, err := ctx.DB().InsertBySql("insert into app_config(revoke_second,manager_revoke_second,creator_revoke_second) values(?,?,?)", 120, 0, 0).Exec()
+	assert.NoError(t, err)
+	groupDB := group.NewDB(ctx)
+	err = groupDB.Insert(&group.Model{
+		GroupNo:             "g1",
+		Name:                "撤回时长群",
+		Creator:             "10002",
+		Status:              group.GroupStatusNormal,
+		RevokeSecond:        60,
+		ManagerRevokeSecond: 600,
+	})
+	assert.NoError(t, err)
+	for memberUID, role := range map[string]int{testutil.UID: group.MemberRoleCommon, "10001": group.MemberRoleManager, "10002": group.MemberRoleCreator} {
+		err = groupDB.InsertMember(&group.MemberModel{
+			GroupNo: "g1",
+			UID:     memberUID,
+			Role:    role,
+			Status:  int(common.GroupMemberStatusNormal),
+		})
+		assert.NoError(t, err)
+	}
+
+	// 单聊使用全局配置
+	revokeSecond, err := m.getRevokeSecond("10001", common.ChannelTypePerson.Uint8(), testutil.UID)
+	assert.NoError(t, err)
+	assert.Equal(t, 120, revokeSecond)
+
+	// 普通成员取群设置与全局配置中较短的一个
+	revokeSecond, err = m.getRevokeSecond("g1", common.ChannelTypeGroup.Uint8(), testutil.UID)
+	assert.NoError(t, err)
+	assert.Equal(t, 60, revokeSecond)
+
+	// 管理员全局不限制时使用群设置
+	revokeSecond, err = m.getRevokeSecond("g1", common.ChannelTypeGroup.Uint8(), "10001")
+	assert.NoError(t, err)
+	assert.Equal(t, 600, revokeSecond)
+
+	// 群主都未设置时不限制
+	revokeSecond, err = m.getRevokeSecond("g1", common.ChannelTypeGroup.Uint8(), "10002")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, revokeSecond)
+}
+
+func TestNewEditHistoryResp(t *testing.T) {
+	original := newOriginalEditHistoryResp(&messageModel{
+		FromUID:   testutil.UID,
+		Timestamp: 100,
+		Payload:   []byte(`{"type":1,"content":"原文"}`),
+	})
+	assert.Equal(t, 0, original.Version)
+	assert.Equal(t, testutil.UID, original.Editor)
+	assert.Equal(t, "原文", original.ContentEdit["content"])
+	assert.Equal(t, 100, original.EditedAt)
+
+	edited := newEditHistoryResp(&editHistoryModel{
+		Editor:      testutil.UID,
+		ContentEdit: dbr.NewNullString(`{"type":1,"content":"修改后"}`),
+		EditedAt:    200,
+	})
+	assert.Equal(t, "修改后", edited.ContentEdit["content"])
+	assert.Equal(t, 200, edited.EditedAt)
+}
+
+func TestMessageEditHistory(t *testing.T) {
+	_, ctx := testutil.NewTestServer()
+	m := New(ctx)
+	tx, err := m.db.session.Begin()
+	assert.NoError(t, err)
+	for i, content := range []string{"第一次修改", "第二次修改"} {
+		contentEdit := dbr.NewNullString(util.ToJson(map[string]interface{}{"type": 1, "content": content}))
+		err = m.messageExtraDB.insertOrUpdateContentEditTx(&messageExtraModel{
+			MessageID:       "1001",
+			MessageSeq:      1,
+			ChannelID:       "g1",
+			ChannelType:     common.ChannelTypeGroup.Uint8(),
+			ContentEdit:     contentEdit,
+			ContentEditHash: content,
+			EditedAt:        i + 1,
+			Version:         int64(i + 1),
+		}, tx)
+		assert.NoError(t, err)
+		err = m.editHistoryDB.insertTx(&editHistoryModel{
+			MessageID:       "1001",
+			ChannelID:       "g1",
+			ChannelType:     common.ChannelTypeGroup.Uint8(),
+			Editor:          testutil.UID,
+			ContentEdit:     contentEdit,
+			ContentEditHash: content,
+			EditedAt:        i + 1,
+		}, tx)
+		assert.NoError(t, err)
+	}
+	// 替换违禁词不算用户编辑
+	err = m.messageExtraDB.insertOrUpdateMaskedContentTx(&messageExtraModel{
+		MessageID:       "1001",
+		MessageSeq:      1,
+		ChannelID:       "g1",
+		ChannelType:     common.ChannelTypeGroup.Uint8(),
+		ContentEdit:     dbr.NewNullString(`{"type":1,"content":"**"}`),
+		ContentEditHash: "masked",
+		Version:         3,
+	}, tx)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit())
+
+	messageExtra, err := m.messageExtraDB.queryWithMessageID("1001")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, messageExtra.EditCount)
+
+	// 编辑历史按编辑先后排序
+	models, err := m.editHistoryDB.queryWithMessageID("1001")
+	assert.NoError(t, err)
+	assert.Len(t, models, 2)
+	assert.Equal(t, "第一次修改", newEditHistoryResp(models[0]).ContentEdit["content"])
+	assert.Equal(t, "第二次修改", newEditHistoryResp(models[1]).ContentEdit["content"])
+}
+
+func TestEscapeLikeKeyword(t *testing.T) {
+	assert.Equal(t, "abc", escapeLikeKeyword("abc"))
+	assert.Equal(t, "100\\%", escapeLikeKeyword("100%"))
+	assert.Equal(t, "a\\_b", escapeLikeKeyword("a_b"))
+	assert.Equal(t, "a\\\\b", escapeLikeKeyword("a\\b"))
+}
+
+func TestFavoriteSearchEscape(t *testing.T) {
+	_, ctx := testutil.NewTestServer()
+	m := New(ctx)
+	for i, searchText := range []string{"完成100%", "完成1000", "a_b", "axb"} {
+		err := m.favoriteDB.insertOrUpdate(&favoriteModel{
+			FavoriteNo: fmt.Sprintf("f%d", i),
+			UID:        testutil.UID,
+			MessageID:  fmt.Sprintf("%d", 1001+i),
+			SearchText: searchText,
+			Version:    int64(i + 1),
+		})
+		assert.NoError(t, err)
+	}
+
+	// 关键字中的%和_按普通字符匹配
+	models, err := m.favoriteDB.search(testutil.UID, "%", "", 10, 1)
+	assert.NoError(t, err)
+	assert.Len(t, models, 1)
+	assert.Equal(t, "完成100%", models[0].SearchText)
+	models, err = m.favoriteDB.search(testutil.UID, "_", "", 10, 1)
+	assert.NoError(t, err)
+	assert.Len(t, models, 1)
+	assert.Equal(t, "a_b", models[0].SearchText)
+}
+
+func TestCheckCardShare(t *testing.T) {
+	_, ctx := testutil.NewTestServer()
+	m := New(ctx)
+	userDB := user.NewDB(ctx)
+	err := userDB.Insert(&user.Model{UID: "10001", Name: "允许分享", AllowShareCard: 1})
+	assert.NoError(t, err)
+	err = userDB.Insert(&user.Model{UID: "10002", Name: "不允许分享"})
+	assert.NoError(t, err)
+	cardPayload := func(cardUID string) map[string]interface{} {
+		return map[string]interface{}{"type": common.Card, "uid": cardUID}
+	}
+
+	// 非名片消息不校验
+	assert.NoError(t, m.checkCardShare(testutil.UID, map[string]interface{}{"type": common.Text, "content": "hi"}))
+	// 分享自己的名片不校验
+	assert.NoError(t, m.checkCardShare("10002", cardPayload("10002")))
+	assert.NoError(t, m.checkCardShare(testutil.UID, cardPayload("10001")))
+	err = m.checkCardShare(testutil.UID, cardPayload("10002"))
+	assert.EqualError(t, err, "该用户不允许分享名片")
+	err = m.checkCardShare(testutil.UID, cardPayload(""))
+	assert.EqualError(t, err, "名片用户不能为空")
+}
@@ -0,0 +1,478 @@
+package message
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// 处理话题回复（payload中包含reply的消息为回复消息，话题根消息为reply.root_mid，没有时为被回复的消息）
+func (m *Message) handleThreadReplies(messages []*config.MessageResp) {
+	for _, message := range messages {
+		if message.FromUID == "" || message.Header.NoPersist == 1 || message.Header.SyncOnce == 1 {
+			continue
+		}
+		if message.ChannelType != common.ChannelTypePerson.Uint8() && message.ChannelType != common.ChannelTypeGroup.Uint8() {
+			continue
+		}
+		if config.SettingFromUint8(message.Setting).Signal {
+			continue
+		}
+		payloadMap, err := message.GetPayloadMap()
+		if err != nil || payloadMap == nil {
+			continue
+		}
+		rootMessageID := getThreadRootMessageID(payloadMap)
+		messageID := fmt.Sprintf("%d", message.MessageID)
+		if rootMessageID == "" || rootMessageID == messageID {
+			continue
+		}
+		fakeChannelID := message.ChannelID
+		if message.ChannelType == common.ChannelTypePerson.Uint8() {
+			fakeChannelID = common.GetFakeChannelIDWith(message.FromUID, message.ChannelID)
+		}
+		rootMessage, err := m.db.queryMessageWithMessageID(fakeChannelID, rootMessageID)
+		if err != nil {
+			m.Warn("查询话题根消息失败！", zap.Error(err), zap.String("rootMessageID", rootMessageID))
+			continue
+		}
+		if rootMessage == nil || rootMessage.ChannelID != fakeChannelID || rootMessage.ChannelType != message.ChannelType {
+			continue
+		}
+		err = m.addThreadReply(message, fakeChannelID, rootMessage)
+		if err != nil {
+			m.Error("添加话题回复失败！", zap.Error(err), zap.String("messageID", messageID))
+		}
+	}
+}
+
+// 记录话题回复，更新根消息的回复数量，回复者和根消息的发送者自动关注话题
+func (m *Message) addThreadReply(message *config.MessageResp, fakeChannelID string, rootMessage *messageModel) error {
+	rootMessageID := fmt.Sprintf("%d", rootMessage.MessageID)
+	tx, err := m.db.session.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.RollbackUnlessCommitted()
+			panic(err)
+		}
+	}()
+	inserted, err := m.threadDB.insertReplyTx(&threadReplyModel{
+		RootMessageID: rootMessageID,
+		MessageID:     fmt.Sprintf("%d", message.MessageID),
+		MessageSeq:    message.MessageSeq,
+		ChannelID:     fakeChannelID,
+		ChannelType:   message.ChannelType,
+		FromUID:       message.FromUID,
+	}, tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if !inserted { // 已处理过的回复
+		tx.Rollback()
+		return nil
+	}
+	err = m.messageExtraDB.insertOrIncrReplyCountTx(&messageExtraModel{
+		MessageID:    rootMessageID,
+		MessageSeq:   rootMessage.MessageSeq,
+		ChannelID:    fakeChannelID,
+		ChannelType:  message.ChannelType,
+		LastReplyUID: message.FromUID,
+		LastReplyAt:  int64(message.Timestamp),
+		Version:      m.genMessageExtraSeq(fakeChannelID),
+	}, tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = m.threadDB.insertOrFollowTx(&threadFollowModel{
+		UID:            message.FromUID,
+		RootMessageID:  rootMessageID,
+		RootMessageSeq: rootMessage.MessageSeq,
+		ChannelID:      message.ChannelID,
+		ChannelType:    message.ChannelType,
+		ReadMessageSeq: message.MessageSeq,
+	}, tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if rootMessage.FromUID != "" && rootMessage.FromUID != message.FromUID {
+		channelID := message.ChannelID
+		if message.ChannelType == common.ChannelTypePerson.Uint8() { // 个人频道中根消息发送者视角的频道是回复者
+			channelID = message.FromUID
+		}
+		err = m.threadDB.insertFollowIgnoreTx(&threadFollowModel{
+			UID:            rootMessage.FromUID,
+			RootMessageID:  rootMessageID,
+			RootMessageSeq: rootMessage.MessageSeq,
+			ChannelID:      channelID,
+			ChannelType:    message.ChannelType,
+			ReadMessageSeq: rootMessage.MessageSeq,
+		}, tx)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		tx.RollbackUnlessCommitted()
+		return err
+	}
+	err = m.ctx.SendCMD(config.MsgCMDReq{
+		NoPersist:   true,
+		ChannelID:   message.ChannelID,
+		ChannelType: message.ChannelType,
+		FromUID:     message.FromUID,
+		CMD:         common.CMDSyncMessageExtra,
+	})
+	if err != nil {
+		m.Warn("发送同步消息扩展命令失败！", zap.Error(err))
+	}
+	return nil
+}
+
+// 话题回复被撤回或删除后，减少根消息的回复数量（同一条回复只减一次）
+func removeThreadReplies(ctx *config.Context, messageIDs []string) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+	threadDB := newThreadDB(ctx)
+	messageExtraDB := newMessageExtraDB(ctx)
+	replies, err := threadDB.queryRepliesWithMessageIDs(messageIDs)
+	if err != nil {
+		return err
+	}
+	for _, reply := range replies {
+		tx, err := ctx.DB().Begin()
+		if err != nil {
+			return err
+		}
+		removed, err := threadDB.removeReplyTx(reply.MessageID, tx)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if !removed {
+			tx.Rollback()
+			continue
+		}
+		err = messageExtraDB.decrReplyCountTx(reply.RootMessageID, ctx.GenSeq(fmt.Sprintf("%s:%s", common.MessageExtraSeqKey, reply.ChannelID)), tx)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			tx.RollbackUnlessCommitted()
+			return err
+		}
+	}
+	return nil
+}
+
+// 分页查询话题的回复
+func (m *Message) threadReplies(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	channelID := c.Query("channel_id")
+	channelTypeI, _ := strconv.ParseUint(c.Query("channel_type"), 10, 8)
+	channelType := uint8(channelTypeI)
+	rootMessageID := c.Query("root_message_id")
+	startMessageSeq, _ := strconv.ParseUint(c.Query("start_message_seq"), 10, 32)
+	limit, _ := strconv.ParseUint(c.Query("limit"), 10, 64)
+	if strings.TrimSpace(channelID) == "" {
+		c.ResponseError(errors.New("频道ID不能为空！"))
+		return
+	}
+	if strings.TrimSpace(rootMessageID) == "" {
+		c.ResponseError(errors.New("话题根消息ID不能为空！"))
+		return
+	}
+	if limit <= 0 || limit > ThreadRepliesMaxLimit {
+		limit = ThreadRepliesMaxLimit
+	}
+	rootMessage, _, err := m.getVisibleMessage(loginUID, channelID, channelType, rootMessageID)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	replies, err := m.threadDB.queryRepliesWithRoot(rootMessageID, uint32(startMessageSeq), limit)
+	if err != nil {
+		m.Error("查询话题回复失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询话题回复失败！"))
+		return
+	}
+	list := make([]*threadReplyResp, 0, len(replies))
+	if len(replies) == 0 {
+		c.Response(list)
+		return
+	}
+	messageIDs := make([]string, 0, len(replies))
+	for _, reply := range replies {
+		messageIDs = append(messageIDs, reply.MessageID)
+	}
+	messageModels, err := m.db.queryMessagesWithMessageIDs(rootMessage.ChannelID, messageIDs)
+	if err != nil {
+		m.Error("查询话题回复消息失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询话题回复消息失败！"))
+		return
+	}
+	messageExtras, err := m.messageExtraDB.queryWithMessageIDs(messageIDs)
+	if err != nil {
+		m.Error("查询话题回复消息扩展失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询话题回复消息扩展失败！"))
+		return
+	}
+	c.Response(newThreadReplyResps(replies, messageModels, messageExtras))
+}
+
+// 组装话题回复（过滤已删除和已撤回的回复，编辑过的回复返回编辑后的正文）
+func newThreadReplyResps(replies []*threadReplyModel, messageModels []*messageModel, messageExtras []*messageExtraModel) []*threadReplyResp {
+	messageMap := make(map[string]*messageModel, len(messageModels))
+	for _, messageM := range messageModels {
+		messageMap[fmt.Sprintf("%d", messageM.MessageID)] = messageM
+	}
+	extraMap := make(map[string]*messageExtraModel, len(messageExtras))
+	for _, extra := range messageExtras {
+		extraMap[extra.MessageID] = extra
+	}
+	list := make([]*threadReplyResp, 0, len(replies))
+	for _, reply := range replies {
+		messageM := messageMap[reply.MessageID]
+		if messageM == nil || messageM.IsDeleted == 1 {
+			continue
+		}
+		resp := newThreadReplyResp(messageM)
+		if extra := extraMap[reply.MessageID]; extra != nil {
+			if extra.Revoke == 1 || extra.IsDeleted == 1 {
+				continue
+			}
+			if extra.ContentEdit.String != "" {
+				var contentEditMap map[string]interface{}
+				if err := util.ReadJsonByByte([]byte(extra.ContentEdit.String), &contentEditMap); err == nil {
+					resp.Payload = contentEditMap
+				}
+			}
+		}
+		list = append(list, resp)
+	}
+	return list
+}
+
+// 关注话题
+func (m *Message) threadFollow(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	var req struct {
+		ChannelID     string `json:"channel_id"`      // 频道ID
+		ChannelType   uint8  `json:"channel_type"`    // 频道类型
+		RootMessageID string `json:"root_message_id"` // 话题根消息ID
+	}
+	if err := c.BindJSON(&req); err != nil {
+		m.Error(common.ErrData.Error(), zap.Error(err))
+		c.ResponseError(common.ErrData)
+		return
+	}
+	if strings.TrimSpace(req.RootMessageID) == "" {
+		c.ResponseError(errors.New("话题根消息ID不能为空！"))
+		return
+	}
+	rootMessage, _, err := m.getVisibleMessage(loginUID, req.ChannelID, req.ChannelType, req.RootMessageID)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	tx, err := m.db.session.Begin()
+	if err != nil {
+		m.Error("开启事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("开启事务失败！"))
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.RollbackUnlessCommitted()
+			panic(err)
+		}
+	}()
+	err = m.threadDB.insertOrFollowTx(&threadFollowModel{
+		UID:            loginUID,
+		RootMessageID:  req.RootMessageID,
+		RootMessageSeq: rootMessage.MessageSeq,
+		ChannelID:      req.ChannelID,
+		ChannelType:    req.ChannelType,
+		ReadMessageSeq: rootMessage.MessageSeq,
+	}, tx)
+	if err != nil {
+		tx.Rollback()
+		m.Error("关注话题失败！", zap.Error(err))
+		c.ResponseError(errors.New("关注话题失败！"))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tx.RollbackUnlessCommitted()
+		m.Error("提交事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("提交事务失败！"))
+		return
+	}
+	m.sendThreadFollowSyncCMD(loginUID)
+	c.ResponseOK()
+}
+
+// 取消关注话题
+func (m *Message) threadUnfollow(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	rootMessageID := c.Query("root_message_id")
+	if strings.TrimSpace(rootMessageID) == "" {
+		c.ResponseError(errors.New("话题根消息ID不能为空！"))
+		return
+	}
+	err := m.threadDB.unfollow(loginUID, rootMessageID)
+	if err != nil {
+		m.Error("取消关注话题失败！", zap.Error(err))
+		c.ResponseError(errors.New("取消关注话题失败！"))
+		return
+	}
+	m.sendThreadFollowSyncCMD(loginUID)
+	c.ResponseOK()
+}
+
+// 话题回复已读
+func (m *Message) threadRead(c *wkhttp.Context) {
+	loginUID := c.GetLoginUID()
+	var req struct {
+		RootMessageID string `json:"root_message_id"` // 话题根消息ID
+		MessageSeq    uint32 `json:"message_seq"`     // 已读到的回复消息序列号
+	}
+	if err := c.BindJSON(&req); err != nil {
+		m.Error(common.ErrData.Error(), zap.Error(err))
+		c.ResponseError(common.ErrData)
+		return
+	}
+	followM, err := m.threadDB.queryFollowWithUIDAndRoot(loginUID, req.RootMessageID)
+	if err != nil {
+		m.Error("查询关注的话题失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询关注的话题失败！"))
+		return
+	}
+	if followM == nil || followM.Followed == 0 {
+		c.ResponseError(errors.New("未关注该话题！"))
+		return
+	}
+	if req.MessageSeq <= followM.ReadMessageSeq {
+		c.ResponseOK()
+		return
+	}
+	err = m.threadDB.updateReadMessageSeq(loginUID, req.RootMessageID, req.MessageSeq)
+	if err != nil {
+		m.Error("更新话题已读失败！", zap.Error(err))
+		c.ResponseError(errors.New("更新话题已读失败！"))
+		return
+	}
+	m.sendThreadFollowSyncCMD(loginUID)
+	c.ResponseOK()
+}
+
+// 关注的话题（按最后回复时间倒序，包含未读回复数量）
+func (m *Message) threadFollowed(c *wkhttp.Context) {
+	pageIndex, pageSize := c.GetPage()
+	models, err := m.threadDB.queryFollowedWithUID(c.GetLoginUID(), uint64(pageSize), uint64(pageIndex))
+	if err != nil {
+		m.Error("查询关注的话题失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询关注的话题失败！"))
+		return
+	}
+	list := make([]*threadFollowResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newThreadFollowResp(model))
+	}
+	c.Response(list)
+}
+
+// 通知用户的其他设备同步关注的话题
+func (m *Message) sendThreadFollowSyncCMD(uid string) {
+	err := m.ctx.SendCMD(config.MsgCMDReq{
+		NoPersist:   true,
+		ChannelID:   uid,
+		ChannelType: common.ChannelTypePerson.Uint8(),
+		CMD:         CMDSyncThreadFollow,
+	})
+	if err != nil {
+		m.Warn("发送同步关注话题命令失败！", zap.Error(err))
+	}
+}
+
+// 获取回复消息所属话题的根消息ID
+func getThreadRootMessageID(payload map[string]interface{}) string {
+	reply, _ := payload["reply"].(map[string]interface{})
+	if reply == nil {
+		return ""
+	}
+	for _, key := range []string{"root_mid", "message_id"} {
+		switch value := reply[key].(type) {
+		case string:
+			if value != "" {
+				return value
+			}
+		case json.Number:
+			return value.String()
+		}
+	}
+	return ""
+}
+
+type threadReplyResp struct {
+	MessageID  string                 `json:"message_id"`  // 回复消息ID
+	MessageSeq uint32                 `json:"message_seq"` // 回复消息序列号
+	FromUID    string                 `json:"from_uid"`    // 回复者uid
+	Timestamp  int64                  `json:"timestamp"`   // 回复时间
+	Payload    map[string]interface{} `json:"payload"`     // 回复内容
+}
+
+func newThreadReplyResp(m *messageModel) *threadReplyResp {
+	var payloadMap map[string]interface{}
+	if len(m.Payload) > 0 {
+		_ = util.ReadJsonByByte(m.Payload, &payloadMap)
+	}
+	return &threadReplyResp{
+		MessageID:  fmt.Sprintf("%d", m.MessageID),
+		MessageSeq: m.MessageSeq,
+		FromUID:    m.FromUID,
+		Timestamp:  m.Timestamp,
+		Payload:    payloadMap,
+	}
+}
+
+type threadFollowResp struct {
+	RootMessageID  string `json:"root_message_id"`  // 话题根消息ID
+	RootMessageSeq uint32 `json:"root_message_seq"` // 话题根消息序列号
+	ChannelID      string `json:"channel_id"`       // 频道ID
+	ChannelType    uint8  `json:"channel_type"`     // 频道类型
+	ReplyCount     int    `json:"reply_count"`      // 回复数量
+	LastReplyUID   string `json:"last_reply_uid"`   // 最后回复者uid
+	LastReplyAt    int64  `json:"last_reply_at"`    // 最后回复时间
+	ReadMessageSeq uint32 `json:"read_message_seq"` // 已读到的回复消息序列号
+	UnreadCount    int    `json:"unread_count"`     // 未读回复数量
+}
+
+func newThreadFollowResp(m *threadFollowDetailModel) *threadFollowResp {
+	return &threadFollowResp{
+		RootMessageID:  m.RootMessageID,
+		RootMessageSeq: m.RootMessageSeq,
+		ChannelID:      m.ChannelID,
+		ChannelType:    m.ChannelType,
+		ReplyCount:     m.ReplyCount,
+		LastReplyUID:   m.LastReplyUID,
+		LastReplyAt:    m.LastReplyAt,
+		ReadMessageSeq: m.ReadMessageSeq,
+		UnreadCount:    m.UnreadCount,
+	}
+}
@@ -0,0 +1,98 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/testutil"
+	"github.com/gocraft/dbr/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewThreadReplyResps(t *testing.T) {
+	replies := []*threadReplyModel{
+		{RootMessageID: "100", MessageID: "101", MessageSeq: 2},
+		{RootMessageID: "100", MessageID: "102", MessageSeq: 3},
+		{RootMessageID: "100", MessageID: "103", MessageSeq: 4},
+		{RootMessageID: "100", MessageID: "104", MessageSeq: 5},
+	}
+	payload := func(content string) []byte {
+		return []byte(util.ToJson(map[string]interface{}{"type": 1, "content": content}))
+	}
+	messageModels := []*messageModel{
+		{MessageID: 101, MessageSeq: 2, Payload: payload("回复一")},
+		{MessageID: 102, MessageSeq: 3, Payload: payload("回复二")},
+		{MessageID: 103, MessageSeq: 4, Payload: payload("回复三")},
+		{MessageID: 104, MessageSeq: 5, Payload: payload("回复四")},
+	}
+	messageExtras := []*messageExtraModel{
+		{MessageID: "102", Revoke: 1},
+		{MessageID: "103", IsDeleted: 1},
+		{MessageID: "104", ContentEdit: dbr.NewNullString(util.ToJson(map[string]interface{}{"type": 1, "content": "回复四（已编辑）"}))},
+	}
+
+	list := newThreadReplyResps(replies, messageModels, messageExtras)
+	assert.Len(t, list, 2)
+	assert.Equal(t, "101", list[0].MessageID)
+	assert.Equal(t, "回复一", list[0].Payload["content"])
+	assert.Equal(t, "104", list[1].MessageID)
+	assert.Equal(t, "回复四（已编辑）", list[1].Payload["content"])
+
+	// 撤回回复后不再返回
+	messageExtras = append(messageExtras, &messageExtraModel{MessageID: "101", Revoke: 1})
+	list = newThreadReplyResps(replies, messageModels, messageExtras)
+	assert.Len(t, list, 1)
+	assert.Equal(t, "104", list[0].MessageID)
+}
+
+func TestRemoveThreadReplies(t *testing.T) {
+	_, ctx := testutil.NewTestServer()
+	m := New(ctx)
+	tx, err := m.db.session.Begin()
+	assert.NoError(t, err)
+	for i, messageID := range []string{"101", "102"} {
+		_, err = m.threadDB.insertReplyTx(&threadReplyModel{
+			RootMessageID: "100",
+			MessageID:     messageID,
+			MessageSeq:    uint32(i + 2),
+			ChannelID:     "g1",
+			ChannelType:   common.ChannelTypeGroup.Uint8(),
+			FromUID:       testutil.UID,
+		}, tx)
+		assert.NoError(t, err)
+		err = m.messageExtraDB.insertOrIncrReplyCountTx(&messageExtraModel{
+			MessageID:    "100",
+			MessageSeq:   1,
+			ChannelID:    "g1",
+			ChannelType:  common.ChannelTypeGroup.Uint8(),
+			LastReplyUID: testutil.UID,
+			Version:      int64(i + 1),
+		}, tx)
+		assert.NoError(t, err)
+	}
+	err = m.threadDB.insertOrFollowTx(&threadFollowModel{
+		UID:            "10001",
+		RootMessageID:  "100",
+		RootMessageSeq: 1,
+		ChannelID:      "g1",
+		ChannelType:    common.ChannelTypeGroup.Uint8(),
+		ReadMessageSeq: 1,
+	}, tx)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit())
+
+	// 同一条回复重复撤回或删除只减一次
+	assert.NoError(t, removeThreadReplies(ctx, []string{"101"}))
+	assert.NoError(t, removeThreadReplies(ctx, []string{"101"}))
+	messageExtra, err := m.messageExtraDB.queryWithMessageID("100")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, messageExtra.ReplyCount)
+
+	// 已移除的回复不计入未读数量
+	follows, err := m.threadDB.queryFollowedWithUID("10001", 10, 1)
+	assert.NoError(t, err)
+	assert.Len(t, follows, 1)
+	assert.Equal(t, 1, follows[0].ReplyCount)
+	assert.Equal(t, 1, follows[0].UnreadCount)
+}
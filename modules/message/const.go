@@ -1,19 +1,138 @@
 package message
 
+import "time"
+
 const (
 	// 消息已删除
 	CMDMessageDeleted = "messageDeleted"
 	// CMDMessageErase 消息擦除
-	CMDMessageErase       = "messageEerase"
-	sensitiveWordsVersion = 1
+	CMDMessageErase = "messageEerase"
+	// CMDScheduledMessageUpdate 定时消息状态变更（已发送或发送失败）
+	CMDScheduledMessageUpdate = "scheduledMessageUpdate"
+	sensitiveWordsVersion     = 1
 )
 const CacheReadedCountPrefix = "readedCount:" // 消息已读数量
 
+// SearchTotalHeader 搜索消息的总数量（响应体为消息数组，总数量通过响应头返回）
+const SearchTotalHeader = "X-Total-Count"
+
+const (
+	// StatusMessageRevokeExpired 消息已超过可撤回时长（客户端据此隐藏撤回操作）
+	StatusMessageRevokeExpired = 1101
+	// StatusMessageEditExpired 消息已超过可编辑时长（与撤回时长一致）
+	StatusMessageEditExpired = 1102
+)
+
 type ReminderType int
 
 const (
 	ReminderTypeMentionMe      = 1 // 有人@我
 	ReminderTypeApplyJoinGroup = 2 // 申请加群
+	ReminderTypeCustom         = 3 // 用户自定义的消息提醒（到提醒时间后由系统账号通知）
+)
+
+const (
+	// ReminderCustomMaxDelay 自定义提醒最长可延后的时间
+	ReminderCustomMaxDelay = time.Hour * 24 * 365
+	// ReminderCustomMaxPending 每个用户最多的未到期自定义提醒数量
+	ReminderCustomMaxPending = 100
+	// ReminderCustomLeaseCacheKey 自定义提醒调度租约（多实例部署时只有持有租约的实例执行提醒）
+	ReminderCustomLeaseCacheKey = "customReminderLease"
+)
+
+// ScheduledMessageSource 定时消息来源
+type ScheduledMessageSource int
+
+const (
+	ScheduledMessageSourceUser    ScheduledMessageSource = 0 // 用户
+	ScheduledMessageSourceManager ScheduledMessageSource = 1 // 后台
+	ScheduledMessageSourceRobot   ScheduledMessageSource = 2 // 机器人
+)
+
+const (
+	ScheduledMessageStatusPending  = 0 // 待发送
+	ScheduledMessageStatusSending  = 1 // 发送中
+	ScheduledMessageStatusSent     = 2 // 已发送
+	ScheduledMessageStatusCanceled = 3 // 已取消
+	ScheduledMessageStatusFailed   = 4 // 发送失败
+)
+
+const (
+	// ScheduledMessageMaxDelay 定时消息最长可延后发送的时间
+	ScheduledMessageMaxDelay = time.Hour * 24 * 30
+	// ScheduledMessageMaxPending 每个用户最多的待发送定时消息数量
+	ScheduledMessageMaxPending = 100
+	// ScheduledMessageLeaseCacheKey 定时消息调度租约（多实例部署时只有持有租约的实例执行发送）
+	ScheduledMessageLeaseCacheKey = "scheduledMessageLease"
+	// ScheduledMessageLeaseTTL 调度租约有效期
+	ScheduledMessageLeaseTTL = time.Second * 30
+	// ScheduledMessageSendingTimeout 发送中状态超过此时间视为发送中断
+	ScheduledMessageSendingTimeout = time.Minute * 5
+)
+
+// 违禁词命中后的处理方式
+const (
+	ProhibitWordActionMask   = 0 // 替换为*
+	ProhibitWordActionBlock  = 1 // 拦截（服务端发送的消息直接拒绝，客户端发送的消息撤回）
+	ProhibitWordActionReview = 2 // 标记待审核
+)
+
+// 违禁词审核状态
+const (
+	ModerationStatusPending  = 0 // 待审核
+	ModerationStatusApproved = 1 // 审核通过（保留消息）
+	ModerationStatusRemoved  = 2 // 已撤回消息
+)
+
+// ContentTypePoll 投票消息
+const ContentTypePoll = 17
+
+// 投票状态
+const (
+	PollStatusOpen   = 0 // 进行中
+	PollStatusClosed = 1 // 已结束
+)
+
+const (
+	// PollMinOptions 投票最少的选项数量
+	PollMinOptions = 2
+	// PollMaxOptions 投票最多的选项数量
+	PollMaxOptions = 20
+	// PollOptionMaxLength 选项最大字符数
+	PollOptionMaxLength = 100
+	// PollQuestionMaxLength 投票问题最大字符数
+	PollQuestionMaxLength = 255
+	// PollMaxDuration 投票截止时间最长可延后的时间
+	PollMaxDuration = time.Hour * 24 * 30
+	// PollUpdateMessageRetries 投票关联消息失败时的重试次数
+	PollUpdateMessageRetries = 3
+	// PollDeadlineLeaseCacheKey 投票截止调度租约（多实例部署时只有持有租约的实例结束到期的投票）
+	PollDeadlineLeaseCacheKey = "pollDeadlineLease"
+)
+
+const (
+	// CMDSyncThreadFollow 关注的话题有变更（同步到用户的其他设备）
+	CMDSyncThreadFollow = "syncThreadFollow"
+	// ThreadRepliesMaxLimit 每次查询话题回复的最大数量
+	ThreadRepliesMaxLimit = 100
+)
+
+// ProhibitWordsReloadInterval 违禁词变更检查间隔（其他实例修改违禁词后最迟在此间隔后生效）
+const ProhibitWordsReloadInterval = time.Second * 10
+
+const (
+	// CMDSyncFavorite 收藏有变更（同步到收藏者的其他设备）
+	CMDSyncFavorite = "syncFavorite"
+	// FavoriteSeqKey 收藏同步版本号
+	FavoriteSeqKey = "messageFavorite"
+	// FavoriteMaxTags 每条收藏最多的标签数量
+	FavoriteMaxTags = 10
+	// FavoriteTagMaxLength 标签最大字符数
+	FavoriteTagMaxLength = 20
+	// FavoriteFullTextMinLength 使用全文索引搜索的最小关键字长度（与MySQL ngram_token_size一致）
+	FavoriteFullTextMinLength = 2
+	// FavoriteSyncMaxLimit 每次同步最多返回的收藏数量
+	FavoriteSyncMaxLimit = 500
 )
 
 var sensitive_words = []string{
@@ -57,6 +57,20 @@ func (d *DB) queryProhibitWordsWithVersion(version int64) ([]*ProhibitWordModel,
 	return list, err
 }
 
+// 违禁词的最大版本号（增删违禁词都会修改版本号）
+func (d *DB) queryProhibitWordsMaxVersion() (int64, error) {
+	var version int64
+	err := d.session.Select("IFNULL(max(`version`),0)").From("prohibit_words").LoadOne(&version)
+	return version, err
+}
+
+// 查询所有有效的违禁词
+func (d *DB) queryValidProhibitWords() ([]*ProhibitWordModel, error) {
+	var list []*ProhibitWordModel
+	_, err := d.session.Select("*").From("prohibit_words").Where("is_deleted=0").Load(&list)
+	return list, err
+}
+
 // 新增消息
 func (d *DB) insertMessage(m *messageModel) error {
 	_, err := d.session.InsertInto(d.getTable(m.ChannelID)).Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
@@ -75,6 +89,7 @@ func (d *DB) getTable(channelID string) string {
 // ProhibitWordModel 违禁词model
 type ProhibitWordModel struct {
 	Content   string
+	Action    int // 命中后的处理方式
 	IsDeleted int
 	Version   int64
 	db.BaseModel
@@ -50,6 +50,12 @@ func (c *channelOffsetDB) queryWithUIDAndChannelIDs(uid string, channelIDs []str
 	return models, err
 }
 
+func (c *channelOffsetDB) queryWithUID(uid string) ([]*channelOffsetModel, error) {
+	var models []*channelOffsetModel
+	_, err := c.session.Select("channel_id,channel_type,max(message_seq) message_seq").From(c.getTable(uid)).Where("(uid=? or uid='') and message_seq>0", uid).GroupBy("channel_id", "channel_type").Load(&models)
+	return models, err
+}
+
 func (c *channelOffsetDB) getTable(uid string) string {
 	tableIndex := crc32.ChecksumIEEE([]byte(uid)) % uint32(c.ctx.GetConfig().TablePartitionConfig.ChannelOffsetTableCount)
 	if tableIndex == 0 {
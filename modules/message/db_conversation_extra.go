@@ -39,6 +39,11 @@ func (c *conversationExtraDB) queryWithChannelIDs(uid string, channelIDs []strin
 	return models, err
 }
 
+func (c *conversationExtraDB) deleteWithChannel(channelID string, channelType uint8) error {
+	_, err := c.session.DeleteFrom("conversation_extra").Where("channel_id=? and channel_type=?", channelID, channelType).Exec()
+	return err
+}
+
 type conversationExtraModel struct {
 	UID            string
 	ChannelID      string
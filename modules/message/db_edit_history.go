@@ -0,0 +1,43 @@
+package message
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+type editHistoryDB struct {
+	ctx     *config.Context
+	session *dbr.Session
+}
+
+func newEditHistoryDB(ctx *config.Context) *editHistoryDB {
+	return &editHistoryDB{
+		ctx:     ctx,
+		session: ctx.DB(),
+	}
+}
+
+func (d *editHistoryDB) insertTx(m *editHistoryModel, tx *dbr.Tx) error {
+	_, err := tx.InsertInto("message_edit_history").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+// 查询消息的编辑历史（按编辑先后排序）
+func (d *editHistoryDB) queryWithMessageID(messageID string) ([]*editHistoryModel, error) {
+	var models []*editHistoryModel
+	_, err := d.session.Select("*").From("message_edit_history").Where("message_id=?", messageID).OrderAsc("id").Load(&models)
+	return models, err
+}
+
+type editHistoryModel struct {
+	MessageID       string
+	ChannelID       string
+	ChannelType     uint8
+	Editor          string         // 编辑者uid
+	ContentEdit     dbr.NullString // 编辑后的正文
+	ContentEditHash string
+	EditedAt        int // 编辑时间 时间戳（秒）
+	db.BaseModel
+}
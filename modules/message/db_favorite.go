@@ -0,0 +1,110 @@
+package message
+
+import (
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/gocraft/dbr/v2"
+)
+
+type favoriteDB struct {
+	ctx     *config.Context
+	session *dbr.Session
+}
+
+func newFavoriteDB(ctx *config.Context) *favoriteDB {
+	return &favoriteDB{
+		ctx:     ctx,
+		session: ctx.DB(),
+	}
+}
+
+// 添加收藏（已收藏过的消息重新收藏时更新快照并恢复，收藏编号不变）
+func (d *favoriteDB) insertOrUpdate(m *favoriteModel) error {
+	_, err := d.session.InsertBySql("INSERT INTO message_favorite (favorite_no,uid,message_id,message_seq,channel_id,channel_type,from_uid,from_name,payload,search_text,tags,message_timestamp,is_deleted,version) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,0,?) ON DUPLICATE KEY UPDATE message_seq=VALUES(message_seq),channel_id=VALUES(channel_id),channel_type=VALUES(channel_type),from_uid=VALUES(from_uid),from_name=VALUES(from_name),payload=VALUES(payload),search_text=VALUES(search_text),tags=VALUES(tags),message_timestamp=VALUES(message_timestamp),is_deleted=0,version=VALUES(version)", m.FavoriteNo, m.UID, m.MessageID, m.MessageSeq, m.ChannelID, m.ChannelType, m.FromUID, m.FromName, m.Payload, m.SearchText, m.Tags, m.MessageTimestamp, m.Version).Exec()
+	return err
+}
+
+func (d *favoriteDB) queryWithUIDAndMessageID(uid string, messageID string) (*favoriteModel, error) {
+	var model *favoriteModel
+	_, err := d.session.Select("*").From("message_favorite").Where("uid=? and message_id=?", uid, messageID).Load(&model)
+	return model, err
+}
+
+func (d *favoriteDB) queryWithFavoriteNo(favoriteNo string) (*favoriteModel, error) {
+	var model *favoriteModel
+	_, err := d.session.Select("*").From("message_favorite").Where("favorite_no=?", favoriteNo).Load(&model)
+	return model, err
+}
+
+// 查询大于指定版本的收藏（包含已删除的，客户端据此删除本地收藏）
+func (d *favoriteDB) queryWithUIDAndVersion(uid string, version int64, limit uint64) ([]*favoriteModel, error) {
+	var models []*favoriteModel
+	_, err := d.session.Select("*").From("message_favorite").Where("uid=? and version>?", uid, version).OrderDir("version", true).Limit(limit).Load(&models)
+	return models, err
+}
+
+// 搜索收藏（keyword为空时不按内容过滤，tag为空时不按标签过滤）
+func (d *favoriteDB) search(uid string, keyword string, tag string, pageSize, page uint64) ([]*favoriteModel, error) {
+	var models []*favoriteModel
+	builder := d.session.Select("*").From("message_favorite").Where("uid=? and is_deleted=0", uid)
+	if keyword != "" {
+		if len([]rune(keyword)) < FavoriteFullTextMinLength { // 全文索引按ngram分词，太短的关键字匹配不到
+			builder = builder.Where("search_text like ? ESCAPE '\\\\'", "%"+escapeLikeKeyword(keyword)+"%")
+		} else {
+			builder = builder.Where("MATCH(search_text) AGAINST(? IN BOOLEAN MODE)", "\""+keyword+"\"")
+		}
+	}
+	if tag != "" {
+		builder = builder.Where("FIND_IN_SET(?,tags)", tag)
+	}
+	_, err := builder.Offset((page-1)*pageSize).Limit(pageSize).OrderDir("id", false).Load(&models)
+	return models, err
+}
+
+// 转义like中的通配符，关键字中的%和_按普通字符匹配
+func escapeLikeKeyword(keyword string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(keyword)
+}
+
+// 查询用户所有未删除收藏的标签
+func (d *favoriteDB) queryTagsWithUID(uid string) ([]string, error) {
+	var tags []string
+	_, err := d.session.Select("tags").From("message_favorite").Where("uid=? and is_deleted=0 and tags<>''", uid).Load(&tags)
+	return tags, err
+}
+
+func (d *favoriteDB) updateTags(favoriteNo string, tags string, version int64) error {
+	_, err := d.session.Update("message_favorite").SetMap(map[string]interface{}{
+		"tags":    tags,
+		"version": version,
+	}).Where("favorite_no=?", favoriteNo).Exec()
+	return err
+}
+
+func (d *favoriteDB) delete(favoriteNo string, version int64) error {
+	_, err := d.session.Update("message_favorite").SetMap(map[string]interface{}{
+		"is_deleted": 1,
+		"version":    version,
+	}).Where("favorite_no=?", favoriteNo).Exec()
+	return err
+}
+
+type favoriteModel struct {
+	FavoriteNo       string
+	UID              string
+	MessageID        string
+	MessageSeq       uint32
+	ChannelID        string
+	ChannelType      uint8
+	FromUID          string
+	FromName         string
+	Payload          string
+	SearchText       string
+	Tags             string
+	MessageTimestamp int64
+	IsDeleted        int
+	Version          int64
+	db.BaseModel
+}
@@ -76,6 +76,7 @@ func (m *managerDB) updateProhibitWord(word *prohibitWordsModel) error {
 	_, err := m.session.Update("prohibit_words").SetMap(map[string]interface{}{
 		"version":    word.Version,
 		"is_deleted": word.IsDeleted,
+		"action":     word.Action,
 	}).Where("content=?", word.Content).Exec()
 	return err
 }
@@ -114,6 +115,7 @@ func (m *managerDB) updateMsgExtraVersionAndDeletedTx(md *messageExtraModel, tx
 
 type prohibitWordsModel struct {
 	Content   string
+	Action    int // 命中后的处理方式
 	IsDeleted int
 	Version   int64
 	db.BaseModel
@@ -37,7 +37,18 @@ func (m *messageExtraDB) updateTx(md *messageExtraModel, tx *dbr.Tx) error {
 }
 
 func (m *messageExtraDB) insertOrUpdateContentEditTx(md *messageExtraModel, tx *dbr.Tx) error {
-	_, err := tx.InsertBySql("INSERT INTO message_extra (message_id,message_seq,channel_id,channel_type,content_edit,content_edit_hash,edited_at,version) VALUES (?,?,?,?,?,?,?,?) ON DUPLICATE KEY UPDATE content_edit=VALUES(content_edit),content_edit_hash=VALUES(content_edit_hash),edited_at=VALUES(edited_at),version=VALUES(version)", md.MessageID, md.MessageSeq, md.ChannelID, md.ChannelType, md.ContentEdit, md.ContentEditHash, md.EditedAt, md.Version).Exec()
+	_, err := tx.InsertBySql("INSERT INTO message_extra (message_id,message_seq,channel_id,channel_type,content_edit,content_edit_hash,edited_at,edit_count,version) VALUES (?,?,?,?,?,?,?,1,?) ON DUPLICATE KEY UPDATE content_edit=VALUES(content_edit),content_edit_hash=VALUES(content_edit_hash),edited_at=VALUES(edited_at),edit_count=edit_count+1,version=VALUES(version)", md.MessageID, md.MessageSeq, md.ChannelID, md.ChannelType, md.ContentEdit, md.ContentEditHash, md.EditedAt, md.Version).Exec()
+	return err
+}
+
+// 替换正文中的违禁词（不是用户编辑，不修改编辑时间和编辑次数）
+func (m *messageExtraDB) insertOrUpdateMaskedContentTx(md *messageExtraModel, tx *dbr.Tx) error {
+	_, err := tx.InsertBySql("INSERT INTO message_extra (message_id,message_seq,channel_id,channel_type,content_edit,content_edit_hash,version) VALUES (?,?,?,?,?,?,?) ON DUPLICATE KEY UPDATE content_edit=VALUES(content_edit),content_edit_hash=VALUES(content_edit_hash),version=VALUES(version)", md.MessageID, md.MessageSeq, md.ChannelID, md.ChannelType, md.ContentEdit, md.ContentEditHash, md.Version).Exec()
+	return err
+}
+
+func (m *messageExtraDB) insertOrUpdateRevoke(md *messageExtraModel) error {
+	_, err := m.session.InsertBySql("INSERT INTO message_extra (message_id,message_seq,channel_id,channel_type,`revoke`,revoker,version) VALUES (?,?,?,?,?,?,?) ON DUPLICATE KEY UPDATE `revoke`=VALUES(`revoke`),revoker=VALUES(revoker),version=VALUES(version)", md.MessageID, md.MessageSeq, md.ChannelID, md.ChannelType, md.Revoke, md.Revoker, md.Version).Exec()
 	return err
 }
 
@@ -46,6 +57,23 @@ func (m *messageExtraDB) insertOrUpdatePinnedTx(md *messageExtraModel, tx *dbr.T
 	return err
 }
 
+func (m *messageExtraDB) insertOrUpdatePollResultTx(md *messageExtraModel, tx *dbr.Tx) error {
+	_, err := tx.InsertBySql("INSERT INTO message_extra (message_id,message_seq,channel_id,channel_type,poll_result,version) VALUES (?,?,?,?,?,?) ON DUPLICATE KEY UPDATE poll_result=VALUES(poll_result),version=VALUES(version)", md.MessageID, md.MessageSeq, md.ChannelID, md.ChannelType, md.PollResult, md.Version).Exec()
+	return err
+}
+
+// 话题根消息的回复数量加一，并记录最后回复信息
+func (m *messageExtraDB) insertOrIncrReplyCountTx(md *messageExtraModel, tx *dbr.Tx) error {
+	_, err := tx.InsertBySql("INSERT INTO message_extra (message_id,message_seq,channel_id,channel_type,reply_count,last_reply_uid,last_reply_at,version) VALUES (?,?,?,?,1,?,?,?) ON DUPLICATE KEY UPDATE reply_count=reply_count+1,last_reply_uid=VALUES(last_reply_uid),last_reply_at=VALUES(last_reply_at),version=VALUES(version)", md.MessageID, md.MessageSeq, md.ChannelID, md.ChannelType, md.LastReplyUID, md.LastReplyAt, md.Version).Exec()
+	return err
+}
+
+// 话题根消息的回复数量减一（回复被撤回或删除）
+func (m *messageExtraDB) decrReplyCountTx(messageID string, version int64, tx *dbr.Tx) error {
+	_, err := tx.Update("message_extra").Set("reply_count", dbr.Expr("GREATEST(reply_count-1,0)")).Set("version", version).Where("message_id=?", messageID).Exec()
+	return err
+}
+
 func (m *messageExtraDB) insertOrUpdateDeleted(md *messageExtraModel) error {
 	_, err := m.session.InsertBySql("INSERT INTO message_extra (message_id,message_seq,channel_id,channel_type,is_deleted,version) VALUES (?,?,?,?,?,?) ON DUPLICATE KEY UPDATE is_deleted=VALUES(is_deleted),version=VALUES(version)", md.MessageID, md.MessageSeq, md.ChannelID, md.ChannelType, md.IsDeleted, md.Version).Exec()
 	return err
@@ -79,6 +107,12 @@ func (m *messageExtraDB) queryWithMessageID(messageID string) (*messageExtraMode
 	return model, err
 }
 
+func (m *messageExtraDB) queryWithMessageIDTx(messageID string, tx *dbr.Tx) (*messageExtraModel, error) {
+	var model *messageExtraModel
+	_, err := tx.Select("*").From("message_extra").Where("message_id=?", messageID).Load(&model)
+	return model, err
+}
+
 func (m *messageExtraDB) sync(version int64, channelID string, channelType uint8, limit uint64, loginUID string) ([]*messageExtraDetailModel, error) {
 	var models []*messageExtraDetailModel
 	selectSql := "message_extra.*,(select count(*) from member_readed where member_readed.message_id=message_extra.message_id and member_readed.uid='" + loginUID + "') readed,(select created_at from member_readed where member_readed.message_id=message_extra.message_id and member_readed.uid='" + loginUID + "') readed_at"
@@ -126,8 +160,13 @@ type messageExtraModel struct {
 	ContentEdit     dbr.NullString // 编辑后的正文
 	ContentEditHash string
 	EditedAt        int // 编辑时间 时间戳（秒）
+	EditCount       int // 编辑次数
 	IsDeleted       int
-	Version         int64 // 数据版本
-	IsPinned        int   // 是否置顶
+	Version         int64          // 数据版本
+	IsPinned        int            // 是否置顶
+	PollResult      dbr.NullString // 投票结果（json）
+	ReplyCount      int            // 话题回复数量
+	LastReplyUID    string         // 最后回复者uid
+	LastReplyAt     int64          // 最后回复时间 时间戳（秒）
 	db.BaseModel
 }
@@ -44,6 +44,19 @@ func (m *messageUserExtraDB) queryWithMessageIDsAndUID(messageIDs []string, uid
 	return models, err
 }
 
+// 查询用户删除的消息ID（指定频道时只查询该频道）
+func (m *messageUserExtraDB) queryDeletedMessageIDsWithUID(uid string, channelID string, channelType uint8) ([]string, error) {
+	var messageIDs []string
+	builder := m.session.Select("message_id").From(m.getTable(uid))
+	if channelID != "" {
+		builder = builder.Where("uid=? and message_is_deleted=1 and channel_id=? and channel_type=?", uid, channelID, channelType)
+	} else {
+		builder = builder.Where("uid=? and message_is_deleted=1", uid)
+	}
+	_, err := builder.Load(&messageIDs)
+	return messageIDs, err
+}
+
 func (m *messageUserExtraDB) getTable(uid string) string {
 	tableIndex := crc32.ChecksumIEEE([]byte(uid)) % uint32(m.ctx.GetConfig().TablePartitionConfig.MessageUserEditTableCount)
 	if tableIndex == 0 {
@@ -0,0 +1,81 @@
+package message
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+type moderationDB struct {
+	ctx     *config.Context
+	session *dbr.Session
+}
+
+func newModerationDB(ctx *config.Context) *moderationDB {
+	return &moderationDB{
+		ctx:     ctx,
+		session: ctx.DB(),
+	}
+}
+
+func (d *moderationDB) insert(m *moderationModel) error {
+	_, err := d.session.InsertInto("message_moderation").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *moderationDB) queryWithModerationNo(moderationNo string) (*moderationModel, error) {
+	var model *moderationModel
+	_, err := d.session.Select("*").From("message_moderation").Where("moderation_no=?", moderationNo).Load(&model)
+	return model, err
+}
+
+// 分页查询审核记录（status小于0时查询所有状态）
+func (d *moderationDB) queryWithStatusPage(status int, pageSize, page uint64) ([]*moderationModel, error) {
+	var models []*moderationModel
+	builder := d.session.Select("*").From("message_moderation")
+	if status >= 0 {
+		builder = builder.Where("status=?", status)
+	}
+	_, err := builder.Offset((page-1)*pageSize).Limit(pageSize).OrderDir("id", false).Load(&models)
+	return models, err
+}
+
+func (d *moderationDB) queryCountWithStatus(status int) (int64, error) {
+	var count int64
+	builder := d.session.Select("count(*)").From("message_moderation")
+	if status >= 0 {
+		builder = builder.Where("status=?", status)
+	}
+	_, err := builder.Load(&count)
+	return count, err
+}
+
+// 处理待审核的记录（只有待审核状态的记录可以处理）
+func (d *moderationDB) handle(moderationNo string, status int, handler string, handledAt int64) (bool, error) {
+	result, err := d.session.Update("message_moderation").SetMap(map[string]interface{}{
+		"status":     status,
+		"handler":    handler,
+		"handled_at": handledAt,
+	}).Where("moderation_no=? and status=?", moderationNo, ModerationStatusPending).Exec()
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+type moderationModel struct {
+	ModerationNo string
+	MessageID    string
+	MessageSeq   uint32
+	FromUID      string
+	ChannelID    string
+	ChannelType  uint8
+	Payload      string // 原始消息内容
+	HitWords     string // 命中的违禁词（逗号分隔）
+	Status       int
+	Handler      string
+	HandledAt    int64
+	db.BaseModel
+}
@@ -29,6 +29,11 @@ func (d *pinnedDB) queryWithMessageIds(channelID string, channelType uint8, mess
 	_, err := d.session.Select("*").From("pinned_message").Where("channel_id=? and channel_type=? and message_id in ?", channelID, channelType, messageIds).Load(&list)
 	return list, err
 }
+func (d *pinnedDB) deleteWithChannel(channelID string, channelType uint8) error {
+	_, err := d.session.DeleteFrom("pinned_message").Where("channel_id=? and channel_type=?", channelID, channelType).Exec()
+	return err
+}
+
 func (d *pinnedDB) insert(m *pinnedMessageModel) error {
 	_, err := d.session.InsertInto("pinned_message").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
 	return err
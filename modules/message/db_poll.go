@@ -0,0 +1,120 @@
+package message
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+type pollDB struct {
+	ctx     *config.Context
+	session *dbr.Session
+}
+
+func newPollDB(ctx *config.Context) *pollDB {
+	return &pollDB{
+		ctx:     ctx,
+		session: ctx.DB(),
+	}
+}
+
+func (d *pollDB) insert(m *pollModel) error {
+	_, err := d.session.InsertInto("message_poll").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *pollDB) updateMessage(pollNo string, messageID string, messageSeq uint32) error {
+	_, err := d.session.Update("message_poll").SetMap(map[string]interface{}{
+		"message_id":  messageID,
+		"message_seq": messageSeq,
+	}).Where("poll_no=?", pollNo).Exec()
+	return err
+}
+
+func (d *pollDB) deleteWithPollNo(pollNo string) error {
+	_, err := d.session.DeleteFrom("message_poll").Where("poll_no=?", pollNo).Exec()
+	return err
+}
+
+func (d *pollDB) queryWithPollNo(pollNo string) (*pollModel, error) {
+	var model *pollModel
+	_, err := d.session.Select("*").From("message_poll").Where("poll_no=?", pollNo).Load(&model)
+	return model, err
+}
+
+// 锁定投票（同一投票的投票和结束操作串行执行，保证写入消息扩展的结果是最新的）
+func (d *pollDB) queryWithPollNoForUpdateTx(pollNo string, tx *dbr.Tx) (*pollModel, error) {
+	var model *pollModel
+	_, err := tx.SelectBySql("select * from message_poll where poll_no=? for update", pollNo).Load(&model)
+	return model, err
+}
+
+// 查询已到截止时间但未结束的投票
+func (d *pollDB) queryExpired(now int64, limit uint64) ([]*pollModel, error) {
+	var models []*pollModel
+	_, err := d.session.Select("*").From("message_poll").Where("status=? and deadline>0 and deadline<=? and message_id<>''", PollStatusOpen, now).OrderAsc("deadline").Limit(limit).Load(&models)
+	return models, err
+}
+
+func (d *pollDB) close(pollNo string, closedAt int64) error {
+	_, err := d.session.Update("message_poll").SetMap(map[string]interface{}{
+		"status":    PollStatusClosed,
+		"closed_at": closedAt,
+	}).Where("poll_no=?", pollNo).Exec()
+	return err
+}
+
+func (d *pollDB) closeTx(pollNo string, closedAt int64, tx *dbr.Tx) error {
+	_, err := tx.Update("message_poll").SetMap(map[string]interface{}{
+		"status":    PollStatusClosed,
+		"closed_at": closedAt,
+	}).Where("poll_no=?", pollNo).Exec()
+	return err
+}
+
+func (d *pollDB) deleteVotesWithUIDTx(pollNo string, uid string, tx *dbr.Tx) error {
+	_, err := tx.DeleteFrom("message_poll_vote").Where("poll_no=? and uid=?", pollNo, uid).Exec()
+	return err
+}
+
+func (d *pollDB) insertVoteTx(m *pollVoteModel, tx *dbr.Tx) error {
+	_, err := tx.InsertInto("message_poll_vote").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *pollDB) queryVotesTx(pollNo string, tx *dbr.Tx) ([]*pollVoteModel, error) {
+	var models []*pollVoteModel
+	_, err := tx.Select("*").From("message_poll_vote").Where("poll_no=?", pollNo).OrderAsc("id").Load(&models)
+	return models, err
+}
+
+func (d *pollDB) queryVotesWithUID(pollNo string, uid string) ([]*pollVoteModel, error) {
+	var models []*pollVoteModel
+	_, err := d.session.Select("*").From("message_poll_vote").Where("poll_no=? and uid=?", pollNo, uid).OrderAsc("option_index").Load(&models)
+	return models, err
+}
+
+type pollModel struct {
+	PollNo      string
+	MessageID   string
+	MessageSeq  uint32
+	ChannelID   string
+	ChannelType uint8
+	Creator     string
+	Question    string
+	Options     string // 选项（json数组）
+	Multiple    int
+	Anonymous   int
+	Deadline    int64
+	Status      int
+	ClosedAt    int64
+	db.BaseModel
+}
+
+type pollVoteModel struct {
+	PollNo      string
+	OptionIndex int
+	UID         string
+	db.BaseModel
+}
@@ -72,6 +72,7 @@ func (r *remindersDB) queryWithUIDAndChannel(uid string, channelID string, chann
 func (r *remindersDB) sync(uid string, version int64, limit uint64, channelIDs []string) ([]*remindersDetailModel, error) {
 	var models []*remindersDetailModel
 	var err error
+	// 未到提醒时间的自定义提醒也会同步（fired_at为0，客户端按remind_at展示待提醒），提醒时更新版本号和fired_at后再次同步
 	if version == 0 {
 		builder := r.session.Select("reminders.*,IF(reminder_done.id is null and reminders.is_deleted=0,0,1) done").From("reminders").LeftJoin("reminder_done", fmt.Sprintf("reminders.id=reminder_done.reminder_id and reminder_done.uid='%s'", uid))
 
@@ -107,6 +108,53 @@ func (r *remindersDB) updateVersionTx(version int64, id int64, tx *dbr.Tx) error
 	return err
 }
 
+// 添加用户自定义提醒
+func (r *remindersDB) insert(m *remindersModel) (int64, error) {
+	result, err := r.session.InsertInto("reminders").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (r *remindersDB) queryWithID(id int64) (*remindersModel, error) {
+	var model *remindersModel
+	_, err := r.session.Select("*").From("reminders").Where("id=?", id).Load(&model)
+	return model, err
+}
+
+// 查询用户未到期的自定义提醒数量
+func (r *remindersDB) queryCustomPendingCountWithUID(uid string) (int64, error) {
+	var count int64
+	_, err := r.session.Select("count(*)").From("reminders").Where("uid=? and reminder_type=? and is_deleted=0 and fired_at=0", uid, ReminderTypeCustom).Load(&count)
+	return count, err
+}
+
+// 查询到期未提醒的自定义提醒
+func (r *remindersDB) queryCustomDue(now int64, limit uint64) ([]*remindersModel, error) {
+	var models []*remindersModel
+	_, err := r.session.Select("*").From("reminders").Where("reminder_type=? and fired_at=0 and remind_at<=? and is_deleted=0", ReminderTypeCustom, now).OrderAsc("remind_at").Limit(limit).Load(&models)
+	return models, err
+}
+
+// 标记自定义提醒已提醒（只有未提醒的可以标记，防止重复提醒）
+func (r *remindersDB) fireCustom(id int64, firedAt int64, version int64) (bool, error) {
+	result, err := r.session.Update("reminders").SetMap(map[string]interface{}{
+		"fired_at": firedAt,
+		"version":  version,
+	}).Where("id=? and fired_at=0 and is_deleted=0", id).Exec()
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+func (r *remindersDB) deleteWithID(id int64, version int64) error {
+	_, err := r.session.Update("reminders").Set("is_deleted", 1).Set("version", version).Where("id=?", id).Exec()
+	return err
+}
+
 type remindersDetailModel struct {
 	Done int
 	remindersModel
@@ -126,5 +174,7 @@ type remindersModel struct {
 	IsLocate     int
 	Version      int64
 	IsDeleted    int
+	RemindAt     int64 // 提醒时间（用户自定义提醒）
+	FiredAt      int64 // 实际提醒时间（用户自定义提醒）
 	db.BaseModel
 }
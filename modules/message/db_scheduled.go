@@ -0,0 +1,143 @@
+package message
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/gocraft/dbr/v2"
+)
+
+type scheduledMessageDB struct {
+	ctx     *config.Context
+	session *dbr.Session
+}
+
+func newScheduledMessageDB(ctx *config.Context) *scheduledMessageDB {
+	return &scheduledMessageDB{
+		ctx:     ctx,
+		session: ctx.DB(),
+	}
+}
+
+func (d *scheduledMessageDB) insert(m *scheduledMessageModel) error {
+	_, err := d.session.InsertInto("scheduled_message").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *scheduledMessageDB) queryWithScheduledNo(scheduledNo string) (*scheduledMessageModel, error) {
+	var model *scheduledMessageModel
+	_, err := d.session.Select("*").From("scheduled_message").Where("scheduled_no=?", scheduledNo).Load(&model)
+	return model, err
+}
+
+// 查询用户在某个频道内待发送和发送失败的定时消息
+func (d *scheduledMessageDB) queryWithCreatorAndChannel(creator string, channelID string, channelType uint8) ([]*scheduledMessageModel, error) {
+	var models []*scheduledMessageModel
+	_, err := d.session.Select("*").From("scheduled_message").Where("creator=? and channel_id=? and channel_type=? and status in ?", creator, channelID, channelType, []int{ScheduledMessageStatusPending, ScheduledMessageStatusFailed}).OrderAsc("send_at").Load(&models)
+	return models, err
+}
+
+func (d *scheduledMessageDB) queryPendingCountWithCreator(creator string) (int64, error) {
+	var count int64
+	_, err := d.session.Select("count(*)").From("scheduled_message").Where("creator=? and status=?", creator, ScheduledMessageStatusPending).Load(&count)
+	return count, err
+}
+
+// 后台分页查询某个来源的定时消息
+func (d *scheduledMessageDB) queryWithSourcePage(source ScheduledMessageSource, pageSize, page uint64) ([]*scheduledMessageModel, error) {
+	var models []*scheduledMessageModel
+	_, err := d.session.Select("*").From("scheduled_message").Where("source=?", source).Offset((page-1)*pageSize).Limit(pageSize).OrderDir("send_at", false).Load(&models)
+	return models, err
+}
+
+func (d *scheduledMessageDB) queryCountWithSource(source ScheduledMessageSource) (int64, error) {
+	var count int64
+	_, err := d.session.Select("count(*)").From("scheduled_message").Where("source=?", source).Load(&count)
+	return count, err
+}
+
+// 修改待发送或发送失败的定时消息（修改后重新进入待发送，已开始发送的不能修改）
+func (d *scheduledMessageDB) updatePending(m *scheduledMessageModel) (bool, error) {
+	result, err := d.session.Update("scheduled_message").SetMap(map[string]interface{}{
+		"payload":     m.Payload,
+		"send_at":     m.SendAt,
+		"status":      ScheduledMessageStatusPending,
+		"fail_reason": "",
+	}).Where("scheduled_no=? and status in ?", m.ScheduledNo, []int{ScheduledMessageStatusPending, ScheduledMessageStatusFailed}).Exec()
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+// 取消定时消息（待发送和发送失败的可以取消）
+func (d *scheduledMessageDB) cancel(scheduledNo string) (bool, error) {
+	result, err := d.session.Update("scheduled_message").Set("status", ScheduledMessageStatusCanceled).Where("scheduled_no=? and status in ?", scheduledNo, []int{ScheduledMessageStatusPending, ScheduledMessageStatusFailed}).Exec()
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+// 查询到达发送时间的定时消息
+func (d *scheduledMessageDB) queryDue(now int64, limit uint64) ([]*scheduledMessageModel, error) {
+	var models []*scheduledMessageModel
+	_, err := d.session.Select("*").From("scheduled_message").Where("status=? and send_at<=?", ScheduledMessageStatusPending, now).OrderAsc("send_at").Limit(limit).Load(&models)
+	return models, err
+}
+
+// 抢占定时消息的发送权（只有状态从待发送改为发送中的实例才能发送，防止重复发送）
+func (d *scheduledMessageDB) claim(id int64, claimedAt int64) (bool, error) {
+	result, err := d.session.Update("scheduled_message").SetMap(map[string]interface{}{
+		"status":     ScheduledMessageStatusSending,
+		"claimed_at": claimedAt,
+	}).Where("id=? and status=?", id, ScheduledMessageStatusPending).Exec()
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+func (d *scheduledMessageDB) updateSent(id int64, sentAt int64) error {
+	_, err := d.session.Update("scheduled_message").SetMap(map[string]interface{}{
+		"status":  ScheduledMessageStatusSent,
+		"sent_at": sentAt,
+	}).Where("id=?", id).Exec()
+	return err
+}
+
+func (d *scheduledMessageDB) updateFailed(id int64, reason string) error {
+	_, err := d.session.Update("scheduled_message").SetMap(map[string]interface{}{
+		"status":      ScheduledMessageStatusFailed,
+		"fail_reason": reason,
+	}).Where("id=?", id).Exec()
+	return err
+}
+
+// 发送中状态超时的定时消息（发送实例中途退出）改为发送失败，避免重复发送
+func (d *scheduledMessageDB) failStaleSending(before int64, reason string) error {
+	_, err := d.session.Update("scheduled_message").SetMap(map[string]interface{}{
+		"status":      ScheduledMessageStatusFailed,
+		"fail_reason": reason,
+	}).Where("status=? and claimed_at<?", ScheduledMessageStatusSending, before).Exec()
+	return err
+}
+
+type scheduledMessageModel struct {
+	ScheduledNo string
+	Source      ScheduledMessageSource
+	Creator     string
+	FromUID     string
+	ChannelID   string
+	ChannelType uint8
+	Payload     string
+	SendAt      int64
+	Status      int
+	FailReason  string
+	ClaimedAt   int64
+	SentAt      int64
+	db.BaseModel
+}
@@ -0,0 +1,118 @@
+package message
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/gocraft/dbr/v2"
+)
+
+type threadDB struct {
+	ctx     *config.Context
+	session *dbr.Session
+}
+
+func newThreadDB(ctx *config.Context) *threadDB {
+	return &threadDB{
+		ctx:     ctx,
+		session: ctx.DB(),
+	}
+}
+
+// 添加话题回复（同一条回复消息重复添加时忽略，返回是否添加成功）
+func (d *threadDB) insertReplyTx(m *threadReplyModel, tx *dbr.Tx) (bool, error) {
+	result, err := tx.InsertBySql("INSERT IGNORE INTO message_thread_reply (root_message_id,message_id,message_seq,channel_id,channel_type,from_uid) VALUES (?,?,?,?,?,?)", m.RootMessageID, m.MessageID, m.MessageSeq, m.ChannelID, m.ChannelType, m.FromUID).Exec()
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+// 分页查询话题回复（按消息序号升序，查询大于startMessageSeq的回复）
+func (d *threadDB) queryRepliesWithRoot(rootMessageID string, startMessageSeq uint32, limit uint64) ([]*threadReplyModel, error) {
+	var models []*threadReplyModel
+	_, err := d.session.Select("*").From("message_thread_reply").Where("root_message_id=? and message_seq>?", rootMessageID, startMessageSeq).OrderAsc("message_seq").Limit(limit).Load(&models)
+	return models, err
+}
+
+// 查询未移除的话题回复
+func (d *threadDB) queryRepliesWithMessageIDs(messageIDs []string) ([]*threadReplyModel, error) {
+	var models []*threadReplyModel
+	_, err := d.session.Select("*").From("message_thread_reply").Where("message_id in ? and is_removed=0", messageIDs).Load(&models)
+	return models, err
+}
+
+// 标记话题回复已移除（返回是否标记成功，已移除的不会重复标记）
+func (d *threadDB) removeReplyTx(messageID string, tx *dbr.Tx) (bool, error) {
+	result, err := tx.Update("message_thread_reply").Set("is_removed", 1).Where("message_id=? and is_removed=0", messageID).Exec()
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+// 关注话题（已取消关注的不会被自动关注）
+func (d *threadDB) insertFollowIgnoreTx(m *threadFollowModel, tx *dbr.Tx) error {
+	_, err := tx.InsertBySql("INSERT IGNORE INTO message_thread_follow (uid,root_message_id,root_message_seq,channel_id,channel_type,followed,read_message_seq) VALUES (?,?,?,?,?,1,?)", m.UID, m.RootMessageID, m.RootMessageSeq, m.ChannelID, m.ChannelType, m.ReadMessageSeq).Exec()
+	return err
+}
+
+// 关注话题（主动关注或回复话题时，已取消关注的重新关注），已读序号只增不减
+func (d *threadDB) insertOrFollowTx(m *threadFollowModel, tx *dbr.Tx) error {
+	_, err := tx.InsertBySql("INSERT INTO message_thread_follow (uid,root_message_id,root_message_seq,channel_id,channel_type,followed,read_message_seq) VALUES (?,?,?,?,?,1,?) ON DUPLICATE KEY UPDATE followed=1,read_message_seq=GREATEST(read_message_seq,VALUES(read_message_seq))", m.UID, m.RootMessageID, m.RootMessageSeq, m.ChannelID, m.ChannelType, m.ReadMessageSeq).Exec()
+	return err
+}
+
+func (d *threadDB) unfollow(uid string, rootMessageID string) error {
+	_, err := d.session.Update("message_thread_follow").Set("followed", 0).Where("uid=? and root_message_id=?", uid, rootMessageID).Exec()
+	return err
+}
+
+func (d *threadDB) updateReadMessageSeq(uid string, rootMessageID string, readMessageSeq uint32) error {
+	_, err := d.session.Update("message_thread_follow").Set("read_message_seq", dbr.Expr("GREATEST(read_message_seq,?)", readMessageSeq)).Where("uid=? and root_message_id=?", uid, rootMessageID).Exec()
+	return err
+}
+
+func (d *threadDB) queryFollowWithUIDAndRoot(uid string, rootMessageID string) (*threadFollowModel, error) {
+	var model *threadFollowModel
+	_, err := d.session.Select("*").From("message_thread_follow").Where("uid=? and root_message_id=?", uid, rootMessageID).Load(&model)
+	return model, err
+}
+
+// 分页查询关注的话题（按最后回复时间倒序，包含回复数量和未读数量）
+func (d *threadDB) queryFollowedWithUID(uid string, pageSize, page uint64) ([]*threadFollowDetailModel, error) {
+	var models []*threadFollowDetailModel
+	_, err := d.session.Select("message_thread_follow.*,IFNULL(message_extra.reply_count,0) reply_count,IFNULL(message_extra.last_reply_uid,'') last_reply_uid,IFNULL(message_extra.last_reply_at,0) last_reply_at,(select count(*) from message_thread_reply where message_thread_reply.root_message_id=message_thread_follow.root_message_id and message_thread_reply.message_seq>message_thread_follow.read_message_seq and message_thread_reply.from_uid<>message_thread_follow.uid and message_thread_reply.is_removed=0) unread_count").From("message_thread_follow").LeftJoin("message_extra", "message_extra.message_id=message_thread_follow.root_message_id").Where("message_thread_follow.uid=? and message_thread_follow.followed=1", uid).OrderDir("last_reply_at", false).Offset((page - 1) * pageSize).Limit(pageSize).Load(&models)
+	return models, err
+}
+
+type threadReplyModel struct {
+	RootMessageID string
+	MessageID     string
+	MessageSeq    uint32
+	ChannelID     string
+	ChannelType   uint8
+	FromUID       string
+	IsRemoved     int // 是否已撤回或删除
+	db.BaseModel
+}
+
+type threadFollowModel struct {
+	UID            string
+	RootMessageID  string
+	RootMessageSeq uint32
+	ChannelID      string
+	ChannelType    uint8
+	Followed       int
+	ReadMessageSeq uint32
+	db.BaseModel
+}
+
+type threadFollowDetailModel struct {
+	threadFollowModel
+	ReplyCount   int    // 回复数量
+	LastReplyUID string // 最后回复者uid
+	LastReplyAt  int64  // 最后回复时间
+	UnreadCount  int    // 未读回复数量
+}
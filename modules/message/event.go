@@ -282,6 +282,104 @@ func (m *Message) handleGroupMemberScanJoinEvent(data []byte, commit config.Even
 		commit(err)
 		return
 	}
+	err = m.groupService.SendWelcome(req.GroupNo, list)
+	if err != nil {
+		m.Warn("发送群欢迎语失败！", zap.Error(err), zap.String("group_no", req.GroupNo))
+	}
+	commit(nil)
+}
+
+// 处理通过群目录入群
+func (m *Message) handleGroupMemberDirectoryJoinEvent(data []byte, commit config.EventCommit) {
+	var req map[string]interface{}
+	err := util.ReadJsonByByte(data, &req)
+	if err != nil {
+		m.Error("解析JSON失败！", zap.Error(err))
+		commit(err)
+		return
+	}
+	groupNo, _ := req["group_no"].(string)
+	uid, _ := req["uid"].(string)
+	name, _ := req["name"].(string)
+	list := []*config.UserBaseVo{
+		{
+			UID:  uid,
+			Name: name,
+		},
+	}
+	err = m.updateMembersChannelOffset(groupNo, list)
+	if err != nil {
+		commit(err)
+		return
+	}
+	err = m.ctx.SendMessage(&config.MsgSendReq{
+		Header: config.MsgHeader{
+			NoPersist: 0,
+			RedDot:    1,
+			SyncOnce:  0, // 只同步一次
+		},
+		ChannelID:   groupNo,
+		ChannelType: common.ChannelTypeGroup.Uint8(),
+		Payload: []byte(util.ToJson(map[string]interface{}{
+			"content": `“{0}”通过群目录加入群聊`,
+			"extra":   list,
+			"type":    common.GroupMemberAdd,
+		}))})
+	if err != nil {
+		commit(err)
+		return
+	}
+	err = m.groupService.SendWelcome(groupNo, list)
+	if err != nil {
+		m.Warn("发送群欢迎语失败！", zap.Error(err), zap.String("group_no", groupNo))
+	}
+	commit(nil)
+}
+
+// 处理群话题删除事件（清理话题的置顶消息和成员的最近会话）
+func (m *Message) handleGroupTopicDeleteEvent(data []byte, commit config.EventCommit) {
+	var req map[string]interface{}
+	err := util.ReadJsonByByte(data, &req)
+	if err != nil {
+		m.Error("解析JSON失败！", zap.Error(err))
+		commit(err)
+		return
+	}
+	topicNo, _ := req["topic_no"].(string)
+	groupNo, _ := req["group_no"].(string)
+	if topicNo == "" || groupNo == "" {
+		commit(errors.New("话题编号或群编号不能为空"))
+		return
+	}
+	channelType := common.ChannelTypeCommunityTopic.Uint8()
+	err = m.pinnedDB.deleteWithChannel(topicNo, channelType)
+	if err != nil {
+		m.Error("删除话题置顶消息失败！", zap.Error(err))
+		commit(err)
+		return
+	}
+	err = m.conversationExtradb.deleteWithChannel(topicNo, channelType)
+	if err != nil {
+		m.Error("删除话题最近会话扩展失败！", zap.Error(err))
+		commit(err)
+		return
+	}
+	members, err := m.groupService.GetMembers(groupNo)
+	if err != nil {
+		m.Error("查询群成员失败！", zap.Error(err))
+		commit(err)
+		return
+	}
+	for _, member := range members {
+		err = m.ctx.IMDeleteConversation(config.DeleteConversationReq{
+			ChannelID:   topicNo,
+			ChannelType: channelType,
+			UID:         member.UID,
+		})
+		if err != nil {
+			m.Warn("删除成员的话题最近会话失败！", zap.Error(err), zap.String("uid", member.UID), zap.String("topic_no", topicNo))
+		}
+	}
 	commit(nil)
 }
 
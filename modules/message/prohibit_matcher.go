@@ -0,0 +1,121 @@
+package message
+
+import "unicode"
+
+// 违禁词匹配器（Aho-Corasick自动机，一次扫描即可匹配所有违禁词，匹配时忽略大小写）
+type prohibitMatcher struct {
+	root  *prohibitMatcherNode
+	words []*prohibitWord
+}
+
+type prohibitMatcherNode struct {
+	children map[rune]*prohibitMatcherNode
+	fail     *prohibitMatcherNode
+	outputs  []int // 以此节点结尾的违禁词下标
+}
+
+type prohibitWord struct {
+	Content string
+	Action  int // 处理方式
+	length  int // 违禁词的字符数
+}
+
+// 匹配结果（start和end为字符下标，不是字节下标）
+type prohibitMatch struct {
+	Word  *prohibitWord
+	Start int
+	End   int
+}
+
+func newProhibitMatcherNode() *prohibitMatcherNode {
+	return &prohibitMatcherNode{
+		children: make(map[rune]*prohibitMatcherNode),
+	}
+}
+
+func newProhibitMatcher(words []*prohibitWord) *prohibitMatcher {
+	matcher := &prohibitMatcher{
+		root:  newProhibitMatcherNode(),
+		words: make([]*prohibitWord, 0, len(words)),
+	}
+	for _, word := range words {
+		runes := toLowerRunes(word.Content)
+		if len(runes) == 0 {
+			continue
+		}
+		word.length = len(runes)
+		node := matcher.root
+		for _, r := range runes {
+			child := node.children[r]
+			if child == nil {
+				child = newProhibitMatcherNode()
+				node.children[r] = child
+			}
+			node = child
+		}
+		node.outputs = append(node.outputs, len(matcher.words))
+		matcher.words = append(matcher.words, word)
+	}
+	matcher.buildFail()
+	return matcher
+}
+
+// 按层构建失配指针，并合并失配节点的输出
+func (p *prohibitMatcher) buildFail() {
+	queue := make([]*prohibitMatcherNode, 0, len(p.root.children))
+	for _, child := range p.root.children {
+		child.fail = p.root
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for r, child := range node.children {
+			fail := node.fail
+			for fail != nil && fail.children[r] == nil {
+				fail = fail.fail
+			}
+			if fail == nil {
+				child.fail = p.root
+			} else {
+				child.fail = fail.children[r]
+			}
+			child.outputs = append(child.outputs, child.fail.outputs...)
+			queue = append(queue, child)
+		}
+	}
+}
+
+// 匹配文本中出现的所有违禁词
+func (p *prohibitMatcher) match(text string) []*prohibitMatch {
+	if len(p.words) == 0 || text == "" {
+		return nil
+	}
+	var matches []*prohibitMatch
+	node := p.root
+	for i, r := range toLowerRunes(text) {
+		for node != p.root && node.children[r] == nil {
+			node = node.fail
+		}
+		if next := node.children[r]; next != nil {
+			node = next
+		}
+		for _, index := range node.outputs {
+			word := p.words[index]
+			matches = append(matches, &prohibitMatch{
+				Word:  word,
+				Start: i + 1 - word.length,
+				End:   i + 1,
+			})
+		}
+	}
+	return matches
+}
+
+func toLowerRunes(text string) []rune {
+	runes := []rune(text)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
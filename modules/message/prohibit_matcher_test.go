@@ -0,0 +1,50 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProhibitMatcher(t *testing.T) {
+	matcher := newProhibitMatcher([]*prohibitWord{
+		{Content: "he", Action: ProhibitWordActionMask},
+		{Content: "she", Action: ProhibitWordActionMask},
+		{Content: "his", Action: ProhibitWordActionBlock},
+		{Content: "hers", Action: ProhibitWordActionReview},
+		{Content: "", Action: ProhibitWordActionBlock},
+	})
+	matches := matcher.match("uSHErs")
+	words := make([]string, 0, len(matches))
+	for _, match := range matches {
+		words = append(words, match.Word.Content)
+	}
+	assert.ElementsMatch(t, []string{"she", "he", "hers"}, words)
+
+	for _, match := range matches {
+		switch match.Word.Content {
+		case "she":
+			assert.Equal(t, 1, match.Start)
+			assert.Equal(t, 4, match.End)
+		case "hers":
+			assert.Equal(t, 2, match.Start)
+			assert.Equal(t, 6, match.End)
+		}
+	}
+	assert.Empty(t, matcher.match("abc"))
+}
+
+func TestProhibitMatcherUnicode(t *testing.T) {
+	matcher := newProhibitMatcher([]*prohibitWord{
+		{Content: "代开发票", Action: ProhibitWordActionMask},
+		{Content: "发票", Action: ProhibitWordActionMask},
+	})
+	matches := matcher.match("专业代开发票，联系我")
+	assert.Len(t, matches, 2)
+	for _, match := range matches {
+		if match.Word.Content == "代开发票" {
+			assert.Equal(t, 2, match.Start)
+			assert.Equal(t, 6, match.End)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package message
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"go.uber.org/zap"
+)
+
+// ErrProhibitWordsBlocked 消息包含需拦截的违禁词
+var ErrProhibitWordsBlocked = errors.New("消息包含违禁词，禁止发送！")
+
+// 违禁词过滤器（违禁词版本有变化时重新构建匹配器）
+type prohibitWordFilter struct {
+	db *DB
+	log.Log
+	mu        sync.Mutex
+	matcher   *prohibitMatcher
+	version   int64
+	checkedAt time.Time
+}
+
+func newProhibitWordFilter(ctx *config.Context) *prohibitWordFilter {
+	return &prohibitWordFilter{
+		db:  NewDB(ctx),
+		Log: log.NewTLog("prohibitWordFilter"),
+	}
+}
+
+func (f *prohibitWordFilter) getMatcher() (*prohibitMatcher, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.matcher != nil && time.Since(f.checkedAt) < ProhibitWordsReloadInterval {
+		return f.matcher, nil
+	}
+	version, err := f.db.queryProhibitWordsMaxVersion()
+	if err != nil {
+		if f.matcher != nil { // 查询失败时继续使用已加载的违禁词
+			f.Warn("查询违禁词版本失败！", zap.Error(err))
+			return f.matcher, nil
+		}
+		return nil, err
+	}
+	f.checkedAt = time.Now()
+	if f.matcher != nil && f.version == version {
+		return f.matcher, nil
+	}
+	models, err := f.db.queryValidProhibitWords()
+	if err != nil {
+		return nil, err
+	}
+	words := make([]*prohibitWord, 0, len(models))
+	for _, model := range models {
+		words = append(words, &prohibitWord{
+			Content: strings.TrimSpace(model.Content),
+			Action:  model.Action,
+		})
+	}
+	f.matcher = newProhibitMatcher(words)
+	f.version = version
+	return f.matcher, nil
+}
+
+// 违禁词有变更，下次检查时重新加载
+func (f *prohibitWordFilter) reset() {
+	f.mu.Lock()
+	f.matcher = nil
+	f.mu.Unlock()
+}
+
+// 检查文本中的违禁词
+func (f *prohibitWordFilter) check(content string) (*prohibitCheckResult, error) {
+	result := &prohibitCheckResult{
+		Content: content,
+	}
+	if strings.TrimSpace(content) == "" {
+		return result, nil
+	}
+	matcher, err := f.getMatcher()
+	if err != nil {
+		return nil, err
+	}
+	matches := matcher.match(content)
+	if len(matches) == 0 {
+		return result, nil
+	}
+	runes := []rune(content)
+	hitMap := make(map[string]bool)
+	for _, match := range matches {
+		if !hitMap[match.Word.Content] {
+			hitMap[match.Word.Content] = true
+			result.HitWords = append(result.HitWords, match.Word.Content)
+		}
+		switch match.Word.Action {
+		case ProhibitWordActionBlock:
+			result.Block = true
+		case ProhibitWordActionReview:
+			result.Review = true
+		default:
+			for i := match.Start; i < match.End; i++ {
+				runes[i] = '*'
+			}
+			result.Masked = true
+		}
+	}
+	if result.Masked {
+		result.Content = string(runes)
+	}
+	return result, nil
+}
+
+// 检查消息正文（payload的content字段）中的违禁词
+func (f *prohibitWordFilter) checkPayload(payload map[string]interface{}) (*prohibitCheckResult, error) {
+	content, _ := payload["content"].(string)
+	return f.check(content)
+}
+
+// 发送前过滤消息正文：包含拦截类违禁词时返回ErrProhibitWordsBlocked，包含替换类违禁词时返回替换后的payload
+func (f *prohibitWordFilter) filterPayload(payload map[string]interface{}) (map[string]interface{}, error) {
+	result, err := f.checkPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	if result.Block {
+		return nil, ErrProhibitWordsBlocked
+	}
+	if !result.Masked {
+		return payload, nil
+	}
+	newPayload := make(map[string]interface{}, len(payload))
+	for key, value := range payload {
+		newPayload[key] = value
+	}
+	newPayload["content"] = result.Content
+	return newPayload, nil
+}
+
+// 发送前过滤文本消息内容
+func (f *prohibitWordFilter) filterContent(content string) (string, error) {
+	result, err := f.check(content)
+	if err != nil {
+		return "", err
+	}
+	if result.Block {
+		return "", ErrProhibitWordsBlocked
+	}
+	return result.Content, nil
+}
+
+type prohibitCheckResult struct {
+	Content  string   // 违禁词替换为*后的内容
+	HitWords []string // 命中的违禁词
+	Block    bool     // 是否命中拦截类违禁词
+	Masked   bool     // 是否替换了违禁词
+	Review   bool     // 是否命中待审核类违禁词
+}
+
+func (r *prohibitCheckResult) hit() bool {
+	return len(r.HitWords) > 0
+}
@@ -8,21 +8,44 @@ import (
 
 type IService interface {
 	DeleteConversation(uid string, channelID string, channelType uint8) error
+	// ScheduleMessage 添加定时消息（到达发送时间后由消息模块发送），返回定时消息编号
+	ScheduleMessage(req *ScheduleMessageReq) (string, error)
+	// FilterProhibitWords 发送前过滤消息中的违禁词（包含拦截类违禁词时返回ErrProhibitWordsBlocked），返回替换违禁词后的payload
+	FilterProhibitWords(payload map[string]interface{}) (map[string]interface{}, error)
 }
 
 type Service struct {
 	ctx *config.Context
 	log.Log
+	scheduledMessageDB *scheduledMessageDB
+	prohibitWordFilter *prohibitWordFilter
 }
 
 func NewService(ctx *config.Context) *Service {
 
 	return &Service{
-		ctx: ctx,
-		Log: log.NewTLog("message.Service"),
+		ctx:                ctx,
+		Log:                log.NewTLog("message.Service"),
+		scheduledMessageDB: newScheduledMessageDB(ctx),
+		prohibitWordFilter: newProhibitWordFilter(ctx),
 	}
 }
 
+func (s *Service) ScheduleMessage(req *ScheduleMessageReq) (string, error) {
+	if err := req.check(); err != nil {
+		return "", err
+	}
+	model, err := addScheduledMessage(s.scheduledMessageDB, req)
+	if err != nil {
+		return "", err
+	}
+	return model.ScheduledNo, nil
+}
+
+func (s *Service) FilterProhibitWords(payload map[string]interface{}) (map[string]interface{}, error) {
+	return s.prohibitWordFilter.filterPayload(payload)
+}
+
 func (s *Service) DeleteConversation(uid string, channelID string, channelType uint8) error {
 	err := s.ctx.IMDeleteConversation(config.DeleteConversationReq{
 		ChannelID:   channelID,
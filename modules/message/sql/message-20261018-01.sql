@@ -0,0 +1,23 @@
+-- +migrate Up
+
+create table `scheduled_message`(
+  id           bigint          not null primary key AUTO_INCREMENT,
+  scheduled_no VARCHAR(40)     not null default '',  -- 定时消息编号
+  source       smallint        not null default 0,   -- 来源 0.用户 1.后台 2.机器人
+  creator      VARCHAR(40)     not null default '',  -- 创建者uid
+  from_uid     VARCHAR(40)     not null default '',  -- 发送者uid
+  channel_id   VARCHAR(100)    not null default '',  -- 频道ID
+  channel_type smallint        not null default 0,   -- 频道类型
+  payload      text            not null,             -- 消息内容
+  send_at      bigint          not null default 0,   -- 计划发送时间（10位时间戳）
+  status       smallint        not null default 0,   -- 状态 0.待发送 1.发送中 2.已发送 3.已取消 4.发送失败
+  fail_reason  VARCHAR(200)    not null default '',  -- 发送失败原因
+  claimed_at   bigint          not null default 0,   -- 开始发送时间
+  sent_at      bigint          not null default 0,   -- 实际发送时间
+  created_at timeStamp     not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at timeStamp     not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+
+CREATE UNIQUE INDEX scheduled_message_no_idx on `scheduled_message` (scheduled_no);
+CREATE INDEX scheduled_message_send_idx on `scheduled_message` (status, send_at);
+CREATE INDEX scheduled_message_channel_idx on `scheduled_message` (creator, channel_id, channel_type);
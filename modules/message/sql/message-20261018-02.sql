@@ -0,0 +1,17 @@
+-- +migrate Up
+
+create table `message_edit_history`(
+  id                bigint        not null primary key AUTO_INCREMENT,
+  message_id        VARCHAR(20)   not null default '',  -- 消息唯一ID（全局唯一）
+  channel_id        VARCHAR(100)  not null default '',  -- 频道ID（单聊为fake频道ID）
+  channel_type      smallint      not null default 0,   -- 频道类型
+  editor            VARCHAR(40)   not null default '',  -- 编辑者uid
+  content_edit      TEXT,                               -- 编辑后的正文
+  content_edit_hash VARCHAR(255)  not null default '',  -- 编辑正文的hash值
+  edited_at         integer       not null default 0,   -- 编辑时间 时间戳（秒）
+  created_at        timeStamp     not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at        timeStamp     not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+
+CREATE INDEX message_edit_history_message_idx on `message_edit_history` (message_id);
+ALTER TABLE `message_extra` ADD COLUMN edit_count integer not null default 0 COMMENT '消息编辑次数';
@@ -0,0 +1,23 @@
+-- +migrate Up
+
+ALTER TABLE `prohibit_words` ADD COLUMN action smallint not null default 0 COMMENT '命中后的处理方式 0.替换为* 1.拦截 2.标记待审核';
+
+create table `message_moderation`(
+  id             bigint        not null primary key AUTO_INCREMENT,
+  moderation_no  VARCHAR(40)   not null default '',  -- 审核编号
+  message_id     VARCHAR(20)   not null default '',  -- 消息唯一ID
+  message_seq    bigint        not null default 0,   -- 消息序列号
+  from_uid       VARCHAR(40)   not null default '',  -- 发送者uid
+  channel_id     VARCHAR(100)  not null default '',  -- 频道ID
+  channel_type   smallint      not null default 0,   -- 频道类型
+  payload        TEXT,                               -- 原始消息内容
+  hit_words      VARCHAR(1000) not null default '',  -- 命中的违禁词（逗号分隔）
+  status         smallint      not null default 0,   -- 状态 0.待审核 1.审核通过 2.已撤回
+  handler        VARCHAR(40)   not null default '',  -- 处理人uid（自动拦截时为空）
+  handled_at     bigint        not null default 0,   -- 处理时间 时间戳（秒）
+  created_at     timeStamp     not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at     timeStamp     not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+
+CREATE UNIQUE INDEX message_moderation_no_idx on `message_moderation` (moderation_no);
+CREATE INDEX message_moderation_status_idx on `message_moderation` (status);
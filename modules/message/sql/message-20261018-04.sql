@@ -0,0 +1,26 @@
+-- +migrate Up
+
+create table `message_favorite`(
+  id                bigint        not null primary key AUTO_INCREMENT,
+  favorite_no       VARCHAR(40)   not null default '',  -- 收藏编号
+  uid               VARCHAR(40)   not null default '',  -- 收藏者uid
+  message_id        VARCHAR(20)   not null default '',  -- 消息唯一ID
+  message_seq       bigint        not null default 0,   -- 消息序列号
+  channel_id        VARCHAR(100)  not null default '',  -- 消息来源频道ID（收藏者视角）
+  channel_type      smallint      not null default 0,   -- 消息来源频道类型
+  from_uid          VARCHAR(40)   not null default '',  -- 消息发送者uid
+  from_name         VARCHAR(100)  not null default '',  -- 收藏时消息发送者的名字
+  payload           TEXT,                               -- 收藏时的消息内容快照（消息被编辑过时为编辑后的内容）
+  search_text       TEXT,                               -- 消息内容中可搜索的文本
+  tags              VARCHAR(500)  not null default '',  -- 标签（逗号分隔）
+  message_timestamp bigint        not null default 0,   -- 消息发送时间
+  is_deleted        smallint      not null default 0,   -- 是否已删除
+  version           bigint        not null default 0,   -- 同步版本号
+  created_at        timeStamp     not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at        timeStamp     not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+
+CREATE UNIQUE INDEX message_favorite_no_idx on `message_favorite` (favorite_no);
+CREATE UNIQUE INDEX message_favorite_uid_message_idx on `message_favorite` (uid, message_id);
+CREATE INDEX message_favorite_uid_version_idx on `message_favorite` (uid, version);
+CREATE FULLTEXT INDEX message_favorite_search_text_idx on `message_favorite` (search_text) WITH PARSER ngram;
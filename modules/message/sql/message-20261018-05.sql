@@ -0,0 +1,6 @@
+-- +migrate Up
+
+ALTER TABLE `reminders` ADD COLUMN `remind_at`  bigint  not null default 0  COMMENT '提醒时间（用户自定义提醒）时间戳（秒）';
+ALTER TABLE `reminders` ADD COLUMN `fired_at`  bigint  not null default 0  COMMENT '实际提醒时间（用户自定义提醒）时间戳（秒）';
+
+CREATE INDEX reminders_remind_at_idx on `reminders` (reminder_type,fired_at,remind_at);
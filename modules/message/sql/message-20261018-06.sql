@@ -0,0 +1,36 @@
+-- +migrate Up
+
+create table `message_poll`(
+  id           bigint        not null primary key AUTO_INCREMENT,
+  poll_no      VARCHAR(40)   not null default '',  -- 投票编号
+  message_id   VARCHAR(20)   not null default '',  -- 投票消息的唯一ID
+  message_seq  bigint        not null default 0,   -- 投票消息的序列号
+  channel_id   VARCHAR(100)  not null default '',  -- 频道ID
+  channel_type smallint      not null default 0,   -- 频道类型
+  creator      VARCHAR(40)   not null default '',  -- 发起人uid
+  question     VARCHAR(255)  not null default '',  -- 投票问题
+  options      TEXT,                               -- 选项（json数组）
+  multiple     smallint      not null default 0,   -- 是否多选
+  anonymous    smallint      not null default 0,   -- 是否匿名
+  deadline     bigint        not null default 0,   -- 截止时间 时间戳（秒） 0表示不截止
+  status       smallint      not null default 0,   -- 状态 0.进行中 1.已结束
+  closed_at    bigint        not null default 0,   -- 结束时间 时间戳（秒）
+  created_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+
+CREATE UNIQUE INDEX message_poll_no_idx on `message_poll` (poll_no);
+CREATE INDEX message_poll_deadline_idx on `message_poll` (status,deadline);
+
+create table `message_poll_vote`(
+  id           bigint        not null primary key AUTO_INCREMENT,
+  poll_no      VARCHAR(40)   not null default '',  -- 投票编号
+  option_index integer       not null default 0,   -- 选项下标
+  uid          VARCHAR(40)   not null default '',  -- 投票人uid
+  created_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at   timeStamp     not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+
+CREATE UNIQUE INDEX message_poll_vote_uidx on `message_poll_vote` (poll_no,uid,option_index);
+
+ALTER TABLE `message_extra` ADD COLUMN poll_result TEXT COMMENT '投票结果（json）';
@@ -0,0 +1,35 @@
+-- +migrate Up
+
+create table `message_thread_reply`(
+  id               bigint        not null primary key AUTO_INCREMENT,
+  root_message_id  VARCHAR(20)   not null default '',  -- 话题根消息的唯一ID
+  message_id       VARCHAR(20)   not null default '',  -- 回复消息的唯一ID
+  message_seq      bigint        not null default 0,   -- 回复消息的序列号
+  channel_id       VARCHAR(100)  not null default '',  -- 频道ID（个人频道为fake channel id）
+  channel_type     smallint      not null default 0,   -- 频道类型
+  from_uid         VARCHAR(40)   not null default '',  -- 回复者uid
+  created_at       timeStamp     not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at       timeStamp     not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+
+CREATE UNIQUE INDEX message_thread_reply_message_idx on `message_thread_reply` (message_id);
+CREATE INDEX message_thread_reply_root_idx on `message_thread_reply` (root_message_id,message_seq);
+
+create table `message_thread_follow`(
+  id                bigint        not null primary key AUTO_INCREMENT,
+  uid               VARCHAR(40)   not null default '',  -- 关注者uid
+  root_message_id   VARCHAR(20)   not null default '',  -- 话题根消息的唯一ID
+  root_message_seq  bigint        not null default 0,   -- 话题根消息的序列号
+  channel_id        VARCHAR(100)  not null default '',  -- 频道ID（关注者视角）
+  channel_type      smallint      not null default 0,   -- 频道类型
+  followed          smallint      not null default 1,   -- 是否关注（取消关注后保留记录，避免再次被自动关注）
+  read_message_seq  bigint        not null default 0,   -- 已读到的回复消息序列号
+  created_at        timeStamp     not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+  updated_at        timeStamp     not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+
+CREATE UNIQUE INDEX message_thread_follow_uidx on `message_thread_follow` (uid,root_message_id);
+
+ALTER TABLE `message_extra` ADD COLUMN reply_count integer not null default 0 COMMENT '话题回复数量';
+ALTER TABLE `message_extra` ADD COLUMN last_reply_uid VARCHAR(40) not null default '' COMMENT '最后回复者uid';
+ALTER TABLE `message_extra` ADD COLUMN last_reply_at bigint not null default 0 COMMENT '最后回复时间 时间戳（秒）';
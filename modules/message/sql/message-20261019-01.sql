@@ -0,0 +1,3 @@
+-- +migrate Up
+
+ALTER TABLE `message_thread_reply` ADD COLUMN is_removed smallint not null default 0 COMMENT '回复是否已撤回或删除（不再计入回复数量和未读数量）';
@@ -0,0 +1,37 @@
+package organization
+
+import (
+	"embed"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/register"
+)
+
+//go:embed sql
+var sqlFS embed.FS
+
+//go:embed swagger/api.yaml
+var swaggerContent string
+
+func init() {
+	register.AddModule(func(ctx interface{}) register.Module {
+		return register.Module{
+			Name: "organization",
+			SetupAPI: func() register.APIRouter {
+				return New(ctx.(*config.Context))
+			},
+			SQLDir:  register.NewSQLFS(sqlFS),
+			Swagger: swaggerContent,
+		}
+	})
+
+	// 组织管理模块
+	register.AddModule(func(ctx interface{}) register.Module {
+		return register.Module{
+			Name: "organization_manager",
+			SetupAPI: func() register.APIRouter {
+				return NewManager(ctx.(*config.Context))
+			},
+		}
+	})
+}
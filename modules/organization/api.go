@@ -0,0 +1,250 @@
+package organization
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// Organization 组织架构
+type Organization struct {
+	ctx *config.Context
+	log.Log
+	db *db
+}
+
+// New New
+func New(ctx *config.Context) *Organization {
+	return &Organization{
+		ctx: ctx,
+		Log: log.NewTLog("Organization"),
+		db:  newDB(ctx),
+	}
+}
+
+// Route 路由配置
+func (o *Organization) Route(r *wkhttp.WKHttp) {
+	auth := r.Group("/v1/organizations", o.ctx.AuthMiddleware(r))
+	{
+		auth.GET("", o.myOrgs)                         // 我加入的组织
+		auth.GET("/:org_id/departments", o.deptTree)   // 组织通讯录（部门树）
+		auth.GET("/:org_id/employees", o.employeeList) // 组织员工
+	}
+}
+
+// 我加入的组织
+func (o *Organization) myOrgs(c *wkhttp.Context) {
+	models, err := o.db.queryOrgsWithUID(c.GetLoginUID())
+	if err != nil {
+		o.Error("查询组织失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询组织失败！"))
+		return
+	}
+	list := make([]*orgResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newOrgResp(model))
+	}
+	c.Response(list)
+}
+
+// 组织通讯录（部门树，只有组织员工可以查看）
+func (o *Organization) deptTree(c *wkhttp.Context) {
+	org, err := o.checkEmployee(c.Param("org_id"), c.GetLoginUID())
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	tree, err := buildDeptTree(o.db, org.OrgID)
+	if err != nil {
+		o.Error("查询部门失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询部门失败！"))
+		return
+	}
+	c.Response(tree)
+}
+
+// 组织员工（dept_id不为空时只查询该部门的员工）
+func (o *Organization) employeeList(c *wkhttp.Context) {
+	org, err := o.checkEmployee(c.Param("org_id"), c.GetLoginUID())
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	resp, err := queryEmployees(o.db, org.OrgID, c)
+	if err != nil {
+		o.Error("查询员工失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询员工失败！"))
+		return
+	}
+	c.Response(resp)
+}
+
+// 校验用户是否是正常组织的员工
+func (o *Organization) checkEmployee(orgID string, uid string) (*orgModel, error) {
+	org, err := o.db.queryOrgWithOrgID(orgID)
+	if err != nil {
+		o.Error("查询组织失败！", zap.Error(err))
+		return nil, errors.New("查询组织失败！")
+	}
+	if org == nil || org.Status != OrgStatusNormal {
+		return nil, errors.New("组织不存在或已被禁用！")
+	}
+	employee, err := o.db.queryEmployee(orgID, uid)
+	if err != nil {
+		o.Error("查询员工信息失败！", zap.Error(err))
+		return nil, errors.New("查询员工信息失败！")
+	}
+	if employee == nil {
+		return nil, errors.New("不是该组织的员工！")
+	}
+	return org, nil
+}
+
+// 构建组织的部门树（员工数量只统计部门直属员工）
+func buildDeptTree(d *db, orgID string) ([]*deptResp, error) {
+	depts, err := d.queryDeptsWithOrgID(orgID)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := d.queryDeptEmployeeCounts(orgID)
+	if err != nil {
+		return nil, err
+	}
+	countMap := make(map[string]int, len(counts))
+	for _, count := range counts {
+		countMap[count.DeptID] = count.Count
+	}
+	respMap := make(map[string]*deptResp, len(depts))
+	for _, dept := range depts {
+		resp := newDeptResp(dept)
+		resp.EmployeeCount = countMap[dept.DeptID]
+		respMap[dept.DeptID] = resp
+	}
+	roots := make([]*deptResp, 0)
+	for _, dept := range depts { // depts已按sort_num排序，子部门按相同顺序追加
+		resp := respMap[dept.DeptID]
+		parent := respMap[dept.ParentID]
+		if parent == nil {
+			roots = append(roots, resp)
+			continue
+		}
+		parent.Children = append(parent.Children, resp)
+	}
+	return roots, nil
+}
+
+// 分页查询组织员工及其所属部门
+func queryEmployees(d *db, orgID string, c *wkhttp.Context) (map[string]interface{}, error) {
+	deptID := c.Query("dept_id")
+	keyword := strings.TrimSpace(c.Query("keyword"))
+	pageIndex, pageSize := c.GetPage()
+	models, err := d.queryEmployeeDetails(orgID, deptID, keyword, uint64(pageSize), uint64(pageIndex))
+	if err != nil {
+		return nil, err
+	}
+	count, err := d.queryEmployeeCount(orgID, deptID, keyword)
+	if err != nil {
+		return nil, err
+	}
+	uids := make([]string, 0, len(models))
+	for _, model := range models {
+		uids = append(uids, model.UID)
+	}
+	deptEmployees, err := d.queryDeptEmployeesWithUIDs(orgID, uids)
+	if err != nil {
+		return nil, err
+	}
+	deptIDsMap := make(map[string][]string)
+	for _, deptEmployee := range deptEmployees {
+		deptIDsMap[deptEmployee.UID] = append(deptIDsMap[deptEmployee.UID], deptEmployee.DeptID)
+	}
+	list := make([]*employeeResp, 0, len(models))
+	for _, model := range models {
+		deptIDs := deptIDsMap[model.UID]
+		if deptIDs == nil {
+			deptIDs = make([]string, 0)
+		}
+		sort.Strings(deptIDs)
+		list = append(list, &employeeResp{
+			UID:          model.UID,
+			Name:         model.Name,
+			EmployeeNo:   model.EmployeeNo,
+			PositionID:   model.PositionID,
+			PositionName: model.PositionName,
+			DeptIDs:      deptIDs,
+		})
+	}
+	return map[string]interface{}{
+		"count": count,
+		"list":  list,
+	}, nil
+}
+
+type orgResp struct {
+	OrgID     string `json:"org_id"`     // 组织ID
+	Name      string `json:"name"`       // 组织名称
+	Logo      string `json:"logo"`       // 组织logo
+	Creator   string `json:"creator"`    // 创建者uid
+	Status    int    `json:"status"`     // 状态 0.禁用 1.正常
+	CreatedAt string `json:"created_at"` // 创建时间
+}
+
+func newOrgResp(m *orgModel) *orgResp {
+	return &orgResp{
+		OrgID:     m.OrgID,
+		Name:      m.Name,
+		Logo:      m.Logo,
+		Creator:   m.Creator,
+		Status:    m.Status,
+		CreatedAt: m.CreatedAt.String(),
+	}
+}
+
+type deptResp struct {
+	DeptID        string      `json:"dept_id"`         // 部门ID
+	ParentID      string      `json:"parent_id"`       // 上级部门ID
+	Name          string      `json:"name"`            // 部门名称
+	SortNum       int         `json:"sort_num"`        // 排序编号
+	IsCreateGroup int         `json:"is_create_group"` // 是否创建了部门群 0.否 1.是（部门群的群编号为部门ID）
+	EmployeeCount int         `json:"employee_count"`  // 部门直属员工数量
+	Children      []*deptResp `json:"children"`        // 下级部门
+}
+
+func newDeptResp(m *deptModel) *deptResp {
+	return &deptResp{
+		DeptID:        m.DeptID,
+		ParentID:      m.ParentID,
+		Name:          m.Name,
+		SortNum:       m.SortNum,
+		IsCreateGroup: m.IsCreateGroup,
+		Children:      make([]*deptResp, 0),
+	}
+}
+
+type positionResp struct {
+	PositionID string `json:"position_id"` // 职位ID
+	Name       string `json:"name"`        // 职位名称
+	SortNum    int    `json:"sort_num"`    // 排序编号
+}
+
+func newPositionResp(m *positionModel) *positionResp {
+	return &positionResp{
+		PositionID: m.PositionID,
+		Name:       m.Name,
+		SortNum:    m.SortNum,
+	}
+}
+
+type employeeResp struct {
+	UID          string   `json:"uid"`           // 员工uid
+	Name         string   `json:"name"`          // 员工在组织内的名字
+	EmployeeNo   string   `json:"employee_no"`   // 工号
+	PositionID   string   `json:"position_id"`   // 职位ID
+	PositionName string   `json:"position_name"` // 职位名称
+	DeptIDs      []string `json:"dept_ids"`      // 所属部门ID
+}
@@ -0,0 +1,1151 @@
+package organization
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/log"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkhttp"
+	"go.uber.org/zap"
+)
+
+// Manager 组织后台管理api
+type Manager struct {
+	ctx *config.Context
+	log.Log
+	db     *db
+	userDB *user.DB
+}
+
+// NewManager NewManager
+func NewManager(ctx *config.Context) *Manager {
+	return &Manager{
+		ctx:    ctx,
+		Log:    log.NewTLog("organizationManager"),
+		db:     newDB(ctx),
+		userDB: user.NewDB(ctx),
+	}
+}
+
+// Route 配置路由规则
+func (m *Manager) Route(r *wkhttp.WKHttp) {
+	auth := r.Group("/v1/manager", m.ctx.AuthMiddleware(r))
+	{
+		auth.GET("/organizations", m.orgList)                                          // 组织列表
+		auth.POST("/organizations", m.orgAdd)                                          // 创建组织
+		auth.PUT("/organizations/:org_id", m.orgUpdate)                                // 修改组织
+		auth.GET("/organizations/:org_id/departments", m.deptTree)                     // 部门树
+		auth.POST("/organizations/:org_id/departments", m.deptAdd)                     // 添加部门
+		auth.PUT("/organizations/:org_id/departments/:dept_id", m.deptUpdate)          // 修改部门
+		auth.DELETE("/organizations/:org_id/departments/:dept_id", m.deptDelete)       // 删除部门
+		auth.GET("/organizations/:org_id/positions", m.positionList)                   // 职位列表
+		auth.POST("/organizations/:org_id/positions", m.positionAdd)                   // 添加职位
+		auth.DELETE("/organizations/:org_id/positions/:position_id", m.positionDelete) // 删除职位
+		auth.GET("/organizations/:org_id/employees", m.employeeList)                   // 员工列表
+		auth.POST("/organizations/:org_id/employees", m.employeeAdd)                   // 添加员工
+		auth.PUT("/organizations/:org_id/employees/:uid", m.employeeUpdate)            // 修改员工
+		auth.DELETE("/organizations/:org_id/employees/:uid", m.employeeDelete)         // 移除员工
+	}
+}
+
+// 组织列表
+func (m *Manager) orgList(c *wkhttp.Context) {
+	err := c.CheckLoginRole()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	keyword := strings.TrimSpace(c.Query("keyword"))
+	pageIndex, pageSize := c.GetPage()
+	models, err := m.db.queryOrgsWithPage(keyword, uint64(pageSize), uint64(pageIndex))
+	if err != nil {
+		m.Error("查询组织列表失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询组织列表失败！"))
+		return
+	}
+	count, err := m.db.queryOrgCount(keyword)
+	if err != nil {
+		m.Error("查询组织数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询组织数量失败！"))
+		return
+	}
+	list := make([]*orgResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newOrgResp(model))
+	}
+	c.Response(map[string]interface{}{
+		"count": count,
+		"list":  list,
+	})
+}
+
+// 创建组织（创建者自动成为组织员工和组织全员群的群主）
+func (m *Manager) orgAdd(c *wkhttp.Context) {
+	err := c.CheckLoginRole()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req orgReq
+	if err := c.BindJSON(&req); err != nil {
+		m.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if strings.TrimSpace(req.Creator) == "" {
+		c.ResponseError(errors.New("组织创建者不能为空！"))
+		return
+	}
+	creator, err := m.userDB.QueryByUID(req.Creator)
+	if err != nil {
+		m.Error("查询创建者信息失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询创建者信息失败！"))
+		return
+	}
+	if creator == nil || creator.IsDestroy == 1 {
+		c.ResponseError(errors.New("组织创建者不存在！"))
+		return
+	}
+	model := &orgModel{
+		OrgID:   util.GenerUUID(),
+		Name:    strings.TrimSpace(req.Name),
+		Logo:    req.Logo,
+		Creator: creator.UID,
+		Status:  OrgStatusNormal,
+	}
+	tx, err := m.ctx.DB().Begin()
+	if err != nil {
+		m.Error("开启事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("开启事务失败！"))
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.RollbackUnlessCommitted()
+			panic(err)
+		}
+	}()
+	err = m.db.insertOrgTx(model, tx)
+	if err != nil {
+		tx.Rollback()
+		m.Error("创建组织失败！", zap.Error(err))
+		c.ResponseError(errors.New("创建组织失败！"))
+		return
+	}
+	err = m.db.insertEmployeeTx(&employeeModel{
+		OrgID: model.OrgID,
+		UID:   creator.UID,
+		Name:  creator.Name,
+	}, tx)
+	if err != nil {
+		tx.Rollback()
+		m.Error("添加组织创建者失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加组织创建者失败！"))
+		return
+	}
+	eventID, err := m.beginOrgOrDeptCreateEvent(model.OrgID, GroupCategoryOrganization, model.Name, &config.UserBaseVo{
+		UID:  creator.UID,
+		Name: creator.Name,
+	}, nil, tx)
+	if err != nil {
+		tx.Rollback()
+		m.Error("开启组织创建事件失败！", zap.Error(err))
+		c.ResponseError(errors.New("开启组织创建事件失败！"))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tx.RollbackUnlessCommitted()
+		m.Error("提交事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("提交事务失败！"))
+		return
+	}
+	m.ctx.EventCommit(eventID)
+	m.sendOrgCMD(creator.UID, model.OrgID, common.CMDJoinOrganization)
+	c.Response(newOrgResp(model))
+}
+
+// 修改组织
+func (m *Manager) orgUpdate(c *wkhttp.Context) {
+	err := c.CheckLoginRole()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req orgReq
+	if err := c.BindJSON(&req); err != nil {
+		m.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if req.Status != OrgStatusNormal && req.Status != OrgStatusDisabled {
+		c.ResponseError(errors.New("组织状态有误！"))
+		return
+	}
+	model, err := m.getOrg(c.Param("org_id"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	model.Name = strings.TrimSpace(req.Name)
+	model.Logo = req.Logo
+	model.Status = req.Status
+	err = m.db.updateOrg(model)
+	if err != nil {
+		m.Error("修改组织失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改组织失败！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 部门树
+func (m *Manager) deptTree(c *wkhttp.Context) {
+	err := c.CheckLoginRole()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	org, err := m.getOrg(c.Param("org_id"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	tree, err := buildDeptTree(m.db, org.OrgID)
+	if err != nil {
+		m.Error("查询部门失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询部门失败！"))
+		return
+	}
+	c.Response(tree)
+}
+
+// 添加部门（开启部门群时以组织创建者为群主创建部门群）
+func (m *Manager) deptAdd(c *wkhttp.Context) {
+	err := c.CheckLoginRole()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req deptReq
+	if err := c.BindJSON(&req); err != nil {
+		m.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	org, err := m.getOrg(c.Param("org_id"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if req.ParentID != "" {
+		parent, err := m.db.queryDeptWithDeptID(req.ParentID)
+		if err != nil {
+			m.Error("查询上级部门失败！", zap.Error(err))
+			c.ResponseError(errors.New("查询上级部门失败！"))
+			return
+		}
+		if parent == nil || parent.OrgID != org.OrgID {
+			c.ResponseError(errors.New("上级部门不存在！"))
+			return
+		}
+	}
+	model := &deptModel{
+		DeptID:        util.GenerUUID(),
+		OrgID:         org.OrgID,
+		ParentID:      req.ParentID,
+		Name:          strings.TrimSpace(req.Name),
+		SortNum:       req.SortNum,
+		IsCreateGroup: req.IsCreateGroup,
+	}
+	tx, err := m.ctx.DB().Begin()
+	if err != nil {
+		m.Error("开启事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("开启事务失败！"))
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.RollbackUnlessCommitted()
+			panic(err)
+		}
+	}()
+	err = m.db.insertDeptTx(model, tx)
+	if err != nil {
+		tx.Rollback()
+		m.Error("添加部门失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加部门失败！"))
+		return
+	}
+	var eventID int64
+	if model.IsCreateGroup == 1 {
+		operator, err := m.getOrgOperator(org)
+		if err != nil {
+			tx.Rollback()
+			c.ResponseError(err)
+			return
+		}
+		eventID, err = m.beginOrgOrDeptCreateEvent(model.DeptID, GroupCategoryDepartment, model.Name, operator, nil, tx)
+		if err != nil {
+			tx.Rollback()
+			m.Error("开启部门创建事件失败！", zap.Error(err))
+			c.ResponseError(errors.New("开启部门创建事件失败！"))
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		tx.RollbackUnlessCommitted()
+		m.Error("提交事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("提交事务失败！"))
+		return
+	}
+	if eventID > 0 {
+		m.ctx.EventCommit(eventID)
+	}
+	c.Response(newDeptResp(model))
+}
+
+// 修改部门（部门群开启后不能关闭）
+func (m *Manager) deptUpdate(c *wkhttp.Context) {
+	err := c.CheckLoginRole()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req deptReq
+	if err := c.BindJSON(&req); err != nil {
+		m.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	org, err := m.getOrg(c.Param("org_id"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	model, err := m.getDept(org.OrgID, c.Param("dept_id"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if model.IsCreateGroup == 1 && req.IsCreateGroup == 0 {
+		c.ResponseError(errors.New("部门群已创建，不能关闭！"))
+		return
+	}
+	if req.ParentID != model.ParentID && req.ParentID != "" {
+		depts, err := m.db.queryDeptsWithOrgID(org.OrgID)
+		if err != nil {
+			m.Error("查询部门失败！", zap.Error(err))
+			c.ResponseError(errors.New("查询部门失败！"))
+			return
+		}
+		if err := checkDeptParent(depts, model.DeptID, req.ParentID); err != nil {
+			c.ResponseError(err)
+			return
+		}
+	}
+	createGroup := model.IsCreateGroup == 0 && req.IsCreateGroup == 1
+	model.Name = strings.TrimSpace(req.Name)
+	model.ParentID = req.ParentID
+	model.SortNum = req.SortNum
+	model.IsCreateGroup = req.IsCreateGroup
+	var operator *config.UserBaseVo
+	var uids []string
+	if createGroup {
+		operator, err = m.getOrgOperator(org)
+		if err != nil {
+			c.ResponseError(err)
+			return
+		}
+		uids, err = m.db.queryUIDsWithDeptID(model.DeptID)
+		if err != nil {
+			m.Error("查询部门员工失败！", zap.Error(err))
+			c.ResponseError(errors.New("查询部门员工失败！"))
+			return
+		}
+	}
+	tx, err := m.ctx.DB().Begin()
+	if err != nil {
+		m.Error("开启事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("开启事务失败！"))
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.RollbackUnlessCommitted()
+			panic(err)
+		}
+	}()
+	err = m.db.updateDeptTx(model, tx)
+	if err != nil {
+		tx.Rollback()
+		m.Error("修改部门失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改部门失败！"))
+		return
+	}
+	var eventID int64
+	if createGroup {
+		// 部门群和部门一起提交，避免部门已开启群但群未创建
+		eventID, err = m.beginOrgOrDeptCreateEvent(model.DeptID, GroupCategoryDepartment, model.Name, operator, uids, tx)
+		if err != nil {
+			tx.Rollback()
+			m.Error("开启部门群创建事件失败！", zap.Error(err))
+			c.ResponseError(errors.New("开启部门群创建事件失败！"))
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		tx.RollbackUnlessCommitted()
+		m.Error("提交事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("提交事务失败！"))
+		return
+	}
+	if eventID > 0 {
+		m.ctx.EventCommit(eventID)
+	}
+	c.ResponseOK()
+}
+
+// 删除部门（只能删除没有下级部门和员工的部门）
+func (m *Manager) deptDelete(c *wkhttp.Context) {
+	err := c.CheckLoginRole()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	org, err := m.getOrg(c.Param("org_id"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	model, err := m.getDept(org.OrgID, c.Param("dept_id"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	subCount, err := m.db.querySubDeptCount(model.DeptID)
+	if err != nil {
+		m.Error("查询下级部门数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询下级部门数量失败！"))
+		return
+	}
+	if subCount > 0 {
+		c.ResponseError(errors.New("请先删除下级部门！"))
+		return
+	}
+	uids, err := m.db.queryUIDsWithDeptID(model.DeptID)
+	if err != nil {
+		m.Error("查询部门员工失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询部门员工失败！"))
+		return
+	}
+	if len(uids) > 0 {
+		c.ResponseError(errors.New("请先移出部门内的员工！"))
+		return
+	}
+	var operator *config.UserBaseVo
+	if model.IsCreateGroup == 1 {
+		operator, err = m.getOrgOperator(org)
+		if err != nil {
+			c.ResponseError(err)
+			return
+		}
+	}
+	tx, err := m.ctx.DB().Begin()
+	if err != nil {
+		m.Error("开启事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("开启事务失败！"))
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.RollbackUnlessCommitted()
+			panic(err)
+		}
+	}()
+	err = m.db.deleteDeptTx(model.DeptID, tx)
+	if err != nil {
+		tx.Rollback()
+		m.Error("删除部门失败！", zap.Error(err))
+		c.ResponseError(errors.New("删除部门失败！"))
+		return
+	}
+	var eventID int64
+	if model.IsCreateGroup == 1 {
+		// 部门删除后解散部门群
+		eventID, err = m.beginDeptDeleteEvent(model.DeptID, operator, tx)
+		if err != nil {
+			tx.Rollback()
+			m.Error("开启部门删除事件失败！", zap.Error(err))
+			c.ResponseError(errors.New("开启部门删除事件失败！"))
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		tx.RollbackUnlessCommitted()
+		m.Error("提交事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("提交事务失败！"))
+		return
+	}
+	if eventID > 0 {
+		m.ctx.EventCommit(eventID)
+	}
+	c.ResponseOK()
+}
+
+// 职位列表
+func (m *Manager) positionList(c *wkhttp.Context) {
+	err := c.CheckLoginRole()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	org, err := m.getOrg(c.Param("org_id"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	models, err := m.db.queryPositionsWithOrgID(org.OrgID)
+	if err != nil {
+		m.Error("查询职位失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询职位失败！"))
+		return
+	}
+	list := make([]*positionResp, 0, len(models))
+	for _, model := range models {
+		list = append(list, newPositionResp(model))
+	}
+	c.Response(list)
+}
+
+// 添加职位
+func (m *Manager) positionAdd(c *wkhttp.Context) {
+	err := c.CheckLoginRole()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req positionReq
+	if err := c.BindJSON(&req); err != nil {
+		m.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	org, err := m.getOrg(c.Param("org_id"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	model := &positionModel{
+		PositionID: util.GenerUUID(),
+		OrgID:      org.OrgID,
+		Name:       strings.TrimSpace(req.Name),
+		SortNum:    req.SortNum,
+	}
+	err = m.db.insertPosition(model)
+	if err != nil {
+		m.Error("添加职位失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加职位失败！"))
+		return
+	}
+	c.Response(newPositionResp(model))
+}
+
+// 删除职位（有员工担任的职位不能删除）
+func (m *Manager) positionDelete(c *wkhttp.Context) {
+	err := c.CheckLoginRole()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	org, err := m.getOrg(c.Param("org_id"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	position, err := m.db.queryPositionWithPositionID(c.Param("position_id"))
+	if err != nil {
+		m.Error("查询职位失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询职位失败！"))
+		return
+	}
+	if position == nil || position.OrgID != org.OrgID {
+		c.ResponseError(errors.New("职位不存在！"))
+		return
+	}
+	count, err := m.db.queryEmployeeCountWithPositionID(position.PositionID)
+	if err != nil {
+		m.Error("查询职位员工数量失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询职位员工数量失败！"))
+		return
+	}
+	if count > 0 {
+		c.ResponseError(errors.New("该职位下还有员工，不能删除！"))
+		return
+	}
+	err = m.db.deletePosition(position.PositionID)
+	if err != nil {
+		m.Error("删除职位失败！", zap.Error(err))
+		c.ResponseError(errors.New("删除职位失败！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 员工列表
+func (m *Manager) employeeList(c *wkhttp.Context) {
+	err := c.CheckLoginRole()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	org, err := m.getOrg(c.Param("org_id"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	resp, err := queryEmployees(m.db, org.OrgID, c)
+	if err != nil {
+		m.Error("查询员工失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询员工失败！"))
+		return
+	}
+	c.Response(resp)
+}
+
+// 添加员工（员工会加入组织全员群和所在部门的部门群）
+func (m *Manager) employeeAdd(c *wkhttp.Context) {
+	err := c.CheckLoginRole()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req employeeReq
+	if err := c.BindJSON(&req); err != nil {
+		m.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if strings.TrimSpace(req.UID) == "" {
+		c.ResponseError(errors.New("员工uid不能为空！"))
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	org, err := m.getOrg(c.Param("org_id"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	userModel, err := m.userDB.QueryByUID(req.UID)
+	if err != nil {
+		m.Error("查询用户信息失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询用户信息失败！"))
+		return
+	}
+	if userModel == nil || userModel.IsDestroy == 1 {
+		c.ResponseError(errors.New("用户不存在！"))
+		return
+	}
+	existEmployee, err := m.db.queryEmployee(org.OrgID, req.UID)
+	if err != nil {
+		m.Error("查询员工信息失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询员工信息失败！"))
+		return
+	}
+	if existEmployee != nil {
+		c.ResponseError(errors.New("该用户已是组织员工！"))
+		return
+	}
+	depts, err := m.checkEmployeeReq(org, &req)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	operator, err := m.getOrgOperator(org)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	model := &employeeModel{
+		OrgID:      org.OrgID,
+		UID:        userModel.UID,
+		Name:       strings.TrimSpace(req.Name),
+		EmployeeNo: req.EmployeeNo,
+		PositionID: req.PositionID,
+	}
+	if model.Name == "" {
+		model.Name = userModel.Name
+	}
+	tx, err := m.ctx.DB().Begin()
+	if err != nil {
+		m.Error("开启事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("开启事务失败！"))
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.RollbackUnlessCommitted()
+			panic(err)
+		}
+	}()
+	err = m.db.insertEmployeeTx(model, tx)
+	if err != nil {
+		tx.Rollback()
+		m.Error("添加员工失败！", zap.Error(err))
+		c.ResponseError(errors.New("添加员工失败！"))
+		return
+	}
+	addGroupNos := []string{org.OrgID}
+	for _, dept := range depts {
+		err = m.db.insertDeptEmployeeTx(&deptEmployeeModel{
+			OrgID:  org.OrgID,
+			DeptID: dept.DeptID,
+			UID:    model.UID,
+		}, tx)
+		if err != nil {
+			tx.Rollback()
+			m.Error("添加部门员工失败！", zap.Error(err))
+			c.ResponseError(errors.New("添加部门员工失败！"))
+			return
+		}
+		if dept.IsCreateGroup == 1 {
+			addGroupNos = append(addGroupNos, dept.DeptID)
+		}
+	}
+	eventID, err := m.beginEmployeeUpdateEvent(operator, &config.UserBaseVo{UID: model.UID, Name: model.Name}, addGroupNos, nil, tx)
+	if err != nil {
+		tx.Rollback()
+		m.Error("开启员工变更事件失败！", zap.Error(err))
+		c.ResponseError(errors.New("开启员工变更事件失败！"))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tx.RollbackUnlessCommitted()
+		m.Error("提交事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("提交事务失败！"))
+		return
+	}
+	m.ctx.EventCommit(eventID)
+	m.sendOrgCMD(model.UID, org.OrgID, common.CMDJoinOrganization)
+	c.ResponseOK()
+}
+
+// 修改员工（调整所在部门时同步加入或移出对应的部门群）
+func (m *Manager) employeeUpdate(c *wkhttp.Context) {
+	err := c.CheckLoginRole()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	var req employeeReq
+	if err := c.BindJSON(&req); err != nil {
+		m.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	org, err := m.getOrg(c.Param("org_id"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	model, err := m.getEmployee(org.OrgID, c.Param("uid"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	depts, err := m.checkEmployeeReq(org, &req)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	oldDeptIDs, err := m.db.queryDeptIDsWithUID(org.OrgID, model.UID)
+	if err != nil {
+		m.Error("查询员工所属部门失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询员工所属部门失败！"))
+		return
+	}
+	oldDepts, err := m.db.queryDeptsWithDeptIDs(oldDeptIDs)
+	if err != nil {
+		m.Error("查询员工所属部门失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询员工所属部门失败！"))
+		return
+	}
+	operator, err := m.getOrgOperator(org)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if name := strings.TrimSpace(req.Name); name != "" {
+		model.Name = name
+	}
+	model.EmployeeNo = req.EmployeeNo
+	model.PositionID = req.PositionID
+
+	newDeptMap := make(map[string]*deptModel, len(depts))
+	for _, dept := range depts {
+		newDeptMap[dept.DeptID] = dept
+	}
+	oldDeptMap := make(map[string]*deptModel, len(oldDepts))
+	for _, dept := range oldDepts {
+		oldDeptMap[dept.DeptID] = dept
+	}
+	tx, err := m.ctx.DB().Begin()
+	if err != nil {
+		m.Error("开启事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("开启事务失败！"))
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.RollbackUnlessCommitted()
+			panic(err)
+		}
+	}()
+	err = m.db.updateEmployeeTx(model, tx)
+	if err != nil {
+		tx.Rollback()
+		m.Error("修改员工失败！", zap.Error(err))
+		c.ResponseError(errors.New("修改员工失败！"))
+		return
+	}
+	addGroupNos := make([]string, 0)
+	deleteGroupNos := make([]string, 0)
+	for _, dept := range depts {
+		if oldDeptMap[dept.DeptID] != nil {
+			continue
+		}
+		err = m.db.insertDeptEmployeeTx(&deptEmployeeModel{
+			OrgID:  org.OrgID,
+			DeptID: dept.DeptID,
+			UID:    model.UID,
+		}, tx)
+		if err != nil {
+			tx.Rollback()
+			m.Error("添加部门员工失败！", zap.Error(err))
+			c.ResponseError(errors.New("添加部门员工失败！"))
+			return
+		}
+		if dept.IsCreateGroup == 1 {
+			addGroupNos = append(addGroupNos, dept.DeptID)
+		}
+	}
+	for _, dept := range oldDepts {
+		if newDeptMap[dept.DeptID] != nil {
+			continue
+		}
+		err = m.db.deleteDeptEmployeeTx(dept.DeptID, model.UID, tx)
+		if err != nil {
+			tx.Rollback()
+			m.Error("移除部门员工失败！", zap.Error(err))
+			c.ResponseError(errors.New("移除部门员工失败！"))
+			return
+		}
+		if dept.IsCreateGroup == 1 && model.UID != org.Creator { // 组织创建者是部门群的群主，不移出部门群
+			deleteGroupNos = append(deleteGroupNos, dept.DeptID)
+		}
+	}
+	eventID, err := m.beginEmployeeUpdateEvent(operator, &config.UserBaseVo{UID: model.UID, Name: model.Name}, addGroupNos, deleteGroupNos, tx)
+	if err != nil {
+		tx.Rollback()
+		m.Error("开启员工变更事件失败！", zap.Error(err))
+		c.ResponseError(errors.New("开启员工变更事件失败！"))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tx.RollbackUnlessCommitted()
+		m.Error("提交事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("提交事务失败！"))
+		return
+	}
+	if eventID > 0 {
+		m.ctx.EventCommit(eventID)
+	}
+	c.ResponseOK()
+}
+
+// 移除员工（员工退出组织全员群和所有部门群）
+func (m *Manager) employeeDelete(c *wkhttp.Context) {
+	err := c.CheckLoginRole()
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	org, err := m.getOrg(c.Param("org_id"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	model, err := m.getEmployee(org.OrgID, c.Param("uid"))
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if model.UID == org.Creator {
+		c.ResponseError(errors.New("不能移除组织创建者！"))
+		return
+	}
+	deptIDs, err := m.db.queryDeptIDsWithUID(org.OrgID, model.UID)
+	if err != nil {
+		m.Error("查询员工所属部门失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询员工所属部门失败！"))
+		return
+	}
+	depts, err := m.db.queryDeptsWithDeptIDs(deptIDs)
+	if err != nil {
+		m.Error("查询员工所属部门失败！", zap.Error(err))
+		c.ResponseError(errors.New("查询员工所属部门失败！"))
+		return
+	}
+	groupNos := []string{org.OrgID}
+	for _, dept := range depts {
+		if dept.IsCreateGroup == 1 {
+			groupNos = append(groupNos, dept.DeptID)
+		}
+	}
+	tx, err := m.ctx.DB().Begin()
+	if err != nil {
+		m.Error("开启事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("开启事务失败！"))
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.RollbackUnlessCommitted()
+			panic(err)
+		}
+	}()
+	err = m.db.deleteEmployeeTx(org.OrgID, model.UID, tx)
+	if err != nil {
+		tx.Rollback()
+		m.Error("移除员工失败！", zap.Error(err))
+		c.ResponseError(errors.New("移除员工失败！"))
+		return
+	}
+	err = m.db.deleteDeptEmployeesWithUIDTx(org.OrgID, model.UID, tx)
+	if err != nil {
+		tx.Rollback()
+		m.Error("移除部门员工失败！", zap.Error(err))
+		c.ResponseError(errors.New("移除部门员工失败！"))
+		return
+	}
+	eventID, err := m.beginEmployeeExitEvent(model.UID, groupNos, tx)
+	if err != nil {
+		tx.Rollback()
+		m.Error("开启员工退出事件失败！", zap.Error(err))
+		c.ResponseError(errors.New("开启员工退出事件失败！"))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tx.RollbackUnlessCommitted()
+		m.Error("提交事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("提交事务失败！"))
+		return
+	}
+	m.ctx.EventCommit(eventID)
+	m.sendOrgCMD(model.UID, org.OrgID, common.CMDQuitOrganization)
+	c.ResponseOK()
+}
+
+// 校验员工的职位和部门是否属于组织，返回员工所在的部门
+func (m *Manager) checkEmployeeReq(org *orgModel, req *employeeReq) ([]*deptModel, error) {
+	if req.PositionID != "" {
+		position, err := m.db.queryPositionWithPositionID(req.PositionID)
+		if err != nil {
+			m.Error("查询职位失败！", zap.Error(err))
+			return nil, errors.New("查询职位失败！")
+		}
+		if position == nil || position.OrgID != org.OrgID {
+			return nil, errors.New("职位不存在！")
+		}
+	}
+	deptIDs := util.RemoveRepeatedElement(req.DeptIDs)
+	depts, err := m.db.queryDeptsWithDeptIDs(deptIDs)
+	if err != nil {
+		m.Error("查询部门失败！", zap.Error(err))
+		return nil, errors.New("查询部门失败！")
+	}
+	if len(depts) != len(deptIDs) {
+		return nil, errors.New("部门不存在！")
+	}
+	for _, dept := range depts {
+		if dept.OrgID != org.OrgID {
+			return nil, errors.New("部门不存在！")
+		}
+	}
+	return depts, nil
+}
+
+// 组织和部门群的操作者（组织创建者）
+func (m *Manager) getOrgOperator(org *orgModel) (*config.UserBaseVo, error) {
+	creator, err := m.userDB.QueryByUID(org.Creator)
+	if err != nil {
+		m.Error("查询组织创建者失败！", zap.Error(err))
+		return nil, errors.New("查询组织创建者失败！")
+	}
+	operator := &config.UserBaseVo{
+		UID: org.Creator,
+	}
+	if creator != nil {
+		operator.Name = creator.Name
+	}
+	return operator, nil
+}
+
+func (m *Manager) getOrg(orgID string) (*orgModel, error) {
+	model, err := m.db.queryOrgWithOrgID(orgID)
+	if err != nil {
+		m.Error("查询组织失败！", zap.Error(err))
+		return nil, errors.New("查询组织失败！")
+	}
+	if model == nil {
+		return nil, errors.New("组织不存在！")
+	}
+	return model, nil
+}
+
+func (m *Manager) getDept(orgID string, deptID string) (*deptModel, error) {
+	model, err := m.db.queryDeptWithDeptID(deptID)
+	if err != nil {
+		m.Error("查询部门失败！", zap.Error(err))
+		return nil, errors.New("查询部门失败！")
+	}
+	if model == nil || model.OrgID != orgID {
+		return nil, errors.New("部门不存在！")
+	}
+	return model, nil
+}
+
+func (m *Manager) getEmployee(orgID string, uid string) (*employeeModel, error) {
+	model, err := m.db.queryEmployee(orgID, uid)
+	if err != nil {
+		m.Error("查询员工失败！", zap.Error(err))
+		return nil, errors.New("查询员工失败！")
+	}
+	if model == nil {
+		return nil, errors.New("员工不存在！")
+	}
+	return model, nil
+}
+
+// 校验部门的新上级部门（不能是自己或自己的下级部门）
+func checkDeptParent(depts []*deptModel, deptID string, parentID string) error {
+	deptMap := make(map[string]*deptModel, len(depts))
+	for _, dept := range depts {
+		deptMap[dept.DeptID] = dept
+	}
+	if deptMap[parentID] == nil {
+		return errors.New("上级部门不存在！")
+	}
+	for id := parentID; id != ""; {
+		if id == deptID {
+			return errors.New("上级部门不能是自己或自己的下级部门！")
+		}
+		parent := deptMap[id]
+		if parent == nil {
+			break
+		}
+		id = parent.ParentID
+	}
+	return nil
+}
+
+type orgReq struct {
+	Name    string `json:"name"`    // 组织名称
+	Logo    string `json:"logo"`    // 组织logo
+	Creator string `json:"creator"` // 创建者uid（仅创建时有效）
+	Status  int    `json:"status"`  // 状态 0.禁用 1.正常（仅修改时有效）
+}
+
+func (r orgReq) check() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("组织名称不能为空！")
+	}
+	if len([]rune(r.Name)) > NameMaxLength {
+		return fmt.Errorf("组织名称不能超过%d个字！", NameMaxLength)
+	}
+	return nil
+}
+
+type deptReq struct {
+	Name          string `json:"name"`            // 部门名称
+	ParentID      string `json:"parent_id"`       // 上级部门ID 为空表示一级部门
+	SortNum       int    `json:"sort_num"`        // 排序编号
+	IsCreateGroup int    `json:"is_create_group"` // 是否创建部门群 0.否 1.是
+}
+
+func (r deptReq) check() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("部门名称不能为空！")
+	}
+	if len([]rune(r.Name)) > NameMaxLength {
+		return fmt.Errorf("部门名称不能超过%d个字！", NameMaxLength)
+	}
+	if r.IsCreateGroup != 0 && r.IsCreateGroup != 1 {
+		return errors.New("是否创建部门群参数有误！")
+	}
+	return nil
+}
+
+type positionReq struct {
+	Name    string `json:"name"`     // 职位名称
+	SortNum int    `json:"sort_num"` // 排序编号
+}
+
+func (r positionReq) check() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("职位名称不能为空！")
+	}
+	if len([]rune(r.Name)) > NameMaxLength {
+		return fmt.Errorf("职位名称不能超过%d个字！", NameMaxLength)
+	}
+	return nil
+}
+
+type employeeReq struct {
+	UID        string   `json:"uid"`         // 员工uid（仅添加时有效）
+	Name       string   `json:"name"`        // 员工在组织内的名字 为空时使用用户名
+	EmployeeNo string   `json:"employee_no"` // 工号
+	PositionID string   `json:"position_id"` // 职位ID
+	DeptIDs    []string `json:"dept_ids"`    // 所在部门ID
+}
+
+func (r employeeReq) check() error {
+	if len([]rune(r.Name)) > NameMaxLength {
+		return fmt.Errorf("员工名字不能超过%d个字！", NameMaxLength)
+	}
+	if len(r.EmployeeNo) > 40 {
+		return errors.New("工号过长！")
+	}
+	return nil
+}
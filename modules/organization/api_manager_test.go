@@ -0,0 +1,80 @@
+package organization
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrgAdd(t *testing.T) {
+	s, ctx := testutil.NewTestServer()
+	//清除数据
+	err := testutil.CleanAllTables(ctx)
+	assert.NoError(t, err)
+	req, _ := http.NewRequest("POST", "/v1/manager/organizations", bytes.NewReader([]byte(util.ToJson(map[string]interface{}{
+		"name":    "唐僧叨叨",
+		"creator": testutil.UID,
+	}))))
+	w := httptest.NewRecorder()
+	req.Header.Set("token", testutil.Token)
+	s.GetRoute().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp orgResp
+	err = util.ReadJsonByByte(w.Body.Bytes(), &resp)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, resp.OrgID)
+
+	// 组织全员群随组织一起创建，创建者为群主
+	var groupCount int64
+	_, err = ctx.DB().Select("count(*)").From("`group`").Where("group_no=? and category=?", resp.OrgID, GroupCategoryOrganization).Load(&groupCount)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), groupCount)
+	var memberUIDs []string
+	_, err = ctx.DB().Select("uid").From("group_member").Where("group_no=? and role=1 and is_deleted=0", resp.OrgID).Load(&memberUIDs)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{testutil.UID}, memberUIDs)
+}
+
+func TestDeptAdd(t *testing.T) {
+	s, ctx := testutil.NewTestServer()
+	m := NewManager(ctx)
+	//清除数据
+	err := testutil.CleanAllTables(ctx)
+	assert.NoError(t, err)
+	tx, _ := ctx.DB().Begin()
+	err = m.db.insertOrgTx(&orgModel{
+		OrgID:   "org1",
+		Name:    "唐僧叨叨",
+		Creator: testutil.UID,
+		Status:  OrgStatusNormal,
+	}, tx)
+	assert.NoError(t, err)
+	err = tx.Commit()
+	assert.NoError(t, err)
+	req, _ := http.NewRequest("POST", "/v1/manager/organizations/org1/departments", bytes.NewReader([]byte(util.ToJson(map[string]interface{}{
+		"name":     "研发部",
+		"sort_num": 1,
+	}))))
+	w := httptest.NewRecorder()
+	req.Header.Set("token", testutil.Token)
+	s.GetRoute().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestCheckDeptParent(t *testing.T) {
+	depts := []*deptModel{
+		{DeptID: "a"},
+		{DeptID: "b", ParentID: "a"},
+		{DeptID: "c", ParentID: "b"},
+	}
+	assert.Error(t, checkDeptParent(depts, "a", "c"))
+	assert.Error(t, checkDeptParent(depts, "a", "a"))
+	assert.Error(t, checkDeptParent(depts, "a", "x"))
+	assert.NoError(t, checkDeptParent(depts, "c", "a"))
+}
@@ -0,0 +1,93 @@
+package organization
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMyOrgs(t *testing.T) {
+	s, ctx := testutil.NewTestServer()
+	o := New(ctx)
+	//清除数据
+	err := testutil.CleanAllTables(ctx)
+	assert.NoError(t, err)
+	tx, _ := ctx.DB().Begin()
+	err = o.db.insertOrgTx(&orgModel{
+		OrgID:   "org1",
+		Name:    "唐僧叨叨",
+		Creator: testutil.UID,
+		Status:  OrgStatusNormal,
+	}, tx)
+	assert.NoError(t, err)
+	err = o.db.insertEmployeeTx(&employeeModel{
+		OrgID: "org1",
+		UID:   testutil.UID,
+		Name:  "张三",
+	}, tx)
+	assert.NoError(t, err)
+	err = tx.Commit()
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/organizations", nil)
+	req.Header.Set("token", testutil.Token)
+	s.GetRoute().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `"org_id":"org1"`))
+}
+
+func TestDeptTree(t *testing.T) {
+	s, ctx := testutil.NewTestServer()
+	o := New(ctx)
+	//清除数据
+	err := testutil.CleanAllTables(ctx)
+	assert.NoError(t, err)
+	tx, _ := ctx.DB().Begin()
+	err = o.db.insertOrgTx(&orgModel{
+		OrgID:   "org1",
+		Name:    "唐僧叨叨",
+		Creator: testutil.UID,
+		Status:  OrgStatusNormal,
+	}, tx)
+	assert.NoError(t, err)
+	err = o.db.insertEmployeeTx(&employeeModel{
+		OrgID: "org1",
+		UID:   testutil.UID,
+		Name:  "张三",
+	}, tx)
+	assert.NoError(t, err)
+	err = o.db.insertDeptTx(&deptModel{
+		DeptID: "dept1",
+		OrgID:  "org1",
+		Name:   "研发部",
+	}, tx)
+	assert.NoError(t, err)
+	err = o.db.insertDeptTx(&deptModel{
+		DeptID:   "dept2",
+		OrgID:    "org1",
+		ParentID: "dept1",
+		Name:     "后端组",
+	}, tx)
+	assert.NoError(t, err)
+	err = o.db.insertDeptEmployeeTx(&deptEmployeeModel{
+		OrgID:  "org1",
+		DeptID: "dept2",
+		UID:    testutil.UID,
+	}, tx)
+	assert.NoError(t, err)
+	err = tx.Commit()
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/organizations/org1/departments", nil)
+	req.Header.Set("token", testutil.Token)
+	s.GetRoute().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `"dept_id":"dept2"`))
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `"employee_count":1`))
+}
@@ -0,0 +1,28 @@
+package organization
+
+// 组织状态
+const (
+	// OrgStatusDisabled 禁用
+	OrgStatusDisabled = 0
+	// OrgStatusNormal 正常
+	OrgStatusNormal = 1
+)
+
+// 组织和部门群的群分类
+const (
+	// GroupCategoryOrganization 组织全员群
+	GroupCategoryOrganization = "organization"
+	// GroupCategoryDepartment 部门群
+	GroupCategoryDepartment = "department"
+)
+
+// 组织或部门员工变更的操作类型
+const (
+	employeeActionAdd    = "add"
+	employeeActionDelete = "delete"
+)
+
+const (
+	// NameMaxLength 组织、部门和职位名称最大长度
+	NameMaxLength = 100
+)
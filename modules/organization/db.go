@@ -0,0 +1,303 @@
+package organization
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/pkg/util"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	dba "github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/db"
+	"github.com/gocraft/dbr/v2"
+)
+
+type db struct {
+	session *dbr.Session
+	ctx     *config.Context
+}
+
+func newDB(ctx *config.Context) *db {
+	return &db{
+		ctx:     ctx,
+		session: ctx.DB(),
+	}
+}
+
+// ---------- 组织 ----------
+
+func (d *db) insertOrgTx(m *orgModel, tx *dbr.Tx) error {
+	_, err := tx.InsertInto("organization").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *db) queryOrgWithOrgID(orgID string) (*orgModel, error) {
+	var m *orgModel
+	_, err := d.session.Select("*").From("organization").Where("org_id=?", orgID).Load(&m)
+	return m, err
+}
+
+func (d *db) updateOrg(m *orgModel) error {
+	_, err := d.session.Update("organization").SetMap(map[string]interface{}{
+		"name":   m.Name,
+		"logo":   m.Logo,
+		"status": m.Status,
+	}).Where("org_id=?", m.OrgID).Exec()
+	return err
+}
+
+// 查询用户加入的组织
+func (d *db) queryOrgsWithUID(uid string) ([]*orgModel, error) {
+	var models []*orgModel
+	_, err := d.session.Select("organization.*").From("organization_employee").Join("organization", "organization_employee.org_id=organization.org_id").Where("organization_employee.uid=? and organization.status=?", uid, OrgStatusNormal).OrderDir("organization_employee.created_at", true).Load(&models)
+	return models, err
+}
+
+func (d *db) queryOrgsWithPage(keyword string, pageSize, page uint64) ([]*orgModel, error) {
+	var models []*orgModel
+	builder := d.session.Select("*").From("organization")
+	if keyword != "" {
+		builder = builder.Where("name like ?", "%"+keyword+"%")
+	}
+	_, err := builder.Offset((page-1)*pageSize).Limit(pageSize).OrderDir("created_at", false).Load(&models)
+	return models, err
+}
+
+func (d *db) queryOrgCount(keyword string) (int64, error) {
+	var count int64
+	builder := d.session.Select("count(*)").From("organization")
+	if keyword != "" {
+		builder = builder.Where("name like ?", "%"+keyword+"%")
+	}
+	_, err := builder.Load(&count)
+	return count, err
+}
+
+// ---------- 部门 ----------
+
+func (d *db) insertDeptTx(m *deptModel, tx *dbr.Tx) error {
+	_, err := tx.InsertInto("organization_department").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *db) queryDeptWithDeptID(deptID string) (*deptModel, error) {
+	var m *deptModel
+	_, err := d.session.Select("*").From("organization_department").Where("dept_id=?", deptID).Load(&m)
+	return m, err
+}
+
+func (d *db) queryDeptsWithOrgID(orgID string) ([]*deptModel, error) {
+	var models []*deptModel
+	_, err := d.session.Select("*").From("organization_department").Where("org_id=?", orgID).OrderDir("sort_num", true).OrderDir("id", true).Load(&models)
+	return models, err
+}
+
+func (d *db) queryDeptsWithDeptIDs(deptIDs []string) ([]*deptModel, error) {
+	if len(deptIDs) == 0 {
+		return nil, nil
+	}
+	var models []*deptModel
+	_, err := d.session.Select("*").From("organization_department").Where("dept_id in ?", deptIDs).Load(&models)
+	return models, err
+}
+
+func (d *db) querySubDeptCount(deptID string) (int64, error) {
+	var count int64
+	_, err := d.session.Select("count(*)").From("organization_department").Where("parent_id=?", deptID).Load(&count)
+	return count, err
+}
+
+func (d *db) updateDeptTx(m *deptModel, tx *dbr.Tx) error {
+	_, err := tx.Update("organization_department").SetMap(map[string]interface{}{
+		"name":            m.Name,
+		"parent_id":       m.ParentID,
+		"sort_num":        m.SortNum,
+		"is_create_group": m.IsCreateGroup,
+	}).Where("dept_id=?", m.DeptID).Exec()
+	return err
+}
+
+func (d *db) deleteDeptTx(deptID string, tx *dbr.Tx) error {
+	_, err := tx.DeleteFrom("organization_department").Where("dept_id=?", deptID).Exec()
+	return err
+}
+
+// ---------- 职位 ----------
+
+func (d *db) insertPosition(m *positionModel) error {
+	_, err := d.session.InsertInto("organization_position").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *db) queryPositionWithPositionID(positionID string) (*positionModel, error) {
+	var m *positionModel
+	_, err := d.session.Select("*").From("organization_position").Where("position_id=?", positionID).Load(&m)
+	return m, err
+}
+
+func (d *db) queryPositionsWithOrgID(orgID string) ([]*positionModel, error) {
+	var models []*positionModel
+	_, err := d.session.Select("*").From("organization_position").Where("org_id=?", orgID).OrderDir("sort_num", true).OrderDir("id", true).Load(&models)
+	return models, err
+}
+
+func (d *db) deletePosition(positionID string) error {
+	_, err := d.session.DeleteFrom("organization_position").Where("position_id=?", positionID).Exec()
+	return err
+}
+
+func (d *db) queryEmployeeCountWithPositionID(positionID string) (int64, error) {
+	var count int64
+	_, err := d.session.Select("count(*)").From("organization_employee").Where("position_id=?", positionID).Load(&count)
+	return count, err
+}
+
+// ---------- 员工 ----------
+
+func (d *db) insertEmployeeTx(m *employeeModel, tx *dbr.Tx) error {
+	_, err := tx.InsertInto("organization_employee").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *db) queryEmployee(orgID string, uid string) (*employeeModel, error) {
+	var m *employeeModel
+	_, err := d.session.Select("*").From("organization_employee").Where("org_id=? and uid=?", orgID, uid).Load(&m)
+	return m, err
+}
+
+func (d *db) updateEmployeeTx(m *employeeModel, tx *dbr.Tx) error {
+	_, err := tx.Update("organization_employee").SetMap(map[string]interface{}{
+		"name":        m.Name,
+		"employee_no": m.EmployeeNo,
+		"position_id": m.PositionID,
+	}).Where("org_id=? and uid=?", m.OrgID, m.UID).Exec()
+	return err
+}
+
+func (d *db) deleteEmployeeTx(orgID string, uid string, tx *dbr.Tx) error {
+	_, err := tx.DeleteFrom("organization_employee").Where("org_id=? and uid=?", orgID, uid).Exec()
+	return err
+}
+
+// 查询组织内的员工（deptID不为空时只查询该部门的员工，keyword匹配名字或工号）
+func (d *db) queryEmployeeDetails(orgID string, deptID string, keyword string, pageSize, page uint64) ([]*employeeDetailModel, error) {
+	var models []*employeeDetailModel
+	builder := d.session.Select("organization_employee.*,IFNULL(organization_position.name,'') position_name").From("organization_employee").LeftJoin("organization_position", "organization_employee.position_id=organization_position.position_id").Where("organization_employee.org_id=?", orgID)
+	if deptID != "" {
+		builder = builder.Join("organization_department_employee", "organization_department_employee.org_id=organization_employee.org_id and organization_department_employee.uid=organization_employee.uid").Where("organization_department_employee.dept_id=?", deptID)
+	}
+	if keyword != "" {
+		builder = builder.Where("(organization_employee.name like ? or organization_employee.employee_no like ?)", "%"+keyword+"%", "%"+keyword+"%")
+	}
+	_, err := builder.Offset((page-1)*pageSize).Limit(pageSize).OrderDir("organization_employee.id", true).Load(&models)
+	return models, err
+}
+
+func (d *db) queryEmployeeCount(orgID string, deptID string, keyword string) (int64, error) {
+	var count int64
+	builder := d.session.Select("count(*)").From("organization_employee").Where("organization_employee.org_id=?", orgID)
+	if deptID != "" {
+		builder = builder.Join("organization_department_employee", "organization_department_employee.org_id=organization_employee.org_id and organization_department_employee.uid=organization_employee.uid").Where("organization_department_employee.dept_id=?", deptID)
+	}
+	if keyword != "" {
+		builder = builder.Where("(organization_employee.name like ? or organization_employee.employee_no like ?)", "%"+keyword+"%", "%"+keyword+"%")
+	}
+	_, err := builder.Load(&count)
+	return count, err
+}
+
+// ---------- 员工所属部门 ----------
+
+func (d *db) insertDeptEmployeeTx(m *deptEmployeeModel, tx *dbr.Tx) error {
+	_, err := tx.InsertInto("organization_department_employee").Columns(util.AttrToUnderscore(m)...).Record(m).Exec()
+	return err
+}
+
+func (d *db) deleteDeptEmployeeTx(deptID string, uid string, tx *dbr.Tx) error {
+	_, err := tx.DeleteFrom("organization_department_employee").Where("dept_id=? and uid=?", deptID, uid).Exec()
+	return err
+}
+
+func (d *db) deleteDeptEmployeesWithUIDTx(orgID string, uid string, tx *dbr.Tx) error {
+	_, err := tx.DeleteFrom("organization_department_employee").Where("org_id=? and uid=?", orgID, uid).Exec()
+	return err
+}
+
+// 查询员工所属的部门ID
+func (d *db) queryDeptIDsWithUID(orgID string, uid string) ([]string, error) {
+	var deptIDs []string
+	_, err := d.session.Select("dept_id").From("organization_department_employee").Where("org_id=? and uid=?", orgID, uid).Load(&deptIDs)
+	return deptIDs, err
+}
+
+// 查询一批员工所属的部门
+func (d *db) queryDeptEmployeesWithUIDs(orgID string, uids []string) ([]*deptEmployeeModel, error) {
+	if len(uids) == 0 {
+		return nil, nil
+	}
+	var models []*deptEmployeeModel
+	_, err := d.session.Select("*").From("organization_department_employee").Where("org_id=? and uid in ?", orgID, uids).Load(&models)
+	return models, err
+}
+
+// 查询部门的员工uid
+func (d *db) queryUIDsWithDeptID(deptID string) ([]string, error) {
+	var uids []string
+	_, err := d.session.Select("uid").From("organization_department_employee").Where("dept_id=?", deptID).Load(&uids)
+	return uids, err
+}
+
+// 查询组织内每个部门的员工数量
+func (d *db) queryDeptEmployeeCounts(orgID string) ([]*deptEmployeeCountModel, error) {
+	var models []*deptEmployeeCountModel
+	_, err := d.session.Select("dept_id,count(*) count").From("organization_department_employee").Where("org_id=?", orgID).GroupBy("dept_id").Load(&models)
+	return models, err
+}
+
+type orgModel struct {
+	OrgID   string // 组织ID
+	Name    string // 组织名称
+	Logo    string // 组织logo
+	Creator string // 创建者uid
+	Status  int    // 状态 0.禁用 1.正常
+	dba.BaseModel
+}
+
+type deptModel struct {
+	DeptID        string // 部门ID
+	OrgID         string // 所属组织ID
+	ParentID      string // 上级部门ID 为空表示一级部门
+	Name          string // 部门名称
+	SortNum       int    // 排序编号
+	IsCreateGroup int    // 是否创建部门群 0.否 1.是
+	dba.BaseModel
+}
+
+type positionModel struct {
+	PositionID string // 职位ID
+	OrgID      string // 所属组织ID
+	Name       string // 职位名称
+	SortNum    int    // 排序编号
+	dba.BaseModel
+}
+
+type employeeModel struct {
+	OrgID      string // 所属组织ID
+	UID        string // 员工uid
+	Name       string // 员工在组织内的名字
+	EmployeeNo string // 工号
+	PositionID string // 职位ID
+	dba.BaseModel
+}
+
+type employeeDetailModel struct {
+	employeeModel
+	PositionName string // 职位名称
+}
+
+type deptEmployeeModel struct {
+	OrgID  string // 所属组织ID
+	DeptID string // 部门ID
+	UID    string // 员工uid
+	dba.BaseModel
+}
+
+type deptEmployeeCountModel struct {
+	DeptID string
+	Count  int
+}
@@ -0,0 +1,114 @@
+package organization
+
+import (
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/event"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/pkg/wkevent"
+	"github.com/gocraft/dbr/v2"
+	"go.uber.org/zap"
+)
+
+// 发布组织或部门创建事件（群模块收到后创建组织全员群或部门群，群编号为组织ID或部门ID，组织创建者为群主）
+func (m *Manager) beginOrgOrDeptCreateEvent(groupNo string, category string, name string, operator *config.UserBaseVo, memberUIDs []string, tx *dbr.Tx) (int64, error) {
+	members := make([]*config.OrgOrDeptEmployeeVO, 0, len(memberUIDs))
+	for _, uid := range memberUIDs {
+		if uid == operator.UID { // 创建者由群模块作为群主添加
+			continue
+		}
+		members = append(members, &config.OrgOrDeptEmployeeVO{
+			Operator:     operator.UID,
+			OperatorName: operator.Name,
+			EmployeeUid:  uid,
+			GroupNo:      groupNo,
+			Action:       employeeActionAdd,
+		})
+	}
+	return m.ctx.EventBegin(&wkevent.Data{
+		Event: event.OrgOrDeptCreate,
+		Type:  wkevent.Message,
+		Data: &config.MsgOrgOrDeptCreateReq{
+			GroupNo:       groupNo,
+			GroupCategory: category,
+			Name:          name,
+			Operator:      operator.UID,
+			OperatorName:  operator.Name,
+			Members:       members,
+		},
+	}, tx)
+}
+
+// 发布组织或部门员工变更事件（群模块收到后将员工加入或移出对应的组织群和部门群）
+func (m *Manager) beginEmployeeUpdateEvent(operator *config.UserBaseVo, employee *config.UserBaseVo, addGroupNos []string, deleteGroupNos []string, tx *dbr.Tx) (int64, error) {
+	if len(addGroupNos) == 0 && len(deleteGroupNos) == 0 {
+		return 0, nil
+	}
+	members := make([]*config.OrgOrDeptEmployeeVO, 0, len(addGroupNos)+len(deleteGroupNos))
+	for _, groupNo := range addGroupNos {
+		members = append(members, &config.OrgOrDeptEmployeeVO{
+			Operator:     operator.UID,
+			OperatorName: operator.Name,
+			EmployeeUid:  employee.UID,
+			EmployeeName: employee.Name,
+			GroupNo:      groupNo,
+			Action:       employeeActionAdd,
+		})
+	}
+	for _, groupNo := range deleteGroupNos {
+		members = append(members, &config.OrgOrDeptEmployeeVO{
+			Operator:     operator.UID,
+			OperatorName: operator.Name,
+			EmployeeUid:  employee.UID,
+			EmployeeName: employee.Name,
+			GroupNo:      groupNo,
+			Action:       employeeActionDelete,
+		})
+	}
+	return m.ctx.EventBegin(&wkevent.Data{
+		Event: event.OrgOrDeptEmployeeUpdate,
+		Type:  wkevent.Message,
+		Data: &config.MsgOrgOrDeptEmployeeUpdateReq{
+			Members: members,
+		},
+	}, tx)
+}
+
+// 发布员工退出组织事件（群模块收到后将员工移出组织群和所有部门群）
+func (m *Manager) beginEmployeeExitEvent(uid string, groupNos []string, tx *dbr.Tx) (int64, error) {
+	return m.ctx.EventBegin(&wkevent.Data{
+		Event: event.OrgEmployeeExit,
+		Type:  wkevent.Message,
+		Data: &config.OrgEmployeeExitReq{
+			Operator: uid,
+			GroupNos: groupNos,
+		},
+	}, tx)
+}
+
+// 发布部门删除事件（群模块收到后解散对应的部门群）
+func (m *Manager) beginDeptDeleteEvent(deptID string, operator *config.UserBaseVo, tx *dbr.Tx) (int64, error) {
+	return m.ctx.EventBegin(&wkevent.Data{
+		Event: event.OrgDeptDelete,
+		Type:  wkevent.Message,
+		Data: &config.MsgGroupDisband{
+			GroupNo:      deptID,
+			Operator:     operator.UID,
+			OperatorName: operator.Name,
+		},
+	}, tx)
+}
+
+// 通知员工加入或退出了组织
+func (m *Manager) sendOrgCMD(uid string, orgID string, cmd string) {
+	err := m.ctx.SendCMD(config.MsgCMDReq{
+		ChannelID:   uid,
+		ChannelType: common.ChannelTypePerson.Uint8(),
+		CMD:         cmd,
+		Param: map[string]interface{}{
+			"org_id": orgID,
+		},
+	})
+	if err != nil {
+		m.Warn("发送组织命令消息失败！", zap.Error(err), zap.String("cmd", cmd))
+	}
+}
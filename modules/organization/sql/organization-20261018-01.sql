@@ -0,0 +1,68 @@
+-- +migrate Up
+
+-- 组织
+create table `organization`(
+    id              bigint         not null primary key AUTO_INCREMENT,
+    org_id          VARCHAR(40)    not null DEFAULT '',                -- 组织ID（同时为组织全员群的群编号）
+    name            VARCHAR(100)   not null DEFAULT '',                -- 组织名称
+    logo            VARCHAR(255)   not null DEFAULT '',                -- 组织logo
+    creator         VARCHAR(40)    not null DEFAULT '',                -- 创建者uid（组织群和部门群的群主）
+    status          smallint       not null DEFAULT 1,                 -- 状态 0.禁用 1.正常
+    created_at      timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+    updated_at      timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE UNIQUE INDEX organization_org_id on `organization` (org_id);
+
+-- 部门
+create table `organization_department`(
+    id              bigint         not null primary key AUTO_INCREMENT,
+    dept_id         VARCHAR(40)    not null DEFAULT '',                -- 部门ID（开启部门群时同时为部门群的群编号）
+    org_id          VARCHAR(40)    not null DEFAULT '',                -- 所属组织ID
+    parent_id       VARCHAR(40)    not null DEFAULT '',                -- 上级部门ID 为空表示一级部门
+    name            VARCHAR(100)   not null DEFAULT '',                -- 部门名称
+    sort_num        integer        not null DEFAULT 0,                 -- 排序编号
+    is_create_group smallint       not null DEFAULT 0,                 -- 是否创建部门群 0.否 1.是
+    created_at      timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+    updated_at      timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE UNIQUE INDEX organization_department_dept_id on `organization_department` (dept_id);
+CREATE INDEX organization_department_org_id on `organization_department` (org_id);
+
+-- 职位
+create table `organization_position`(
+    id              bigint         not null primary key AUTO_INCREMENT,
+    position_id     VARCHAR(40)    not null DEFAULT '',                -- 职位ID
+    org_id          VARCHAR(40)    not null DEFAULT '',                -- 所属组织ID
+    name            VARCHAR(100)   not null DEFAULT '',                -- 职位名称
+    sort_num        integer        not null DEFAULT 0,                 -- 排序编号
+    created_at      timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+    updated_at      timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE UNIQUE INDEX organization_position_position_id on `organization_position` (position_id);
+CREATE INDEX organization_position_org_id on `organization_position` (org_id);
+
+-- 员工
+create table `organization_employee`(
+    id              bigint         not null primary key AUTO_INCREMENT,
+    org_id          VARCHAR(40)    not null DEFAULT '',                -- 所属组织ID
+    uid             VARCHAR(40)    not null DEFAULT '',                -- 员工uid
+    name            VARCHAR(100)   not null DEFAULT '',                -- 员工在组织内的名字
+    employee_no     VARCHAR(40)    not null DEFAULT '',                -- 工号
+    position_id     VARCHAR(40)    not null DEFAULT '',                -- 职位ID
+    created_at      timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+    updated_at      timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE UNIQUE INDEX organization_employee_org_uid on `organization_employee` (org_id, uid);
+CREATE INDEX organization_employee_uid on `organization_employee` (uid);
+
+-- 员工所属部门（一个员工可以属于多个部门）
+create table `organization_department_employee`(
+    id              bigint         not null primary key AUTO_INCREMENT,
+    org_id          VARCHAR(40)    not null DEFAULT '',                -- 所属组织ID
+    dept_id         VARCHAR(40)    not null DEFAULT '',                -- 部门ID
+    uid             VARCHAR(40)    not null DEFAULT '',                -- 员工uid
+    created_at      timeStamp      not null DEFAULT CURRENT_TIMESTAMP, -- 创建时间
+    updated_at      timeStamp      not null DEFAULT CURRENT_TIMESTAMP  -- 更新时间
+);
+CREATE UNIQUE INDEX organization_department_employee_dept_uid on `organization_department_employee` (dept_id, uid);
+CREATE INDEX organization_department_employee_org_uid on `organization_department_employee` (org_id, uid);
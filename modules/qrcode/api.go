@@ -102,6 +102,17 @@ func (q *QRCode) handleQRCodeInfo(c *wkhttp.Context) {
 		return
 	}
 
+	if strings.HasPrefix(code, group.InviteLinkCodePrefix) { // 群邀请链接 格式： grouplink_xxxx
+		result, err := q.handleInviteLink(loginUID, code[len(group.InviteLinkCodePrefix):])
+		if err != nil {
+			q.Error("处理群邀请链接失败！", zap.Error(err))
+			c.ResponseError(err)
+			return
+		}
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
 	qrcodeContent, err := q.ctx.GetRedisConn().GetString(fmt.Sprintf("%s%s", common.QRCodeCachePrefix, code))
 	if err != nil {
 		q.Error("获取二维码信息失败！", zap.Error(err))
@@ -170,10 +181,31 @@ func (q *QRCode) handleScanLogin(loginUID string, uuid string, qrCodeModel commo
 	}), nil
 }
 
+// 处理群邀请链接
+func (q *QRCode) handleInviteLink(loginUID string, linkNo string) (interface{}, error) {
+	inviteLink, err := q.groupDB.QueryInviteLinkWithLinkNo(linkNo)
+	if err != nil {
+		q.Error("查询邀请链接失败！", zap.Error(err))
+		return nil, errors.New("查询邀请链接失败！")
+	}
+	if inviteLink == nil {
+		return nil, errors.New("邀请链接不存在！")
+	}
+	if err = inviteLink.CheckAvailable(); err != nil {
+		return nil, err
+	}
+	return q.handleJoinGroup(loginUID, *common.NewQRCodeModel(common.QRCodeTypeGroup, map[string]interface{}{
+		"group_no":  inviteLink.GroupNo,
+		"generator": inviteLink.Creator,
+		"link_no":   inviteLink.LinkNo,
+	}))
+}
+
 // 处理扫码入群
 func (q *QRCode) handleJoinGroup(loginUID string, qrCodeModel common.QRCodeModel) (interface{}, error) {
 	groupNo := qrCodeModel.Data["group_no"].(string)
 	generator := qrCodeModel.Data["generator"].(string)
+	linkNo, _ := qrCodeModel.Data["link_no"].(string) // 通过邀请链接入群时存在
 
 	exist, err := q.groupDB.ExistMember(loginUID, groupNo) // 已在群内
 	if err != nil {
@@ -189,6 +221,7 @@ func (q *QRCode) handleJoinGroup(loginUID string, qrCodeModel common.QRCodeModel
 		"group_no":  groupNo,   // 群编号
 		"generator": generator, // 二维码生成者
 		"scaner":    loginUID,  // 二维码扫码者
+		"link_no":   linkNo,    // 邀请链接编号
 		"type":      common.AuthCodeTypeJoinGroup,
 	}), time.Minute*30)
 	if err != nil {
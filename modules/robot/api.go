@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/base/app"
+	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/message"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServer/modules/user"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/common"
 	"github.com/TangSengDaoDao/TangSengDaoDaoServerLib/config"
@@ -31,6 +32,7 @@ type Robot struct {
 	robotEventPrefix                  string
 	userService                       user.IService
 	appService                        app.IService
+	messageService                    message.IService
 	inlineQueryEventsMap              map[string][]*robotEvent // inlineQuery事件
 	inlineQueryEventsMapLock          sync.RWMutex
 	inlineQueryEventResultChanMap     map[string]chan *InlineQueryResult
@@ -46,6 +48,7 @@ func New(ctx *config.Context) *Robot {
 		robotEventPrefix:              "robotEvent:",
 		userService:                   user.NewService(ctx),
 		appService:                    app.NewService(ctx),
+		messageService:                message.NewService(ctx),
 		inlineQueryEventsMap:          map[string][]*robotEvent{},
 		inlineQueryEventResultChanMap: map[string]chan *InlineQueryResult{},
 		mentionRegexp:                 regexp.MustCompile(`@\S+`),
@@ -235,6 +238,18 @@ func (rb *Robot) sendMessage(c *wkhttp.Context) {
 		c.ResponseError(fmt.Errorf("无效的payload[%s]", util.ToJson(messageReq.Payload)))
 		return
 	}
+	if contentType == common.Card {
+		allow, err := rb.userService.AllowShareCard(payloadResult.Str("uid"))
+		if err != nil {
+			rb.Error("查询用户是否允许分享名片失败！", zap.Error(err))
+			c.ResponseError(errors.New("查询用户是否允许分享名片失败！"))
+			return
+		}
+		if !allow {
+			c.ResponseError(errors.New("该用户不允许分享名片！"))
+			return
+		}
+	}
 	robotID := c.Param("robot_id")
 	userResp, err := rb.userService.GetUserWithUsername(robotID)
 	if err != nil {
@@ -246,12 +261,42 @@ func (rb *Robot) sendMessage(c *wkhttp.Context) {
 		c.ResponseError(fmt.Errorf("机器人[%s]不存在！", robotID))
 		return
 	}
+	payload, err := rb.messageService.FilterProhibitWords(messageReq.Payload)
+	if err != nil {
+		if errors.Is(err, message.ErrProhibitWordsBlocked) {
+			c.ResponseError(err)
+			return
+		}
+		rb.Error("检查违禁词失败！", zap.Error(err))
+		c.ResponseError(errors.New("检查违禁词失败！"))
+		return
+	}
+	if messageReq.SendAt > 0 { // 定时发送
+		scheduledNo, err := rb.messageService.ScheduleMessage(&message.ScheduleMessageReq{
+			Source:      message.ScheduledMessageSourceRobot,
+			Creator:     robotID,
+			FromUID:     robotID,
+			ChannelID:   messageReq.ChannelID,
+			ChannelType: messageReq.ChannelType,
+			Payload:     messageReq.Payload,
+			SendAt:      messageReq.SendAt,
+		})
+		if err != nil {
+			rb.Error("添加robot定时消息失败！", zap.Error(err))
+			c.ResponseError(err)
+			return
+		}
+		c.Response(map[string]interface{}{
+			"scheduled_no": scheduledNo,
+		})
+		return
+	}
 	result, err := rb.ctx.SendMessageWithResult(&config.MsgSendReq{
 		StreamNo:    messageReq.StreamNo,
 		ChannelID:   messageReq.ChannelID,
 		ChannelType: messageReq.ChannelType,
 		FromUID:     robotID,
-		Payload:     []byte(util.ToJson(messageReq.Payload)),
+		Payload:     []byte(util.ToJson(payload)),
 	})
 	if err != nil {
 		rb.Error("发送robot消息失败！", zap.Error(err))
@@ -262,7 +307,7 @@ func (rb *Robot) sendMessage(c *wkhttp.Context) {
 }
 
 func (rb *Robot) supportContentType(contentType common.ContentType) bool {
-	return contentType == common.Text
+	return contentType == common.Text || contentType == common.Card
 }
 
 func (rb *Robot) payloadIsVail(payloadResult maputil.Data) bool {
@@ -272,6 +317,11 @@ func (rb *Robot) payloadIsVail(payloadResult maputil.Data) bool {
 			return true
 		}
 	}
+	if contentType == common.Card {
+		if strings.TrimSpace(payloadResult.Str("uid")) != "" {
+			return true
+		}
+	}
 	return false
 }
 
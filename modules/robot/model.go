@@ -60,6 +60,7 @@ type MessageReq struct {
 	StreamNo    string                 `json:"stream_no"`
 	Entities    []*Entitiy             `json:"entities"`
 	Payload     map[string]interface{} `json:"payload"`
+	SendAt      int64                  `json:"send_at"` // 定时发送时间（10位时间戳） 为0表示立即发送
 }
 
 type Entitiy struct {
@@ -227,6 +227,16 @@ func CheckRequestAddFriendCode(code string, requestUID string) error {
 	return nil
 }
 
+// GetCodeType 获取验证码类型
+func GetCodeType(code string) common.VercodeType {
+	strs := strings.Split(code, "@")
+	if len(strs) < 2 {
+		return 0
+	}
+	codeTypeStr, _ := strconv.Atoi(strs[1])
+	return common.VercodeType(codeTypeStr)
+}
+
 // CheckSource 验证加好友来源
 func CheckSource(code string) error {
 	strs := strings.Split(code, "@")
@@ -701,7 +701,8 @@ func (u *User) userUpdateSetting(c *wkhttp.Context) {
 			key == "offline_protection" ||
 			key == "voice_on" ||
 			key == "shock_on" ||
-			key == "mute_of_app" {
+			key == "mute_of_app" ||
+			key == "allow_share_card" {
 			err = u.db.UpdateUsersWithField(key, fmt.Sprintf("%v", value), loginUID)
 			if err != nil {
 				u.Error("修改用户资料失败", zap.Error(err))
@@ -2274,12 +2275,46 @@ func (u *User) destroyAccount(c *wkhttp.Context) {
 	time := fmt.Sprintf("%d%d%d%d%d", t.Year(), t.Month(), t.Day(), t.Minute(), t.Second())
 	phone := fmt.Sprintf("%s@%s@delete", userInfo.Phone, time)
 	username := fmt.Sprintf("%s%s", userInfo.Zone, phone)
-	err = u.db.destroyAccount(loginUID, username, phone)
+	tx, err := u.db.session.Begin()
 	if err != nil {
+		u.Error("开启事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("开启事务失败！"))
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.RollbackUnlessCommitted()
+			panic(err)
+		}
+	}()
+	err = u.db.destroyAccountTx(loginUID, username, phone, tx)
+	if err != nil {
+		tx.Rollback()
 		u.Error("注销账号错误", zap.Error(err))
 		c.ResponseError(errors.New("注销账号错误"))
 		return
 	}
+	// 发送用户注销事件（处理用户创建的群的群主继承等）
+	eventID, err := u.ctx.EventBegin(&wkevent.Data{
+		Event: event.EventUserDestroy,
+		Type:  wkevent.Message,
+		Data: map[string]interface{}{
+			"uid": loginUID,
+		},
+	}, tx)
+	if err != nil {
+		tx.Rollback()
+		u.Error("开启事件失败！", zap.Error(err))
+		c.ResponseError(errors.New("开启事件失败！"))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		tx.RollbackUnlessCommitted()
+		u.Error("提交事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("提交事务失败！"))
+		return
+	}
+	u.ctx.EventCommit(eventID)
 	err = u.ctx.QuitUserDevice(c.GetLoginUID(), -1) // 退出全部登陆设备
 	if err != nil {
 		u.Error("退出登陆设备失败", zap.Error(err))
@@ -2587,6 +2622,7 @@ func (u *User) createUserWithRespAndTx(registerSpanCtx context.Context, createUs
 	userModel.SearchByShort = 1
 	userModel.VoiceOn = 1
 	userModel.ShockOn = 1
+	userModel.AllowShareCard = 1
 	userModel.IsUploadAvatar = createUser.IsUploadAvatar
 	userModel.WXOpenid = createUser.WXOpenid
 	userModel.WXUnionid = createUser.WXUnionid
@@ -2815,6 +2851,7 @@ type setting struct {
 	OfflineProtection int `json:"offline_protection"` //离线保护，断网屏保
 	DeviceLock        int `json:"device_lock"`        // 设备锁
 	MuteOfApp         int `json:"mute_of_app"`        // web登录 app是否静音
+	AllowShareCard    int `json:"allow_share_card"`   // 是否允许他人分享自己的名片0.否1.是
 }
 
 type blacklistResp struct {
@@ -2852,6 +2889,7 @@ func newLoginUserDetailResp(m *Model, token string, ctx *config.Context) *loginU
 			OfflineProtection: m.OfflineProtection,
 			DeviceLock:        m.DeviceLock,
 			MuteOfApp:         m.MuteOfApp,
+			AllowShareCard:    m.AllowShareCard,
 		},
 	}
 }
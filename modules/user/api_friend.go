@@ -158,6 +158,7 @@ func (f *Friend) apply(c *wkhttp.Context) {
 				Remark:    apply.Remark,
 				Status:    apply.Status,
 				Token:     apply.Token,
+				Source:    apply.Source,
 				CreatedAt: apply.CreatedAt.String(),
 			})
 		}
@@ -356,6 +357,12 @@ func (f *Friend) friendApply(c *wkhttp.Context) {
 		c.ResponseError(err)
 		return
 	}
+	// 通过名片添加需对方允许分享名片
+	if source.GetCodeType(req.Vercode) == common.Friend && toUser.AllowShareCard != 1 {
+		c.ResponseError(errors.New("对方已关闭名片分享，无法通过名片添加！"))
+		return
+	}
+	applySource := source.GetSoruce(req.Vercode)
 	// 设置token
 	token := util.GenerUUID()
 
@@ -403,6 +410,7 @@ func (f *Friend) friendApply(c *wkhttp.Context) {
 			ToUID:  fromUID,
 			Remark: req.Remark,
 			Token:  token,
+			Source: applySource,
 		}, tx)
 		if err != nil {
 			tx.Rollback()
@@ -411,9 +419,12 @@ func (f *Friend) friendApply(c *wkhttp.Context) {
 			return
 		}
 	} else {
-		if apply.Status != 0 {
-			isAddCount = true
+		if apply.Status != 0 || apply.Source != applySource {
+			if apply.Status != 0 {
+				isAddCount = true
+			}
 			apply.Status = 0
+			apply.Source = applySource
 			err = f.db.updateApplyTx(apply, tx)
 			if err != nil {
 				tx.Rollback()
@@ -468,6 +479,7 @@ func (f *Friend) friendApply(c *wkhttp.Context) {
 			"to_uid":     toUser.UID,
 			"remark":     req.Remark,
 			"token":      token,
+			"source":     applySource,
 		},
 	})
 	if err != nil {
@@ -948,6 +960,7 @@ type friendApplyResp struct {
 	Remark    string `json:"remark"`
 	Status    int    `json:"status"` // 状态 0.未处理 1.通过 2.拒绝
 	Token     string `json:"token"`
+	Source    string `json:"source"` // 申请来源
 	CreatedAt string `json:"created_at"`
 }
 
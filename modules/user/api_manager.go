@@ -463,6 +463,7 @@ func (m *Manager) addUser(c *wkhttp.Context) {
 	userModel.SearchByShort = 1
 	userModel.VoiceOn = 1
 	userModel.ShockOn = 1
+	userModel.AllowShareCard = 1
 	userModel.Sex = req.Sex
 	userModel.Status = int(common.UserAvailable)
 	err = m.userDB.insertTx(userModel, tx)
@@ -849,12 +850,51 @@ func (m *Manager) liftBanUser(c *wkhttp.Context) {
 		c.ResponseOK()
 		return
 	}
-	err = m.userDB.UpdateUsersWithField("status", status, uid)
+	tx, err := m.db.session.Begin()
 	if err != nil {
+		m.Error("开启事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("开启事务失败！"))
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			tx.RollbackUnlessCommitted()
+			panic(err)
+		}
+	}()
+	err = m.userDB.UpdateUsersWithFieldTx("status", status, uid, tx)
+	if err != nil {
+		tx.Rollback()
 		m.Error("修改用户状态错误", zap.Error(err))
 		c.ResponseError(errors.New("修改用户状态错误"))
 		return
 	}
+	var eventID int64
+	if userStatus == int(common.UserDisable) {
+		// 发送用户封禁事件（处理用户创建的群的群主继承等）
+		eventID, err = m.ctx.EventBegin(&wkevent.Data{
+			Event: event.EventUserDisable,
+			Type:  wkevent.Message,
+			Data: map[string]interface{}{
+				"uid": uid,
+			},
+		}, tx)
+		if err != nil {
+			tx.Rollback()
+			m.Error("开启事件失败！", zap.Error(err))
+			c.ResponseError(errors.New("开启事件失败！"))
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		tx.RollbackUnlessCommitted()
+		m.Error("提交事务失败！", zap.Error(err))
+		c.ResponseError(errors.New("提交事务失败！"))
+		return
+	}
+	if eventID > 0 {
+		m.ctx.EventCommit(eventID)
+	}
 
 	ban := 0
 	if userStatus == int(common.UserDisable) {
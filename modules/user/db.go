@@ -149,6 +149,12 @@ func (d *DB) UpdateUsersWithField(field string, value string, uid string) error
 	return err
 }
 
+// UpdateUsersWithFieldTx 修改用户某个字段（带事务）
+func (d *DB) UpdateUsersWithFieldTx(field string, value string, uid string, tx *dbr.Tx) error {
+	_, err := tx.Update("user").Set(field, value).Where("uid=?", uid).Exec()
+	return err
+}
+
 // AddOrRemoveBlacklist 添加黑名单
 func (d *DB) AddOrRemoveBlacklistTx(uid string, touid string, blacklist int, version int64, tx *dbr.Tx) error {
 	_, err := tx.Update("user_setting").Set("blacklist", blacklist).Set("version", version).Set("updated_at", dbr.Expr("Now()")).Where("uid=? and to_uid=?", uid, touid).Exec()
@@ -214,8 +220,8 @@ func (d *DB) updatePassword(password string, uid string) error {
 }
 
 // 注销账户
-func (d *DB) destroyAccount(uid, username, phone string) error {
-	_, err := d.session.Update("user").SetMap(map[string]interface{}{
+func (d *DB) destroyAccountTx(uid, username, phone string, tx *dbr.Tx) error {
+	_, err := tx.Update("user").SetMap(map[string]interface{}{
 		"phone":      phone,
 		"username":   username,
 		"is_destroy": 1,
@@ -347,6 +353,7 @@ type Model struct {
 	GithubUID         string // github uid
 	Web3PublicKey     string // web3公钥
 	MsgExpireSecond   int64  // 消息过期时长
+	AllowShareCard    int    // 是否允许他人分享自己的名片0.否1.是
 	db.BaseModel
 }
 
@@ -266,6 +266,7 @@ func (d *friendDB) updateApply(apply *FriendApplyModel) error {
 func (d *friendDB) updateApplyTx(apply *FriendApplyModel, tx *dbr.Tx) error {
 	_, err := tx.Update("friend_apply_record").SetMap(map[string]interface{}{
 		"status": apply.Status,
+		"source": apply.Source,
 	}).Where("id=?", apply.Id).Exec()
 	return err
 }
@@ -317,6 +318,7 @@ type FriendApplyModel struct {
 	ToUID  string
 	Remark string
 	Token  string
-	Status int // 状态 0.未处理 1.通过 2.拒绝
+	Source string // 申请来源
+	Status int    // 状态 0.未处理 1.通过 2.拒绝
 	db.BaseModel
 }
@@ -78,6 +78,8 @@ type IService interface {
 	UpdateUserMsgExpireSecond(uid string, msgExpireSecond int64) error
 	// 搜索好友
 	SearchFriendsWithKeyword(uid string, keyword string) ([]*FriendResp, error)
+	// 是否允许分享用户名片
+	AllowShareCard(uid string) (bool, error)
 }
 
 // Service Service
@@ -396,14 +398,15 @@ func (s *Service) AddUser(user *AddUserReq) error {
 		username = fmt.Sprintf("%s%s", user.Zone, user.Phone)
 	}
 	userM := &Model{
-		Name:     user.Name,
-		UID:      uid,
-		Zone:     user.Zone,
-		Phone:    user.Phone,
-		Username: username,
-		Email:    user.Email,
-		ShortNo:  util.Ten2Hex(time.Now().UnixNano()),
-		Status:   1,
+		Name:           user.Name,
+		UID:            uid,
+		Zone:           user.Zone,
+		Phone:          user.Phone,
+		Username:       username,
+		Email:          user.Email,
+		ShortNo:        util.Ten2Hex(time.Now().UnixNano()),
+		Status:         1,
+		AllowShareCard: 1,
 	}
 	if user.Password != "" {
 		userM.Password = util.MD5(util.MD5(user.Password))
@@ -484,6 +487,18 @@ func (s *Service) GetUser(uid string) (*Resp, error) {
 	return newResp(userM), nil
 }
 
+// AllowShareCard 是否允许分享用户名片
+func (s *Service) AllowShareCard(uid string) (bool, error) {
+	userM, err := s.db.QueryByUID(uid)
+	if err != nil {
+		return false, err
+	}
+	if userM == nil || userM.IsDestroy == 1 {
+		return false, ErrorUserNotExist
+	}
+	return userM.AllowShareCard == 1, nil
+}
+
 // GetUserWithUsername 获取用户
 func (s *Service) GetUserWithUsername(username string) (*Resp, error) {
 	userM, err := s.db.QueryByUsername(username)
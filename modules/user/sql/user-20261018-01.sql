@@ -0,0 +1,6 @@
+
+-- +migrate Up
+
+ALTER TABLE `user` ADD COLUMN allow_share_card smallint NOT NULL DEFAULT 1 COMMENT '是否允许他人分享自己的名片0.否1.是';
+
+ALTER TABLE `friend_apply_record` ADD COLUMN source VARCHAR(100) NOT NULL DEFAULT '' COMMENT '好友申请来源';
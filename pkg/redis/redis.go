@@ -403,3 +403,34 @@ func (rc *Conn) BRPoplpush(source string, destination string, timeout time.Durat
 func (rc *Conn) LPUSH(key string, values ...interface{}) (int64, error) {
 	return rc.client.LPush(key, values...).Result()
 }
+
+// SetNX 键不存在时设置值和过期时间（SET key value NX PX expire），返回是否设置成功
+func (rc *Conn) SetNX(key string, value interface{}, expire time.Duration) (bool, error) {
+	return rc.client.SetNX(key, value, expire).Result()
+}
+
+// 值与owner一致时续期（比较和续期在redis内原子执行）
+var renewIfOwnerScript = rd.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// HoldLease 获取或续期租约，返回当前是否持有租约
+// 租约不存在时通过SET NX PX抢占，已持有时通过lua脚本比较持有者后续期，避免多个实例同时持有
+func (rc *Conn) HoldLease(key string, owner string, ttl time.Duration) (bool, error) {
+	ok, err := rc.SetNX(key, owner, ttl)
+	if err != nil {
+		return false, err
+	}
+	if ok {
+		return true, nil
+	}
+	result, err := renewIfOwnerScript.Run(rc.client, []string{key}, owner, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return false, err
+	}
+	return result == 1, nil
+}
+